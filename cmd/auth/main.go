@@ -1,74 +1,49 @@
 package main
 
 import (
-	"database/sql"
-	"encoding/json"
+	"context"
+	"flag"
+	"net"
 	"net/http"
 	"os"
-	"strconv"
-	"strings"
+	"os/signal"
+	"syscall"
 	"time"
 
 	_ "github.com/mos1rain/forum_go/docs"
 	"github.com/mos1rain/forum_go/internal/auth/grpc"
 	"github.com/mos1rain/forum_go/internal/auth/handler"
+	"github.com/mos1rain/forum_go/internal/auth/middleware"
+	"github.com/mos1rain/forum_go/internal/auth/models"
 	"github.com/mos1rain/forum_go/internal/auth/repository"
 	"github.com/mos1rain/forum_go/internal/auth/service"
+	"github.com/mos1rain/forum_go/internal/config"
+	"github.com/mos1rain/forum_go/migrations"
+	"github.com/mos1rain/forum_go/pkg/database"
+	"github.com/mos1rain/forum_go/pkg/health"
 	"github.com/mos1rain/forum_go/pkg/jwt"
+	"github.com/mos1rain/forum_go/pkg/logging"
+	"github.com/mos1rain/forum_go/pkg/metrics"
+	"github.com/mos1rain/forum_go/pkg/migrate"
+	"github.com/mos1rain/forum_go/pkg/ratelimit"
+	"github.com/mos1rain/forum_go/pkg/rbac"
 	"github.com/rs/zerolog"
 	_ "github.com/swaggo/files"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"golang.org/x/crypto/bcrypt"
-	_ "modernc.org/sqlite"
+	googlegrpc "google.golang.org/grpc"
 )
 
-type Category struct {
-	ID          int64  `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	CreatorID   int64  `json:"creatorId"`
-}
-
-type Post struct {
-	ID         int64     `json:"id"`
-	Title      string    `json:"title"`
-	Content    string    `json:"content"`
-	CategoryID int64     `json:"categoryId"`
-	AuthorID   int64     `json:"authorId"`
-	CreatedAt  time.Time `json:"createdAt"`
-	UpdatedAt  time.Time `json:"updatedAt"`
-}
-
-var categories []Category
-var posts []Post
-
-// Обёртка для CORS
-func withCORS(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		w.Header().Set("Access-Control-Max-Age", "3600")
-
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		next(w, r)
-	}
-}
-
-func checkAdminExists(db *sql.DB) (bool, error) {
+func checkAdminExists(db *database.DB) (bool, error) {
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM users WHERE role = 'admin'").Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM users WHERE role = ?", models.RoleAdmin).Scan(&count)
 	if err != nil {
 		return false, err
 	}
 	return count > 0, nil
 }
 
-func createDefaultAdmin(db *sql.DB, logger zerolog.Logger) error {
+func createDefaultAdmin(db *database.DB, logger zerolog.Logger) error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
 	if err != nil {
 		return err
@@ -77,7 +52,7 @@ func createDefaultAdmin(db *sql.DB, logger zerolog.Logger) error {
 	_, err = db.Exec(`
 		INSERT INTO users (username, email, password_hash, role, created_at, updated_at)
 		VALUES (?, ?, ?, ?, ?, ?)
-	`, "admin", "admin@forum.com", string(hashedPassword), "admin", time.Now(), time.Now())
+	`, "admin", "admin@forum.com", string(hashedPassword), models.RoleAdmin, time.Now(), time.Now())
 
 	if err != nil {
 		return err
@@ -93,37 +68,34 @@ func createDefaultAdmin(db *sql.DB, logger zerolog.Logger) error {
 
 func main() {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
-	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger()
+	logger := logging.New("auth", logging.FormatConsole, "info")
 
-	// Подключение к SQLite с правильными настройками
-	db, err := sql.Open("sqlite", "/Users/Sieger/Desktop/forum_go/forum.db?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)")
+	// Настройки берутся из файла, переменных окружения и флагов
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
 	if err != nil {
-		logger.Fatal().Err(err).Msg("Failed to connect to database")
+		logger.Fatal().Err(err).Msg("Invalid configuration")
 	}
-	defer db.Close()
+	logger = logging.New("auth", cfg.Log.Format, cfg.Log.Level)
 
-	// Проверка соединения
-	if err := db.Ping(); err != nil {
-		logger.Fatal().Err(err).Msg("Failed to ping database")
+	db, err := database.Open(cfg.Database.Connection())
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to connect to database")
 	}
+	defer db.Close()
 
-	logger.Info().Msg("Successfully connected to database")
+	logger.Info().Str("driver", string(db.Dialect)).Msg("Successfully connected to database")
 
-	// Инициализация таблиц
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			username TEXT UNIQUE NOT NULL,
-			email TEXT UNIQUE NOT NULL,
-			password_hash TEXT NOT NULL,
-			role TEXT NOT NULL DEFAULT 'user',
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
+	// Схема базы создаётся и обновляется миграциями из migrations/
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to load database migrations")
+	}
+	migrator.EnableBaseline(migrations.Legacy)
+	applied, err := migrator.Up()
 	if err != nil {
-		logger.Fatal().Err(err).Msg("Failed to initialize users table")
+		logger.Fatal().Err(err).Msg("Failed to apply database migrations")
 	}
+	logger.Info().Int("applied", len(applied)).Msg("Database migrations applied")
 
 	// Проверяем наличие администратора и создаем его, если нет
 	adminExists, err := checkAdminExists(db)
@@ -139,244 +111,159 @@ func main() {
 
 	logger.Info().Msg("Database tables initialized successfully")
 
-	// Инициализируем JWT менеджер
-	tokenManager := jwt.NewTokenManager(jwt.SecretKey)
-	tokenTTL := 24 * time.Hour
+	// Останавливаемся по SIGINT/SIGTERM, дав текущим запросам завершиться
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Инициализируем слои приложения
-	userRepo := repository.NewUserRepository(db)
-	userService := service.NewUserService(userRepo, tokenManager, tokenTTL)
-	userHandler := handler.NewUserHandler(userService)
+	// Инициализируем JWT менеджер: ключи подписи хранятся на диске и периодически ротируются
+	accessTTL := cfg.JWT.AccessTTL
+	refreshTTL := cfg.JWT.RefreshTTL
+	keyRotation := cfg.JWT.KeyRotation
 
-	// Запуск gRPC-сервера в отдельной горутине
-	go grpc.RunGRPCServer(userRepo, tokenManager, ":50052")
-
-	// Регистрируем маршруты с CORS
-	mux := http.NewServeMux()
-	mux.HandleFunc("/api/auth/register", withCORS(userHandler.Register))
-	mux.HandleFunc("/api/auth/login", withCORS(userHandler.Login))
-	mux.HandleFunc("/api/categories", withCORS(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-
-		switch r.Method {
-		case http.MethodGet:
-			json.NewEncoder(w).Encode(categories)
-		case http.MethodPost:
-			// Получаем ID пользователя из токена
-			token := r.Header.Get("Authorization")
-			if token == "" {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-			token = strings.TrimPrefix(token, "Bearer ")
-
-			// Декодируем токен и получаем ID пользователя
-			claims, err := tokenManager.Parse(token)
-			if err != nil {
-				http.Error(w, "Invalid token", http.StatusUnauthorized)
-				return
-			}
-
-			logger.Info().
-				Int("user_id", claims.UserID).
-				Str("username", claims.Username).
-				Msg("Creating new category")
-
-			var category Category
-			if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+	keyStore := jwt.NewFileKeyStore(cfg.JWT.KeysDir, cfg.JWT.Algorithm)
+	keyRing, err := keyStore.Load()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to load signing keys")
+	}
+	if err := keyStore.Rotate(keyRing, keyRotation, accessTTL); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to rotate signing keys")
+	}
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
 				return
-			}
-
-			// Устанавливаем ID создателя категории
-			category.ID = int64(len(categories) + 1)
-			category.CreatorID = int64(claims.UserID)
-
-			logger.Info().
-				Int64("category_id", category.ID).
-				Int64("creator_id", category.CreatorID).
-				Str("name", category.Name).
-				Msg("New category created")
-
-			categories = append(categories, category)
-			json.NewEncoder(w).Encode(category)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	}))
-	mux.HandleFunc("/api/categories/", withCORS(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-
-		// Извлекаем ID категории из URL
-		parts := strings.Split(r.URL.Path, "/")
-		if len(parts) < 4 {
-			http.Error(w, "Invalid category ID", http.StatusBadRequest)
-			return
-		}
-
-		categoryID, err := strconv.ParseInt(parts[3], 10, 64)
-		if err != nil {
-			http.Error(w, "Invalid category ID", http.StatusBadRequest)
-			return
-		}
-
-		// Проверяем, существует ли категория
-		var categoryExists bool
-		for _, cat := range categories {
-			if cat.ID == categoryID {
-				categoryExists = true
-				break
-			}
-		}
-
-		if !categoryExists {
-			http.Error(w, "Category not found", http.StatusNotFound)
-			return
-		}
-
-		// Если запрос к /api/categories/{id}/posts
-		if len(parts) > 4 && parts[4] == "posts" {
-			// Фильтруем посты по категории
-			var categoryPosts []Post
-			for _, post := range posts {
-				if post.CategoryID == categoryID {
-					categoryPosts = append(categoryPosts, post)
+			case <-ticker.C:
+				if err := keyStore.Rotate(keyRing, keyRotation, accessTTL); err != nil {
+					logger.Error().Err(err).Msg("Failed to rotate signing keys")
 				}
 			}
-			json.NewEncoder(w).Encode(categoryPosts)
-			return
 		}
+	}()
+	tokenManager := jwt.NewTokenManager(keyRing)
 
-		http.Error(w, "Not found", http.StatusNotFound)
-	}))
-	mux.HandleFunc("/api/posts", withCORS(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-
-		switch r.Method {
-		case http.MethodGet:
-			json.NewEncoder(w).Encode(posts)
-		case http.MethodPost:
-			var post Post
-			if err := json.NewDecoder(r.Body).Decode(&post); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+	// Инициализируем слои приложения
+	userRepo := repository.NewUserRepository(db)
+	tokenRepo := repository.NewTokenRepository(db)
+	attemptRepo := repository.NewLoginAttemptRepository(db)
+	userService := service.NewUserService(userRepo, tokenRepo, tokenManager, accessTTL, refreshTTL)
+	userService.EnableLockout(attemptRepo, service.LockoutPolicy{
+		Threshold:    cfg.Lockout.Threshold,
+		BaseDuration: cfg.Lockout.BaseDuration,
+		MaxDuration:  cfg.Lockout.MaxDuration,
+		IPThreshold:  cfg.Lockout.IPThreshold,
+		IPWindow:     cfg.Lockout.IPWindow,
+	})
+	// Забаненные и отстранённые не могут войти и обновить токены
+	sanctionService := service.NewSanctionService(repository.NewSanctionRepository(db), userRepo)
+	userService.EnableSanctions(sanctionService)
+	// Журнал действий модераторов всех сервисов хранится здесь; смена ролей,
+	// разблокировка и ограничения записываются в него до выполнения
+	auditRepo := repository.NewAuditRepository(db)
+	userService.EnableAudit(auditRepo)
+	sanctionService.EnableAudit(auditRepo)
+	userHandler := handler.NewUserHandler(userService, cfg.RateLimit.TrustForwardedFor)
+	sanctionHandler := handler.NewSanctionHandler(sanctionService)
+	auditHandler := handler.NewAuditHandler(service.NewAuditService(auditRepo))
+	authenticator := middleware.NewAuthenticator(tokenManager, tokenRepo)
+
+	// Журнал входов хранится AttemptRetention, но не меньше окна блокировки по IP
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
 				return
+			case <-ticker.C:
+				deleted, err := attemptRepo.DeleteBefore(time.Now().Add(-max(cfg.Lockout.AttemptRetention, cfg.Lockout.IPWindow)))
+				if err != nil {
+					logger.Error().Err(err).Msg("Failed to delete old login attempts")
+					continue
+				}
+				logger.Debug().Int64("deleted", deleted).Msg("Old login attempts deleted")
 			}
-			post.ID = int64(len(posts) + 1)
-			post.CreatedAt = time.Now()
-			post.UpdatedAt = time.Now()
-			posts = append(posts, post)
-			json.NewEncoder(w).Encode(post)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	}))
-	mux.HandleFunc("/api/forum/delete_category", withCORS(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		// Получаем ID пользователя из токена
-		token := r.Header.Get("Authorization")
-		if token == "" {
-			logger.Error().Msg("No authorization token provided")
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-		token = strings.TrimPrefix(token, "Bearer ")
-
-		logger.Info().
-			Str("token", token).
-			Msg("Received token for category deletion")
-
-		// Декодируем токен и получаем ID пользователя
-		claims, err := tokenManager.Parse(token)
-		if err != nil {
-			logger.Error().
-				Err(err).
-				Msg("Failed to parse token")
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
-
-		logger.Info().
-			Int("current_user_id", claims.UserID).
-			Str("username", claims.Username).
-			Str("token", token).
-			Msg("Successfully parsed token")
-
-		// Выводим информацию о всех существующих категориях
-		logger.Info().Msg("Current categories:")
-		for _, cat := range categories {
-			logger.Info().
-				Int64("category_id", cat.ID).
-				Int64("creator_id", cat.CreatorID).
-				Str("name", cat.Name).
-				Msg("Category info")
 		}
+	}()
 
-		idStr := r.URL.Query().Get("id")
-		id, err := strconv.ParseInt(idStr, 10, 64)
-		if err != nil {
-			logger.Error().
-				Err(err).
-				Str("id", idStr).
-				Msg("Invalid category ID format")
-			http.Error(w, "Invalid category ID", http.StatusBadRequest)
-			return
-		}
-
-		logger.Info().
-			Int64("requested_category_id", id).
-			Int("current_user_id", claims.UserID).
-			Msg("Attempting to delete category")
+	checker := health.NewChecker(2 * time.Second)
+	checker.Add("database", db.PingContext)
 
-		// Находим категорию и проверяем права доступа
-		for i, cat := range categories {
-			if cat.ID == id {
-				logger.Info().
-					Int64("category_id", cat.ID).
-					Int64("category_creator_id", cat.CreatorID).
-					Int("current_user_id", claims.UserID).
-					Str("category_name", cat.Name).
-					Msg("Found category, checking permissions")
+	registry := metrics.NewRegistry()
+	metrics.RegisterDB(registry, db.DB, "auth")
+	httpMetrics := metrics.NewHTTP(registry)
+	grpcMetrics := metrics.NewGRPCServer(registry)
 
-				// Проверяем, является ли пользователь создателем категории
-				if cat.CreatorID != int64(claims.UserID) {
-					logger.Warn().
-						Int64("category_id", cat.ID).
-						Int64("category_creator_id", cat.CreatorID).
-						Int("current_user_id", claims.UserID).
-						Str("category_name", cat.Name).
-						Msg("Permission denied: user is not the category creator")
-					http.Error(w, "You don't have permission to delete this category", http.StatusForbidden)
-					return
-				}
+	// Регистрируем маршруты с CORS
+	withCORS := cfg.CORS.Middleware("GET, POST, PUT, DELETE, OPTIONS")
+	mux := http.NewServeMux()
+	// Ограничиваем подбор паролей и refresh-токенов и массовую регистрацию по IP клиента
+	clientIP := ratelimit.ByIP(cfg.RateLimit.TrustForwardedFor)
+	registerLimiter := ratelimit.New(cfg.RateLimit.Register)
+	loginLimiter := ratelimit.New(cfg.RateLimit.Login)
+	refreshLimiter := ratelimit.New(cfg.RateLimit.Refresh)
+	logoutLimiter := ratelimit.New(cfg.RateLimit.Refresh)
+	mux.HandleFunc("/api/auth/register", withCORS(registerLimiter.Wrap(clientIP, userHandler.Register)))
+	mux.HandleFunc("/api/auth/login", withCORS(loginLimiter.Wrap(clientIP, userHandler.Login)))
+	mux.HandleFunc("/api/auth/refresh", withCORS(refreshLimiter.Wrap(clientIP, userHandler.Refresh)))
+	mux.HandleFunc("/api/auth/logout", withCORS(logoutLimiter.Wrap(clientIP, userHandler.Logout)))
+	mux.HandleFunc("/api/auth/sign-ins", withCORS(authenticator.Authenticate(userHandler.SignIns)))
+	mux.HandleFunc("/api/auth/users/{id}/unlock", withCORS(authenticator.RequirePermission(rbac.ManageUsers, userHandler.Unlock)))
+	mux.HandleFunc("/api/auth/users/{id}/role", withCORS(authenticator.RequirePermission(rbac.ManageUsers, userHandler.SetRole)))
+	mux.HandleFunc("/api/auth/users/{id}/role-changes", withCORS(authenticator.RequirePermission(rbac.ManageUsers, userHandler.RoleChanges)))
+	mux.HandleFunc("/api/auth/users/{id}/sanctions", withCORS(authenticator.RequirePermission(rbac.BanUser, sanctionHandler.UserSanctions)))
+	mux.HandleFunc("/api/auth/sanctions/{id}", withCORS(authenticator.RequirePermission(rbac.BanUser, sanctionHandler.Revoke)))
+	mux.HandleFunc("/api/auth/audit", withCORS(authenticator.RequirePermission(rbac.ViewAuditLog, auditHandler.List)))
+	mux.HandleFunc("/api/auth/audit/export", withCORS(authenticator.RequirePermission(rbac.ViewAuditLog, auditHandler.Export)))
+	mux.HandleFunc("/.well-known/jwks.json", withCORS(handler.NewJWKSHandler(keyRing).ServeHTTP))
+	mux.HandleFunc("/swagger/", httpSwagger.WrapHandler)
+	checker.Register(mux)
+	mux.Handle("/metrics", metrics.Handler(registry))
 
-				categories = append(categories[:i], categories[i+1:]...)
-				logger.Info().
-					Int64("category_id", cat.ID).
-					Int64("creator_id", cat.CreatorID).
-					Str("category_name", cat.Name).
-					Msg("Category successfully deleted")
-				w.WriteHeader(http.StatusOK)
-				json.NewEncoder(w).Encode(map[string]string{"message": "Category deleted successfully"})
-				return
-			}
-		}
+	lis, err := net.Listen("tcp", cfg.Auth.GRPCAddr)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to listen for gRPC")
+	}
+	grpcServer := grpc.NewServer(userRepo, tokenRepo, tokenManager, userService, sanctionService, auditRepo,
+		googlegrpc.ChainUnaryInterceptor(
+			logging.UnaryServerInterceptor(logger),
+			grpcMetrics.UnaryServerInterceptor(),
+		))
+	srv := &http.Server{Addr: cfg.Auth.HTTPAddr, Handler: logging.Middleware(logger)(httpMetrics.Middleware(mux))}
+
+	serveErr := make(chan error, 2)
+	go func() {
+		logger.Info().Str("addr", cfg.Auth.GRPCAddr).Msg("Starting auth gRPC server")
+		serveErr <- grpcServer.Serve(lis)
+	}()
+	go func() {
+		logger.Info().Str("addr", cfg.Auth.HTTPAddr).Msg("Starting auth server")
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	failed := false
+	select {
+	case <-ctx.Done():
+		logger.Info().Msg("Shutting down auth server")
+	case err := <-serveErr:
+		logger.Error().Err(err).Msg("Server stopped unexpectedly")
+		failed = true
+	}
+	stop()
 
-		logger.Warn().
-			Int64("requested_category_id", id).
-			Msg("Category not found")
-		http.Error(w, "Category not found", http.StatusNotFound)
-	}))
-	mux.HandleFunc("/swagger/", httpSwagger.WrapHandler)
+	checker.Shutdown()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error().Err(err).Msg("Failed to drain HTTP requests")
+	}
+	grpcServer.Shutdown(shutdownCtx)
+	logger.Info().Msg("Auth server stopped")
 
-	// Запускаем HTTP сервер
-	logger.Info().Str("port", "3001").Msg("Starting auth server on :3001")
-	if err := http.ListenAndServe(":3001", mux); err != nil {
-		logger.Fatal().Err(err).Msg("Failed to start server")
+	if failed {
+		db.Close()
+		os.Exit(1)
 	}
 }
@@ -1,77 +1,153 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
 	"errors"
+	"flag"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/websocket"
 	_ "github.com/mos1rain/forum_go/docs"
+	"github.com/mos1rain/forum_go/internal/chat/grpc"
 	"github.com/mos1rain/forum_go/internal/chat/service"
+	"github.com/mos1rain/forum_go/internal/config"
+	"github.com/mos1rain/forum_go/migrations"
+	"github.com/mos1rain/forum_go/pkg/audit"
+	"github.com/mos1rain/forum_go/pkg/database"
+	"github.com/mos1rain/forum_go/pkg/health"
+	"github.com/mos1rain/forum_go/pkg/jwt"
+	"github.com/mos1rain/forum_go/pkg/logging"
+	"github.com/mos1rain/forum_go/pkg/metrics"
+	"github.com/mos1rain/forum_go/pkg/migrate"
+	"github.com/mos1rain/forum_go/pkg/ratelimit"
+	"github.com/mos1rain/forum_go/pkg/rbac"
+	"github.com/mos1rain/forum_go/pkg/sanction"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 	_ "github.com/swaggo/files"
 	httpSwagger "github.com/swaggo/http-swagger"
-	_ "modernc.org/sqlite"
+	googlegrpc "google.golang.org/grpc"
 )
 
 var (
-	clients   = make(map[*websocket.Conn]bool)
-	broadcast = make(chan service.Message)
+	clients = make(map[*websocket.Conn]bool)
+	// Буфер сглаживает всплески; его заполненность видна в метрике chat_broadcast_queue_length
+	broadcast = make(chan service.Message, 256)
 	mutex     sync.Mutex
-	upgrader  = websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool { return true },
-	}
-	logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger()
+	// closing выставляется при остановке: новые WebSocket-клиенты сразу отключаются
+	closing  bool
+	upgrader = websocket.Upgrader{}
+	logger   = logging.New("chat", logging.FormatConsole, "info")
+	// Токены проверяются публичными ключами auth-сервиса, адрес задаётся в main
+	tokenManager *jwt.TokenManager
+	// Баны и запреты писать в чат выдаёт auth-сервис
+	authClient *grpc.AuthGRPCClient
+
+	errUnauthenticated = errors.New("missing or invalid token")
+	errAuthUnavailable = errors.New("auth service unavailable")
 )
 
 func main() {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 
-	// Подключение к SQLite
-	db, err := sql.Open("sqlite", "./forum.db")
+	// Настройки берутся из файла, переменных окружения и флагов
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Invalid configuration")
+	}
+	logger = logging.New("chat", cfg.Log.Format, cfg.Log.Level)
+	tokenManager = jwt.NewTokenVerifier(jwt.NewRemoteKeySet(cfg.JWT.JWKSURL))
+	// Браузер всегда присылает Origin; клиенты без него (CLI, тесты) допускаются
+	upgrader.CheckOrigin = func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		return origin == "" || cfg.CORS.Allowed(origin)
+	}
+
+	db, err := database.Open(cfg.Database.Connection())
 	if err != nil {
 		logger.Fatal().Err(err).Msg("Failed to connect to database")
 	}
 	defer db.Close()
 
-	// Проверка соединения
-	if err := db.Ping(); err != nil {
-		logger.Fatal().Err(err).Msg("Failed to ping database")
+	// Схема базы создаётся и обновляется миграциями из migrations/
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to load database migrations")
 	}
-
-	// Инициализация таблицы chat_messages
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS chat_messages (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			username TEXT NOT NULL,
-			content TEXT NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-		)
-	`)
+	migrator.EnableBaseline(migrations.Legacy)
+	applied, err := migrator.Up()
 	if err != nil {
-		logger.Fatal().Err(err).Msg("Failed to initialize chat_messages table")
+		logger.Fatal().Err(err).Msg("Failed to apply database migrations")
 	}
+	logger.Info().Int("applied", len(applied)).Msg("Database migrations applied")
 
 	chatService := service.NewChatService(db)
 
-	go handleMessages(chatService)
-	go cleanOldMessages(chatService)
+	// Фоновые задачи живут до остановки HTTP-сервера
+	workers, stopWorkers := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		handleMessages(workers)
+	}()
+	go func() {
+		defer wg.Done()
+		cleanOldMessages(workers, chatService, cfg.Chat.Retention, cfg.Chat.CleanupInterval)
+	}()
 
-	http.HandleFunc("/ws", withCORS(handleWS(chatService)))
+	registry := metrics.NewRegistry()
+	authClient, err = grpc.NewAuthGRPCClient(cfg.Chat.AuthGRPCAddr,
+		googlegrpc.WithChainUnaryInterceptor(
+			logging.UnaryClientInterceptor(),
+			metrics.NewGRPCClient(registry).UnaryClientInterceptor(),
+		))
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to connect to auth service")
+	}
+	defer authClient.Close()
+
+	checker := health.NewChecker(2 * time.Second)
+	checker.Add("database", db.PingContext)
+	checker.Add("auth", authClient.Ping)
+	checker.Register(http.DefaultServeMux)
+
+	metrics.RegisterDB(registry, db.DB, "chat")
+	registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "chat_websocket_clients",
+			Help: "Number of connected WebSocket clients.",
+		}, func() float64 {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return float64(len(clients))
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "chat_broadcast_queue_length",
+			Help: "Number of messages waiting to be sent to WebSocket clients.",
+		}, func() float64 { return float64(len(broadcast)) }),
+	)
+	httpMetrics := metrics.NewHTTP(registry)
+	http.Handle("/metrics", metrics.Handler(registry))
+
+	// Сообщения через HTTP ограничены по пользователю, WebSocket — по соединению
+	messageLimiter := ratelimit.New(cfg.RateLimit.ChatMessage)
+
+	withCORS := cfg.CORS.Middleware("GET, POST, DELETE, OPTIONS")
+	http.HandleFunc("/ws", withCORS(handleWS(workers, chatService, cfg.RateLimit)))
 
 	http.HandleFunc("/history", withCORS(func(w http.ResponseWriter, r *http.Request) {
 		history, err := chatService.GetHistory(50)
 		if err != nil {
-			logger.Error().Err(err).Msg("Failed to get chat history")
+			zerolog.Ctx(r.Context()).Error().Err(err).Msg("Failed to get chat history")
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -91,66 +167,176 @@ func main() {
 
 	http.HandleFunc("/messages", withCORS(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
+			claims, err := authenticate(r)
+			if err != nil {
+				writeAuthError(w, err)
+				return
+			}
+			if ok, retry := messageLimiter.Allow("user:" + strconv.Itoa(claims.UserID)); !ok {
+				zerolog.Ctx(r.Context()).Warn().Dur("retry_after", retry).Msg("Chat message rate limit exceeded")
+				ratelimit.TooManyRequests(w, retry)
+				return
+			}
+			restricted, err := authClient.ActiveSanctions(r.Context(), claims.UserID)
+			if err != nil {
+				zerolog.Ctx(r.Context()).Error().Err(err).Msg("Failed to check user sanctions")
+				http.Error(w, "auth service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			if active := sanction.Find(restricted, sanction.Chat); active != nil {
+				sanction.WriteHTTP(w, *active)
+				return
+			}
 			var m service.Message
 			if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
 				w.WriteHeader(http.StatusBadRequest)
 				return
 			}
-			if m.Content == "" || m.Username == "" || m.UserID == 0 {
+			if m.Content == "" {
 				w.WriteHeader(http.StatusBadRequest)
 				return
 			}
-			msg, err := chatService.AddMessage(m.UserID, m.Username, m.Content)
+			// Автор сообщения берётся из токена, а не из тела запроса
+			msg, err := chatService.AddMessage(claims.UserID, claims.Username, m.Content)
 			if err != nil {
-				logger.Error().Err(err).Msg("Failed to add message")
+				zerolog.Ctx(r.Context()).Error().Err(err).Msg("Failed to add message")
 				w.WriteHeader(http.StatusInternalServerError)
 				return
 			}
-			broadcast <- msg
+			publish(workers, msg)
 			w.WriteHeader(http.StatusCreated)
 			return
 		}
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}))
 
+	// Сообщение по id; forum проверяет им жалобы на сообщения чата
+	http.HandleFunc("/messages/{id}", withCORS(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		msg, err := chatService.GetMessage(id)
+		if err != nil {
+			zerolog.Ctx(r.Context()).Error().Err(err).Msg("Failed to get message")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if msg == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(msg)
+	}))
+
 	http.HandleFunc("/delete_message", withCORS(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodDelete {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		token := r.Header.Get("Authorization")
-		if !strings.HasPrefix(token, "Bearer ") {
-			w.WriteHeader(http.StatusUnauthorized)
+		claims, err := authenticate(r)
+		if err != nil {
+			writeAuthError(w, err)
 			return
 		}
-		token = strings.TrimPrefix(token, "Bearer ")
-		claims, err := parseJWT(token)
-		if err != nil || claims["role"] != "admin" {
+		if !rbac.Default.Can(claims.Role, rbac.DeleteChatMessage) {
 			w.WriteHeader(http.StatusForbidden)
 			return
 		}
 		idStr := r.URL.Query().Get("id")
 		id, err := strconv.Atoi(idStr)
-		if err != nil {
+		if err != nil || id <= 0 {
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
+		msg, err := chatService.GetMessage(id)
+		if err != nil {
+			zerolog.Ctx(r.Context()).Error().Err(err).Msg("Failed to get message")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if msg == nil {
+			// Удалять нечего, и в журнал ничего не пишется
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		// Удаление попадает в журнал auth-сервиса до самого удаления;
+		// reason — необязательная причина
+		err = authClient.RecordAudit(r.Context(), requestToken(r), audit.Entry{
+			Service:    audit.ServiceChat,
+			ActorID:    int64(claims.UserID),
+			Action:     audit.ActionDeleteChatMessage,
+			TargetType: audit.TargetChatMessage,
+			TargetID:   int64(msg.ID),
+			Snapshot:   audit.Snapshot(msg),
+			Reason:     r.URL.Query().Get("reason"),
+		})
+		if err != nil {
+			zerolog.Ctx(r.Context()).Error().Err(err).Msg("Failed to record audit entry")
+			http.Error(w, "audit log unavailable", http.StatusServiceUnavailable)
+			return
+		}
 		if err := chatService.DeleteMessage(id); err != nil {
-			logger.Error().Err(err).Msg("Failed to delete message")
+			zerolog.Ctx(r.Context()).Error().Err(err).Msg("Failed to delete message")
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		zerolog.Ctx(r.Context()).Info().Int("message_id", id).Msg("Chat message deleted")
 		w.WriteHeader(http.StatusNoContent)
 	}))
 
 	http.HandleFunc("/swagger/", httpSwagger.WrapHandler)
 
-	logger.Info().Msg("Chat service started on :3003")
-	logger.Fatal().Err(http.ListenAndServe(":3003", nil)).Msg("chat server crashed")
+	// Останавливаемся по SIGINT/SIGTERM, дав текущим запросам завершиться
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Addr: cfg.Chat.HTTPAddr, Handler: logging.Middleware(logger)(httpMetrics.Middleware(http.DefaultServeMux))}
+	srv.RegisterOnShutdown(closeClients)
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info().Str("addr", cfg.Chat.HTTPAddr).Msg("Chat service started")
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case <-ctx.Done():
+		logger.Info().Msg("Shutting down chat service")
+	case err := <-serveErr:
+		logger.Fatal().Err(err).Msg("chat server crashed")
+	}
+	stop()
+
+	checker.Shutdown()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error().Err(err).Msg("Failed to drain HTTP requests")
+	}
+	stopWorkers()
+	wg.Wait()
+	logger.Info().Msg("Chat service stopped")
 }
 
-func handleWS(chatService *service.ChatService) http.HandlerFunc {
+func handleWS(ctx context.Context, chatService *service.ChatService, limits config.RateLimitConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Логгер запроса: все записи соединения содержат request_id и user_id
+		logger := zerolog.Ctx(r.Context())
+
+		// Браузер не может передать заголовок при открытии WebSocket,
+		// поэтому токен допускается в query-параметре token
+		claims, err := authenticate(r)
+		if err != nil {
+			writeAuthError(w, err)
+			return
+		}
+
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
 			logger.Error().Err(err).Msg("WebSocket upgrade error")
@@ -158,10 +344,21 @@ func handleWS(chatService *service.ChatService) http.HandlerFunc {
 		}
 		defer func() {
 			conn.Close()
+			mutex.Lock()
 			delete(clients, conn)
+			mutex.Unlock()
 		}()
 
+		mutex.Lock()
+		if closing {
+			mutex.Unlock()
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server is shutting down"),
+				time.Now().Add(time.Second))
+			return
+		}
 		clients[conn] = true
+		mutex.Unlock()
 
 		// Отправляем историю сообщений при подключении
 		history, err := chatService.GetHistory(50)
@@ -169,7 +366,7 @@ func handleWS(chatService *service.ChatService) http.HandlerFunc {
 			logger.Error().Err(err).Msg("Failed to get chat history")
 		} else {
 			for _, msg := range history {
-				logger.Info().Msgf("Send history to client: %+v", msg)
+				logger.Debug().Int("message_id", msg.ID).Msg("Send history message")
 				out := map[string]interface{}{
 					"id":         msg.ID,
 					"user_id":    msg.UserID,
@@ -189,43 +386,107 @@ func handleWS(chatService *service.ChatService) http.HandlerFunc {
 			}
 		}
 
+		flood := newFloodControl(limits.ChatMessage, limits.ChatMaxWarnings)
 		for {
 			var raw map[string]interface{}
 			if err := conn.ReadJSON(&raw); err != nil {
-				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure, websocket.ClosePolicyViolation) {
 					logger.Warn().Err(err).Msg("WebSocket read error")
 				}
 				return
 			}
 
-			logger.Info().Msgf("RAW from client: %+v", raw)
-			userID := 0
-			if v, ok := raw["user_id"].(float64); ok {
-				userID = int(v)
+			if ok, retry, disconnect := flood.check(time.Now()); !ok {
+				if disconnect {
+					logger.Warn().Msg("Disconnecting flooding WebSocket client")
+					conn.WriteControl(websocket.CloseMessage,
+						websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "message rate limit exceeded"),
+						time.Now().Add(time.Second))
+					return
+				}
+				logger.Warn().Dur("retry_after", retry).Msg("Chat message rate limit exceeded")
+				warning := map[string]interface{}{
+					"error":       "rate limit exceeded, slow down",
+					"retry_after": ratelimit.RetryAfterSeconds(retry),
+				}
+				mutex.Lock()
+				err := conn.WriteJSON(warning)
+				mutex.Unlock()
+				if err != nil {
+					return
+				}
+				continue
 			}
-			username, _ := raw["username"].(string)
+
+			// user_id и username из сообщения игнорируются: автор — владелец токена
 			content, _ := raw["content"].(string)
+			logger.Debug().Int("length", len(content)).Msg("Message from client")
 
-			if content == "" || username == "" {
-				logger.Warn().Msg("Empty message content or username")
+			if content == "" {
+				logger.Warn().Msg("Empty message content")
 				continue
 			}
 
-			msg, err := chatService.AddMessage(userID, username, content)
+			// Ограничения проверяются на каждое сообщение: бан, выданный
+			// во время сессии, действует сразу
+			restricted, err := authClient.ActiveSanctions(r.Context(), claims.UserID)
+			if err != nil {
+				logger.Error().Err(err).Msg("Failed to check user sanctions")
+				mutex.Lock()
+				err = conn.WriteJSON(map[string]string{"error": "auth service unavailable, message not sent"})
+				mutex.Unlock()
+				if err != nil {
+					return
+				}
+				continue
+			}
+			if active := sanction.Find(restricted, sanction.Chat); active != nil {
+				logger.Warn().Str("sanction", string(active.Type)).Msg("Message from restricted user rejected")
+				mutex.Lock()
+				err = conn.WriteJSON(active.Notice())
+				mutex.Unlock()
+				if err != nil {
+					return
+				}
+				// Мут только отклоняет сообщения, бан и отстранение закрывают соединение
+				if active.Type != sanction.Mute {
+					conn.WriteControl(websocket.CloseMessage,
+						websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "account is restricted"),
+						time.Now().Add(time.Second))
+					return
+				}
+				continue
+			}
+
+			msg, err := chatService.AddMessage(claims.UserID, claims.Username, content)
 			if err != nil {
 				logger.Error().Err(err).Msg("Failed to add message from WebSocket")
 				continue
 			}
-			logger.Info().Msgf("Broadcast to clients: %+v", msg)
-			broadcast <- msg
+			logger.Debug().Int("message_id", msg.ID).Msg("Broadcast message")
+			publish(ctx, msg)
 		}
 	}
 }
 
-func handleMessages(chatService *service.ChatService) {
+// publish передаёт сообщение рассылке; после остановки рассылки сообщение
+// уже сохранено в базе и придёт клиентам с историей
+func publish(ctx context.Context, msg service.Message) {
+	select {
+	case broadcast <- msg:
+	case <-ctx.Done():
+	}
+}
+
+func handleMessages(ctx context.Context) {
 	for {
-		msg := <-broadcast
-		logger.Info().Msgf("Send to client: %+v", msg)
+		var msg service.Message
+		select {
+		case <-ctx.Done():
+			return
+		case msg = <-broadcast:
+		}
+		logger.Debug().Int("message_id", msg.ID).Msg("Send message to clients")
 		mutex.Lock()
 		for client := range clients {
 			out := map[string]interface{}{
@@ -235,7 +496,6 @@ func handleMessages(chatService *service.ChatService) {
 				"content":    msg.Content,
 				"created_at": msg.CreatedAt,
 			}
-			logger.Info().Msgf("Send to client (WS): %+v", out)
 			data, err := json.Marshal(out)
 			if err != nil {
 				logger.Error().Err(err).Msg("marshal error")
@@ -251,42 +511,111 @@ func handleMessages(chatService *service.ChatService) {
 	}
 }
 
-func cleanOldMessages(chatService *service.ChatService) {
+// cleanOldMessages раз в interval удаляет сообщения старше retention
+func cleanOldMessages(ctx context.Context, chatService *service.ChatService, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 	for {
-		removed, err := chatService.CleanOldMessages(24 * time.Hour)
+		removed, err := chatService.CleanOldMessages(retention)
 		if err != nil {
 			logger.Error().Err(err).Msg("Failed to clean old messages")
 		} else if removed > 0 {
 			logger.Info().Int("count", removed).Msg("Cleaned old messages")
 		}
-		time.Sleep(5 * time.Minute)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
 }
 
-func withCORS(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
+// floodControl ограничивает частоту сообщений одного WebSocket-соединения.
+// За превышение клиент получает предупреждение, а после maxWarnings
+// предупреждений подряд отключается. Счётчик сбрасывается, если клиент
+// целый период лимита не превышал его.
+type floodControl struct {
+	bucket        *ratelimit.Bucket
+	per           time.Duration
+	maxWarnings   int
+	warnings      int
+	lastViolation time.Time
+}
+
+func newFloodControl(limit ratelimit.Limit, maxWarnings int) *floodControl {
+	return &floodControl{bucket: ratelimit.NewBucket(limit), per: limit.Per, maxWarnings: maxWarnings}
+}
+
+// check возвращает ok, если сообщение можно принять, иначе время до
+// следующей попытки и нужно ли отключить клиента
+func (f *floodControl) check(now time.Time) (ok bool, retry time.Duration, disconnect bool) {
+	if ok, retry = f.bucket.Allow(); ok {
+		return true, 0, false
+	}
+	if now.Sub(f.lastViolation) > f.per {
+		f.warnings = 0
+	}
+	f.lastViolation = now
+	f.warnings++
+	return false, retry, f.warnings > f.maxWarnings
+}
+
+// closeClients отправляет всем WebSocket-клиентам кадр закрытия и рвёт
+// соединения. http.Server.Shutdown не ждёт перехваченные соединения,
+// поэтому их закрываем сами.
+func closeClients() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	closing = true
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server is shutting down")
+	for client := range clients {
+		if err := client.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second)); err != nil {
+			logger.Warn().Err(err).Msg("Failed to send close frame")
 		}
-		next(w, r)
+		client.Close()
+		delete(clients, client)
+	}
+}
+
+// requestToken access-токен запроса: из заголовка Authorization или
+// параметра token (браузер не передаёт заголовки при открытии WebSocket)
+func requestToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
 	}
+	return r.URL.Query().Get("token")
 }
 
-func parseJWT(token string) (map[string]interface{}, error) {
-	parsed, _, err := new(jwt.Parser).ParseUnverified(token, jwt.MapClaims{})
+// authenticate проверяет подпись токена из заголовка Authorization
+// (или query-параметра token), спрашивает auth-сервис, не отозвана ли
+// сессия токена, и возвращает его claims
+func authenticate(r *http.Request) (*jwt.Claims, error) {
+	token := requestToken(r)
+	if token == "" {
+		return nil, errUnauthenticated
+	}
+	claims, err := tokenManager.Parse(token)
 	if err != nil {
-		return nil, err
+		return nil, errUnauthenticated
 	}
-	if claims, ok := parsed.Claims.(jwt.MapClaims); ok {
-		out := make(map[string]interface{})
-		for k, v := range claims {
-			out[k] = v
-		}
-		return out, nil
+	resp, err := authClient.ValidateToken(r.Context(), token)
+	if err != nil {
+		zerolog.Ctx(r.Context()).Error().Err(err).Msg("Failed to validate token in auth service")
+		return nil, errAuthUnavailable
+	}
+	if !resp.Valid {
+		return nil, errUnauthenticated
+	}
+	logging.SetUserID(r.Context(), claims.UserID)
+	return claims, nil
+}
+
+// writeAuthError отвечает на ошибку authenticate: 503, если auth-сервис
+// недоступен, иначе 401
+func writeAuthError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errAuthUnavailable) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
 	}
-	return nil, errors.New("invalid token claims")
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
 }
@@ -1,136 +1,173 @@
 package main
 
 import (
-	"database/sql"
-	"encoding/json"
+	"context"
+	"flag"
 	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
+	"time"
 
 	_ "github.com/mos1rain/forum_go/docs"
+	"github.com/mos1rain/forum_go/internal/config"
+	"github.com/mos1rain/forum_go/internal/forum/chat"
 	"github.com/mos1rain/forum_go/internal/forum/grpc"
 	"github.com/mos1rain/forum_go/internal/forum/handler"
 	"github.com/mos1rain/forum_go/internal/forum/middleware"
 	"github.com/mos1rain/forum_go/internal/forum/repository"
 	"github.com/mos1rain/forum_go/internal/forum/service"
+	"github.com/mos1rain/forum_go/migrations"
+	"github.com/mos1rain/forum_go/pkg/database"
+	"github.com/mos1rain/forum_go/pkg/health"
 	"github.com/mos1rain/forum_go/pkg/jwt"
+	"github.com/mos1rain/forum_go/pkg/logging"
+	"github.com/mos1rain/forum_go/pkg/metrics"
+	"github.com/mos1rain/forum_go/pkg/migrate"
+	"github.com/mos1rain/forum_go/pkg/ratelimit"
+	"github.com/mos1rain/forum_go/pkg/rbac"
 	"github.com/rs/zerolog"
 	_ "github.com/swaggo/files"
 	httpSwagger "github.com/swaggo/http-swagger"
-	_ "modernc.org/sqlite"
+	googlegrpc "google.golang.org/grpc"
 )
 
-// Обёртка для CORS
-func withCORS(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		w.Header().Set("Access-Control-Max-Age", "3600")
-
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		next(w, r)
-	}
-}
-
 func main() {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
-	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger()
+	logger := logging.New("forum", logging.FormatConsole, "info")
 
-	// Подключение к SQLite с правильными настройками
-	db, err := sql.Open("sqlite", "/Users/Sieger/Desktop/forum_go/forum.db?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)")
+	// Настройки берутся из файла, переменных окружения и флагов
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Invalid configuration")
+	}
+	logger = logging.New("forum", cfg.Log.Format, cfg.Log.Level)
+
+	db, err := database.Open(cfg.Database.Connection())
 	if err != nil {
 		logger.Fatal().Err(err).Msg("Failed to connect to database")
 	}
 	defer db.Close()
 
-	// Проверка соединения
-	if err := db.Ping(); err != nil {
-		logger.Fatal().Err(err).Msg("Failed to ping database")
-	}
+	logger.Info().Str("driver", string(db.Dialect)).Msg("Successfully connected to database")
 
-	logger.Info().Msg("Successfully connected to database")
-
-	// Инициализация таблиц
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS categories (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT UNIQUE NOT NULL,
-			description TEXT NOT NULL,
-			creator_id INTEGER NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (creator_id) REFERENCES users(id) ON DELETE CASCADE
-		);
-
-		CREATE TABLE IF NOT EXISTS posts (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			author_id INTEGER NOT NULL,
-			category_id INTEGER NOT NULL,
-			title TEXT NOT NULL,
-			content TEXT NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (author_id) REFERENCES users(id) ON DELETE CASCADE,
-			FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
-		);
-
-		CREATE TABLE IF NOT EXISTS comments (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			post_id INTEGER NOT NULL,
-			user_id INTEGER NOT NULL,
-			content TEXT NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
-			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-		);
-	`)
+	// Схема базы создаётся и обновляется миграциями из migrations/
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to load database migrations")
+	}
+	migrator.EnableBaseline(migrations.Legacy)
+	applied, err := migrator.Up()
 	if err != nil {
-		logger.Fatal().Err(err).Msg("Failed to initialize database tables")
+		logger.Fatal().Err(err).Msg("Failed to apply database migrations")
 	}
+	logger.Info().Int("applied", len(applied)).Msg("Database migrations applied")
 
-	logger.Info().Msg("Database tables initialized successfully")
+	registry := metrics.NewRegistry()
+	metrics.RegisterDB(registry, db.DB, "forum")
+	httpMetrics := metrics.NewHTTP(registry)
+	grpcMetrics := metrics.NewGRPCClient(registry)
 
 	// Инициализация gRPC клиента для аутентификации
-	authClient, err := grpc.NewAuthGRPCClient("localhost:50052")
+	authClient, err := grpc.NewAuthGRPCClient(cfg.Forum.AuthGRPCAddr,
+		googlegrpc.WithChainUnaryInterceptor(
+			logging.UnaryClientInterceptor(),
+			grpcMetrics.UnaryClientInterceptor(),
+		))
 	if err != nil {
 		logger.Fatal().Err(err).Msg("Failed to connect to auth service")
 	}
+	defer authClient.Close()
 	middleware.SetAuthClient(authClient)
 
 	catRepo := repository.NewCategoryRepository(db)
 	postRepo := repository.NewPostRepository(db)
 	commRepo := repository.NewCommentRepository(db)
-	forumService := service.NewForumService(catRepo, postRepo, commRepo)
+	searchRepo := repository.NewSearchRepository(db)
+	forumService := service.NewForumService(catRepo, postRepo, commRepo, searchRepo, authClient)
+	forumService.EnableCategoryModerators(repository.NewModeratorRepository(db))
+	forumService.EnableAudit(authClient)
+	forumService.EnableReports(repository.NewReportRepository(db), chat.NewClient(cfg.Forum.ChatURL), authClient)
 	h := handler.NewForumHandler(forumService)
 
-	// Создаем TokenManager с тем же секретным ключом
-	tokenManager := jwt.NewTokenManager(jwt.SecretKey)
+	// Токены проверяются публичными ключами auth-сервиса
+	tokenManager := jwt.NewTokenVerifier(jwt.NewRemoteKeySet(cfg.JWT.JWKSURL))
 	middleware.SetTokenManager(tokenManager)
 
+	// Готовность зависит от базы и доступности auth-сервиса
+	checker := health.NewChecker(2 * time.Second)
+	checker.Add("database", db.PingContext)
+	checker.Add("auth", authClient.Ping)
+
+	// Создание постов и комментариев ограничено по пользователю; лимитер
+	// стоит после AuthMiddleware, чтобы знать, кто пишет
+	postLimiter := ratelimit.New(cfg.RateLimit.Post)
+	limitPosting := postLimiter.Middleware(ratelimit.ByUser(middleware.AuthUserID, ratelimit.ByIP(cfg.RateLimit.TrustForwardedFor)))
+
 	// Создаем новый маршрутизатор
 	mux := http.NewServeMux()
+	checker.Register(mux)
+	mux.Handle("/metrics", metrics.Handler(registry))
 
 	// Регистрируем маршруты с CORS
+	withCORS := cfg.CORS.Middleware("GET, POST, PUT, PATCH, DELETE, OPTIONS")
 	mux.HandleFunc("/api/forum/categories", withCORS(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodGet {
 			h.GetCategories(w, r)
 		} else if r.Method == http.MethodPost {
-			middleware.AuthMiddleware(http.HandlerFunc(h.CreateCategory)).ServeHTTP(w, r)
+			middleware.AuthMiddleware(middleware.RequirePermission(rbac.CreateCategory, http.HandlerFunc(h.CreateCategory))).ServeHTTP(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/api/forum/categories/{id}/posts", withCORS(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.GetCategoryPosts(w, r)
+	}))
+
+	mux.HandleFunc("/api/forum/categories/{id}/moderators", withCORS(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			h.GetCategoryModerators(w, r)
+		} else if r.Method == http.MethodPost {
+			middleware.AuthMiddleware(middleware.RequirePermission(rbac.ManageModerators, http.HandlerFunc(h.AssignCategoryModerator))).ServeHTTP(w, r)
 		} else {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	}))
 
+	mux.HandleFunc("/api/forum/categories/{id}/moderators/{user_id}", withCORS(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		middleware.AuthMiddleware(middleware.RequirePermission(rbac.ManageModerators, http.HandlerFunc(h.RemoveCategoryModerator))).ServeHTTP(w, r)
+	}))
+
+	mux.HandleFunc("/api/forum/users/{id}/posts", withCORS(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.GetUserPosts(w, r)
+	}))
+
+	mux.HandleFunc("/api/forum/search", withCORS(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.Search(w, r)
+	}))
+
 	mux.HandleFunc("/api/forum/posts", withCORS(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodGet {
 			h.GetPosts(w, r)
 		} else if r.Method == http.MethodPost {
-			middleware.AuthMiddleware(http.HandlerFunc(h.CreatePost)).ServeHTTP(w, r)
+			middleware.AuthMiddleware(limitPosting(http.HandlerFunc(h.CreatePost))).ServeHTTP(w, r)
 		} else {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
@@ -140,36 +177,59 @@ func main() {
 		if r.Method == http.MethodGet {
 			h.GetCommentsByPost(w, r)
 		} else if r.Method == http.MethodPost {
-			middleware.AuthMiddleware(http.HandlerFunc(h.CreateComment)).ServeHTTP(w, r)
+			middleware.AuthMiddleware(limitPosting(http.HandlerFunc(h.CreateComment))).ServeHTTP(w, r)
 		} else {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	}))
 
-	mux.HandleFunc("/api/forum/posts/", withCORS(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/forum/posts/{id}", withCORS(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodGet {
-			idStr := r.URL.Path[len("/api/forum/posts/"):]
-			if idStr == "" {
-				http.Error(w, "Missing post id", http.StatusBadRequest)
-				return
-			}
-			id, err := strconv.Atoi(idStr)
-			if err != nil {
-				http.Error(w, "Invalid post id", http.StatusBadRequest)
-				return
-			}
-			post, err := h.GetPostByID(id)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(post)
+			h.GetPost(w, r)
+			return
+		}
+		if r.Method == http.MethodPut || r.Method == http.MethodPatch {
+			middleware.AuthMiddleware(http.HandlerFunc(h.UpdatePost)).ServeHTTP(w, r)
 			return
 		}
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}))
 
+	// Закрыть пост может модератор категории, поэтому право проверяет сервис
+	mux.HandleFunc("/api/forum/posts/{id}/lock", withCORS(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		middleware.AuthMiddleware(http.HandlerFunc(h.LockPost)).ServeHTTP(w, r)
+	}))
+
+	// История правок видна всем, откатить пост может автор или модератор
+	// категории, поэтому право проверяет сервис
+	mux.HandleFunc("/api/forum/posts/{id}/revisions", withCORS(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.GetPostRevisions(w, r)
+	}))
+
+	mux.HandleFunc("/api/forum/posts/{id}/revisions/diff", withCORS(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.DiffPostRevisions(w, r)
+	}))
+
+	mux.HandleFunc("/api/forum/posts/{id}/revisions/{revision}/rollback", withCORS(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		middleware.AuthMiddleware(http.HandlerFunc(h.RollbackPost)).ServeHTTP(w, r)
+	}))
+
 	mux.HandleFunc("/api/forum/delete_post", withCORS(func(w http.ResponseWriter, r *http.Request) {
 		middleware.AuthMiddleware(http.HandlerFunc(h.DeletePost)).ServeHTTP(w, r)
 	}))
@@ -179,13 +239,108 @@ func main() {
 	}))
 
 	mux.HandleFunc("/api/forum/delete_category", withCORS(func(w http.ResponseWriter, r *http.Request) {
-		middleware.AuthMiddleware(http.HandlerFunc(h.DeleteCategory)).ServeHTTP(w, r)
+		middleware.AuthMiddleware(middleware.RequirePermission(rbac.DeleteCategory, http.HandlerFunc(h.DeleteCategory))).ServeHTTP(w, r)
+	}))
+
+	// Пожаловаться может любой пользователь, очередь видят модераторы
+	mux.HandleFunc("/api/forum/reports", withCORS(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(middleware.RequirePermission(rbac.ReviewReports, http.HandlerFunc(h.GetReports))).ServeHTTP(w, r)
+		} else if r.Method == http.MethodPost {
+			middleware.AuthMiddleware(limitPosting(http.HandlerFunc(h.CreateReport))).ServeHTTP(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/api/forum/reports/{id}", withCORS(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		middleware.AuthMiddleware(middleware.RequirePermission(rbac.ReviewReports, http.HandlerFunc(h.GetReport))).ServeHTTP(w, r)
+	}))
+
+	mux.HandleFunc("/api/forum/reports/{id}/resolve", withCORS(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		middleware.AuthMiddleware(middleware.RequirePermission(rbac.ReviewReports, http.HandlerFunc(h.ResolveReport))).ServeHTTP(w, r)
+	}))
+
+	// Удалённое видит и восстанавливает администратор
+	mux.HandleFunc("/api/forum/deleted/{type}", withCORS(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		middleware.AuthMiddleware(middleware.RequirePermission(rbac.RestoreContent, http.HandlerFunc(h.GetDeleted))).ServeHTTP(w, r)
+	}))
+
+	mux.HandleFunc("/api/forum/deleted/{type}/{id}/restore", withCORS(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		middleware.AuthMiddleware(middleware.RequirePermission(rbac.RestoreContent, http.HandlerFunc(h.RestoreDeleted))).ServeHTTP(w, r)
 	}))
 
 	mux.HandleFunc("/swagger/", httpSwagger.WrapHandler)
 
-	logger.Info().Str("port", "3002").Msg("Starting forum server on :3002")
-	if err := http.ListenAndServe(":3002", mux); err != nil {
+	// Останавливаемся по SIGINT/SIGTERM, дав текущим запросам завершиться
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Просроченное удалённое очищается в фоне до остановки сервера
+	purgeDone := make(chan struct{})
+	go func() {
+		defer close(purgeDone)
+		purgeDeleted(ctx, logger, forumService, cfg.Forum.DeletedRetention, cfg.Forum.PurgeInterval)
+	}()
+
+	srv := &http.Server{Addr: cfg.Forum.HTTPAddr, Handler: logging.Middleware(logger)(httpMetrics.Middleware(mux))}
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info().Str("addr", cfg.Forum.HTTPAddr).Msg("Starting forum server")
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case <-ctx.Done():
+		logger.Info().Msg("Shutting down forum server")
+	case err := <-serveErr:
 		logger.Fatal().Err(err).Msg("Failed to start server")
 	}
+	stop()
+
+	<-purgeDone
+	checker.Shutdown()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error().Err(err).Msg("Failed to drain HTTP requests")
+	}
+	logger.Info().Msg("Forum server stopped")
+}
+
+// purgeDeleted раз в interval окончательно удаляет посты, комментарии и
+// категории, удалённые больше retention назад
+func purgeDeleted(ctx context.Context, logger zerolog.Logger, forumService *service.ForumService, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		stats, err := forumService.PurgeDeleted(ctx, time.Now().Add(-retention))
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to purge deleted content")
+		} else if stats.Categories+stats.Posts+stats.Comments > 0 {
+			logger.Info().Int64("categories", stats.Categories).Int64("posts", stats.Posts).
+				Int64("comments", stats.Comments).Msg("Purged deleted content")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
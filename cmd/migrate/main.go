@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/mos1rain/forum_go/internal/config"
+	"github.com/mos1rain/forum_go/migrations"
+	"github.com/mos1rain/forum_go/pkg/database"
+	"github.com/mos1rain/forum_go/pkg/logging"
+	"github.com/mos1rain/forum_go/pkg/migrate"
+	"github.com/rs/zerolog"
+)
+
+const usage = `Usage: migrate [flags] <command>
+
+Commands:
+  up             apply all pending migrations
+  down [N]       revert the last N applied migrations (default 1)
+  status         list migrations and whether they are applied
+  force VERSION  mark migrations up to VERSION as applied without running them
+
+Flags:
+`
+
+func main() {
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	logger := logging.New("migrate", logging.FormatConsole, "info")
+
+	dir := flag.String("path", "", "directory with sqlite/ and postgres/ migrations (default: built-in)")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	// База берётся из той же конфигурации, что и у сервисов
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Invalid configuration")
+	}
+	logger = logging.New("migrate", cfg.Log.Format, cfg.Log.Level)
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var source fs.FS = migrations.FS
+	if *dir != "" {
+		source = os.DirFS(*dir)
+	}
+
+	db, err := database.Open(cfg.Database.Connection())
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to connect to database")
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(db, source)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to load migrations")
+	}
+	// Базы, созданные до миграций, берутся под управление только со
+	// встроенным набором: объекты Legacy описывают именно его
+	if *dir == "" {
+		migrator.EnableBaseline(migrations.Legacy)
+	}
+
+	switch cmd := flag.Arg(0); cmd {
+	case "up":
+		applied, err := migrator.Up()
+		for _, m := range applied {
+			logger.Info().Int64("version", m.Version).Str("name", m.Name).Msg("Applied migration")
+		}
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to apply migrations")
+		}
+		logger.Info().Int("count", len(applied)).Msg("Database is up to date")
+
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			steps, err = strconv.Atoi(flag.Arg(1))
+			if err != nil || steps <= 0 {
+				logger.Fatal().Str("steps", flag.Arg(1)).Msg("down expects a positive number of migrations")
+			}
+		}
+		reverted, err := migrator.Down(steps)
+		for _, m := range reverted {
+			logger.Info().Int64("version", m.Version).Str("name", m.Name).Msg("Reverted migration")
+		}
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to revert migrations")
+		}
+
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to read migration status")
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%06d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		w.Flush()
+
+	case "force":
+		if flag.NArg() < 2 {
+			logger.Fatal().Msg("force expects a version")
+		}
+		version, err := strconv.ParseInt(flag.Arg(1), 10, 64)
+		if err != nil || version < 0 {
+			logger.Fatal().Str("version", flag.Arg(1)).Msg("Invalid version")
+		}
+		if err := migrator.Force(version); err != nil {
+			logger.Fatal().Err(err).Msg("Failed to force version")
+		}
+		logger.Info().Int64("version", version).Msg("Schema version forced")
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", cmd)
+		flag.Usage()
+		os.Exit(2)
+	}
+}
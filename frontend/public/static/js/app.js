@@ -61,6 +61,7 @@ async function login(username, password) {
 
         console.log('Storing token and user data in localStorage');
         localStorage.setItem('token', data.token);
+        localStorage.setItem('refresh_token', data.refresh_token);
         localStorage.setItem('username', username);
         localStorage.setItem('user_id', data.user.id);
         localStorage.setItem('user_role', data.user.role);
@@ -80,8 +81,57 @@ async function login(username, password) {
     }
 }
 
+// Обменивает refresh-токен на новую пару токенов
+async function refreshTokens() {
+    const refreshToken = localStorage.getItem('refresh_token');
+    if (!refreshToken) {
+        return false;
+    }
+    const response = await fetch(`${AUTH_API_URL}/auth/refresh`, {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({ refresh_token: refreshToken }),
+    });
+    if (!response.ok) {
+        return false;
+    }
+    const data = await response.json();
+    localStorage.setItem('token', data.token);
+    localStorage.setItem('refresh_token', data.refresh_token);
+    return true;
+}
+
+// fetch с access-токеном: при 401 один раз обновляет токены и повторяет запрос
+async function authFetch(url, options = {}) {
+    const withToken = () => ({
+        ...options,
+        headers: {
+            ...(options.headers || {}),
+            'Authorization': `Bearer ${localStorage.getItem('token')}`
+        }
+    });
+    let response = await fetch(url, withToken());
+    if (response.status === 401 && await refreshTokens()) {
+        response = await fetch(url, withToken());
+    }
+    return response;
+}
+
 function logout() {
+    const refreshToken = localStorage.getItem('refresh_token');
+    if (refreshToken) {
+        fetch(`${AUTH_API_URL}/auth/logout`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+            },
+            body: JSON.stringify({ refresh_token: refreshToken }),
+        }).catch(error => console.error('Logout error:', error));
+    }
     localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
     localStorage.removeItem('username');
     localStorage.removeItem('user_id');
     localStorage.removeItem('user_role');
@@ -105,14 +155,22 @@ function updateAuthUI(isAuthenticated) {
     }
 }
 
+// Списки отдаются постранично: { items, next_cursor, total }
+function pageQuery({ limit = 100, cursor = '', sort = '' } = {}) {
+    const params = new URLSearchParams({ limit });
+    if (cursor) params.set('cursor', cursor);
+    if (sort) params.set('sort', sort);
+    return params.toString();
+}
+
 // Функции для работы с категориями
-async function getCategories() {
+async function getCategories(page = {}) {
     try {
-        const response = await fetch(`${API_BASE_URL}/categories`);
+        const response = await fetch(`${API_BASE_URL}/categories?${pageQuery(page)}`);
         if (!response.ok) {
             throw new Error('Failed to fetch categories');
         }
-        return await response.json();
+        return (await response.json()).items;
     } catch (error) {
         console.error('Error fetching categories:', error);
         throw error;
@@ -121,11 +179,10 @@ async function getCategories() {
 
 async function createCategory(name, description) {
     try {
-        const response = await fetch(`${API_BASE_URL}/categories`, {
+        const response = await authFetch(`${API_BASE_URL}/categories`, {
             method: 'POST',
             headers: {
-                'Content-Type': 'application/json',
-                'Authorization': `Bearer ${localStorage.getItem('token')}`
+                'Content-Type': 'application/json'
             },
             body: JSON.stringify({ name, description })
         });
@@ -141,11 +198,8 @@ async function createCategory(name, description) {
 
 async function deleteCategory(categoryId) {
     try {
-        const response = await fetch(`${API_BASE_URL}/delete_category?id=${categoryId}`, {
-            method: 'POST',
-            headers: {
-                'Authorization': `Bearer ${localStorage.getItem('token')}`
-            }
+        const response = await authFetch(`${API_BASE_URL}/delete_category?id=${categoryId}`, {
+            method: 'POST'
         });
         if (!response.ok) {
             throw new Error('Failed to delete category');
@@ -199,13 +253,14 @@ function updateCategoriesList(categories) {
 }
 
 // Функции для работы с постами
-async function getPosts() {
+async function getPosts(categoryId = null, page = {}) {
     try {
-        const response = await fetch(`${API_BASE_URL}/posts`);
+        const path = categoryId ? `/categories/${categoryId}/posts` : '/posts';
+        const response = await fetch(`${API_BASE_URL}${path}?${pageQuery(page)}`);
         if (!response.ok) {
             throw new Error('Failed to fetch posts');
         }
-        return await response.json();
+        return (await response.json()).items;
     } catch (error) {
         console.error('Error fetching posts:', error);
         throw error;
@@ -214,11 +269,10 @@ async function getPosts() {
 
 async function createPost(title, content, categoryId) {
     try {
-        const response = await fetch(`${API_BASE_URL}/posts`, {
+        const response = await authFetch(`${API_BASE_URL}/posts`, {
             method: 'POST',
             headers: {
-                'Content-Type': 'application/json',
-                'Authorization': `Bearer ${localStorage.getItem('token')}`
+                'Content-Type': 'application/json'
             },
             body: JSON.stringify({ title, content, categoryId })
         });
@@ -233,28 +287,27 @@ async function createPost(title, content, categoryId) {
 }
 
 // Функции для работы с комментариями
-async function getComments(postId) {
+async function getComments(postId, view = 'flat', page = {}) {
     try {
-        const response = await fetch(`${API_BASE_URL}/comments?post_id=${postId}`);
+        const response = await fetch(`${API_BASE_URL}/comments?post_id=${postId}&view=${view}&${pageQuery(page)}`);
         if (!response.ok) {
             throw new Error('Failed to fetch comments');
         }
-        return await response.json();
+        return (await response.json()).items;
     } catch (error) {
         console.error('Error fetching comments:', error);
         throw error;
     }
 }
 
-async function createComment(content, postId) {
+async function createComment(content, postId, parentId = null) {
     try {
-        const response = await fetch(`${API_BASE_URL}/comments`, {
+        const response = await authFetch(`${API_BASE_URL}/comments`, {
             method: 'POST',
             headers: {
-                'Content-Type': 'application/json',
-                'Authorization': `Bearer ${localStorage.getItem('token')}`
+                'Content-Type': 'application/json'
             },
-            body: JSON.stringify({ content, postId })
+            body: JSON.stringify({ content, post_id: Number(postId), parent_id: parentId })
         });
         if (!response.ok) {
             throw new Error('Failed to create comment');
@@ -269,11 +322,8 @@ async function createComment(content, postId) {
 // Функции для удаления
 async function deletePost(postId) {
     try {
-        const response = await fetch(`${API_BASE_URL}/delete_post?id=${postId}`, {
-            method: 'POST',
-            headers: {
-                'Authorization': `Bearer ${localStorage.getItem('token')}`
-            }
+        const response = await authFetch(`${API_BASE_URL}/delete_post?id=${postId}`, {
+            method: 'POST'
         });
         if (!response.ok) {
             throw new Error('Failed to delete post');
@@ -286,11 +336,8 @@ async function deletePost(postId) {
 
 async function deleteComment(commentId) {
     try {
-        const response = await fetch(`${API_BASE_URL}/delete_comment?id=${commentId}`, {
-            method: 'POST',
-            headers: {
-                'Authorization': `Bearer ${localStorage.getItem('token')}`
-            }
+        const response = await authFetch(`${API_BASE_URL}/delete_comment?id=${commentId}`, {
+            method: 'POST'
         });
         if (!response.ok) {
             throw new Error('Failed to delete comment');
@@ -305,7 +352,7 @@ async function deleteComment(commentId) {
 let ws = null;
 
 function connectToChat() {
-    ws = new WebSocket('ws://localhost:3003/ws');
+    ws = new WebSocket(`ws://localhost:3003/ws?token=${encodeURIComponent(localStorage.getItem('token'))}`);
     
     ws.onopen = () => {
         console.log('Connected to chat');
@@ -316,19 +363,17 @@ function connectToChat() {
         displayMessage(message);
     };
     
-    ws.onclose = () => {
+    ws.onclose = async () => {
         console.log('Disconnected from chat');
+        // Токен мог истечь: обновляем его перед переподключением
+        await refreshTokens().catch(() => false);
         setTimeout(connectToChat, 1000);
     };
 }
 
 function sendMessage(content) {
     if (ws && ws.readyState === WebSocket.OPEN) {
-        ws.send(JSON.stringify({
-            content,
-            user_id: parseInt(localStorage.getItem('user_id')),
-            username: localStorage.getItem('username')
-        }));
+        ws.send(JSON.stringify({ content }));
     }
 }
 
@@ -528,7 +573,7 @@ document.addEventListener('DOMContentLoaded', () => {
                 postForm.reset();
                 
                 // Обновляем список постов после создания
-                const posts = await getPosts();
+                const posts = await getPosts(categoryId);
                 updatePostsList(posts);
                 
                 // Показываем раздел с постами
@@ -558,7 +603,7 @@ document.addEventListener('DOMContentLoaded', () => {
 async function loadPosts(categoryId) {
     try {
         localStorage.setItem('currentCategoryId', categoryId);
-        const posts = await getPosts();
+        const posts = await getPosts(categoryId);
         updatePostsList(posts);
         showSection('posts');
     } catch (error) {
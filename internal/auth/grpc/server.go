@@ -2,29 +2,82 @@ package grpc
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+	"errors"
 	"net"
 
+	"github.com/mos1rain/forum_go/internal/auth/models"
 	"github.com/mos1rain/forum_go/internal/auth/repository"
+	"github.com/mos1rain/forum_go/internal/auth/service"
+	"github.com/mos1rain/forum_go/pkg/audit"
 	"github.com/mos1rain/forum_go/pkg/jwt"
+	"github.com/mos1rain/forum_go/pkg/rbac"
+	"github.com/mos1rain/forum_go/pkg/sanction"
 	"github.com/mos1rain/forum_go/proto/auth"
+	"github.com/rs/zerolog"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
+// RoleManager меняет роли пользователей с аудитом (см. service.UserService)
+type RoleManager interface {
+	ChangeRole(actorID, userID int, role string) (*models.User, error)
+}
+
+// SanctionManager выдаёт ограничения и возвращает действующие
+// (см. service.SanctionService)
+type SanctionManager interface {
+	Issue(actorID, userID int, input models.IssueSanctionInput) (*models.Sanction, error)
+	Active(userID int) ([]sanction.Active, error)
+}
+
+// AuditRecorder журнал действий модераторов (см. repository.AuditRepository)
+type AuditRecorder interface {
+	Create(entry *audit.Entry) error
+}
+
 type AuthGRPCServer struct {
 	auth.UnimplementedAuthServiceServer
 	repo      *repository.UserRepository
+	tokens    *repository.TokenRepository
 	tokenMngr *jwt.TokenManager
+	roles     RoleManager
+	sanctions SanctionManager
+	audit     AuditRecorder
 }
 
-func NewAuthGRPCServer(repo *repository.UserRepository, tokenMngr *jwt.TokenManager) *AuthGRPCServer {
-	return &AuthGRPCServer{repo: repo, tokenMngr: tokenMngr}
+func NewAuthGRPCServer(repo *repository.UserRepository, tokens *repository.TokenRepository, tokenMngr *jwt.TokenManager, roles RoleManager, sanctions SanctionManager, audit AuditRecorder) *AuthGRPCServer {
+	return &AuthGRPCServer{repo: repo, tokens: tokens, tokenMngr: tokenMngr, roles: roles, sanctions: sanctions, audit: audit}
+}
+
+// verify проверяет подпись токена и то, что его сессия не отозвана.
+// Вторым значением возвращается причина отказа для ответа клиенту.
+func (s *AuthGRPCServer) verify(token string) (*jwt.Claims, string, error) {
+	claims, err := s.tokenMngr.Parse(token)
+	if err != nil {
+		return nil, err.Error(), nil
+	}
+	// Токены отозванной сессии (logout, повторное использование refresh-токена) недействительны
+	if claims.SessionID != "" {
+		revoked, err := s.tokens.IsFamilyRevoked(claims.SessionID)
+		if err != nil {
+			return nil, "", err
+		}
+		if revoked {
+			return nil, "token revoked", nil
+		}
+	}
+	return claims, "", nil
 }
 
 func (s *AuthGRPCServer) ValidateToken(ctx context.Context, req *auth.ValidateTokenRequest) (*auth.ValidateTokenResponse, error) {
-	claims, err := s.tokenMngr.Parse(req.Token)
+	claims, reason, err := s.verify(req.Token)
 	if err != nil {
-		return &auth.ValidateTokenResponse{Valid: false, Error: err.Error()}, nil
+		return nil, err
+	}
+	if claims == nil {
+		return &auth.ValidateTokenResponse{Valid: false, Error: reason}, nil
 	}
 	return &auth.ValidateTokenResponse{
 		UserId:   int32(claims.UserID),
@@ -48,15 +101,188 @@ func (s *AuthGRPCServer) GetUserByID(ctx context.Context, req *auth.GetUserByIDR
 	}, nil
 }
 
-func RunGRPCServer(repo *repository.UserRepository, tokenMngr *jwt.TokenManager, addr string) {
-	lis, err := net.Listen("tcp", addr)
+// SetUserRole меняет роль пользователя от имени администратора, которому
+// принадлежит req.Token
+func (s *AuthGRPCServer) SetUserRole(ctx context.Context, req *auth.SetUserRoleRequest) (*auth.SetUserRoleResponse, error) {
+	claims, reason, err := s.verify(req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if claims == nil {
+		return &auth.SetUserRoleResponse{Error: reason}, nil
+	}
+
+	user, err := s.roles.ChangeRole(claims.UserID, int(req.UserId), req.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrForbidden), errors.Is(err, service.ErrInvalidRole),
+			errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrLastAdmin):
+			return &auth.SetUserRoleResponse{Error: err.Error()}, nil
+		}
+		return nil, err
+	}
+	zerolog.Ctx(ctx).Info().Int("target_user_id", user.ID).Str("role", user.Role).Msg("User role changed")
+
+	return &auth.SetUserRoleResponse{
+		UserId:   int32(user.ID),
+		Username: user.Username,
+		Role:     user.Role,
+	}, nil
+}
+
+// GetActiveSanctions возвращает действующие ограничения пользователя;
+// forum и chat решают по ним, пускать ли его запросы
+func (s *AuthGRPCServer) GetActiveSanctions(ctx context.Context, req *auth.GetActiveSanctionsRequest) (*auth.GetActiveSanctionsResponse, error) {
+	active, err := s.sanctions.Active(int(req.UserId))
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &auth.GetActiveSanctionsResponse{Sanctions: make([]*auth.Sanction, 0, len(active))}
+	for _, a := range active {
+		out := &auth.Sanction{Type: string(a.Type), Reason: a.Reason}
+		if a.ExpiresAt != nil {
+			out.ExpiresAt = a.ExpiresAt.Unix()
+		}
+		resp.Sanctions = append(resp.Sanctions, out)
+	}
+	return resp, nil
+}
+
+// IssueSanction ограничивает пользователя от имени модератора, которому
+// принадлежит req.Token; права и старшинство ролей проверяет SanctionService
+func (s *AuthGRPCServer) IssueSanction(ctx context.Context, req *auth.IssueSanctionRequest) (*auth.IssueSanctionResponse, error) {
+	claims, reason, err := s.verify(req.Token)
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		return nil, err
 	}
-	grpcServer := grpc.NewServer()
-	auth.RegisterAuthServiceServer(grpcServer, NewAuthGRPCServer(repo, tokenMngr))
-	log.Printf("gRPC Auth server started on %s", addr)
-	if err := grpcServer.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+	if claims == nil {
+		return &auth.IssueSanctionResponse{Error: reason}, nil
+	}
+	input := models.IssueSanctionInput{Type: sanction.Type(req.Type), Reason: req.Reason, Duration: req.Duration}
+	issued, err := s.sanctions.Issue(claims.UserID, int(req.UserId), input)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrForbidden), errors.Is(err, service.ErrInvalidSanction),
+			errors.Is(err, service.ErrUserNotFound):
+			return &auth.IssueSanctionResponse{Error: err.Error()}, nil
+		}
+		return nil, err
+	}
+	zerolog.Ctx(ctx).Info().Int("target_user_id", issued.UserID).Int("sanction_id", issued.ID).Str("type", string(issued.Type)).Msg("User sanctioned")
+	return &auth.IssueSanctionResponse{SanctionId: int64(issued.ID)}, nil
+}
+
+// remoteAction действие, которое сервис пишет в журнал через RecordAudit:
+// над какими объектами оно выполняется и какое право нужно роли автора
+type remoteAction struct {
+	service    string
+	targetType string
+	permission rbac.Permission
+}
+
+// remoteActions действия forum и chat. Действия самого auth-сервиса (роли,
+// блокировки, ограничения) он пишет сам, поэтому через gRPC они не принимаются.
+var remoteActions = map[string]remoteAction{
+	audit.ActionDeleteCategory:    {audit.ServiceForum, audit.TargetCategory, rbac.DeleteCategory},
+	audit.ActionDeletePost:        {audit.ServiceForum, audit.TargetPost, rbac.DeleteAnyPost},
+	audit.ActionDeleteComment:     {audit.ServiceForum, audit.TargetComment, rbac.DeleteAnyComment},
+	audit.ActionEditPost:          {audit.ServiceForum, audit.TargetPost, rbac.EditAnyPost},
+	audit.ActionRollbackPost:      {audit.ServiceForum, audit.TargetPost, rbac.EditAnyPost},
+	audit.ActionLockPost:          {audit.ServiceForum, audit.TargetPost, rbac.LockThread},
+	audit.ActionUnlockPost:        {audit.ServiceForum, audit.TargetPost, rbac.LockThread},
+	audit.ActionAssignModerator:   {audit.ServiceForum, audit.TargetUser, rbac.ManageModerators},
+	audit.ActionRemoveModerator:   {audit.ServiceForum, audit.TargetUser, rbac.ManageModerators},
+	audit.ActionDismissReport:     {audit.ServiceForum, audit.TargetReport, rbac.ReviewReports},
+	audit.ActionRestoreCategory:   {audit.ServiceForum, audit.TargetCategory, rbac.RestoreContent},
+	audit.ActionRestorePost:       {audit.ServiceForum, audit.TargetPost, rbac.RestoreContent},
+	audit.ActionRestoreComment:    {audit.ServiceForum, audit.TargetComment, rbac.RestoreContent},
+	audit.ActionDeleteChatMessage: {audit.ServiceChat, audit.TargetChatMessage, rbac.DeleteChatMessage},
+}
+
+// RecordAudit сохраняет запись журнала, присланную forum или chat, от
+// имени модератора, которому принадлежит req.Token. Автор записи берётся
+// из токена: actor_id в запросе, если указан, должен с ним совпадать.
+// Действие должно быть из remoteActions, а роль автора — давать нужное
+// для него право.
+func (s *AuthGRPCServer) RecordAudit(ctx context.Context, req *auth.RecordAuditRequest) (*auth.RecordAuditResponse, error) {
+	claims, reason, err := s.verify(req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if claims == nil {
+		return &auth.RecordAuditResponse{Error: reason}, nil
+	}
+	e := req.Entry
+	if e == nil || e.Service == "" || e.Action == "" || e.TargetType == "" {
+		return &auth.RecordAuditResponse{Error: "service, action and target_type are required"}, nil
+	}
+	if e.ActorId != 0 && e.ActorId != int64(claims.UserID) {
+		return &auth.RecordAuditResponse{Error: "actor_id does not match token"}, nil
+	}
+	action, ok := remoteActions[e.Action]
+	if !ok {
+		return &auth.RecordAuditResponse{Error: "unknown action: " + e.Action}, nil
+	}
+	if e.Service != action.service || e.TargetType != action.targetType {
+		return &auth.RecordAuditResponse{Error: "action " + e.Action + " is recorded by " + action.service + " for " + action.targetType}, nil
+	}
+	if !rbac.Default.Can(claims.Role, action.permission) {
+		return &auth.RecordAuditResponse{Error: "permission denied: " + string(action.permission)}, nil
+	}
+	entry := &audit.Entry{
+		Service:    e.Service,
+		ActorID:    int64(claims.UserID),
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetId,
+		Reason:     e.Reason,
+	}
+	if e.Snapshot != "" {
+		if !json.Valid([]byte(e.Snapshot)) {
+			return &auth.RecordAuditResponse{Error: "snapshot must be valid JSON"}, nil
+		}
+		entry.Snapshot = json.RawMessage(e.Snapshot)
+	}
+	if err := s.audit.Create(entry); err != nil {
+		return nil, err
+	}
+	return &auth.RecordAuditResponse{Id: entry.ID}, nil
+}
+
+// Server gRPC-сервер auth-сервиса вместе со службой grpc.health.v1,
+// по которой forum проверяет готовность auth
+type Server struct {
+	server *grpc.Server
+	health *health.Server
+}
+
+func NewServer(repo *repository.UserRepository, tokens *repository.TokenRepository, tokenMngr *jwt.TokenManager, roles RoleManager, sanctions SanctionManager, audit AuditRecorder, opts ...grpc.ServerOption) *Server {
+	s := &Server{server: grpc.NewServer(opts...), health: health.NewServer()}
+	auth.RegisterAuthServiceServer(s.server, NewAuthGRPCServer(repo, tokens, tokenMngr, roles, sanctions, audit))
+	healthpb.RegisterHealthServer(s.server, s.health)
+	return s
+}
+
+// Serve обслуживает lis до вызова Shutdown
+func (s *Server) Serve(lis net.Listener) error {
+	return s.server.Serve(lis)
+}
+
+// Shutdown перестаёт принимать новые вызовы и ждёт завершения текущих.
+// Если ctx истекает раньше, оставшиеся соединения закрываются принудительно.
+func (s *Server) Shutdown(ctx context.Context) {
+	s.health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.server.Stop()
+		<-done
 	}
 }
@@ -0,0 +1,80 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mos1rain/forum_go/pkg/audit"
+	"github.com/mos1rain/forum_go/pkg/jwt"
+	"github.com/mos1rain/forum_go/proto/auth"
+)
+
+type mockAuditRecorder struct{ entries []*audit.Entry }
+
+func (m *mockAuditRecorder) Create(entry *audit.Entry) error {
+	entry.ID = int64(len(m.entries) + 1)
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+func TestRecordAuditRequiresActorToken(t *testing.T) {
+	ring := jwt.NewKeyRing()
+	if _, err := ring.Rotate(jwt.AlgEdDSA); err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tokens := jwt.NewTokenManager(ring)
+	token, err := tokens.NewJWTWithRole(5, "mod", "moderator", time.Minute)
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+	userToken, err := tokens.NewJWTWithRole(6, "user", "user", time.Minute)
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+	log := &mockAuditRecorder{}
+	s := NewAuthGRPCServer(nil, nil, tokens, nil, nil, log)
+	ctx := context.Background()
+	entry := func(actorID int64) *auth.AuditEntry {
+		return &auth.AuditEntry{Service: audit.ServiceForum, ActorId: actorID, Action: audit.ActionDeletePost,
+			TargetType: audit.TargetPost, TargetId: 12, Snapshot: `{"id":12}`}
+	}
+
+	rejected := map[string]*auth.RecordAuditRequest{
+		"no token":       {Entry: entry(5)},
+		"invalid token":  {Entry: entry(5), Token: "forged"},
+		"forged actor":   {Entry: entry(1), Token: token},
+		"missing fields": {Entry: &auth.AuditEntry{ActorId: 5}, Token: token},
+		"plain user":     {Entry: entry(6), Token: userToken},
+		"unknown action": {Entry: &auth.AuditEntry{Service: audit.ServiceForum, Action: "erase_history", TargetType: audit.TargetPost}, Token: token},
+		"auth action":    {Entry: &auth.AuditEntry{Service: audit.ServiceForum, Action: audit.ActionSetRole, TargetType: audit.TargetUser}, Token: token},
+		"wrong target":   {Entry: &auth.AuditEntry{Service: audit.ServiceForum, Action: audit.ActionDeletePost, TargetType: audit.TargetUser}, Token: token},
+		"wrong service":  {Entry: &auth.AuditEntry{Service: audit.ServiceChat, Action: audit.ActionDeletePost, TargetType: audit.TargetPost}, Token: token},
+		"no permission":  {Entry: &auth.AuditEntry{Service: audit.ServiceForum, Action: audit.ActionRestorePost, TargetType: audit.TargetPost}, Token: token},
+	}
+	for name, req := range rejected {
+		resp, err := s.RecordAudit(ctx, req)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if resp.Error == "" {
+			t.Errorf("%s: expected the call to be rejected", name)
+		}
+	}
+	if len(log.entries) != 0 {
+		t.Fatalf("rejected calls must not be recorded: %+v", log.entries)
+	}
+
+	// Автор записи берётся из токена, даже если actor_id не указан
+	for _, actorID := range []int64{5, 0} {
+		resp, err := s.RecordAudit(ctx, &auth.RecordAuditRequest{Entry: entry(actorID), Token: token})
+		if err != nil || resp.Error != "" {
+			t.Fatalf("record: %+v, %v", resp, err)
+		}
+	}
+	for _, e := range log.entries {
+		if e.ActorID != 5 {
+			t.Errorf("expected actor from token, got %+v", e)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/auth/models"
+	"github.com/mos1rain/forum_go/internal/auth/service"
+	"github.com/mos1rain/forum_go/pkg/audit"
+	"github.com/rs/zerolog"
+)
+
+// AuditHandler просмотр и выгрузка журнала действий модераторов и администраторов
+type AuditHandler struct {
+	service service.AuditServiceInterface
+}
+
+func NewAuditHandler(service service.AuditServiceInterface) *AuditHandler {
+	return &AuditHandler{service: service}
+}
+
+// @Summary Query the audit log
+// @Description Moderation and admin actions of all services, newest first. Requires the view_audit_log permission
+// @Tags audit
+// @Produce json
+// @Security Bearer
+// @Param actor_id query int false "Who performed the action"
+// @Param target_type query string false "Target type: category, post, comment, chat_message, user, sanction, report"
+// @Param target_id query int false "Target ID, requires target_type"
+// @Param service query string false "Service: auth, forum, chat"
+// @Param action query string false "Action such as delete_post"
+// @Param from query string false "Start of the time range, RFC3339, inclusive"
+// @Param to query string false "End of the time range, RFC3339, exclusive"
+// @Param limit query int false "Page size, 50 by default, at most 500"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} models.AuditPage
+// @Failure 400 {string} string "Invalid filter"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal server error"
+// @Router /api/auth/audit [get]
+func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	logger := zerolog.Ctx(r.Context())
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter, err := parseAuditFilter(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var limit int
+	if v := query.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := h.service.List(filter, limit, query.Get("cursor"))
+	if err != nil {
+		writeAuditError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		logger.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// @Summary Export the audit log
+// @Description All matching entries in the order they were recorded, one JSON object per line. Requires the view_audit_log permission
+// @Tags audit
+// @Produce application/x-ndjson
+// @Security Bearer
+// @Param actor_id query int false "Who performed the action"
+// @Param target_type query string false "Target type"
+// @Param target_id query int false "Target ID, requires target_type"
+// @Param service query string false "Service: auth, forum, chat"
+// @Param action query string false "Action such as delete_post"
+// @Param from query string false "Start of the time range, RFC3339, inclusive"
+// @Param to query string false "End of the time range, RFC3339, exclusive"
+// @Success 200 {string} string "JSON lines"
+// @Failure 400 {string} string "Invalid filter"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal server error"
+// @Router /api/auth/audit/export [get]
+func (h *AuditHandler) Export(w http.ResponseWriter, r *http.Request) {
+	logger := zerolog.Ctx(r.Context())
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Заголовки отправляются с первой записью: до неё ещё можно ответить ошибкой
+	enc := json.NewEncoder(w)
+	started := false
+	err = h.service.Export(filter, func(entry *audit.Entry) error {
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			started = true
+		}
+		return enc.Encode(entry)
+	})
+	switch {
+	case err != nil && !started:
+		writeAuditError(w, r, err)
+	case err != nil:
+		logger.Error().Err(err).Msg("Audit log export interrupted")
+	case !started:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+}
+
+// parseAuditFilter разбирает параметры фильтра журнала из запроса
+func parseAuditFilter(query url.Values) (models.AuditFilter, error) {
+	filter := models.AuditFilter{
+		TargetType: query.Get("target_type"),
+		Service:    query.Get("service"),
+		Action:     query.Get("action"),
+	}
+	for name, dst := range map[string]**int64{"actor_id": &filter.ActorID, "target_id": &filter.TargetID} {
+		if v := query.Get(name); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || id <= 0 {
+				return filter, fmt.Errorf("invalid %s", name)
+			}
+			*dst = &id
+		}
+	}
+	for name, dst := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := query.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s: expected RFC3339 time", name)
+			}
+			*dst = t
+		}
+	}
+	return filter, nil
+}
+
+func writeAuditError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidAuditFilter):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		zerolog.Ctx(r.Context()).Error().Err(err).Msg("Failed to read audit log")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mos1rain/forum_go/pkg/jwt"
+)
+
+type JWKSHandler struct {
+	keys *jwt.KeyRing
+}
+
+func NewJWKSHandler(keys *jwt.KeyRing) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+// @Summary JSON Web Key Set
+// @Description Public keys used to verify access tokens, including keys that were recently rotated out
+// @Tags auth
+// @Produce json
+// @Success 200 {object} jwt.JWKSet "Public signing keys"
+// @Router /.well-known/jwks.json [get]
+func (h *JWKSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.keys.JWKS())
+}
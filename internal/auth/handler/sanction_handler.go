@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/mos1rain/forum_go/internal/auth/middleware"
+	"github.com/mos1rain/forum_go/internal/auth/models"
+	"github.com/mos1rain/forum_go/internal/auth/service"
+	"github.com/rs/zerolog"
+)
+
+// SanctionHandler выдача, просмотр и отмена ограничений модераторами
+type SanctionHandler struct {
+	service service.SanctionServiceInterface
+}
+
+func NewSanctionHandler(service service.SanctionServiceInterface) *SanctionHandler {
+	return &SanctionHandler{service: service}
+}
+
+// UserSanctions обрабатывает /api/auth/users/{id}/sanctions:
+// GET — история ограничений, POST — новое ограничение
+func (h *SanctionHandler) UserSanctions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.List(w, r)
+	case http.MethodPost:
+		h.Issue(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// @Summary Sanction a user
+// @Description Ban (permanent), suspend (requires duration), mute in chat (optional duration) or warn a user with a lower role. Requires the ban_user permission
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path int true "User ID"
+// @Param input body models.IssueSanctionInput true "Sanction type (ban, suspension, mute, warning), reason and duration such as 72h"
+// @Success 201 {object} models.Sanction "Issued sanction"
+// @Failure 400 {string} string "Invalid sanction"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /api/auth/users/{id}/sanctions [post]
+func (h *SanctionHandler) Issue(w http.ResponseWriter, r *http.Request) {
+	logger := zerolog.Ctx(r.Context())
+
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || userID <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var input models.IssueSanctionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		logger.Error().Err(err).Msg("Failed to decode request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	issued, err := h.service.Issue(claims.UserID, userID, input)
+	if err != nil {
+		writeSanctionError(w, r, err)
+		return
+	}
+
+	logger.Info().Int("target_user_id", userID).Int("sanction_id", issued.ID).Str("type", string(issued.Type)).Msg("User sanctioned")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(issued); err != nil {
+		logger.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// @Summary List user sanctions
+// @Description All sanctions of a user, newest first, including expired and revoked ones. Requires the ban_user permission
+// @Tags moderation
+// @Produce json
+// @Security Bearer
+// @Param id path int true "User ID"
+// @Success 200 {array} models.Sanction
+// @Failure 400 {string} string "Invalid user ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /api/auth/users/{id}/sanctions [get]
+func (h *SanctionHandler) List(w http.ResponseWriter, r *http.Request) {
+	logger := zerolog.Ctx(r.Context())
+
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || userID <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	sanctions, err := h.service.List(userID)
+	if err != nil {
+		writeSanctionError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(sanctions); err != nil {
+		logger.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// @Summary Revoke a sanction
+// @Description Lift an active sanction before it expires. Requires the ban_user permission
+// @Tags moderation
+// @Produce json
+// @Security Bearer
+// @Param id path int true "Sanction ID"
+// @Success 200 {object} models.Sanction "Revoked sanction"
+// @Failure 400 {string} string "Invalid sanction ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Sanction not found"
+// @Failure 409 {string} string "Sanction is not active"
+// @Failure 500 {string} string "Internal server error"
+// @Router /api/auth/sanctions/{id} [delete]
+func (h *SanctionHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	logger := zerolog.Ctx(r.Context())
+
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sanctionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || sanctionID <= 0 {
+		http.Error(w, "Invalid sanction ID", http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := h.service.Revoke(claims.UserID, sanctionID)
+	if err != nil {
+		writeSanctionError(w, r, err)
+		return
+	}
+
+	logger.Info().Int("sanction_id", sanctionID).Int("target_user_id", revoked.UserID).Msg("Sanction revoked")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(revoked); err != nil {
+		logger.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+func writeSanctionError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidSanction):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrForbidden):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrSanctionNotFound):
+		http.Error(w, "Sanction not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrSanctionInactive):
+		http.Error(w, "Sanction is not active", http.StatusConflict)
+	default:
+		zerolog.Ctx(r.Context()).Error().Err(err).Msg("Failed to process sanction")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -2,28 +2,36 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
-	"os"
+	"strconv"
 
+	"github.com/mos1rain/forum_go/internal/auth/middleware"
 	"github.com/mos1rain/forum_go/internal/auth/models"
 	"github.com/mos1rain/forum_go/internal/auth/service"
+	"github.com/mos1rain/forum_go/pkg/logging"
+	"github.com/mos1rain/forum_go/pkg/ratelimit"
+	"github.com/mos1rain/forum_go/pkg/sanction"
 	"github.com/rs/zerolog"
 )
 
+// UserHandler пишет в логгер запроса из контекста (см. pkg/logging),
+// поэтому каждая запись содержит request_id
 type UserHandler struct {
 	service service.UserServiceInterface
-	logger  zerolog.Logger
+	// trustForwarded брать IP клиента для журнала входов из X-Forwarded-For
+	trustForwarded bool
 }
 
-func NewUserHandler(service service.UserServiceInterface) *UserHandler {
+func NewUserHandler(service service.UserServiceInterface, trustForwardedFor bool) *UserHandler {
 	return &UserHandler{
-		service: service,
-		logger:  zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger(),
+		service:        service,
+		trustForwarded: trustForwardedFor,
 	}
 }
 
 // @Summary Register new user
-// @Description Register a new user in the system
+// @Description Register a new user in the system. New users always get the "user" role
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -34,6 +42,8 @@ func NewUserHandler(service service.UserServiceInterface) *UserHandler {
 // @Failure 500 {string} string "Internal server error"
 // @Router /api/auth/register [post]
 func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
+	logger := zerolog.Ctx(r.Context())
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -41,20 +51,23 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 	var input models.CreateUserInput
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		h.logger.Error().Err(err).Msg("Failed to decode request body")
+		logger.Error().Err(err).Msg("Failed to decode request body")
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"message": "Неверный формат данных"})
 		return
 	}
 
-	h.logger.Info().Str("username", input.Username).Str("email", input.Email).Msg("Attempting to register new user")
+	logger.Info().Str("username", input.Username).Str("email", input.Email).Msg("Attempting to register new user")
 
 	response, err := h.service.Register(input)
 	if err != nil {
-		h.logger.Error().Err(err).Msg("Failed to register user")
+		logger.Error().Err(err).Msg("Failed to register user")
 		w.Header().Set("Content-Type", "application/json")
 		switch err {
+		case service.ErrInvalidInput:
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"message": "Заполните имя пользователя, email и пароль"})
 		case service.ErrUserAlreadyExists:
 			w.WriteHeader(http.StatusConflict)
 			json.NewEncoder(w).Encode(map[string]string{"message": "Пользователь с такими данными уже существует"})
@@ -65,12 +78,13 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.logger.Info().Int("user_id", response.User.ID).Msg("User successfully registered")
+	logging.SetUserID(r.Context(), response.User.ID)
+	logger.Info().Int("user_id", response.User.ID).Msg("User successfully registered")
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		h.logger.Error().Err(err).Msg("Failed to encode response")
+		logger.Error().Err(err).Msg("Failed to encode response")
 	}
 }
 
@@ -83,9 +97,13 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} service.AuthResponse "Login successful"
 // @Failure 400 {string} string "Invalid request data"
 // @Failure 401 {string} string "Invalid credentials"
+// @Failure 403 {object} sanction.Notice "Account is banned or suspended"
+// @Failure 429 {string} string "Too many failed login attempts, see Retry-After"
 // @Failure 500 {string} string "Internal server error"
 // @Router /api/auth/login [post]
 func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
+	logger := zerolog.Ctx(r.Context())
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -93,29 +111,349 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	var input models.LoginInput
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		h.logger.Error().Err(err).Msg("Failed to decode request body")
+		logger.Error().Err(err).Msg("Failed to decode request body")
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	h.logger.Info().Str("username", input.Username).Msg("Attempting to login user")
+	input.IP = ratelimit.ClientIP(r, h.trustForwarded)
+	input.UserAgent = r.UserAgent()
+	logger.Info().Str("username", input.Username).Msg("Attempting to login user")
 
 	response, err := h.service.Login(input)
 	if err != nil {
-		h.logger.Error().Err(err).Msg("Failed to login user")
-		switch err {
-		case service.ErrInvalidCredentials:
+		var locked *service.LockedError
+		var sanctioned *service.SanctionedError
+		switch {
+		case errors.As(err, &sanctioned):
+			logger.Info().Str("username", input.Username).Str("sanction", string(sanctioned.Sanction.Type)).Msg("Login denied by sanction")
+			sanction.WriteHTTP(w, sanctioned.Sanction)
+		case errors.As(err, &locked):
+			logger.Warn().Str("username", input.Username).Dur("retry_after", locked.RetryAfter).Msg("Login locked out")
+			w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(locked.RetryAfter)))
+			http.Error(w, "Too many failed login attempts", http.StatusTooManyRequests)
+		case errors.Is(err, service.ErrInvalidCredentials):
+			logger.Info().Err(err).Msg("Failed to login user")
 			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		default:
+			logger.Error().Err(err).Msg("Failed to login user")
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
 		return
 	}
 
-	h.logger.Info().Int("user_id", response.User.ID).Msg("User successfully logged in")
+	logging.SetUserID(r.Context(), response.User.ID)
+	logger.Info().Int("user_id", response.User.ID).Msg("User successfully logged in")
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		h.logger.Error().Err(err).Msg("Failed to encode response")
+		logger.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access/refresh token pair (the old refresh token is rotated)
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body models.RefreshInput true "Refresh token"
+// @Success 200 {object} service.AuthResponse "Tokens refreshed"
+// @Failure 400 {string} string "Invalid request data"
+// @Failure 401 {string} string "Invalid or reused refresh token"
+// @Failure 403 {object} sanction.Notice "Account is banned or suspended"
+// @Failure 500 {string} string "Internal server error"
+// @Router /api/auth/refresh [post]
+func (h *UserHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	logger := zerolog.Ctx(r.Context())
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input models.RefreshInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		logger.Error().Err(err).Msg("Failed to decode request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.service.Refresh(input)
+	if err != nil {
+		var sanctioned *service.SanctionedError
+		if errors.As(err, &sanctioned) {
+			sanction.WriteHTTP(w, sanctioned.Sanction)
+			return
+		}
+		switch err {
+		case service.ErrRefreshTokenReused:
+			logger.Warn().Err(err).Msg("Refresh token reuse detected, session revoked")
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		case service.ErrInvalidRefreshToken:
+			logger.Info().Err(err).Msg("Failed to refresh tokens")
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		default:
+			logger.Error().Err(err).Msg("Failed to refresh tokens")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// @Summary User logout
+// @Description Revoke the session the refresh token belongs to
+// @Tags auth
+// @Accept json
+// @Param input body models.RefreshInput true "Refresh token"
+// @Success 204 "Session revoked"
+// @Failure 400 {string} string "Invalid request data"
+// @Failure 401 {string} string "Invalid refresh token"
+// @Failure 500 {string} string "Internal server error"
+// @Router /api/auth/logout [post]
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	logger := zerolog.Ctx(r.Context())
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input models.RefreshInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		logger.Error().Err(err).Msg("Failed to decode request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Logout(input); err != nil {
+		switch err {
+		case service.ErrInvalidRefreshToken:
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		default:
+			logger.Error().Err(err).Msg("Failed to logout user")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+const (
+	defaultSignInsLimit = 20
+	maxSignInsLimit     = 100
+)
+
+// @Summary Recent sign-ins
+// @Description List recent successful and failed login attempts to the current user's account
+// @Tags auth
+// @Produce json
+// @Security Bearer
+// @Param limit query int false "Number of entries (default 20, max 100)"
+// @Success 200 {array} models.LoginAttempt "Login attempts, newest first"
+// @Failure 400 {string} string "Invalid limit"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 500 {string} string "Internal server error"
+// @Router /api/auth/sign-ins [get]
+func (h *UserHandler) SignIns(w http.ResponseWriter, r *http.Request) {
+	logger := zerolog.Ctx(r.Context())
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit := defaultSignInsLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxSignInsLimit)
+	}
+
+	attempts, err := h.service.RecentSignIns(claims.UserID, limit)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to list sign-ins")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(attempts); err != nil {
+		logger.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// @Summary Unlock user account
+// @Description Lift a login lockout and reset the failed attempt counter (admin only)
+// @Tags admin
+// @Security Bearer
+// @Param id path int true "User ID"
+// @Success 204 "Account unlocked"
+// @Failure 400 {string} string "Invalid user ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /api/auth/users/{id}/unlock [post]
+func (h *UserHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	logger := zerolog.Ctx(r.Context())
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || userID <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Unlock(claims.UserID, userID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			http.Error(w, "User not found", http.StatusNotFound)
+		default:
+			logger.Error().Err(err).Msg("Failed to unlock user")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logger.Info().Int("target_user_id", userID).Msg("User account unlocked")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Change user role
+// @Description Promote or demote a user between user, moderator and admin (admin only). The change is audited; the new role is included in tokens issued afterwards, and a demotion revokes the user's sessions
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path int true "User ID"
+// @Param input body models.SetRoleInput true "New role: user, moderator or admin"
+// @Success 200 {object} models.User "Updated user"
+// @Failure 400 {string} string "Invalid user ID or role"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "User not found"
+// @Failure 409 {string} string "Cannot demote the last admin"
+// @Failure 500 {string} string "Internal server error"
+// @Router /api/auth/users/{id}/role [put]
+func (h *UserHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	logger := zerolog.Ctx(r.Context())
+
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || userID <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var input models.SetRoleInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		logger.Error().Err(err).Msg("Failed to decode request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.service.ChangeRole(claims.UserID, userID, input.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidRole):
+			http.Error(w, "Invalid role", http.StatusBadRequest)
+		case errors.Is(err, service.ErrForbidden):
+			http.Error(w, "forbidden", http.StatusForbidden)
+		case errors.Is(err, service.ErrUserNotFound):
+			http.Error(w, "User not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrLastAdmin):
+			http.Error(w, "Cannot demote the last admin", http.StatusConflict)
+		default:
+			logger.Error().Err(err).Msg("Failed to change user role")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logger.Info().Int("target_user_id", userID).Str("role", user.Role).Msg("User role changed")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		logger.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// @Summary User role history
+// @Description List role changes of a user, newest first (admin only)
+// @Tags admin
+// @Produce json
+// @Security Bearer
+// @Param id path int true "User ID"
+// @Success 200 {array} models.RoleChange "Role changes"
+// @Failure 400 {string} string "Invalid user ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /api/auth/users/{id}/role-changes [get]
+func (h *UserHandler) RoleChanges(w http.ResponseWriter, r *http.Request) {
+	logger := zerolog.Ctx(r.Context())
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || userID <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	changes, err := h.service.RoleChanges(userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			http.Error(w, "User not found", http.StatusNotFound)
+		default:
+			logger.Error().Err(err).Msg("Failed to list role changes")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(changes); err != nil {
+		logger.Error().Err(err).Msg("Failed to encode response")
 	}
 }
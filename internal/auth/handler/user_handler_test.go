@@ -6,14 +6,22 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/mos1rain/forum_go/internal/auth/middleware"
 	"github.com/mos1rain/forum_go/internal/auth/models"
 	"github.com/mos1rain/forum_go/internal/auth/service"
+	"github.com/mos1rain/forum_go/pkg/jwt"
 )
 
 type mockUserService struct {
 	registerFunc func(input models.CreateUserInput) (*service.AuthResponse, error)
 	loginFunc    func(input models.LoginInput) (*service.AuthResponse, error)
+	refreshFunc  func(input models.RefreshInput) (*service.AuthResponse, error)
+	logoutFunc   func(input models.RefreshInput) error
+	unlockFunc   func(actorID, userID int) error
+	signInsFunc  func(userID, limit int) ([]models.LoginAttempt, error)
+	roleFunc     func(actorID, userID int, role string) (*models.User, error)
 }
 
 func (m *mockUserService) Register(input models.CreateUserInput) (*service.AuthResponse, error) {
@@ -24,6 +32,30 @@ func (m *mockUserService) Login(input models.LoginInput) (*service.AuthResponse,
 	return m.loginFunc(input)
 }
 
+func (m *mockUserService) Refresh(input models.RefreshInput) (*service.AuthResponse, error) {
+	return m.refreshFunc(input)
+}
+
+func (m *mockUserService) Logout(input models.RefreshInput) error {
+	return m.logoutFunc(input)
+}
+
+func (m *mockUserService) Unlock(actorID, userID int) error {
+	return m.unlockFunc(actorID, userID)
+}
+
+func (m *mockUserService) RecentSignIns(userID, limit int) ([]models.LoginAttempt, error) {
+	return m.signInsFunc(userID, limit)
+}
+
+func (m *mockUserService) ChangeRole(actorID, userID int, role string) (*models.User, error) {
+	return m.roleFunc(actorID, userID, role)
+}
+
+func (m *mockUserService) RoleChanges(userID int) ([]models.RoleChange, error) {
+	return []models.RoleChange{}, nil
+}
+
 func TestUserHandler_Register(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -87,7 +119,7 @@ func TestUserHandler_Register(t *testing.T) {
 			mockService := &mockUserService{
 				registerFunc: tt.mockRegister,
 			}
-			handler := NewUserHandler(mockService)
+			handler := NewUserHandler(mockService, false)
 
 			// Создаем тестовый запрос
 			body, _ := json.Marshal(tt.input)
@@ -127,7 +159,7 @@ func TestUserHandler_Register(t *testing.T) {
 }
 
 func TestUserHandler_Register_InvalidMethod(t *testing.T) {
-	handler := NewUserHandler(&mockUserService{})
+	handler := NewUserHandler(&mockUserService{}, false)
 	req := httptest.NewRequest(http.MethodGet, "/api/auth/register", nil)
 	rec := httptest.NewRecorder()
 
@@ -139,7 +171,7 @@ func TestUserHandler_Register_InvalidMethod(t *testing.T) {
 }
 
 func TestUserHandler_Register_InvalidJSON(t *testing.T) {
-	handler := NewUserHandler(&mockUserService{})
+	handler := NewUserHandler(&mockUserService{}, false)
 	req := httptest.NewRequest(http.MethodPost, "/api/auth/register", bytes.NewBufferString("invalid json"))
 	rec := httptest.NewRecorder()
 
@@ -149,3 +181,196 @@ func TestUserHandler_Register_InvalidJSON(t *testing.T) {
 		t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rec.Code)
 	}
 }
+
+func TestUserHandler_Refresh(t *testing.T) {
+	tests := []struct {
+		name         string
+		mockRefresh  func(input models.RefreshInput) (*service.AuthResponse, error)
+		expectedCode int
+	}{
+		{
+			name: "successful refresh",
+			mockRefresh: func(input models.RefreshInput) (*service.AuthResponse, error) {
+				return &service.AuthResponse{Token: "new.jwt.token", RefreshToken: "new-refresh"}, nil
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "invalid token",
+			mockRefresh: func(input models.RefreshInput) (*service.AuthResponse, error) {
+				return nil, service.ErrInvalidRefreshToken
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name: "reused token",
+			mockRefresh: func(input models.RefreshInput) (*service.AuthResponse, error) {
+				return nil, service.ErrRefreshTokenReused
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewUserHandler(&mockUserService{refreshFunc: tt.mockRefresh}, false)
+
+			body, _ := json.Marshal(models.RefreshInput{RefreshToken: "refresh"})
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/refresh", bytes.NewBuffer(body))
+			rec := httptest.NewRecorder()
+
+			handler.Refresh(rec, req)
+
+			if rec.Code != tt.expectedCode {
+				t.Errorf("expected status code %d, got %d", tt.expectedCode, rec.Code)
+			}
+		})
+	}
+}
+
+func TestUserHandler_Logout(t *testing.T) {
+	handler := NewUserHandler(&mockUserService{
+		logoutFunc: func(input models.RefreshInput) error { return nil },
+	}, false)
+
+	body, _ := json.Marshal(models.RefreshInput{RefreshToken: "refresh"})
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/logout", bytes.NewBuffer(body))
+	rec := httptest.NewRecorder()
+
+	handler.Logout(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected status code %d, got %d", http.StatusNoContent, rec.Code)
+	}
+}
+
+func TestUserHandler_Login(t *testing.T) {
+	var got models.LoginInput
+	handler := NewUserHandler(&mockUserService{
+		loginFunc: func(input models.LoginInput) (*service.AuthResponse, error) {
+			got = input
+			return nil, &service.LockedError{RetryAfter: 90 * time.Second}
+		},
+	}, true)
+
+	body, _ := json.Marshal(models.LoginInput{Username: "user", Password: "wrong"})
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewBuffer(body))
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	req.Header.Set("User-Agent", "test-agent")
+	rec := httptest.NewRecorder()
+
+	handler.Login(rec, req)
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status code %d, got %d", http.StatusTooManyRequests, rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "90" {
+		t.Errorf("expected Retry-After 90, got %q", rec.Header().Get("Retry-After"))
+	}
+	if got.IP != "203.0.113.7" || got.UserAgent != "test-agent" {
+		t.Errorf("client info not passed to service: %+v", got)
+	}
+}
+
+func TestUserHandler_SignIns(t *testing.T) {
+	var gotUser, gotLimit int
+	handler := NewUserHandler(&mockUserService{
+		signInsFunc: func(userID, limit int) ([]models.LoginAttempt, error) {
+			gotUser, gotLimit = userID, limit
+			return []models.LoginAttempt{{IP: "10.0.0.1", Success: true}}, nil
+		},
+	}, false)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/sign-ins?limit=500", nil)
+	req = req.WithContext(middleware.WithClaims(req.Context(), &jwt.Claims{UserID: 7}))
+	rec := httptest.NewRecorder()
+
+	handler.SignIns(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, rec.Code)
+	}
+	if gotUser != 7 || gotLimit != maxSignInsLimit {
+		t.Errorf("expected user 7 and limit %d, got %d and %d", maxSignInsLimit, gotUser, gotLimit)
+	}
+	var attempts []models.LoginAttempt
+	if err := json.NewDecoder(rec.Body).Decode(&attempts); err != nil || len(attempts) != 1 {
+		t.Errorf("unexpected response %v, %v", attempts, err)
+	}
+}
+
+func TestUserHandler_Unlock(t *testing.T) {
+	handler := NewUserHandler(&mockUserService{
+		unlockFunc: func(actorID, userID int) error {
+			if actorID != 7 {
+				return service.ErrForbidden
+			}
+			if userID != 1 {
+				return service.ErrUserNotFound
+			}
+			return nil
+		},
+	}, false)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/auth/users/{id}/unlock", handler.Unlock)
+
+	for path, want := range map[string]int{
+		"/api/auth/users/1/unlock":   http.StatusNoContent,
+		"/api/auth/users/2/unlock":   http.StatusNotFound,
+		"/api/auth/users/abc/unlock": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req = req.WithContext(middleware.WithClaims(req.Context(), &jwt.Claims{UserID: 7}))
+		mux.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("%s: expected status code %d, got %d", path, want, rec.Code)
+		}
+	}
+}
+
+func TestUserHandler_SetRole(t *testing.T) {
+	handler := NewUserHandler(&mockUserService{
+		roleFunc: func(actorID, userID int, role string) (*models.User, error) {
+			switch {
+			case actorID != 1:
+				return nil, service.ErrForbidden
+			case userID == 1:
+				return nil, service.ErrLastAdmin
+			case role != models.RoleModerator:
+				return nil, service.ErrInvalidRole
+			}
+			return &models.User{ID: userID, Role: role}, nil
+		},
+	}, false)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/auth/users/{id}/role", handler.SetRole)
+
+	tests := []struct {
+		name  string
+		actor int
+		path  string
+		role  string
+		want  int
+	}{
+		{"promote", 1, "/api/auth/users/2/role", models.RoleModerator, http.StatusOK},
+		{"invalid role", 1, "/api/auth/users/2/role", "root", http.StatusBadRequest},
+		{"not admin", 2, "/api/auth/users/2/role", models.RoleModerator, http.StatusForbidden},
+		{"last admin", 1, "/api/auth/users/1/role", models.RoleUser, http.StatusConflict},
+		{"bad id", 1, "/api/auth/users/x/role", models.RoleModerator, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(models.SetRoleInput{Role: tt.role})
+			req := httptest.NewRequest(http.MethodPut, tt.path, bytes.NewBuffer(body))
+			req = req.WithContext(middleware.WithClaims(req.Context(), &jwt.Claims{UserID: tt.actor}))
+			rec := httptest.NewRecorder()
+
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("expected status code %d, got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
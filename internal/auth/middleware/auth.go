@@ -0,0 +1,88 @@
+// Package middleware проверяет access-токены в HTTP API auth-сервиса
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/mos1rain/forum_go/pkg/jwt"
+	"github.com/mos1rain/forum_go/pkg/logging"
+	"github.com/mos1rain/forum_go/pkg/rbac"
+	"github.com/rs/zerolog"
+)
+
+type contextKey struct{}
+
+// SessionChecker сообщает, отозвана ли сессия (logout, кража refresh-токена)
+type SessionChecker interface {
+	IsFamilyRevoked(familyID string) (bool, error)
+}
+
+type Authenticator struct {
+	tokens   *jwt.TokenManager
+	sessions SessionChecker
+}
+
+func NewAuthenticator(tokens *jwt.TokenManager, sessions SessionChecker) *Authenticator {
+	return &Authenticator{tokens: tokens, sessions: sessions}
+}
+
+// Authenticate пропускает запрос с действующим access-токеном и кладёт его
+// claims в контекст
+func (a *Authenticator) Authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := zerolog.Ctx(r.Context())
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			http.Error(w, "unauthorized: no token provided", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := a.tokens.Parse(token)
+		if err != nil {
+			logger.Info().Err(err).Msg("Rejected invalid token")
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		if claims.SessionID != "" {
+			revoked, err := a.sessions.IsFamilyRevoked(claims.SessionID)
+			if err != nil {
+				logger.Error().Err(err).Msg("Failed to check session")
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if revoked {
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		logging.SetUserID(r.Context(), claims.UserID)
+		next(w, r.WithContext(WithClaims(r.Context(), claims)))
+	}
+}
+
+// RequirePermission то же, что Authenticate, но пропускает только
+// пользователей, чья роль даёт право perm
+func (a *Authenticator) RequirePermission(perm rbac.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return a.Authenticate(func(w http.ResponseWriter, r *http.Request) {
+		if claims, _ := ClaimsFromContext(r.Context()); !rbac.Default.Can(claims.Role, perm) {
+			zerolog.Ctx(r.Context()).Warn().Str("role", claims.Role).Str("permission", string(perm)).Msg("Access denied")
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
+
+func WithClaims(ctx context.Context, claims *jwt.Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, claims)
+}
+
+// ClaimsFromContext возвращает claims токена, проверенного Authenticate
+func ClaimsFromContext(ctx context.Context) (*jwt.Claims, bool) {
+	claims, ok := ctx.Value(contextKey{}).(*jwt.Claims)
+	return claims, ok
+}
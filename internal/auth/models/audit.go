@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/mos1rain/forum_go/pkg/audit"
+)
+
+// AuditFilter условия выборки из журнала действий. Пустые поля не
+// ограничивают выборку; From включительно, To не включительно.
+type AuditFilter struct {
+	ActorID    *int64
+	TargetType string
+	TargetID   *int64
+	Service    string
+	Action     string
+	From       time.Time
+	To         time.Time
+}
+
+// AuditPage страница журнала, начиная с последних записей. NextCursor
+// передаётся в cursor за следующей страницей; пуст на последней.
+type AuditPage struct {
+	Items      []audit.Entry `json:"items"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// LoginAttempt запись журнала входов. UserID пуст, если пользователя
+// с таким именем нет.
+type LoginAttempt struct {
+	ID        int       `json:"id"`
+	UserID    *int      `json:"-"`
+	Username  string    `json:"-"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Success   bool      `json:"success"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Lockout счётчик неудачных входов подряд и время, до которого вход
+// в учётную запись запрещён
+type Lockout struct {
+	UserID      int        `json:"user_id"`
+	FailedCount int        `json:"failed_count"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
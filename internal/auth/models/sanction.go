@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/mos1rain/forum_go/pkg/sanction"
+)
+
+// Sanction ограничение, выданное модератором. ExpiresAt пуст у бессрочных,
+// RevokedAt заполняется при досрочной отмене. ModeratorID и RevokedBy
+// пусты, если модератор удалён.
+type Sanction struct {
+	ID          int           `json:"id"`
+	UserID      int           `json:"user_id"`
+	Type        sanction.Type `json:"type"`
+	Reason      string        `json:"reason"`
+	ModeratorID *int          `json:"moderator_id"`
+	CreatedAt   time.Time     `json:"created_at"`
+	ExpiresAt   *time.Time    `json:"expires_at,omitempty"`
+	RevokedAt   *time.Time    `json:"revoked_at,omitempty"`
+	RevokedBy   *int          `json:"revoked_by,omitempty"`
+}
+
+// ActiveAt сообщает, действует ли ограничение в момент now
+func (s *Sanction) ActiveAt(now time.Time) bool {
+	return s.RevokedAt == nil && (s.ExpiresAt == nil || now.Before(*s.ExpiresAt))
+}
+
+// IssueSanctionInput запрос на ограничение. Duration — срок в формате
+// time.ParseDuration ("72h"); обязателен для отстранения, не допускается
+// у бана и предупреждения, у запрета писать в чат пустой срок означает
+// бессрочный.
+type IssueSanctionInput struct {
+	Type     sanction.Type `json:"type"`
+	Reason   string        `json:"reason"`
+	Duration string        `json:"duration,omitempty"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// RefreshToken хранимая запись refresh-токена.
+// Все токены, полученные ротацией из одного логина, образуют семейство (FamilyID).
+type RefreshToken struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	FamilyID  string     `json:"family_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type RefreshInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
@@ -2,6 +2,26 @@ package models
 
 import "time"
 
+// Роли пользователей по возрастанию полномочий
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// RoleRank уровень полномочий роли; 0 для неизвестной роли
+func RoleRank(role string) int {
+	switch role {
+	case RoleUser:
+		return 1
+	case RoleModerator:
+		return 2
+	case RoleAdmin:
+		return 3
+	}
+	return 0
+}
+
 type User struct {
 	ID           int       `json:"id"`
 	Username     string    `json:"username"`
@@ -12,14 +32,33 @@ type User struct {
 	Role         string    `json:"role"`
 }
 
+// CreateUserInput данные регистрации. Роль не задаётся: новый
+// пользователь всегда получает RoleUser
 type CreateUserInput struct {
 	Username string `json:"username"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
-	Role     string `json:"role"`
 }
 
 type LoginInput struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	// IP и UserAgent клиента заполняет обработчик для журнала входов
+	IP        string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+type SetRoleInput struct {
+	Role string `json:"role"`
+}
+
+// RoleChange запись аудита смены роли. ChangedBy пуст, если
+// администратор, сменивший роль, удалён
+type RoleChange struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	OldRole   string    `json:"old_role"`
+	NewRole   string    `json:"new_role"`
+	ChangedBy *int      `json:"changed_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
 }
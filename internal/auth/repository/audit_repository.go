@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/auth/models"
+	"github.com/mos1rain/forum_go/pkg/audit"
+	"github.com/mos1rain/forum_go/pkg/database"
+)
+
+// AuditRepository журнал действий модераторов и администраторов всех сервисов
+type AuditRepository struct {
+	db *database.DB
+}
+
+func NewAuditRepository(db *database.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+const auditColumns = `id, service, actor_id, action, target_type, target_id, snapshot, reason, created_at`
+
+// Create добавляет запись в журнал
+func (r *AuditRepository) Create(entry *audit.Entry) error {
+	query := `
+		INSERT INTO audit_log (service, actor_id, action, target_type, target_id, snapshot, reason, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`
+
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	var snapshot *string
+	if len(entry.Snapshot) > 0 {
+		s := string(entry.Snapshot)
+		snapshot = &s
+	}
+	return r.db.QueryRow(query, entry.Service, entry.ActorID, entry.Action, entry.TargetType, entry.TargetID,
+		snapshot, entry.Reason, entry.CreatedAt).Scan(&entry.ID)
+}
+
+// auditWhere собирает условия выборки по filter и записям до beforeID
+func auditWhere(filter models.AuditFilter, beforeID int64) (string, []any) {
+	var conds []string
+	var args []any
+	if filter.ActorID != nil {
+		conds, args = append(conds, "actor_id = ?"), append(args, *filter.ActorID)
+	}
+	if filter.TargetType != "" {
+		conds, args = append(conds, "target_type = ?"), append(args, filter.TargetType)
+	}
+	if filter.TargetID != nil {
+		conds, args = append(conds, "target_id = ?"), append(args, *filter.TargetID)
+	}
+	if filter.Service != "" {
+		conds, args = append(conds, "service = ?"), append(args, filter.Service)
+	}
+	if filter.Action != "" {
+		conds, args = append(conds, "action = ?"), append(args, filter.Action)
+	}
+	if !filter.From.IsZero() {
+		conds, args = append(conds, "created_at >= ?"), append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		conds, args = append(conds, "created_at < ?"), append(args, filter.To)
+	}
+	if beforeID > 0 {
+		conds, args = append(conds, "id < ?"), append(args, beforeID)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// List возвращает до limit записей по filter, начиная с последних и
+// пропуская записи с id не меньше beforeID (0 — с самой последней)
+func (r *AuditRepository) List(filter models.AuditFilter, limit int, beforeID int64) ([]audit.Entry, error) {
+	where, args := auditWhere(filter, beforeID)
+	rows, err := r.db.Query(`SELECT `+auditColumns+` FROM audit_log`+where+` ORDER BY id DESC LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []audit.Entry{}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, rows.Err()
+}
+
+// Export передаёт fn все записи по filter в порядке добавления, не
+// загружая журнал в память целиком. Ошибка fn прерывает выгрузку.
+func (r *AuditRepository) Export(filter models.AuditFilter, fn func(*audit.Entry) error) error {
+	where, args := auditWhere(filter, 0)
+	rows, err := r.db.Query(`SELECT `+auditColumns+` FROM audit_log`+where+` ORDER BY id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func scanAuditEntry(rows *sql.Rows) (*audit.Entry, error) {
+	var entry audit.Entry
+	var snapshot sql.NullString
+	if err := rows.Scan(&entry.ID, &entry.Service, &entry.ActorID, &entry.Action, &entry.TargetType, &entry.TargetID,
+		&snapshot, &entry.Reason, &entry.CreatedAt); err != nil {
+		return nil, err
+	}
+	if snapshot.Valid {
+		entry.Snapshot = json.RawMessage(snapshot.String)
+	}
+	return &entry, nil
+}
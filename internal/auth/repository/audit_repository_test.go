@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/auth/models"
+	"github.com/mos1rain/forum_go/pkg/audit"
+	"github.com/mos1rain/forum_go/pkg/database"
+	"github.com/mos1rain/forum_go/pkg/database/dbtest"
+)
+
+func TestAuditRepository(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewAuditRepository(db)
+		now := time.Now().UTC().Truncate(time.Second)
+
+		entries := []*audit.Entry{
+			{Service: audit.ServiceForum, ActorID: 2, Action: audit.ActionDeletePost, TargetType: audit.TargetPost, TargetID: 10,
+				Snapshot: audit.Snapshot(map[string]string{"title": "spam"}), Reason: "spam", CreatedAt: now.Add(-2 * time.Hour)},
+			{Service: audit.ServiceChat, ActorID: 2, Action: audit.ActionDeleteChatMessage, TargetType: audit.TargetChatMessage, TargetID: 5,
+				CreatedAt: now.Add(-time.Hour)},
+			{Service: audit.ServiceAuth, ActorID: 1, Action: audit.ActionBanUser, TargetType: audit.TargetUser, TargetID: 7,
+				Reason: "abuse", CreatedAt: now},
+		}
+		for _, e := range entries {
+			if err := repo.Create(e); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if e.ID == 0 {
+				t.Fatal("Create() didn't set entry ID")
+			}
+		}
+
+		all, err := repo.List(models.AuditFilter{}, 10, 0)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		if len(all) != 3 || all[0].ID != entries[2].ID {
+			t.Fatalf("expected 3 entries newest first, got %+v", all)
+		}
+		if string(all[2].Snapshot) != `{"title":"spam"}` || all[1].Snapshot != nil {
+			t.Errorf("unexpected snapshots: %s, %s", all[2].Snapshot, all[1].Snapshot)
+		}
+
+		// Страница после самой новой записи
+		page, err := repo.List(models.AuditFilter{}, 1, entries[2].ID)
+		if err != nil || len(page) != 1 || page[0].ID != entries[1].ID {
+			t.Errorf("unexpected page: %+v, %v", page, err)
+		}
+
+		actor := int64(2)
+		byActor, err := repo.List(models.AuditFilter{ActorID: &actor, From: now.Add(-90 * time.Minute)}, 10, 0)
+		if err != nil || len(byActor) != 1 || byActor[0].Service != audit.ServiceChat {
+			t.Errorf("unexpected entries by actor and time: %+v, %v", byActor, err)
+		}
+
+		target := int64(7)
+		byTarget, err := repo.List(models.AuditFilter{TargetType: audit.TargetUser, TargetID: &target}, 10, 0)
+		if err != nil || len(byTarget) != 1 || byTarget[0].Reason != "abuse" {
+			t.Errorf("unexpected entries by target: %+v, %v", byTarget, err)
+		}
+
+		var exported []int64
+		err = repo.Export(models.AuditFilter{To: now}, func(e *audit.Entry) error {
+			exported = append(exported, e.ID)
+			return nil
+		})
+		if err != nil || len(exported) != 2 || exported[0] != entries[0].ID {
+			t.Errorf("unexpected export: %v, %v", exported, err)
+		}
+	})
+}
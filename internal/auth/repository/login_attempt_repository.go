@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/auth/models"
+	"github.com/mos1rain/forum_go/pkg/database"
+)
+
+// LoginAttemptRepository журнал попыток входа и блокировки учётных записей
+type LoginAttemptRepository struct {
+	db *database.DB
+}
+
+func NewLoginAttemptRepository(db *database.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db}
+}
+
+func (r *LoginAttemptRepository) Create(attempt *models.LoginAttempt) error {
+	query := `
+		INSERT INTO login_attempts (user_id, username, ip, user_agent, success, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id`
+
+	if attempt.CreatedAt.IsZero() {
+		attempt.CreatedAt = time.Now()
+	}
+	var id int64
+	err := r.db.QueryRow(query, attempt.UserID, attempt.Username, attempt.IP, attempt.UserAgent, attempt.Success, attempt.CreatedAt).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	attempt.ID = int(id)
+	return nil
+}
+
+// ListByUser возвращает последние limit попыток входа пользователя,
+// начиная с самой свежей
+func (r *LoginAttemptRepository) ListByUser(userID, limit int) ([]models.LoginAttempt, error) {
+	query := `
+		SELECT id, user_id, username, ip, user_agent, success, created_at
+		FROM login_attempts
+		WHERE user_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?`
+
+	rows, err := r.db.Query(query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []models.LoginAttempt{}
+	for rows.Next() {
+		var attempt models.LoginAttempt
+		var user sql.NullInt64
+		if err := rows.Scan(&attempt.ID, &user, &attempt.Username, &attempt.IP, &attempt.UserAgent, &attempt.Success, &attempt.CreatedAt); err != nil {
+			return nil, err
+		}
+		if user.Valid {
+			id := int(user.Int64)
+			attempt.UserID = &id
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, rows.Err()
+}
+
+// NthFailureByIP возвращает время n-й с конца неудачной попытки с адреса ip
+// не раньше since, или nil, если неудачных попыток меньше n
+func (r *LoginAttemptRepository) NthFailureByIP(ip string, n int, since time.Time) (*time.Time, error) {
+	query := `
+		SELECT created_at FROM login_attempts
+		WHERE ip = ? AND success = ? AND created_at >= ?
+		ORDER BY created_at DESC
+		LIMIT 1 OFFSET ?`
+
+	var at time.Time
+	err := r.db.QueryRow(query, ip, false, since, n-1).Scan(&at)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &at, nil
+}
+
+// DeleteBefore удаляет записи журнала старше before
+func (r *LoginAttemptRepository) DeleteBefore(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM login_attempts WHERE created_at < ?`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetLockout возвращает состояние блокировки пользователя или nil, если
+// неудачных попыток подряд не было
+func (r *LoginAttemptRepository) GetLockout(userID int) (*models.Lockout, error) {
+	lockout := &models.Lockout{UserID: userID}
+	var lockedUntil sql.NullTime
+	err := r.db.QueryRow(`SELECT failed_count, locked_until FROM account_lockouts WHERE user_id = ?`, userID).Scan(&lockout.FailedCount, &lockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if lockedUntil.Valid {
+		lockout.LockedUntil = &lockedUntil.Time
+	}
+	return lockout, nil
+}
+
+// SaveLockout создаёт или обновляет состояние блокировки
+func (r *LoginAttemptRepository) SaveLockout(lockout *models.Lockout) error {
+	query := `
+		INSERT INTO account_lockouts (user_id, failed_count, locked_until, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			failed_count = excluded.failed_count,
+			locked_until = excluded.locked_until,
+			updated_at = excluded.updated_at`
+
+	_, err := r.db.Exec(query, lockout.UserID, lockout.FailedCount, lockout.LockedUntil, time.Now())
+	return err
+}
+
+// ResetLockout сбрасывает счётчик неудачных попыток и снимает блокировку.
+// Возвращает false, если сбрасывать было нечего.
+func (r *LoginAttemptRepository) ResetLockout(userID int) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM account_lockouts WHERE user_id = ?`, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
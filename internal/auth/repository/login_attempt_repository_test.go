@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/auth/models"
+	"github.com/mos1rain/forum_go/pkg/database"
+	"github.com/mos1rain/forum_go/pkg/database/dbtest"
+)
+
+func TestLoginAttemptRepository_Attempts(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewLoginAttemptRepository(db)
+		userID := createTestUser(t, db)
+		start := time.Now().UTC().Truncate(time.Second)
+
+		for i, attempt := range []models.LoginAttempt{
+			{UserID: &userID, Username: "owner", IP: "10.0.0.1", Success: false},
+			{Username: "ghost", IP: "10.0.0.1", Success: false},
+			{UserID: &userID, Username: "owner", IP: "10.0.0.2", UserAgent: "curl", Success: true},
+		} {
+			attempt.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+			if err := repo.Create(&attempt); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if attempt.ID == 0 {
+				t.Fatal("Create() didn't set attempt ID")
+			}
+		}
+
+		attempts, err := repo.ListByUser(userID, 10)
+		if err != nil {
+			t.Fatalf("ListByUser() error = %v", err)
+		}
+		if len(attempts) != 2 || !attempts[0].Success || attempts[0].UserAgent != "curl" || attempts[1].Success {
+			t.Fatalf("ListByUser() = %+v, want newest successful attempt first", attempts)
+		}
+
+		nth, err := repo.NthFailureByIP("10.0.0.1", 2, start)
+		if err != nil {
+			t.Fatalf("NthFailureByIP() error = %v", err)
+		}
+		if nth == nil || !nth.Equal(start) {
+			t.Errorf("NthFailureByIP() = %v, want %v", nth, start)
+		}
+		if nth, _ := repo.NthFailureByIP("10.0.0.1", 2, start.Add(30*time.Second)); nth != nil {
+			t.Errorf("failures outside the window must not count, got %v", nth)
+		}
+
+		deleted, err := repo.DeleteBefore(start.Add(90 * time.Second))
+		if err != nil || deleted != 2 {
+			t.Errorf("DeleteBefore() = %d, %v; want 2", deleted, err)
+		}
+	})
+}
+
+func TestLoginAttemptRepository_Lockout(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewLoginAttemptRepository(db)
+		userID := createTestUser(t, db)
+
+		if lockout, err := repo.GetLockout(userID); err != nil || lockout != nil {
+			t.Fatalf("GetLockout() = %v, %v; want nil", lockout, err)
+		}
+
+		if err := repo.SaveLockout(&models.Lockout{UserID: userID, FailedCount: 1}); err != nil {
+			t.Fatalf("SaveLockout() error = %v", err)
+		}
+		until := time.Now().Add(time.Minute).UTC().Truncate(time.Second)
+		if err := repo.SaveLockout(&models.Lockout{UserID: userID, FailedCount: 2, LockedUntil: &until}); err != nil {
+			t.Fatalf("SaveLockout() update error = %v", err)
+		}
+
+		lockout, err := repo.GetLockout(userID)
+		if err != nil {
+			t.Fatalf("GetLockout() error = %v", err)
+		}
+		if lockout.FailedCount != 2 || lockout.LockedUntil == nil || !lockout.LockedUntil.Equal(until) {
+			t.Errorf("GetLockout() = %+v, want 2 failures locked until %v", lockout, until)
+		}
+
+		if reset, err := repo.ResetLockout(userID); err != nil || !reset {
+			t.Errorf("ResetLockout() = %v, %v; want true", reset, err)
+		}
+		if reset, _ := repo.ResetLockout(userID); reset {
+			t.Error("second ResetLockout() must report nothing to reset")
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/auth/models"
+	"github.com/mos1rain/forum_go/pkg/database"
+)
+
+// SanctionRepository баны, отстранения и запреты писать в чат
+type SanctionRepository struct {
+	db *database.DB
+}
+
+func NewSanctionRepository(db *database.DB) *SanctionRepository {
+	return &SanctionRepository{db: db}
+}
+
+const sanctionColumns = `id, user_id, type, reason, moderator_id, created_at, expires_at, revoked_at, revoked_by`
+
+func (r *SanctionRepository) Create(s *models.Sanction) error {
+	query := `
+		INSERT INTO sanctions (user_id, type, reason, moderator_id, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id`
+
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = time.Now()
+	}
+	var id int64
+	err := r.db.QueryRow(query, s.UserID, s.Type, s.Reason, s.ModeratorID, s.CreatedAt, s.ExpiresAt).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	s.ID = int(id)
+	return nil
+}
+
+// GetByID возвращает ограничение или nil, если его нет
+func (r *SanctionRepository) GetByID(id int) (*models.Sanction, error) {
+	rows, err := r.db.Query(`SELECT `+sanctionColumns+` FROM sanctions WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	sanctions, err := scanSanctions(rows)
+	if err != nil || len(sanctions) == 0 {
+		return nil, err
+	}
+	return &sanctions[0], nil
+}
+
+// ListByUser возвращает все ограничения пользователя, начиная с последнего
+func (r *SanctionRepository) ListByUser(userID int) ([]models.Sanction, error) {
+	rows, err := r.db.Query(`
+		SELECT `+sanctionColumns+`
+		FROM sanctions
+		WHERE user_id = ?
+		ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanSanctions(rows)
+}
+
+// ListActive возвращает ограничения пользователя, действующие в момент now
+func (r *SanctionRepository) ListActive(userID int, now time.Time) ([]models.Sanction, error) {
+	rows, err := r.db.Query(`
+		SELECT `+sanctionColumns+`
+		FROM sanctions
+		WHERE user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY created_at DESC, id DESC`, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	return scanSanctions(rows)
+}
+
+// Revoke досрочно отменяет ограничение. Возвращает false, если оно уже
+// отменено или не существует.
+func (r *SanctionRepository) Revoke(id, revokedBy int, at time.Time) (bool, error) {
+	result, err := r.db.Exec(`UPDATE sanctions SET revoked_at = ?, revoked_by = ? WHERE id = ? AND revoked_at IS NULL`, at, revokedBy, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func scanSanctions(rows *sql.Rows) ([]models.Sanction, error) {
+	defer rows.Close()
+
+	sanctions := []models.Sanction{}
+	for rows.Next() {
+		var s models.Sanction
+		var moderatorID, revokedBy sql.NullInt64
+		var expiresAt, revokedAt sql.NullTime
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Type, &s.Reason, &moderatorID, &s.CreatedAt, &expiresAt, &revokedAt, &revokedBy); err != nil {
+			return nil, err
+		}
+		s.ModeratorID = nullIntPtr(moderatorID)
+		s.RevokedBy = nullIntPtr(revokedBy)
+		if expiresAt.Valid {
+			s.ExpiresAt = &expiresAt.Time
+		}
+		if revokedAt.Valid {
+			s.RevokedAt = &revokedAt.Time
+		}
+		sanctions = append(sanctions, s)
+	}
+	return sanctions, rows.Err()
+}
+
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	id := int(v.Int64)
+	return &id
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/auth/models"
+	"github.com/mos1rain/forum_go/pkg/database"
+	"github.com/mos1rain/forum_go/pkg/database/dbtest"
+	"github.com/mos1rain/forum_go/pkg/sanction"
+)
+
+func TestSanctionRepository(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewSanctionRepository(db)
+		userID := createTestUser(t, db)
+		moderator := &models.User{Username: "mod", Email: "mod@example.com", PasswordHash: "hash", Role: "moderator"}
+		if err := NewUserRepository(db).Create(moderator); err != nil {
+			t.Fatalf("Failed to create moderator: %v", err)
+		}
+		now := time.Now().UTC().Truncate(time.Second)
+		expired := now.Add(-time.Hour)
+		future := now.Add(time.Hour)
+
+		issued := []*models.Sanction{
+			{UserID: userID, Type: sanction.Suspension, Reason: "old", ModeratorID: &moderator.ID, CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: &expired},
+			{UserID: userID, Type: sanction.Mute, Reason: "flood", ModeratorID: &moderator.ID, CreatedAt: now.Add(-time.Minute), ExpiresAt: &future},
+			{UserID: userID, Type: sanction.Ban, Reason: "spam", CreatedAt: now},
+		}
+		for _, s := range issued {
+			if err := repo.Create(s); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if s.ID == 0 {
+				t.Fatal("Create() didn't set sanction ID")
+			}
+		}
+
+		all, err := repo.ListByUser(userID)
+		if err != nil {
+			t.Fatalf("ListByUser() error = %v", err)
+		}
+		if len(all) != 3 || all[0].Type != sanction.Ban || all[0].ModeratorID != nil || all[2].ExpiresAt == nil {
+			t.Fatalf("ListByUser() = %+v, want newest first", all)
+		}
+
+		active, err := repo.ListActive(userID, now)
+		if err != nil {
+			t.Fatalf("ListActive() error = %v", err)
+		}
+		if len(active) != 2 || active[0].Type != sanction.Ban || active[1].Type != sanction.Mute {
+			t.Fatalf("ListActive() = %+v, want ban and mute", active)
+		}
+
+		revoked, err := repo.Revoke(issued[2].ID, moderator.ID, now)
+		if err != nil || !revoked {
+			t.Fatalf("Revoke() = %v, %v", revoked, err)
+		}
+		if revoked, _ := repo.Revoke(issued[2].ID, moderator.ID, now); revoked {
+			t.Error("Revoke() of a revoked sanction must return false")
+		}
+
+		ban, err := repo.GetByID(issued[2].ID)
+		if err != nil {
+			t.Fatalf("GetByID() error = %v", err)
+		}
+		if ban.RevokedAt == nil || ban.RevokedBy == nil || *ban.RevokedBy != moderator.ID || ban.ActiveAt(now) {
+			t.Errorf("GetByID() = %+v, want revoked by moderator", ban)
+		}
+		if missing, err := repo.GetByID(9999); err != nil || missing != nil {
+			t.Errorf("GetByID(missing) = %+v, %v", missing, err)
+		}
+
+		if active, _ = repo.ListActive(userID, now); len(active) != 1 || active[0].Type != sanction.Mute {
+			t.Errorf("ListActive() after revoke = %+v, want only mute", active)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/auth/models"
+	"github.com/mos1rain/forum_go/pkg/database"
+)
+
+type TokenRepository struct {
+	db *database.DB
+}
+
+func NewTokenRepository(db *database.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+func (r *TokenRepository) Create(token *models.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id`
+
+	now := time.Now()
+	var id int64
+	err := r.db.QueryRow(query, token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt, now).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	token.ID = int(id)
+	token.CreatedAt = now
+
+	return nil
+}
+
+func (r *TokenRepository) GetByHash(hash string) (*models.RefreshToken, error) {
+	token := &models.RefreshToken{}
+	var usedAt, revokedAt sql.NullTime
+	query := `SELECT id, user_id, family_id, token_hash, expires_at, created_at, used_at, revoked_at FROM refresh_tokens WHERE token_hash = ?`
+
+	err := r.db.QueryRow(query, hash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+		&usedAt,
+		&revokedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+
+	return token, nil
+}
+
+// MarkUsed помечает токен использованным. Возвращает false, если токен
+// уже был использован ранее (например, параллельным запросом).
+func (r *TokenRepository) MarkUsed(id int) (bool, error) {
+	result, err := r.db.Exec(`UPDATE refresh_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL`, time.Now(), id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// RevokeFamily отзывает все токены семейства
+func (r *TokenRepository) RevokeFamily(familyID string) error {
+	_, err := r.db.Exec(`UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL`, time.Now(), familyID)
+	return err
+}
+
+// IsFamilyRevoked сообщает, отозвано ли семейство токенов (сессия)
+func (r *TokenRepository) IsFamilyRevoked(familyID string) (bool, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM refresh_tokens WHERE family_id = ? AND revoked_at IS NOT NULL`, familyID).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// RevokeUser отзывает все сессии пользователя
+func (r *TokenRepository) RevokeUser(userID int) error {
+	_, err := r.db.Exec(`UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`, time.Now(), userID)
+	return err
+}
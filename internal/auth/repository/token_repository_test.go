@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/auth/models"
+	"github.com/mos1rain/forum_go/pkg/database"
+	"github.com/mos1rain/forum_go/pkg/database/dbtest"
+)
+
+// createTestUser создаёт владельца токенов: в Postgres внешний ключ проверяется всегда
+func createTestUser(t *testing.T, db *database.DB) int {
+	t.Helper()
+	user := &models.User{Username: "owner", Email: "owner@example.com", PasswordHash: "hash", Role: "user"}
+	if err := NewUserRepository(db).Create(user); err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	return user.ID
+}
+
+func TestTokenRepository_Rotation(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewTokenRepository(db)
+		userID := createTestUser(t, db)
+
+		token := &models.RefreshToken{
+			UserID:    userID,
+			FamilyID:  "family",
+			TokenHash: "hash-1",
+			ExpiresAt: time.Now().Add(time.Hour),
+		}
+		if err := repo.Create(token); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if token.ID == 0 {
+			t.Fatal("Create() didn't set token ID")
+		}
+
+		got, err := repo.GetByHash("hash-1")
+		if err != nil || got == nil {
+			t.Fatalf("GetByHash() = %v, %v", got, err)
+		}
+		if got.UsedAt != nil || got.RevokedAt != nil {
+			t.Error("new token must be neither used nor revoked")
+		}
+
+		marked, err := repo.MarkUsed(token.ID)
+		if err != nil || !marked {
+			t.Fatalf("MarkUsed() = %v, %v, want true", marked, err)
+		}
+		marked, err = repo.MarkUsed(token.ID)
+		if err != nil || marked {
+			t.Fatalf("second MarkUsed() = %v, %v, want false", marked, err)
+		}
+
+		missing, err := repo.GetByHash("unknown")
+		if err != nil || missing != nil {
+			t.Errorf("GetByHash(unknown) = %v, %v, want nil", missing, err)
+		}
+	})
+}
+
+func TestTokenRepository_RevokeFamily(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewTokenRepository(db)
+		userID := createTestUser(t, db)
+
+		for _, hash := range []string{"hash-1", "hash-2"} {
+			if err := repo.Create(&models.RefreshToken{UserID: userID, FamilyID: "family", TokenHash: hash, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+		}
+
+		revoked, err := repo.IsFamilyRevoked("family")
+		if err != nil || revoked {
+			t.Fatalf("IsFamilyRevoked() = %v, %v, want false", revoked, err)
+		}
+
+		if err := repo.RevokeFamily("family"); err != nil {
+			t.Fatalf("RevokeFamily() error = %v", err)
+		}
+
+		revoked, err = repo.IsFamilyRevoked("family")
+		if err != nil || !revoked {
+			t.Fatalf("IsFamilyRevoked() = %v, %v, want true", revoked, err)
+		}
+
+		got, _ := repo.GetByHash("hash-2")
+		if got == nil || got.RevokedAt == nil {
+			t.Error("every token of the family must be revoked")
+		}
+	})
+}
@@ -6,33 +6,31 @@ import (
 	"time"
 
 	"github.com/mos1rain/forum_go/internal/auth/models"
+	"github.com/mos1rain/forum_go/pkg/database"
 )
 
 type UserRepository struct {
-	db *sql.DB
+	db *database.DB
 }
 
-func NewUserRepository(db *sql.DB) *UserRepository {
+func NewUserRepository(db *database.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
 func (r *UserRepository) Create(user *models.User) error {
 	query := `
 		INSERT INTO users (username, email, password_hash, role)
-		VALUES (?, ?, ?, ?)`
+		VALUES (?, ?, ?, ?)
+		RETURNING id`
 
-	result, err := r.db.Exec(
+	var id int64
+	err := r.db.QueryRow(
 		query,
 		user.Username,
 		user.Email,
 		user.PasswordHash,
 		user.Role,
-	)
-	if err != nil {
-		return err
-	}
-
-	id, err := result.LastInsertId()
+	).Scan(&id)
 	if err != nil {
 		return err
 	}
@@ -115,3 +113,77 @@ func (r *UserRepository) GetByID(id int) (*models.User, error) {
 
 	return user, nil
 }
+
+// UpdateRole меняет роль пользователя и в той же транзакции пишет запись
+// аудита. Возвращает nil, если роль уже была такой.
+func (r *UserRepository) UpdateRole(userID int, role string, changedBy int) (*models.RoleChange, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var oldRole string
+	if err := tx.QueryRow(`SELECT role FROM users WHERE id = ?`, userID).Scan(&oldRole); err != nil {
+		return nil, err
+	}
+	if oldRole == role {
+		return nil, nil
+	}
+
+	now := time.Now()
+	if _, err := tx.Exec(`UPDATE users SET role = ?, updated_at = ? WHERE id = ?`, role, now, userID); err != nil {
+		return nil, err
+	}
+
+	change := &models.RoleChange{UserID: userID, OldRole: oldRole, NewRole: role, ChangedBy: &changedBy, CreatedAt: now}
+	var id int64
+	err = tx.QueryRow(`
+		INSERT INTO role_changes (user_id, old_role, new_role, changed_by, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id`, userID, oldRole, role, changedBy, now).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	change.ID = int(id)
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// CountByRole возвращает число пользователей с ролью role
+func (r *UserRepository) CountByRole(role string) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM users WHERE role = ?`, role).Scan(&count)
+	return count, err
+}
+
+// ListRoleChanges возвращает историю смены ролей пользователя, начиная с последней
+func (r *UserRepository) ListRoleChanges(userID int) ([]models.RoleChange, error) {
+	rows, err := r.db.Query(`
+		SELECT id, user_id, old_role, new_role, changed_by, created_at
+		FROM role_changes
+		WHERE user_id = ?
+		ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []models.RoleChange{}
+	for rows.Next() {
+		var change models.RoleChange
+		var changedBy sql.NullInt64
+		if err := rows.Scan(&change.ID, &change.UserID, &change.OldRole, &change.NewRole, &changedBy, &change.CreatedAt); err != nil {
+			return nil, err
+		}
+		if changedBy.Valid {
+			id := int(changedBy.Int64)
+			change.ChangedBy = &id
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
@@ -1,272 +1,286 @@
 package repository
 
 import (
-	"database/sql"
 	"testing"
 	"time"
 
 	"github.com/mos1rain/forum_go/internal/auth/models"
-	_ "modernc.org/sqlite"
+	"github.com/mos1rain/forum_go/pkg/database"
+	"github.com/mos1rain/forum_go/pkg/database/dbtest"
 )
 
-func setupTestDB(t *testing.T) *sql.DB {
-	db, err := sql.Open("sqlite", ":memory:")
-	if err != nil {
-		t.Fatalf("Failed to open test database: %v", err)
-	}
-
-	// Создаем таблицу users
-	_, err = db.Exec(`
-		CREATE TABLE users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			username TEXT UNIQUE NOT NULL,
-			email TEXT UNIQUE NOT NULL,
-			password_hash TEXT NOT NULL,
-			role TEXT NOT NULL DEFAULT 'user',
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		t.Fatalf("Failed to create users table: %v", err)
-	}
-
-	return db
-}
-
 func TestUserRepository_Create(t *testing.T) {
-	db := setupTestDB(t)
-	defer db.Close()
-
-	repo := NewUserRepository(db)
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewUserRepository(db)
 
-	tests := []struct {
-		name    string
-		user    *models.User
-		wantErr bool
-	}{
-		{
-			name: "valid user",
-			user: &models.User{
-				Username:     "testuser",
-				Email:        "test@example.com",
-				PasswordHash: "hashedpassword",
-				Role:         "user",
+		tests := []struct {
+			name    string
+			user    *models.User
+			wantErr bool
+		}{
+			{
+				name: "valid user",
+				user: &models.User{
+					Username:     "testuser",
+					Email:        "test@example.com",
+					PasswordHash: "hashedpassword",
+					Role:         "user",
+				},
+				wantErr: false,
 			},
-			wantErr: false,
-		},
-		{
-			name: "duplicate username",
-			user: &models.User{
-				Username:     "testuser",
-				Email:        "another@example.com",
-				PasswordHash: "hashedpassword",
-				Role:         "user",
+			{
+				name: "duplicate username",
+				user: &models.User{
+					Username:     "testuser",
+					Email:        "another@example.com",
+					PasswordHash: "hashedpassword",
+					Role:         "user",
+				},
+				wantErr: true,
 			},
-			wantErr: true,
-		},
-		{
-			name: "duplicate email",
-			user: &models.User{
-				Username:     "anotheruser",
-				Email:        "test@example.com",
-				PasswordHash: "hashedpassword",
-				Role:         "user",
+			{
+				name: "duplicate email",
+				user: &models.User{
+					Username:     "anotheruser",
+					Email:        "test@example.com",
+					PasswordHash: "hashedpassword",
+					Role:         "user",
+				},
+				wantErr: true,
 			},
-			wantErr: true,
-		},
-	}
+		}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := repo.Create(tt.user)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
-			}
-			if !tt.wantErr {
-				if tt.user.ID == 0 {
-					t.Error("Create() didn't set user ID")
-				}
-				if tt.user.CreatedAt.IsZero() {
-					t.Error("Create() didn't set CreatedAt")
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				err := repo.Create(tt.user)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
 				}
-				if tt.user.UpdatedAt.IsZero() {
-					t.Error("Create() didn't set UpdatedAt")
+				if !tt.wantErr {
+					if tt.user.ID == 0 {
+						t.Error("Create() didn't set user ID")
+					}
+					if tt.user.CreatedAt.IsZero() {
+						t.Error("Create() didn't set CreatedAt")
+					}
+					if tt.user.UpdatedAt.IsZero() {
+						t.Error("Create() didn't set UpdatedAt")
+					}
 				}
-			}
-		})
-	}
+			})
+		}
+	})
 }
 
 func TestUserRepository_GetByUsername(t *testing.T) {
-	db := setupTestDB(t)
-	defer db.Close()
-
-	repo := NewUserRepository(db)
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewUserRepository(db)
 
-	// Создаем тестового пользователя
-	testUser := &models.User{
-		Username:     "testuser",
-		Email:        "test@example.com",
-		PasswordHash: "hashedpassword",
-		Role:         "user",
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
-	}
-	if err := repo.Create(testUser); err != nil {
-		t.Fatalf("Failed to create test user: %v", err)
-	}
+		// Создаем тестового пользователя
+		testUser := &models.User{
+			Username:     "testuser",
+			Email:        "test@example.com",
+			PasswordHash: "hashedpassword",
+			Role:         "user",
+			CreatedAt:    time.Now(),
+			UpdatedAt:    time.Now(),
+		}
+		if err := repo.Create(testUser); err != nil {
+			t.Fatalf("Failed to create test user: %v", err)
+		}
 
-	tests := []struct {
-		name     string
-		username string
-		want     *models.User
-		wantErr  bool
-	}{
-		{
-			name:     "existing user",
-			username: "testuser",
-			want:     testUser,
-			wantErr:  false,
-		},
-		{
-			name:     "non-existing user",
-			username: "nonexistent",
-			want:     nil,
-			wantErr:  false,
-		},
-	}
+		tests := []struct {
+			name     string
+			username string
+			want     *models.User
+			wantErr  bool
+		}{
+			{
+				name:     "existing user",
+				username: "testuser",
+				want:     testUser,
+				wantErr:  false,
+			},
+			{
+				name:     "non-existing user",
+				username: "nonexistent",
+				want:     nil,
+				wantErr:  false,
+			},
+		}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := repo.GetByUsername(tt.username)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("GetByUsername() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if tt.want == nil && got != nil {
-				t.Errorf("GetByUsername() got = %v, want nil", got)
-			}
-			if tt.want != nil && got != nil {
-				if got.Username != tt.want.Username {
-					t.Errorf("GetByUsername() got username = %v, want %v", got.Username, tt.want.Username)
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := repo.GetByUsername(tt.username)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("GetByUsername() error = %v, wantErr %v", err, tt.wantErr)
+					return
 				}
-				if got.Email != tt.want.Email {
-					t.Errorf("GetByUsername() got email = %v, want %v", got.Email, tt.want.Email)
+				if tt.want == nil && got != nil {
+					t.Errorf("GetByUsername() got = %v, want nil", got)
 				}
-			}
-		})
-	}
+				if tt.want != nil && got != nil {
+					if got.Username != tt.want.Username {
+						t.Errorf("GetByUsername() got username = %v, want %v", got.Username, tt.want.Username)
+					}
+					if got.Email != tt.want.Email {
+						t.Errorf("GetByUsername() got email = %v, want %v", got.Email, tt.want.Email)
+					}
+				}
+			})
+		}
+	})
 }
 
 func TestUserRepository_GetByEmail(t *testing.T) {
-	db := setupTestDB(t)
-	defer db.Close()
-
-	repo := NewUserRepository(db)
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewUserRepository(db)
 
-	// Создаем тестового пользователя
-	testUser := &models.User{
-		Username:     "testuser",
-		Email:        "test@example.com",
-		PasswordHash: "hashedpassword",
-		Role:         "user",
-	}
-	if err := repo.Create(testUser); err != nil {
-		t.Fatalf("Failed to create test user: %v", err)
-	}
+		// Создаем тестового пользователя
+		testUser := &models.User{
+			Username:     "testuser",
+			Email:        "test@example.com",
+			PasswordHash: "hashedpassword",
+			Role:         "user",
+		}
+		if err := repo.Create(testUser); err != nil {
+			t.Fatalf("Failed to create test user: %v", err)
+		}
 
-	tests := []struct {
-		name    string
-		email   string
-		want    *models.User
-		wantErr bool
-	}{
-		{
-			name:    "existing email",
-			email:   "test@example.com",
-			want:    testUser,
-			wantErr: false,
-		},
-		{
-			name:    "non-existing email",
-			email:   "nonexistent@example.com",
-			want:    nil,
-			wantErr: false,
-		},
-	}
+		tests := []struct {
+			name    string
+			email   string
+			want    *models.User
+			wantErr bool
+		}{
+			{
+				name:    "existing email",
+				email:   "test@example.com",
+				want:    testUser,
+				wantErr: false,
+			},
+			{
+				name:    "non-existing email",
+				email:   "nonexistent@example.com",
+				want:    nil,
+				wantErr: false,
+			},
+		}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := repo.GetByEmail(tt.email)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("GetByEmail() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if tt.want == nil && got != nil {
-				t.Errorf("GetByEmail() got = %v, want nil", got)
-			}
-			if tt.want != nil && got != nil {
-				if got.Email != tt.want.Email {
-					t.Errorf("GetByEmail() got email = %v, want %v", got.Email, tt.want.Email)
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := repo.GetByEmail(tt.email)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("GetByEmail() error = %v, wantErr %v", err, tt.wantErr)
+					return
 				}
-			}
-		})
-	}
+				if tt.want == nil && got != nil {
+					t.Errorf("GetByEmail() got = %v, want nil", got)
+				}
+				if tt.want != nil && got != nil {
+					if got.Email != tt.want.Email {
+						t.Errorf("GetByEmail() got email = %v, want %v", got.Email, tt.want.Email)
+					}
+				}
+			})
+		}
+	})
 }
 
 func TestUserRepository_GetByID(t *testing.T) {
-	db := setupTestDB(t)
-	defer db.Close()
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewUserRepository(db)
 
-	repo := NewUserRepository(db)
+		// Создаем тестового пользователя
+		testUser := &models.User{
+			Username:     "testuser",
+			Email:        "test@example.com",
+			PasswordHash: "hashedpassword",
+			Role:         "user",
+		}
+		if err := repo.Create(testUser); err != nil {
+			t.Fatalf("Failed to create test user: %v", err)
+		}
 
-	// Создаем тестового пользователя
-	testUser := &models.User{
-		Username:     "testuser",
-		Email:        "test@example.com",
-		PasswordHash: "hashedpassword",
-		Role:         "user",
-	}
-	if err := repo.Create(testUser); err != nil {
-		t.Fatalf("Failed to create test user: %v", err)
-	}
-
-	tests := []struct {
-		name    string
-		id      int
-		want    *models.User
-		wantErr bool
-	}{
-		{
-			name:    "existing id",
-			id:      testUser.ID,
-			want:    testUser,
-			wantErr: false,
-		},
-		{
-			name:    "non-existing id",
-			id:      9999,
-			want:    nil,
-			wantErr: false,
-		},
-	}
+		tests := []struct {
+			name    string
+			id      int
+			want    *models.User
+			wantErr bool
+		}{
+			{
+				name:    "existing id",
+				id:      testUser.ID,
+				want:    testUser,
+				wantErr: false,
+			},
+			{
+				name:    "non-existing id",
+				id:      9999,
+				want:    nil,
+				wantErr: false,
+			},
+		}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := repo.GetByID(tt.id)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("GetByID() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if tt.want == nil && got != nil {
-				t.Errorf("GetByID() got = %v, want nil", got)
-			}
-			if tt.want != nil && got != nil {
-				if got.ID != tt.want.ID {
-					t.Errorf("GetByID() got ID = %v, want %v", got.ID, tt.want.ID)
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := repo.GetByID(tt.id)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("GetByID() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if tt.want == nil && got != nil {
+					t.Errorf("GetByID() got = %v, want nil", got)
 				}
+				if tt.want != nil && got != nil {
+					if got.ID != tt.want.ID {
+						t.Errorf("GetByID() got ID = %v, want %v", got.ID, tt.want.ID)
+					}
+				}
+			})
+		}
+	})
+}
+
+func TestUserRepository_UpdateRole(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewUserRepository(db)
+		admin := &models.User{Username: "root", Email: "root@example.com", PasswordHash: "hash", Role: models.RoleAdmin}
+		user := &models.User{Username: "alice", Email: "alice@example.com", PasswordHash: "hash", Role: models.RoleUser}
+		for _, u := range []*models.User{admin, user} {
+			if err := repo.Create(u); err != nil {
+				t.Fatalf("Create() error = %v", err)
 			}
-		})
-	}
+		}
+
+		change, err := repo.UpdateRole(user.ID, models.RoleModerator, admin.ID)
+		if err != nil {
+			t.Fatalf("UpdateRole() error = %v", err)
+		}
+		if change == nil || change.OldRole != models.RoleUser || change.NewRole != models.RoleModerator || *change.ChangedBy != admin.ID {
+			t.Fatalf("UpdateRole() = %+v", change)
+		}
+		if change, err := repo.UpdateRole(user.ID, models.RoleModerator, admin.ID); err != nil || change != nil {
+			t.Errorf("UpdateRole() with the same role = %+v, %v; want nil", change, err)
+		}
+
+		got, _ := repo.GetByID(user.ID)
+		if got.Role != models.RoleModerator {
+			t.Errorf("expected role %q, got %q", models.RoleModerator, got.Role)
+		}
+		if count, err := repo.CountByRole(models.RoleAdmin); err != nil || count != 1 {
+			t.Errorf("CountByRole() = %d, %v; want 1", count, err)
+		}
+
+		if _, err := repo.UpdateRole(user.ID, models.RoleAdmin, admin.ID); err != nil {
+			t.Fatalf("UpdateRole() error = %v", err)
+		}
+		changes, err := repo.ListRoleChanges(user.ID)
+		if err != nil {
+			t.Fatalf("ListRoleChanges() error = %v", err)
+		}
+		if len(changes) != 2 || changes[0].NewRole != models.RoleAdmin || changes[1].NewRole != models.RoleModerator {
+			t.Errorf("ListRoleChanges() = %+v, want newest first", changes)
+		}
+	})
 }
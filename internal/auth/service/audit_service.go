@@ -0,0 +1,114 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/mos1rain/forum_go/internal/auth/models"
+	"github.com/mos1rain/forum_go/pkg/audit"
+)
+
+var ErrInvalidAuditFilter = errors.New("invalid audit filter")
+
+// Размер страницы журнала действий
+const (
+	DefaultAuditPageSize = 50
+	MaxAuditPageSize     = 500
+)
+
+// AuditLog журнал, в который пишутся действия модераторов и администраторов
+type AuditLog interface {
+	Create(entry *audit.Entry) error
+}
+
+type AuditRepo interface {
+	AuditLog
+	List(filter models.AuditFilter, limit int, beforeID int64) ([]audit.Entry, error)
+	Export(filter models.AuditFilter, fn func(*audit.Entry) error) error
+}
+
+type AuditServiceInterface interface {
+	List(filter models.AuditFilter, limit int, cursor string) (*models.AuditPage, error)
+	Export(filter models.AuditFilter, fn func(*audit.Entry) error) error
+}
+
+// AuditService просмотр и выгрузка журнала действий всех сервисов.
+// Записи в журнале не меняются и не удаляются.
+type AuditService struct {
+	repo AuditRepo
+}
+
+func NewAuditService(repo AuditRepo) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// List возвращает страницу журнала по filter, начиная с последних записей.
+// cursor — NextCursor предыдущей страницы.
+func (s *AuditService) List(filter models.AuditFilter, limit int, cursor string) (*models.AuditPage, error) {
+	if err := validateAuditFilter(filter); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = DefaultAuditPageSize
+	}
+	limit = min(limit, MaxAuditPageSize)
+	var beforeID int64
+	if cursor != "" {
+		id, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("%w: invalid cursor", ErrInvalidAuditFilter)
+		}
+		beforeID = id
+	}
+
+	// Лишняя запись показывает, есть ли следующая страница
+	entries, err := s.repo.List(filter, limit+1, beforeID)
+	if err != nil {
+		return nil, err
+	}
+	page := &models.AuditPage{Items: entries}
+	if len(entries) > limit {
+		page.Items = entries[:limit]
+		page.NextCursor = strconv.FormatInt(page.Items[limit-1].ID, 10)
+	}
+	return page, nil
+}
+
+// Export передаёт fn все записи по filter в порядке добавления
+func (s *AuditService) Export(filter models.AuditFilter, fn func(*audit.Entry) error) error {
+	if err := validateAuditFilter(filter); err != nil {
+		return err
+	}
+	return s.repo.Export(filter, fn)
+}
+
+func validateAuditFilter(filter models.AuditFilter) error {
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return fmt.Errorf("%w: from must be before to", ErrInvalidAuditFilter)
+	}
+	if filter.TargetID != nil && filter.TargetType == "" {
+		return fmt.Errorf("%w: target_id requires target_type", ErrInvalidAuditFilter)
+	}
+	return nil
+}
+
+// recordAudit пишет в log действие actorID над объектом auth-сервиса;
+// before — состояние объекта до действия. Смена роли и снятие блокировки
+// записываются после изменения, чтобы в журнале не оставалось
+// несостоявшихся действий, а ограничения — перед ним: без записи в журнале
+// они не выдаются. Без журнала ничего не делает.
+func recordAudit(log AuditLog, actorID int, action, targetType string, targetID int, before any, reason string) error {
+	if log == nil {
+		return nil
+	}
+	return log.Create(&audit.Entry{
+		Service:    audit.ServiceAuth,
+		ActorID:    int64(actorID),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   int64(targetID),
+		Snapshot:   audit.Snapshot(before),
+		Reason:     reason,
+	})
+}
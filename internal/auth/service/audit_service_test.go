@@ -0,0 +1,141 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/auth/models"
+	"github.com/mos1rain/forum_go/pkg/audit"
+	"github.com/mos1rain/forum_go/pkg/sanction"
+)
+
+// mockAuditRepo хранит записи в порядке добавления; с err журнал недоступен
+type mockAuditRepo struct {
+	entries []audit.Entry
+	err     error
+}
+
+var _ AuditRepo = (*mockAuditRepo)(nil)
+
+func (m *mockAuditRepo) Create(entry *audit.Entry) error {
+	if m.err != nil {
+		return m.err
+	}
+	entry.ID = int64(len(m.entries) + 1)
+	m.entries = append(m.entries, *entry)
+	return nil
+}
+func (m *mockAuditRepo) List(filter models.AuditFilter, limit int, beforeID int64) ([]audit.Entry, error) {
+	out := []audit.Entry{}
+	for i := len(m.entries) - 1; i >= 0 && len(out) < limit; i-- {
+		e := m.entries[i]
+		if (beforeID == 0 || e.ID < beforeID) && (filter.ActorID == nil || e.ActorID == *filter.ActorID) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+func (m *mockAuditRepo) Export(filter models.AuditFilter, fn func(*audit.Entry) error) error {
+	for i := range m.entries {
+		if err := fn(&m.entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestAuditList(t *testing.T) {
+	repo := &mockAuditRepo{}
+	for i := 0; i < 5; i++ {
+		repo.Create(&audit.Entry{Service: audit.ServiceForum, ActorID: 1, Action: audit.ActionDeletePost, TargetType: audit.TargetPost, TargetID: int64(i)})
+	}
+	s := NewAuditService(repo)
+
+	page, err := s.List(models.AuditFilter{}, 2, "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(page.Items) != 2 || page.Items[0].ID != 5 || page.NextCursor != "4" {
+		t.Fatalf("unexpected first page: %+v", page)
+	}
+	page, err = s.List(models.AuditFilter{}, 2, "2")
+	if err != nil || len(page.Items) != 1 || page.Items[0].ID != 1 || page.NextCursor != "" {
+		t.Errorf("unexpected last page: %+v, %v", page, err)
+	}
+
+	now := time.Now()
+	target := int64(3)
+	for name, tt := range map[string]struct {
+		filter models.AuditFilter
+		cursor string
+	}{
+		"bad cursor":          {cursor: "abc"},
+		"empty range":         {filter: models.AuditFilter{From: now, To: now}},
+		"target id w/o type":  {filter: models.AuditFilter{TargetID: &target}},
+		"reversed time range": {filter: models.AuditFilter{From: now, To: now.Add(-time.Hour)}},
+	} {
+		if _, err := s.List(tt.filter, 0, tt.cursor); !errors.Is(err, ErrInvalidAuditFilter) {
+			t.Errorf("%s: expected ErrInvalidAuditFilter, got %v", name, err)
+		}
+	}
+}
+
+func TestAuditRecordedByAuth(t *testing.T) {
+	log := &mockAuditRepo{}
+
+	users, repo, _ := newRoleService()
+	users.EnableAudit(log)
+	if _, err := users.ChangeRole(1, 2, models.RoleModerator); err != nil {
+		t.Fatalf("ChangeRole: %v", err)
+	}
+
+	sanctions, _, _, _ := newSanctionService()
+	sanctions.EnableAudit(log)
+	issued, err := sanctions.Issue(2, 3, models.IssueSanctionInput{Type: sanction.Mute, Reason: "flood"})
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if _, err := sanctions.Revoke(2, issued.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+
+	want := []struct {
+		action, targetType string
+		actorID, targetID  int64
+		reason             string
+	}{
+		{audit.ActionSetRole, audit.TargetUser, 1, 2, "role user -> moderator"},
+		{audit.ActionMuteUser, audit.TargetUser, 2, 3, "flood"},
+		{audit.ActionRevokeSanction, audit.TargetSanction, 2, int64(issued.ID), ""},
+	}
+	if len(log.entries) != len(want) {
+		t.Fatalf("expected %d entries, got %+v", len(want), log.entries)
+	}
+	for i, w := range want {
+		e := log.entries[i]
+		if e.Service != audit.ServiceAuth || e.Action != w.action || e.TargetType != w.targetType ||
+			e.ActorID != w.actorID || e.TargetID != w.targetID || e.Reason != w.reason || len(e.Snapshot) == 0 {
+			t.Errorf("entry %d: expected %+v, got %+v", i, w, e)
+		}
+	}
+
+	// Несостоявшаяся смена роли в журнал не попадает
+	entries := len(log.entries)
+	repo.updateErr = errors.New("database is locked")
+	if _, err := users.ChangeRole(1, 2, models.RoleAdmin); err == nil {
+		t.Fatal("expected ChangeRole to fail")
+	}
+	if len(log.entries) != entries {
+		t.Errorf("failed role change must not be recorded, got %+v", log.entries[entries:])
+	}
+
+	// Без журнала ограничение не выдаётся
+	log.err = errors.New("disk full")
+	if _, err := sanctions.Issue(2, 3, models.IssueSanctionInput{Type: sanction.Warning, Reason: "rude"}); err == nil {
+		t.Fatal("expected error when the audit log is unavailable")
+	}
+	if list, _ := sanctions.List(3); len(list) != 1 {
+		t.Errorf("expected no new sanction, got %+v", list)
+	}
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/auth/models"
+	"github.com/mos1rain/forum_go/pkg/audit"
+	"github.com/mos1rain/forum_go/pkg/rbac"
+	"github.com/mos1rain/forum_go/pkg/sanction"
+)
+
+var (
+	ErrInvalidSanction  = errors.New("invalid sanction")
+	ErrSanctionNotFound = errors.New("sanction not found")
+	// ErrSanctionInactive ограничение уже отменено или истекло
+	ErrSanctionInactive = errors.New("sanction is not active")
+	// ErrSanctioned вход запрещён действующим ограничением; возвращается
+	// обёрнутым в *SanctionedError
+	ErrSanctioned = errors.New("account is restricted")
+)
+
+// SanctionedError сообщает, какое ограничение запрещает вход
+type SanctionedError struct {
+	Sanction sanction.Active
+}
+
+func (e *SanctionedError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrSanctioned, e.Sanction.Type)
+}
+
+func (e *SanctionedError) Unwrap() error {
+	return ErrSanctioned
+}
+
+type SanctionRepo interface {
+	Create(s *models.Sanction) error
+	GetByID(id int) (*models.Sanction, error)
+	ListByUser(userID int) ([]models.Sanction, error)
+	ListActive(userID int, now time.Time) ([]models.Sanction, error)
+	Revoke(id, revokedBy int, at time.Time) (bool, error)
+}
+
+type SanctionServiceInterface interface {
+	Issue(actorID, userID int, input models.IssueSanctionInput) (*models.Sanction, error)
+	Revoke(actorID, sanctionID int) (*models.Sanction, error)
+	List(userID int) ([]models.Sanction, error)
+}
+
+// SanctionService выдаёт и отменяет ограничения. Право ban_user
+// проверяется по роли модератора в базе, а не по токену, и ограничить
+// можно только пользователя с меньшими полномочиями.
+type SanctionService struct {
+	repo  SanctionRepo
+	users UserRepo
+	audit AuditLog
+	now   func() time.Time
+}
+
+func NewSanctionService(repo SanctionRepo, users UserRepo) *SanctionService {
+	return &SanctionService{repo: repo, users: users, now: time.Now}
+}
+
+// EnableAudit записывает выдачу и отмену ограничений в журнал действий
+func (s *SanctionService) EnableAudit(log AuditLog) {
+	s.audit = log
+}
+
+// Действие журнала для каждого вида ограничения
+var sanctionActions = map[sanction.Type]string{
+	sanction.Ban:        audit.ActionBanUser,
+	sanction.Suspension: audit.ActionSuspendUser,
+	sanction.Mute:       audit.ActionMuteUser,
+	sanction.Warning:    audit.ActionWarnUser,
+}
+
+// Issue ограничивает пользователя userID от имени модератора actorID
+func (s *SanctionService) Issue(actorID, userID int, input models.IssueSanctionInput) (*models.Sanction, error) {
+	reason := strings.TrimSpace(input.Reason)
+	if !input.Type.Valid() {
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidSanction, input.Type)
+	}
+	if reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidSanction)
+	}
+	var duration time.Duration
+	if input.Duration != "" {
+		d, err := time.ParseDuration(input.Duration)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%w: invalid duration %q", ErrInvalidSanction, input.Duration)
+		}
+		duration = d
+	}
+	switch {
+	case input.Type == sanction.Ban && duration > 0:
+		return nil, fmt.Errorf("%w: ban is permanent, use suspension", ErrInvalidSanction)
+	case input.Type == sanction.Warning && duration > 0:
+		return nil, fmt.Errorf("%w: warning has no duration", ErrInvalidSanction)
+	case input.Type == sanction.Suspension && duration == 0:
+		return nil, fmt.Errorf("%w: suspension requires duration", ErrInvalidSanction)
+	}
+
+	actor, err := s.authorize(actorID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.users.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if models.RoleRank(user.Role) >= models.RoleRank(actor.Role) {
+		return nil, ErrForbidden
+	}
+
+	now := s.now()
+	issued := &models.Sanction{
+		UserID:      user.ID,
+		Type:        input.Type,
+		Reason:      reason,
+		ModeratorID: &actor.ID,
+		CreatedAt:   now,
+	}
+	if duration > 0 {
+		expires := now.Add(duration)
+		issued.ExpiresAt = &expires
+	}
+	// У нового ограничения нет прежнего состояния: в журнал попадает само ограничение
+	if err := recordAudit(s.audit, actor.ID, sanctionActions[issued.Type], audit.TargetUser, user.ID, issued, reason); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(issued); err != nil {
+		return nil, err
+	}
+	return issued, nil
+}
+
+// Revoke досрочно отменяет действующее ограничение
+func (s *SanctionService) Revoke(actorID, sanctionID int) (*models.Sanction, error) {
+	actor, err := s.authorize(actorID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.repo.GetByID(sanctionID)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, ErrSanctionNotFound
+	}
+	user, err := s.users.GetByID(existing.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil && models.RoleRank(user.Role) >= models.RoleRank(actor.Role) {
+		return nil, ErrForbidden
+	}
+
+	now := s.now()
+	if !existing.ActiveAt(now) {
+		return nil, ErrSanctionInactive
+	}
+	if err := recordAudit(s.audit, actor.ID, audit.ActionRevokeSanction, audit.TargetSanction, existing.ID, existing, ""); err != nil {
+		return nil, err
+	}
+	revoked, err := s.repo.Revoke(existing.ID, actor.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !revoked {
+		return nil, ErrSanctionInactive
+	}
+	existing.RevokedAt = &now
+	existing.RevokedBy = &actor.ID
+	return existing, nil
+}
+
+// List возвращает все ограничения пользователя, включая истёкшие и отменённые
+func (s *SanctionService) List(userID int) ([]models.Sanction, error) {
+	user, err := s.users.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return s.repo.ListByUser(userID)
+}
+
+// Active возвращает действующие ограничения пользователя. Предупреждения
+// ничего не запрещают и в результат не попадают.
+func (s *SanctionService) Active(userID int) ([]sanction.Active, error) {
+	sanctions, err := s.repo.ListActive(userID, s.now())
+	if err != nil {
+		return nil, err
+	}
+	active := make([]sanction.Active, 0, len(sanctions))
+	for _, sn := range sanctions {
+		if sn.Type == sanction.Warning {
+			continue
+		}
+		active = append(active, sanction.Active{Type: sn.Type, Reason: sn.Reason, ExpiresAt: sn.ExpiresAt})
+	}
+	return active, nil
+}
+
+// Restriction возвращает самое строгое ограничение пользователя в scope
+// или nil, если ограничений нет
+func (s *SanctionService) Restriction(userID int, scope sanction.Scope) (*sanction.Active, error) {
+	active, err := s.Active(userID)
+	if err != nil {
+		return nil, err
+	}
+	return sanction.Find(active, scope), nil
+}
+
+func (s *SanctionService) authorize(actorID int) (*models.User, error) {
+	actor, err := s.users.GetByID(actorID)
+	if err != nil {
+		return nil, err
+	}
+	if actor == nil || !rbac.Default.Can(actor.Role, rbac.BanUser) {
+		return nil, ErrForbidden
+	}
+	return actor, nil
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/auth/models"
+	"github.com/mos1rain/forum_go/pkg/sanction"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type mockSanctionRepo struct {
+	sanctions []*models.Sanction
+}
+
+var _ SanctionRepo = (*mockSanctionRepo)(nil)
+
+func (m *mockSanctionRepo) Create(s *models.Sanction) error {
+	s.ID = len(m.sanctions) + 1
+	m.sanctions = append(m.sanctions, s)
+	return nil
+}
+func (m *mockSanctionRepo) GetByID(id int) (*models.Sanction, error) {
+	for _, s := range m.sanctions {
+		if s.ID == id {
+			copied := *s
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+func (m *mockSanctionRepo) ListByUser(userID int) ([]models.Sanction, error) {
+	out := []models.Sanction{}
+	for i := len(m.sanctions) - 1; i >= 0; i-- {
+		if m.sanctions[i].UserID == userID {
+			out = append(out, *m.sanctions[i])
+		}
+	}
+	return out, nil
+}
+func (m *mockSanctionRepo) ListActive(userID int, now time.Time) ([]models.Sanction, error) {
+	out := []models.Sanction{}
+	for _, s := range m.sanctions {
+		if s.UserID == userID && s.ActiveAt(now) {
+			out = append(out, *s)
+		}
+	}
+	return out, nil
+}
+func (m *mockSanctionRepo) Revoke(id, revokedBy int, at time.Time) (bool, error) {
+	for _, s := range m.sanctions {
+		if s.ID == id && s.RevokedAt == nil {
+			s.RevokedAt, s.RevokedBy = &at, &revokedBy
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func newSanctionService() (*SanctionService, *mockUserRepo, *mockSanctionRepo, *time.Time) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	users := &mockUserRepo{users: map[string]*models.User{
+		"root":  {ID: 1, Username: "root", Role: models.RoleAdmin},
+		"mod":   {ID: 2, Username: "mod", Role: models.RoleModerator},
+		"alice": {ID: 3, Username: "alice", Role: models.RoleUser, PasswordHash: string(hash)},
+		"bob":   {ID: 4, Username: "bob", Role: models.RoleModerator},
+	}}
+	repo := &mockSanctionRepo{}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := NewSanctionService(repo, users)
+	s.now = func() time.Time { return now }
+	return s, users, repo, &now
+}
+
+func TestSanctionIssue_Validation(t *testing.T) {
+	s, _, _, _ := newSanctionService()
+	for _, input := range []models.IssueSanctionInput{
+		{Type: "kick", Reason: "spam"},
+		{Type: sanction.Ban, Reason: "  "},
+		{Type: sanction.Ban, Reason: "spam", Duration: "24h"},
+		{Type: sanction.Suspension, Reason: "spam"},
+		{Type: sanction.Mute, Reason: "flood", Duration: "soon"},
+		{Type: sanction.Mute, Reason: "flood", Duration: "-1h"},
+		{Type: sanction.Warning, Reason: "rude", Duration: "1h"},
+	} {
+		if _, err := s.Issue(2, 3, input); !errors.Is(err, ErrInvalidSanction) {
+			t.Errorf("Issue(%+v): expected ErrInvalidSanction, got %v", input, err)
+		}
+	}
+}
+
+func TestSanctionIssue(t *testing.T) {
+	s, _, _, now := newSanctionService()
+
+	issued, err := s.Issue(2, 3, models.IssueSanctionInput{Type: sanction.Suspension, Reason: " spam ", Duration: "72h"})
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if issued.Reason != "spam" || *issued.ModeratorID != 2 || !issued.ExpiresAt.Equal(now.Add(72*time.Hour)) {
+		t.Errorf("unexpected sanction %+v", issued)
+	}
+
+	restriction, err := s.Restriction(3, sanction.Forum)
+	if err != nil || restriction == nil || restriction.Type != sanction.Suspension {
+		t.Fatalf("Restriction() = %+v, %v, want suspension", restriction, err)
+	}
+
+	*now = now.Add(73 * time.Hour)
+	if restriction, _ := s.Restriction(3, sanction.Forum); restriction != nil {
+		t.Errorf("expired suspension must not restrict, got %+v", restriction)
+	}
+}
+
+func TestSanctionIssue_Forbidden(t *testing.T) {
+	s, _, _, _ := newSanctionService()
+	input := models.IssueSanctionInput{Type: sanction.Ban, Reason: "spam"}
+
+	// Обычный пользователь не может ограничивать, модератор — равного себе
+	// или старшего
+	for _, tc := range []struct{ actor, target int }{{3, 4}, {2, 4}, {2, 1}} {
+		if _, err := s.Issue(tc.actor, tc.target, input); !errors.Is(err, ErrForbidden) {
+			t.Errorf("Issue(%d -> %d): expected ErrForbidden, got %v", tc.actor, tc.target, err)
+		}
+	}
+	if _, err := s.Issue(1, 4, input); err != nil {
+		t.Errorf("admin must be able to ban a moderator: %v", err)
+	}
+	if _, err := s.Issue(1, 99, input); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestSanctionRevoke(t *testing.T) {
+	s, _, _, _ := newSanctionService()
+	issued, err := s.Issue(2, 3, models.IssueSanctionInput{Type: sanction.Ban, Reason: "spam"})
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	if _, err := s.Revoke(3, issued.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("user must not revoke sanctions, got %v", err)
+	}
+	revoked, err := s.Revoke(1, issued.ID)
+	if err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if revoked.RevokedAt == nil || *revoked.RevokedBy != 1 {
+		t.Errorf("unexpected revoked sanction %+v", revoked)
+	}
+	if _, err := s.Revoke(1, issued.ID); !errors.Is(err, ErrSanctionInactive) {
+		t.Errorf("expected ErrSanctionInactive, got %v", err)
+	}
+	if _, err := s.Revoke(1, 99); !errors.Is(err, ErrSanctionNotFound) {
+		t.Errorf("expected ErrSanctionNotFound, got %v", err)
+	}
+	if restriction, _ := s.Restriction(3, sanction.Forum); restriction != nil {
+		t.Errorf("revoked ban must not restrict, got %+v", restriction)
+	}
+
+	history, err := s.List(3)
+	if err != nil || len(history) != 1 {
+		t.Errorf("List() = %+v, %v, want revoked ban kept in history", history, err)
+	}
+}
+
+func TestLogin_Sanctioned(t *testing.T) {
+	sanctions, users, _, _ := newSanctionService()
+	s := NewUserService(users, newMockTokenRepo(), newTestTokenManager(), time.Minute, time.Hour)
+	s.EnableSanctions(sanctions)
+	login := models.LoginInput{Username: "alice", Password: "password"}
+
+	resp, err := s.Login(login)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	// Запрет писать в чат не мешает входу
+	if _, err := sanctions.Issue(2, 3, models.IssueSanctionInput{Type: sanction.Mute, Reason: "flood"}); err != nil {
+		t.Fatalf("mute: %v", err)
+	}
+	if _, err := s.Login(login); err != nil {
+		t.Fatalf("muted user must be able to log in: %v", err)
+	}
+
+	if _, err := sanctions.Issue(2, 3, models.IssueSanctionInput{Type: sanction.Ban, Reason: "spam"}); err != nil {
+		t.Fatalf("ban: %v", err)
+	}
+	var sanctioned *SanctionedError
+	if _, err := s.Login(login); !errors.As(err, &sanctioned) || sanctioned.Sanction.Type != sanction.Ban || sanctioned.Sanction.Reason != "spam" {
+		t.Fatalf("expected SanctionedError with ban, got %v", err)
+	}
+	if !errors.Is(sanctioned, ErrSanctioned) {
+		t.Error("SanctionedError must unwrap to ErrSanctioned")
+	}
+	if _, err := s.Refresh(models.RefreshInput{RefreshToken: resp.RefreshToken}); !errors.Is(err, ErrSanctioned) {
+		t.Errorf("expected refresh to be blocked, got %v", err)
+	}
+}
@@ -2,9 +2,15 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/mos1rain/forum_go/internal/auth/models"
+	"github.com/mos1rain/forum_go/pkg/audit"
+	"github.com/mos1rain/forum_go/pkg/jwt"
+	"github.com/mos1rain/forum_go/pkg/rbac"
+	"github.com/mos1rain/forum_go/pkg/sanction"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -12,44 +18,178 @@ var (
 	ErrUserAlreadyExists  = errors.New("user already exists")
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrInvalidInput       = errors.New("invalid input")
+	// ErrInvalidRefreshToken refresh-токен не найден, истёк или отозван
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused повторное использование уже ротированного токена;
+	// всё семейство токенов при этом отзывается
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	// ErrAccountLocked вход временно запрещён после серии неудачных попыток;
+	// возвращается обёрнутым в *LockedError
+	ErrAccountLocked = errors.New("too many failed login attempts")
+	ErrUserNotFound  = errors.New("user not found")
+	ErrInvalidRole   = errors.New("invalid role")
+	// ErrForbidden действие доступно только администратору
+	ErrForbidden = errors.New("forbidden")
+	// ErrLastAdmin понижение единственного администратора оставило бы
+	// сервис без администраторов
+	ErrLastAdmin = errors.New("cannot demote the last admin")
 )
 
+// LockedError сообщает, через сколько можно повторить вход
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrAccountLocked, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockedError) Unwrap() error {
+	return ErrAccountLocked
+}
+
 type UserRepo interface {
 	GetByUsername(username string) (*models.User, error)
 	GetByEmail(email string) (*models.User, error)
 	GetByID(id int) (*models.User, error)
 	Create(user *models.User) error
+	UpdateRole(userID int, role string, changedBy int) (*models.RoleChange, error)
+	CountByRole(role string) (int, error)
+	ListRoleChanges(userID int) ([]models.RoleChange, error)
+}
+
+type TokenRepo interface {
+	Create(token *models.RefreshToken) error
+	GetByHash(hash string) (*models.RefreshToken, error)
+	MarkUsed(id int) (bool, error)
+	RevokeFamily(familyID string) error
+	RevokeUser(userID int) error
+}
+
+type AttemptRepo interface {
+	Create(attempt *models.LoginAttempt) error
+	ListByUser(userID, limit int) ([]models.LoginAttempt, error)
+	NthFailureByIP(ip string, n int, since time.Time) (*time.Time, error)
+	GetLockout(userID int) (*models.Lockout, error)
+	SaveLockout(lockout *models.Lockout) error
+	ResetLockout(userID int) (bool, error)
 }
 
 type TokenManager interface {
-	NewJWTWithRole(userID int, username, role string, ttl time.Duration) (string, error)
+	NewAccessToken(userID int, username, role, sessionID string, ttl time.Duration) (string, error)
 }
 
 type UserServiceInterface interface {
 	Register(input models.CreateUserInput) (*AuthResponse, error)
 	Login(input models.LoginInput) (*AuthResponse, error)
+	Refresh(input models.RefreshInput) (*AuthResponse, error)
+	Logout(input models.RefreshInput) error
+	Unlock(actorID, userID int) error
+	RecentSignIns(userID, limit int) ([]models.LoginAttempt, error)
+	ChangeRole(actorID, userID int, role string) (*models.User, error)
+	RoleChanges(userID int) ([]models.RoleChange, error)
+}
+
+// LockoutPolicy правила блокировки входа. После Threshold неудачных
+// попыток подряд учётная запись блокируется на BaseDuration, и каждая
+// следующая неудача удваивает срок, но не больше MaxDuration. Отдельно
+// IPThreshold неудач с одного адреса за IPWindow блокируют вход с него.
+// Нулевой порог отключает соответствующую блокировку.
+type LockoutPolicy struct {
+	Threshold    int
+	BaseDuration time.Duration
+	MaxDuration  time.Duration
+	IPThreshold  int
+	IPWindow     time.Duration
+}
+
+// Duration срок блокировки после failures неудачных попыток подряд
+func (p LockoutPolicy) Duration(failures int) time.Duration {
+	if p.Threshold <= 0 || failures < p.Threshold {
+		return 0
+	}
+	d := p.BaseDuration
+	for i := p.Threshold; i < failures && d < p.MaxDuration; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxDuration)
 }
 
 type UserService struct {
 	repo         UserRepo
+	tokens       TokenRepo
 	tokenManager TokenManager
-	tokenTTL     time.Duration
+	accessTTL    time.Duration
+	refreshTTL   time.Duration
+
+	attempts  AttemptRepo
+	lockout   LockoutPolicy
+	sanctions SanctionChecker
+	audit     AuditLog
+	now       func() time.Time
+}
+
+// SanctionChecker сообщает о действующих ограничениях пользователя
+type SanctionChecker interface {
+	Restriction(userID int, scope sanction.Scope) (*sanction.Active, error)
 }
 
-func NewUserService(repo UserRepo, tokenManager TokenManager, tokenTTL time.Duration) *UserService {
+func NewUserService(repo UserRepo, tokens TokenRepo, tokenManager TokenManager, accessTTL, refreshTTL time.Duration) *UserService {
 	return &UserService{
 		repo:         repo,
+		tokens:       tokens,
 		tokenManager: tokenManager,
-		tokenTTL:     tokenTTL,
+		accessTTL:    accessTTL,
+		refreshTTL:   refreshTTL,
+		now:          time.Now,
+	}
+}
+
+// EnableLockout включает журнал входов и блокировку по policy
+func (s *UserService) EnableLockout(attempts AttemptRepo, policy LockoutPolicy) {
+	s.attempts = attempts
+	s.lockout = policy
+}
+
+// EnableSanctions запрещает вход и обновление токенов забаненным и
+// отстранённым пользователям
+func (s *UserService) EnableSanctions(sanctions SanctionChecker) {
+	s.sanctions = sanctions
+}
+
+// EnableAudit записывает смену ролей и снятие блокировок в журнал действий
+func (s *UserService) EnableAudit(log AuditLog) {
+	s.audit = log
+}
+
+// checkSanctions возвращает *SanctionedError, если пользователю запрещён вход
+func (s *UserService) checkSanctions(userID int) error {
+	if s.sanctions == nil {
+		return nil
+	}
+	restriction, err := s.sanctions.Restriction(userID, sanction.Forum)
+	if err != nil {
+		return err
 	}
+	if restriction != nil {
+		return &SanctionedError{Sanction: *restriction}
+	}
+	return nil
 }
 
 type AuthResponse struct {
-	User  *models.User `json:"user"`
-	Token string       `json:"token"`
+	User         *models.User `json:"user"`
+	Token        string       `json:"token"`
+	RefreshToken string       `json:"refresh_token"`
+	// ExpiresIn время жизни access-токена в секундах
+	ExpiresIn int64 `json:"expires_in"`
 }
 
 func (s *UserService) Register(input models.CreateUserInput) (*AuthResponse, error) {
+	if strings.TrimSpace(input.Username) == "" || !strings.Contains(input.Email, "@") || input.Password == "" {
+		return nil, ErrInvalidInput
+	}
+
 	// Проверяем, существует ли пользователь с таким username
 	if user, _ := s.repo.GetByUsername(input.Username); user != nil {
 		return nil, ErrUserAlreadyExists
@@ -66,57 +206,355 @@ func (s *UserService) Register(input models.CreateUserInput) (*AuthResponse, err
 		return nil, err
 	}
 
-	role := input.Role
-	if role == "" {
-		role = "user"
-	}
-
+	// Роль при регистрации всегда user: повысить её может только администратор
 	user := &models.User{
 		Username:     input.Username,
 		Email:        input.Email,
 		PasswordHash: string(hashedPassword),
-		Role:         role,
+		Role:         models.RoleUser,
 	}
 
 	if err := s.repo.Create(user); err != nil {
 		return nil, err
 	}
 
-	// Генерируем токен с ролью
-	token, err := s.tokenManager.NewJWTWithRole(user.ID, user.Username, user.Role, s.tokenTTL)
+	sessionID, err := jwt.NewSessionID()
 	if err != nil {
 		return nil, err
 	}
 
-	return &AuthResponse{
-		User:  user,
-		Token: token,
-	}, nil
+	return s.issueTokens(user, sessionID)
 }
 
 func (s *UserService) Login(input models.LoginInput) (*AuthResponse, error) {
+	now := s.now()
+	if err := s.checkIPLockout(input.IP, now); err != nil {
+		return nil, err
+	}
+
 	user, err := s.repo.GetByUsername(input.Username)
 	if err != nil {
 		return nil, err
 	}
 
 	if user == nil {
+		if err := s.recordAttempt(nil, input, false, now); err != nil {
+			return nil, err
+		}
 		return nil, ErrInvalidCredentials
 	}
 
+	var lockout *models.Lockout
+	if s.attempts != nil {
+		if lockout, err = s.attempts.GetLockout(user.ID); err != nil {
+			return nil, err
+		}
+	}
+	// Пока учётная запись заблокирована, пароль не проверяется вовсе
+	if lockout != nil && lockout.LockedUntil != nil && now.Before(*lockout.LockedUntil) {
+		if err := s.recordAttempt(user, input, false, now); err != nil {
+			return nil, err
+		}
+		return nil, &LockedError{RetryAfter: lockout.LockedUntil.Sub(now)}
+	}
+
 	// Проверяем пароль
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password)); err != nil {
-		return nil, ErrInvalidCredentials
+		if err := s.recordAttempt(user, input, false, now); err != nil {
+			return nil, err
+		}
+		return nil, s.registerFailure(user.ID, lockout, now)
 	}
 
+	if lockout != nil {
+		if _, err := s.attempts.ResetLockout(user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Ограничение сообщается только тому, кто знает пароль
+	if err := s.checkSanctions(user.ID); err != nil {
+		if recErr := s.recordAttempt(user, input, false, now); recErr != nil {
+			return nil, recErr
+		}
+		return nil, err
+	}
+	if err := s.recordAttempt(user, input, true, now); err != nil {
+		return nil, err
+	}
+
+	// Каждый логин открывает новое семейство refresh-токенов
+	sessionID, err := jwt.NewSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, sessionID)
+}
+
+// checkIPLockout запрещает вход с адреса, с которого за IPWindow было не
+// меньше IPThreshold неудачных попыток
+func (s *UserService) checkIPLockout(ip string, now time.Time) error {
+	if s.attempts == nil || s.lockout.IPThreshold <= 0 || ip == "" {
+		return nil
+	}
+	nth, err := s.attempts.NthFailureByIP(ip, s.lockout.IPThreshold, now.Add(-s.lockout.IPWindow))
+	if err != nil {
+		return err
+	}
+	if nth == nil {
+		return nil
+	}
+	// Блокировка снимается, когда самая старая из учтённых неудач выйдет из окна
+	return &LockedError{RetryAfter: nth.Add(s.lockout.IPWindow).Sub(now)}
+}
+
+// registerFailure увеличивает счётчик неудач подряд и при достижении
+// порога блокирует учётную запись
+func (s *UserService) registerFailure(userID int, lockout *models.Lockout, now time.Time) error {
+	if s.attempts == nil {
+		return ErrInvalidCredentials
+	}
+	if lockout == nil {
+		lockout = &models.Lockout{UserID: userID}
+	}
+	lockout.FailedCount++
+	lockout.LockedUntil = nil
+
+	d := s.lockout.Duration(lockout.FailedCount)
+	if d > 0 {
+		until := now.Add(d)
+		lockout.LockedUntil = &until
+	}
+	if err := s.attempts.SaveLockout(lockout); err != nil {
+		return err
+	}
+	if d > 0 {
+		return &LockedError{RetryAfter: d}
+	}
+	return ErrInvalidCredentials
+}
+
+func (s *UserService) recordAttempt(user *models.User, input models.LoginInput, success bool, now time.Time) error {
+	if s.attempts == nil {
+		return nil
+	}
+	attempt := &models.LoginAttempt{
+		Username:  input.Username,
+		IP:        input.IP,
+		UserAgent: input.UserAgent,
+		Success:   success,
+		CreatedAt: now,
+	}
+	if user != nil {
+		attempt.UserID = &user.ID
+	}
+	return s.attempts.Create(attempt)
+}
+
+// Unlock снимает блокировку входа и обнуляет счётчик неудачных попыток
+// от имени администратора actorID
+func (s *UserService) Unlock(actorID, userID int) error {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if s.attempts == nil {
+		return nil
+	}
+	lockout, err := s.attempts.GetLockout(userID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.attempts.ResetLockout(userID); err != nil {
+		return err
+	}
+	// В журнал попадает только состоявшееся снятие блокировки
+	return recordAudit(s.audit, actorID, audit.ActionUnlockUser, audit.TargetUser, userID, lockout, "")
+}
+
+// RecentSignIns возвращает последние попытки входа в учётную запись
+func (s *UserService) RecentSignIns(userID, limit int) ([]models.LoginAttempt, error) {
+	if s.attempts == nil {
+		return []models.LoginAttempt{}, nil
+	}
+	return s.attempts.ListByUser(userID, limit)
+}
+
+// Refresh ротирует refresh-токен: старый помечается использованным,
+// взамен выдаётся новая пара токенов в том же семействе.
+func (s *UserService) Refresh(input models.RefreshInput) (*AuthResponse, error) {
+	if input.RefreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	stored, err := s.tokens.GetByHash(jwt.HashToken(input.RefreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || stored.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Токен уже обменивали: скорее всего он украден, отзываем всю сессию
+	if stored.UsedAt != nil {
+		if err := s.tokens.RevokeFamily(stored.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	marked, err := s.tokens.MarkUsed(stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !marked {
+		if err := s.tokens.RevokeFamily(stored.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	user, err := s.repo.GetByID(stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err := s.checkSanctions(user.ID); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, stored.FamilyID)
+}
+
+// Logout отзывает сессию, которой принадлежит refresh-токен
+func (s *UserService) Logout(input models.RefreshInput) error {
+	if input.RefreshToken == "" {
+		return ErrInvalidRefreshToken
+	}
+
+	stored, err := s.tokens.GetByHash(jwt.HashToken(input.RefreshToken))
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		return ErrInvalidRefreshToken
+	}
+
+	return s.tokens.RevokeFamily(stored.FamilyID)
+}
+
+// ChangeRole назначает пользователю userID роль role от имени администратора
+// actorID. Роль актора проверяется по базе, а не по токену, чтобы
+// разжалованный администратор не мог пользоваться старым токеном.
+// Новая роль попадает в токены при следующем входе или обновлении; при
+// понижении все сессии пользователя отзываются, чтобы выданные токены со
+// старой ролью перестали действовать.
+func (s *UserService) ChangeRole(actorID, userID int, role string) (*models.User, error) {
+	if models.RoleRank(role) == 0 {
+		return nil, ErrInvalidRole
+	}
+
+	actor, err := s.repo.GetByID(actorID)
+	if err != nil {
+		return nil, err
+	}
+	if actor == nil || !rbac.Default.Can(actor.Role, rbac.ManageUsers) {
+		return nil, ErrForbidden
+	}
+
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if user.Role == role {
+		return user, nil
+	}
+
+	if user.Role == models.RoleAdmin {
+		admins, err := s.repo.CountByRole(models.RoleAdmin)
+		if err != nil {
+			return nil, err
+		}
+		if admins <= 1 {
+			return nil, ErrLastAdmin
+		}
+	}
+
+	before := *user
+	change, err := s.repo.UpdateRole(user.ID, role, actor.ID)
+	if err != nil {
+		return nil, err
+	}
+	user.Role = role
+	// Роль уже была такой: изменения не было, записывать нечего
+	if change == nil {
+		return user, nil
+	}
+	if models.RoleRank(change.NewRole) < models.RoleRank(change.OldRole) {
+		if err := s.tokens.RevokeUser(user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	// В журнал попадает только состоявшаяся смена роли
+	reason := fmt.Sprintf("role %s -> %s", change.OldRole, change.NewRole)
+	if err := recordAudit(s.audit, actor.ID, audit.ActionSetRole, audit.TargetUser, user.ID, before, reason); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// RoleChanges возвращает историю смены ролей пользователя
+func (s *UserService) RoleChanges(userID int) ([]models.RoleChange, error) {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return s.repo.ListRoleChanges(userID)
+}
+
+func (s *UserService) issueTokens(user *models.User, sessionID string) (*AuthResponse, error) {
 	// Генерируем токен с ролью
-	token, err := s.tokenManager.NewJWTWithRole(user.ID, user.Username, user.Role, s.tokenTTL)
+	token, err := s.tokenManager.NewAccessToken(user.ID, user.Username, user.Role, sessionID, s.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := jwt.NewRefreshToken()
 	if err != nil {
 		return nil, err
 	}
 
+	stored := &models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  sessionID,
+		TokenHash: jwt.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}
+	if err := s.tokens.Create(stored); err != nil {
+		return nil, err
+	}
+
 	return &AuthResponse{
-		User:  user,
-		Token: token,
+		User:         user,
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.accessTTL.Seconds()),
 	}, nil
 }
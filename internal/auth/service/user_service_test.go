@@ -1,16 +1,21 @@
 package service
 
 import (
+	"encoding/json"
 	"errors"
 	"testing"
 	"time"
 
 	"github.com/mos1rain/forum_go/internal/auth/models"
+	"github.com/mos1rain/forum_go/pkg/audit"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type mockUserRepo struct {
-	users map[string]*models.User
+	users   map[string]*models.User
+	changes []models.RoleChange
+	// updateErr если задана, UpdateRole с ней завершается
+	updateErr error
 }
 
 var _ UserRepo = (*mockUserRepo)(nil)
@@ -22,7 +27,14 @@ func (m *mockUserRepo) GetByUsername(username string) (*models.User, error) {
 	return nil, nil
 }
 func (m *mockUserRepo) GetByEmail(email string) (*models.User, error) { return nil, nil }
-func (m *mockUserRepo) GetByID(id int) (*models.User, error)          { return nil, nil }
+func (m *mockUserRepo) GetByID(id int) (*models.User, error) {
+	for _, u := range m.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
 func (m *mockUserRepo) Create(user *models.User) error {
 	if _, ok := m.users[user.Username]; ok {
 		return errors.New("already exists")
@@ -31,10 +43,42 @@ func (m *mockUserRepo) Create(user *models.User) error {
 	return nil
 }
 
+func (m *mockUserRepo) UpdateRole(userID int, role string, changedBy int) (*models.RoleChange, error) {
+	if m.updateErr != nil {
+		return nil, m.updateErr
+	}
+	user, _ := m.GetByID(userID)
+	if user.Role == role {
+		return nil, nil
+	}
+	change := models.RoleChange{ID: len(m.changes) + 1, UserID: userID, OldRole: user.Role, NewRole: role, ChangedBy: &changedBy}
+	user.Role = role
+	m.changes = append(m.changes, change)
+	return &change, nil
+}
+func (m *mockUserRepo) CountByRole(role string) (int, error) {
+	count := 0
+	for _, u := range m.users {
+		if u.Role == role {
+			count++
+		}
+	}
+	return count, nil
+}
+func (m *mockUserRepo) ListRoleChanges(userID int) ([]models.RoleChange, error) {
+	var out []models.RoleChange
+	for i := len(m.changes) - 1; i >= 0; i-- {
+		if m.changes[i].UserID == userID {
+			out = append(out, m.changes[i])
+		}
+	}
+	return out, nil
+}
+
 func TestRegister_NewUser(t *testing.T) {
 	repo := &mockUserRepo{users: map[string]*models.User{}}
 	tm := newTestTokenManager()
-	s := NewUserService(repo, tm, 0)
+	s := NewUserService(repo, newMockTokenRepo(), tm, 0, time.Hour)
 	input := models.CreateUserInput{
 		Username: "testuser",
 		Email:    "test@example.com",
@@ -49,10 +93,41 @@ func TestRegister_NewUser(t *testing.T) {
 	}
 }
 
+// Роль из запроса регистрации игнорируется
+func TestRegister_IgnoresRole(t *testing.T) {
+	repo := &mockUserRepo{users: map[string]*models.User{}}
+	s := NewUserService(repo, newMockTokenRepo(), newTestTokenManager(), 0, time.Hour)
+
+	var input models.CreateUserInput
+	if err := json.Unmarshal([]byte(`{"username":"mallory","email":"m@example.com","password":"password","role":"admin"}`), &input); err != nil {
+		t.Fatalf("decode input: %v", err)
+	}
+	resp, err := s.Register(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.User.Role != models.RoleUser {
+		t.Errorf("expected role %q, got %q", models.RoleUser, resp.User.Role)
+	}
+}
+
+func TestRegister_InvalidInput(t *testing.T) {
+	s := NewUserService(&mockUserRepo{users: map[string]*models.User{}}, newMockTokenRepo(), newTestTokenManager(), 0, time.Hour)
+	for _, input := range []models.CreateUserInput{
+		{Username: "", Email: "a@example.com", Password: "password"},
+		{Username: "user", Email: "invalid", Password: "password"},
+		{Username: "user", Email: "a@example.com", Password: ""},
+	} {
+		if _, err := s.Register(input); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("Register(%+v): expected ErrInvalidInput, got %v", input, err)
+		}
+	}
+}
+
 func TestRegister_AlreadyExists(t *testing.T) {
 	repo := &mockUserRepo{users: map[string]*models.User{"testuser": {Username: "testuser"}}}
 	tm := newTestTokenManager()
-	s := NewUserService(repo, tm, 0)
+	s := NewUserService(repo, newMockTokenRepo(), tm, 0, time.Hour)
 	input := models.CreateUserInput{
 		Username: "testuser",
 		Email:    "test@example.com",
@@ -70,7 +145,7 @@ func TestLogin_Success(t *testing.T) {
 		"testuser": {ID: 1, Username: "testuser", PasswordHash: string(hash), Role: "user"},
 	}}
 	tm := newTestTokenManager()
-	s := NewUserService(repo, tm, 0)
+	s := NewUserService(repo, newMockTokenRepo(), tm, 0, time.Hour)
 	input := models.LoginInput{
 		Username: "testuser",
 		Password: "password",
@@ -90,7 +165,7 @@ func TestLogin_InvalidPassword(t *testing.T) {
 		"testuser": {ID: 1, Username: "testuser", PasswordHash: string(hash), Role: "user"},
 	}}
 	tm := newTestTokenManager()
-	s := NewUserService(repo, tm, 0)
+	s := NewUserService(repo, newMockTokenRepo(), tm, 0, time.Hour)
 	input := models.LoginInput{
 		Username: "testuser",
 		Password: "wrongpass",
@@ -104,7 +179,7 @@ func TestLogin_InvalidPassword(t *testing.T) {
 func TestLogin_UserNotFound(t *testing.T) {
 	repo := &mockUserRepo{users: map[string]*models.User{}}
 	tm := newTestTokenManager()
-	s := NewUserService(repo, tm, 0)
+	s := NewUserService(repo, newMockTokenRepo(), tm, 0, time.Hour)
 	input := models.LoginInput{
 		Username: "nouser",
 		Password: "password",
@@ -126,7 +201,7 @@ func (e *errorRepo) GetByUsername(username string) (*models.User, error) {
 func TestLogin_RepoError(t *testing.T) {
 	repo := &errorRepo{mockUserRepo{users: map[string]*models.User{}}}
 	tm := newTestTokenManager()
-	s := NewUserService(repo, tm, 0)
+	s := NewUserService(repo, newMockTokenRepo(), tm, 0, time.Hour)
 	input := models.LoginInput{
 		Username: "testuser",
 		Password: "password",
@@ -142,9 +217,429 @@ type testTokenManager struct{}
 var _ TokenManager = (*testTokenManager)(nil)
 
 func newTestTokenManager() *testTokenManager { return &testTokenManager{} }
-func (t *testTokenManager) NewJWTWithRole(userID int, username, role string, ttl time.Duration) (string, error) {
+func (t *testTokenManager) NewAccessToken(userID int, username, role, sessionID string, ttl time.Duration) (string, error) {
 	return "token", nil
 }
-func (t *testTokenManager) NewJWT(userID int, username string, ttl int64) (string, error) {
+
+type mockTokenRepo struct {
+	tokens map[string]*models.RefreshToken
+}
+
+var _ TokenRepo = (*mockTokenRepo)(nil)
+
+func newMockTokenRepo() *mockTokenRepo {
+	return &mockTokenRepo{tokens: map[string]*models.RefreshToken{}}
+}
+
+func (m *mockTokenRepo) Create(token *models.RefreshToken) error {
+	token.ID = len(m.tokens) + 1
+	m.tokens[token.TokenHash] = token
+	return nil
+}
+func (m *mockTokenRepo) GetByHash(hash string) (*models.RefreshToken, error) {
+	return m.tokens[hash], nil
+}
+func (m *mockTokenRepo) MarkUsed(id int) (bool, error) {
+	for _, t := range m.tokens {
+		if t.ID == id {
+			if t.UsedAt != nil {
+				return false, nil
+			}
+			now := time.Now()
+			t.UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (m *mockTokenRepo) RevokeUser(userID int) error {
+	now := time.Now()
+	for _, t := range m.tokens {
+		if t.UserID == userID {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+func (m *mockTokenRepo) RevokeFamily(familyID string) error {
+	now := time.Now()
+	for _, t := range m.tokens {
+		if t.FamilyID == familyID {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func newLoggedInService(t *testing.T) (*UserService, *mockTokenRepo, *AuthResponse) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	repo := &mockUserRepo{users: map[string]*models.User{
+		"testuser": {ID: 1, Username: "testuser", PasswordHash: string(hash), Role: "user"},
+	}}
+	tokens := newMockTokenRepo()
+	s := NewUserService(repo, tokens, newTestTokenManager(), time.Minute, time.Hour)
+	resp, err := s.Login(models.LoginInput{Username: "testuser", Password: "password"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	return s, tokens, resp
+}
+
+func TestRefresh_RotatesToken(t *testing.T) {
+	s, _, login := newLoggedInService(t)
+	if login.RefreshToken == "" {
+		t.Fatal("expected refresh token on login")
+	}
+
+	resp, err := s.Refresh(models.RefreshInput{RefreshToken: login.RefreshToken})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.RefreshToken == "" || resp.RefreshToken == login.RefreshToken {
+		t.Fatal("expected a new refresh token")
+	}
+	if _, err := s.Refresh(models.RefreshInput{RefreshToken: resp.RefreshToken}); err != nil {
+		t.Fatalf("rotated token must be usable: %v", err)
+	}
+}
+
+func TestRefresh_ReuseRevokesFamily(t *testing.T) {
+	s, _, login := newLoggedInService(t)
+
+	rotated, err := s.Refresh(models.RefreshInput{RefreshToken: login.RefreshToken})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = s.Refresh(models.RefreshInput{RefreshToken: login.RefreshToken})
+	if !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+
+	_, err = s.Refresh(models.RefreshInput{RefreshToken: rotated.RefreshToken})
+	if !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected whole family to be revoked, got %v", err)
+	}
+}
+
+func TestRefresh_Expired(t *testing.T) {
+	s, tokens, login := newLoggedInService(t)
+	for _, tok := range tokens.tokens {
+		tok.ExpiresAt = time.Now().Add(-time.Minute)
+	}
+
+	_, err := s.Refresh(models.RefreshInput{RefreshToken: login.RefreshToken})
+	if !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected ErrInvalidRefreshToken, got %v", err)
+	}
+}
+
+func TestLogout_RevokesSession(t *testing.T) {
+	s, _, login := newLoggedInService(t)
+
+	if err := s.Logout(models.RefreshInput{RefreshToken: login.RefreshToken}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err := s.Refresh(models.RefreshInput{RefreshToken: login.RefreshToken})
+	if !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected ErrInvalidRefreshToken after logout, got %v", err)
+	}
+	if err := s.Logout(models.RefreshInput{RefreshToken: "unknown"}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected ErrInvalidRefreshToken for unknown token, got %v", err)
+	}
+}
+
+type mockAttemptRepo struct {
+	attempts []models.LoginAttempt
+	lockouts map[int]*models.Lockout
+	// resetErr если задана, ResetLockout с ней завершается
+	resetErr error
+}
+
+var _ AttemptRepo = (*mockAttemptRepo)(nil)
+
+func newMockAttemptRepo() *mockAttemptRepo {
+	return &mockAttemptRepo{lockouts: map[int]*models.Lockout{}}
+}
+
+func (m *mockAttemptRepo) Create(attempt *models.LoginAttempt) error {
+	attempt.ID = len(m.attempts) + 1
+	m.attempts = append(m.attempts, *attempt)
+	return nil
+}
+func (m *mockAttemptRepo) ListByUser(userID, limit int) ([]models.LoginAttempt, error) {
+	var out []models.LoginAttempt
+	for i := len(m.attempts) - 1; i >= 0 && len(out) < limit; i-- {
+		if a := m.attempts[i]; a.UserID != nil && *a.UserID == userID {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+func (m *mockAttemptRepo) NthFailureByIP(ip string, n int, since time.Time) (*time.Time, error) {
+	for i := len(m.attempts) - 1; i >= 0; i-- {
+		a := m.attempts[i]
+		if a.IP != ip || a.Success || a.CreatedAt.Before(since) {
+			continue
+		}
+		if n--; n == 0 {
+			return &a.CreatedAt, nil
+		}
+	}
+	return nil, nil
+}
+func (m *mockAttemptRepo) GetLockout(userID int) (*models.Lockout, error) {
+	if l, ok := m.lockouts[userID]; ok {
+		copied := *l
+		return &copied, nil
+	}
+	return nil, nil
+}
+func (m *mockAttemptRepo) SaveLockout(lockout *models.Lockout) error {
+	copied := *lockout
+	m.lockouts[lockout.UserID] = &copied
+	return nil
+}
+func (m *mockAttemptRepo) ResetLockout(userID int) (bool, error) {
+	if m.resetErr != nil {
+		return false, m.resetErr
+	}
+	_, ok := m.lockouts[userID]
+	delete(m.lockouts, userID)
+	return ok, nil
+}
+
+func newLockoutService(t *testing.T, policy LockoutPolicy) (*UserService, *mockAttemptRepo, *time.Time) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	repo := &mockUserRepo{users: map[string]*models.User{
+		"testuser": {ID: 1, Username: "testuser", PasswordHash: string(hash), Role: "user"},
+	}}
+	attempts := newMockAttemptRepo()
+	s := NewUserService(repo, newMockTokenRepo(), newTestTokenManager(), time.Minute, time.Hour)
+	s.EnableLockout(attempts, policy)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, attempts, &now
+}
+
+func TestLockoutPolicy_Duration(t *testing.T) {
+	p := LockoutPolicy{Threshold: 3, BaseDuration: time.Minute, MaxDuration: 10 * time.Minute}
+	for failures, want := range map[int]time.Duration{
+		2:   0,
+		3:   time.Minute,
+		4:   2 * time.Minute,
+		5:   4 * time.Minute,
+		6:   8 * time.Minute,
+		7:   10 * time.Minute,
+		100: 10 * time.Minute,
+	} {
+		if got := p.Duration(failures); got != want {
+			t.Errorf("Duration(%d) = %v, want %v", failures, got, want)
+		}
+	}
+	if (LockoutPolicy{}).Duration(100) != 0 {
+		t.Error("zero threshold must disable lockout")
+	}
+}
+
+func TestLogin_LockoutWithBackoff(t *testing.T) {
+	s, attempts, now := newLockoutService(t, LockoutPolicy{Threshold: 2, BaseDuration: time.Minute, MaxDuration: time.Hour})
+	wrong := models.LoginInput{Username: "testuser", Password: "wrong", IP: "10.0.0.1"}
+	right := models.LoginInput{Username: "testuser", Password: "password", IP: "10.0.0.1"}
+
+	if _, err := s.Login(wrong); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("first failure: expected ErrInvalidCredentials, got %v", err)
+	}
+	var locked *LockedError
+	if _, err := s.Login(wrong); !errors.As(err, &locked) || locked.RetryAfter != time.Minute {
+		t.Fatalf("threshold reached: expected 1m lockout, got %v", err)
+	}
+	// Верный пароль во время блокировки не помогает
+	if _, err := s.Login(right); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("expected ErrAccountLocked during lockout, got %v", err)
+	}
+
+	// Следующая неудача после блокировки удваивает срок
+	*now = now.Add(time.Minute)
+	if _, err := s.Login(wrong); !errors.As(err, &locked) || locked.RetryAfter != 2*time.Minute {
+		t.Fatalf("expected 2m lockout, got %v", err)
+	}
+
+	*now = now.Add(2 * time.Minute)
+	if _, err := s.Login(right); err != nil {
+		t.Fatalf("login after lockout: %v", err)
+	}
+	if l, _ := attempts.GetLockout(1); l != nil {
+		t.Errorf("successful login must reset lockout, got %+v", l)
+	}
+
+	signIns, err := s.RecentSignIns(1, 10)
+	if err != nil {
+		t.Fatalf("RecentSignIns: %v", err)
+	}
+	if len(signIns) != 5 || !signIns[0].Success || signIns[1].Success {
+		t.Errorf("expected 5 attempts with the latest successful, got %+v", signIns)
+	}
+}
+
+func TestLogin_IPLockout(t *testing.T) {
+	s, attempts, now := newLockoutService(t, LockoutPolicy{IPThreshold: 3, IPWindow: 10 * time.Minute})
+
+	for i := 0; i < 3; i++ {
+		_, err := s.Login(models.LoginInput{Username: "nobody", Password: "x", IP: "10.0.0.1"})
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: expected ErrInvalidCredentials, got %v", i+1, err)
+		}
+		*now = now.Add(time.Minute)
+	}
+	if attempts.attempts[0].UserID != nil {
+		t.Error("attempt for unknown user must not reference an account")
+	}
+
+	var locked *LockedError
+	_, err := s.Login(models.LoginInput{Username: "testuser", Password: "password", IP: "10.0.0.1"})
+	if !errors.As(err, &locked) || locked.RetryAfter != 7*time.Minute {
+		t.Fatalf("expected IP lockout until the first failure leaves the window, got %v", err)
+	}
+	if _, err := s.Login(models.LoginInput{Username: "testuser", Password: "password", IP: "10.0.0.2"}); err != nil {
+		t.Fatalf("other IP must not be locked: %v", err)
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	s, attempts, _ := newLockoutService(t, LockoutPolicy{Threshold: 1, BaseDuration: time.Hour, MaxDuration: time.Hour})
+
+	if _, err := s.Login(models.LoginInput{Username: "testuser", Password: "wrong"}); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("expected lockout, got %v", err)
+	}
+	if err := s.Unlock(9, 1); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if l, _ := attempts.GetLockout(1); l != nil {
+		t.Errorf("expected lockout to be removed, got %+v", l)
+	}
+	if _, err := s.Login(models.LoginInput{Username: "testuser", Password: "password"}); err != nil {
+		t.Fatalf("login after unlock: %v", err)
+	}
+	if err := s.Unlock(9, 42); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestUnlock_Audit(t *testing.T) {
+	s, attempts, _ := newLockoutService(t, LockoutPolicy{Threshold: 1, BaseDuration: time.Hour, MaxDuration: time.Hour})
+	log := &mockAuditRepo{}
+	s.EnableAudit(log)
+	if _, err := s.Login(models.LoginInput{Username: "testuser", Password: "wrong"}); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("expected lockout, got %v", err)
+	}
+
+	// Несостоявшееся снятие блокировки в журнал не попадает
+	attempts.resetErr = errors.New("database is locked")
+	if err := s.Unlock(9, 1); err == nil {
+		t.Fatal("expected Unlock to fail")
+	}
+	if len(log.entries) != 0 {
+		t.Fatalf("failed unlock must not be recorded, got %+v", log.entries)
+	}
+
+	attempts.resetErr = nil
+	if err := s.Unlock(9, 1); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if len(log.entries) != 1 || log.entries[0].Action != audit.ActionUnlockUser || log.entries[0].ActorID != 9 {
+		t.Errorf("expected unlock entry, got %+v", log.entries)
+	}
+}
+
+func newRoleService() (*UserService, *mockUserRepo, *mockTokenRepo) {
+	repo := &mockUserRepo{users: map[string]*models.User{
+		"root":  {ID: 1, Username: "root", Role: models.RoleAdmin},
+		"alice": {ID: 2, Username: "alice", Role: models.RoleUser},
+	}}
+	tokens := newMockTokenRepo()
+	return NewUserService(repo, tokens, newTestTokenManager(), time.Minute, time.Hour), repo, tokens
+}
+
+func TestChangeRole(t *testing.T) {
+	s, repo, tokens := newRoleService()
+	tokens.Create(&models.RefreshToken{UserID: 2, FamilyID: "alice-session", TokenHash: "h"})
+
+	user, err := s.ChangeRole(1, 2, models.RoleModerator)
+	if err != nil {
+		t.Fatalf("promote: %v", err)
+	}
+	if user.Role != models.RoleModerator || repo.users["alice"].Role != models.RoleModerator {
+		t.Fatalf("expected alice to become moderator, got %q", user.Role)
+	}
+	if tokens.tokens["h"].RevokedAt != nil {
+		t.Error("promotion must keep sessions")
+	}
+
+	if _, err := s.ChangeRole(1, 2, models.RoleUser); err != nil {
+		t.Fatalf("demote: %v", err)
+	}
+	if tokens.tokens["h"].RevokedAt == nil {
+		t.Error("demotion must revoke sessions")
+	}
+
+	changes, err := s.RoleChanges(2)
+	if err != nil {
+		t.Fatalf("RoleChanges: %v", err)
+	}
+	if len(changes) != 2 || changes[0].NewRole != models.RoleUser || *changes[0].ChangedBy != 1 {
+		t.Errorf("unexpected audit trail %+v", changes)
+	}
+}
+
+func TestChangeRole_Errors(t *testing.T) {
+	s, _, _ := newRoleService()
+
+	tests := []struct {
+		name          string
+		actor, target int
+		role          string
+		want          error
+	}{
+		{"unknown role", 1, 2, "superuser", ErrInvalidRole},
+		{"not an admin", 2, 2, models.RoleAdmin, ErrForbidden},
+		{"unknown actor", 42, 2, models.RoleModerator, ErrForbidden},
+		{"unknown user", 1, 42, models.RoleModerator, ErrUserNotFound},
+		{"last admin", 1, 1, models.RoleUser, ErrLastAdmin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.ChangeRole(tt.actor, tt.target, tt.role); !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// Новая роль попадает в токены, выданные после изменения
+func TestChangeRole_ReflectedInNewTokens(t *testing.T) {
+	s, repo, _ := newRoleService()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	repo.users["alice"].PasswordHash = string(hash)
+	tm := &recordingTokenManager{}
+	s.tokenManager = tm
+
+	if _, err := s.ChangeRole(1, 2, models.RoleModerator); err != nil {
+		t.Fatalf("ChangeRole: %v", err)
+	}
+	if _, err := s.Login(models.LoginInput{Username: "alice", Password: "password"}); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if tm.role != models.RoleModerator {
+		t.Errorf("expected token role %q, got %q", models.RoleModerator, tm.role)
+	}
+}
+
+type recordingTokenManager struct {
+	role string
+}
+
+func (r *recordingTokenManager) NewAccessToken(userID int, username, role, sessionID string, ttl time.Duration) (string, error) {
+	r.role = role
 	return "token", nil
 }
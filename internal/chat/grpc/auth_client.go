@@ -0,0 +1,106 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mos1rain/forum_go/pkg/audit"
+	"github.com/mos1rain/forum_go/pkg/sanction"
+	"github.com/mos1rain/forum_go/proto/auth"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type AuthGRPCClient struct {
+	conn   *grpc.ClientConn
+	client auth.AuthServiceClient
+	health healthpb.HealthClient
+}
+
+func NewAuthGRPCClient(addr string, opts ...grpc.DialOption) (*AuthGRPCClient, error) {
+	opts = append([]grpc.DialOption{grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(3 * time.Second)}, opts...)
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthGRPCClient{
+		conn:   conn,
+		client: auth.NewAuthServiceClient(conn),
+		health: healthpb.NewHealthClient(conn),
+	}, nil
+}
+
+// Ping проверяет, что auth-сервис доступен и готов обслуживать запросы
+func (c *AuthGRPCClient) Ping(ctx context.Context) error {
+	resp, err := c.health.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("auth service is %s", resp.Status)
+	}
+	return nil
+}
+
+// Close закрывает соединение с auth-сервисом
+func (c *AuthGRPCClient) Close() error {
+	return c.conn.Close()
+}
+
+// ValidateToken проверяет в auth-сервисе, что сессия токена не отозвана
+func (c *AuthGRPCClient) ValidateToken(ctx context.Context, token string) (*auth.ValidateTokenResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	return c.client.ValidateToken(ctx, &auth.ValidateTokenRequest{Token: token})
+}
+
+// ActiveSanctions возвращает действующие ограничения пользователя
+func (c *AuthGRPCClient) ActiveSanctions(ctx context.Context, userID int) ([]sanction.Active, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	resp, err := c.client.GetActiveSanctions(ctx, &auth.GetActiveSanctionsRequest{UserId: int32(userID)})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+
+	active := make([]sanction.Active, 0, len(resp.Sanctions))
+	for _, s := range resp.Sanctions {
+		a := sanction.Active{Type: sanction.Type(s.Type), Reason: s.Reason}
+		if s.ExpiresAt != 0 {
+			expires := time.Unix(s.ExpiresAt, 0).UTC()
+			a.ExpiresAt = &expires
+		}
+		active = append(active, a)
+	}
+	return active, nil
+}
+
+// RecordAudit записывает в журнал auth-сервиса действие модератора,
+// которому принадлежит token
+func (c *AuthGRPCClient) RecordAudit(ctx context.Context, token string, entry audit.Entry) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	resp, err := c.client.RecordAudit(ctx, &auth.RecordAuditRequest{Entry: &auth.AuditEntry{
+		Service:    entry.Service,
+		ActorId:    entry.ActorID,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetId:   entry.TargetID,
+		Snapshot:   string(entry.Snapshot),
+		Reason:     entry.Reason,
+	}, Token: token})
+	if err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
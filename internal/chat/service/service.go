@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"errors"
 	"time"
+
+	"github.com/mos1rain/forum_go/pkg/database"
 )
 
 var (
@@ -23,10 +25,10 @@ type Message struct {
 }
 
 type ChatService struct {
-	db *sql.DB
+	db *database.DB
 }
 
-func NewChatService(db *sql.DB) *ChatService {
+func NewChatService(db *database.DB) *ChatService {
 	return &ChatService{
 		db: db,
 	}
@@ -94,6 +96,20 @@ func (c *ChatService) GetHistory(limit int) ([]Message, error) {
 	return messages, nil
 }
 
+// GetMessage возвращает сообщение или nil, если его нет
+func (c *ChatService) GetMessage(id int) (*Message, error) {
+	var msg Message
+	query := `SELECT id, user_id, username, content, created_at FROM chat_messages WHERE id = ?`
+	err := c.db.QueryRow(query, id).Scan(&msg.ID, &msg.UserID, &msg.Username, &msg.Content, &msg.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
 func (c *ChatService) DeleteMessage(id int) error {
 	if id <= 0 {
 		return ErrInvalidUserID
@@ -103,12 +119,13 @@ func (c *ChatService) DeleteMessage(id int) error {
 	return err
 }
 
+// CleanOldMessages удаляет сообщения старше olderThan
 func (c *ChatService) CleanOldMessages(olderThan time.Duration) (int, error) {
 	query := `
 		DELETE FROM chat_messages
-		WHERE created_at < datetime('now', '-1 day')
+		WHERE created_at < ?
 	`
-	result, err := c.db.Exec(query)
+	result, err := c.db.Exec(query, time.Now().Add(-olderThan))
 	if err != nil {
 		return 0, err
 	}
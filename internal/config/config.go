@@ -0,0 +1,486 @@
+// Package config загружает настройки сервисов auth, forum и chat.
+// Значения берутся по возрастанию приоритета: значения по умолчанию,
+// файл YAML/TOML (флаг -config или CONFIG_FILE), переменные окружения
+// и флаги командной строки.
+package config
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mos1rain/forum_go/pkg/database"
+	"github.com/mos1rain/forum_go/pkg/jwt"
+	"github.com/mos1rain/forum_go/pkg/logging"
+	"github.com/mos1rain/forum_go/pkg/ratelimit"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported config file format")
+
+// Config содержит конфигурацию всех сервисов. Каждое поле задаётся
+// переменной окружения из тега env или флагом с тем же именем в нижнем
+// регистре через дефис (DB_DSN → -db-dsn).
+type Config struct {
+	Database  DatabaseConfig  `yaml:"database" toml:"database"`
+	Auth      AuthConfig      `yaml:"auth" toml:"auth"`
+	Forum     ForumConfig     `yaml:"forum" toml:"forum"`
+	Chat      ChatConfig      `yaml:"chat" toml:"chat"`
+	JWT       JWTConfig       `yaml:"jwt" toml:"jwt"`
+	CORS      CORSConfig      `yaml:"cors" toml:"cors"`
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	Log       LogConfig       `yaml:"log" toml:"log"`
+	RateLimit RateLimitConfig `yaml:"rate_limit" toml:"rate_limit"`
+	Lockout   LockoutConfig   `yaml:"lockout" toml:"lockout"`
+}
+
+type DatabaseConfig struct {
+	// Driver sqlite или postgres
+	Driver string `yaml:"driver" toml:"driver" env:"DB_DRIVER" usage:"database driver: sqlite or postgres"`
+	// DSN путь к файлу SQLite или строка подключения Postgres
+	DSN string `yaml:"dsn" toml:"dsn" env:"DB_DSN" usage:"database DSN"`
+}
+
+// Connection возвращает параметры для database.Open
+func (c DatabaseConfig) Connection() database.Config {
+	return database.Config{Driver: c.Driver, DSN: c.DSN}
+}
+
+type AuthConfig struct {
+	HTTPAddr string `yaml:"http_addr" toml:"http_addr" env:"AUTH_HTTP_ADDR" usage:"auth service HTTP listen address"`
+	GRPCAddr string `yaml:"grpc_addr" toml:"grpc_addr" env:"AUTH_GRPC_ADDR" usage:"auth service gRPC listen address"`
+}
+
+type ForumConfig struct {
+	HTTPAddr string `yaml:"http_addr" toml:"http_addr" env:"FORUM_HTTP_ADDR" usage:"forum service HTTP listen address"`
+	// AuthGRPCAddr адрес gRPC-сервера auth-сервиса
+	AuthGRPCAddr string `yaml:"auth_grpc_addr" toml:"auth_grpc_addr" env:"FORUM_AUTH_GRPC_ADDR" usage:"auth service gRPC address used by forum"`
+	// ChatURL адрес HTTP API chat-сервиса: через него forum проверяет и
+	// удаляет сообщения чата, на которые пожаловались
+	ChatURL string `yaml:"chat_url" toml:"chat_url" env:"FORUM_CHAT_URL" usage:"chat service HTTP URL used by forum for reported messages"`
+	// DeletedRetention сколько удалённые посты, комментарии и категории
+	// можно восстановить, прежде чем они будут удалены окончательно
+	DeletedRetention time.Duration `yaml:"deleted_retention" toml:"deleted_retention" env:"FORUM_DELETED_RETENTION" usage:"how long deleted forum content can be restored before it is purged"`
+	// PurgeInterval как часто окончательно удаляется просроченное удалённое
+	PurgeInterval time.Duration `yaml:"purge_interval" toml:"purge_interval" env:"FORUM_PURGE_INTERVAL" usage:"how often expired deleted forum content is purged"`
+}
+
+type ChatConfig struct {
+	HTTPAddr string `yaml:"http_addr" toml:"http_addr" env:"CHAT_HTTP_ADDR" usage:"chat service HTTP listen address"`
+	// AuthGRPCAddr адрес gRPC-сервера auth-сервиса, у которого chat
+	// узнаёт об ограничениях пользователей
+	AuthGRPCAddr string `yaml:"auth_grpc_addr" toml:"auth_grpc_addr" env:"CHAT_AUTH_GRPC_ADDR" usage:"auth service gRPC address used by chat"`
+	// Retention сколько хранятся сообщения чата
+	Retention time.Duration `yaml:"retention" toml:"retention" env:"CHAT_RETENTION" usage:"how long chat messages are kept"`
+	// CleanupInterval как часто удаляются старые сообщения
+	CleanupInterval time.Duration `yaml:"cleanup_interval" toml:"cleanup_interval" env:"CHAT_CLEANUP_INTERVAL" usage:"how often old chat messages are removed"`
+}
+
+type JWTConfig struct {
+	// KeysDir каталог с ключами подписи auth-сервиса
+	KeysDir string `yaml:"keys_dir" toml:"keys_dir" env:"JWT_KEYS_DIR" usage:"directory with JWT signing keys"`
+	// Algorithm алгоритм новых ключей: RS256 или EdDSA
+	Algorithm   string        `yaml:"algorithm" toml:"algorithm" env:"JWT_ALGORITHM" usage:"algorithm of new signing keys: RS256 or EdDSA"`
+	AccessTTL   time.Duration `yaml:"access_ttl" toml:"access_ttl" env:"JWT_ACCESS_TTL" usage:"access token lifetime"`
+	RefreshTTL  time.Duration `yaml:"refresh_ttl" toml:"refresh_ttl" env:"JWT_REFRESH_TTL" usage:"refresh token lifetime"`
+	KeyRotation time.Duration `yaml:"key_rotation" toml:"key_rotation" env:"JWT_KEY_ROTATION" usage:"how often the signing key is rotated"`
+	// JWKSURL адрес публичных ключей, по которым forum и chat проверяют токены
+	JWKSURL string `yaml:"jwks_url" toml:"jwks_url" env:"JWT_JWKS_URL" usage:"JWKS endpoint used by forum and chat to verify tokens"`
+}
+
+type CORSConfig struct {
+	// AllowedOrigins разрешённые источники; "*" разрешает любой
+	AllowedOrigins []string `yaml:"allowed_origins" toml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" usage:"comma-separated list of allowed CORS origins"`
+}
+
+// Allowed сообщает, разрешены ли запросы с источника origin
+func (c CORSConfig) Allowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware возвращает обёртку, разрешающую запросы с источников из
+// конфигурации; methods — значение Access-Control-Allow-Methods сервиса.
+// Preflight-запросы OPTIONS получают ответ сразу.
+func (c CORSConfig) Middleware(methods string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Origin")
+			if origin := r.Header.Get("Origin"); c.Allowed(origin) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, X-Request-ID")
+			w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Max-Age", "3600")
+
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+type ServerConfig struct {
+	// ShutdownTimeout сколько сервис ждёт завершения текущих запросов при остановке
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" usage:"how long to wait for in-flight requests on shutdown"`
+}
+
+type LogConfig struct {
+	// Level минимальный уровень: debug, info, warn или error
+	Level string `yaml:"level" toml:"level" env:"LOG_LEVEL" usage:"log level: debug, info, warn or error"`
+	// Format console для чтения человеком или json для сборщиков логов
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT" usage:"log format: console or json"`
+}
+
+// RateLimitConfig лимиты задаются строкой "N/период" (например, 10/1m)
+// или "off"
+type RateLimitConfig struct {
+	// Login попытки входа с одного IP
+	Login ratelimit.Limit `yaml:"login" toml:"login" env:"RATE_LIMIT_LOGIN" usage:"login attempts per client IP, e.g. 10/1m or off"`
+	// Register регистрации с одного IP
+	Register ratelimit.Limit `yaml:"register" toml:"register" env:"RATE_LIMIT_REGISTER" usage:"registrations per client IP"`
+	// Refresh обновления токенов и выходы с одного IP, отдельно для каждого маршрута
+	Refresh ratelimit.Limit `yaml:"refresh" toml:"refresh" env:"RATE_LIMIT_REFRESH" usage:"token refreshes and logouts per client IP"`
+	// Post создание постов и комментариев одним пользователем
+	Post ratelimit.Limit `yaml:"post" toml:"post" env:"RATE_LIMIT_POST" usage:"posts and comments created per user"`
+	// ChatMessage сообщения чата от пользователя через HTTP и от одного WebSocket-соединения
+	ChatMessage ratelimit.Limit `yaml:"chat_message" toml:"chat_message" env:"RATE_LIMIT_CHAT_MESSAGE" usage:"chat messages per user (HTTP) and per WebSocket connection"`
+	// ChatMaxWarnings сколько предупреждений получает WebSocket-клиент перед отключением
+	ChatMaxWarnings int `yaml:"chat_max_warnings" toml:"chat_max_warnings" env:"RATE_LIMIT_CHAT_MAX_WARNINGS" usage:"warnings sent to a flooding WebSocket client before it is disconnected"`
+	// TrustForwardedFor брать IP клиента из X-Forwarded-For; включайте только за доверенным прокси
+	TrustForwardedFor bool `yaml:"trust_forwarded_for" toml:"trust_forwarded_for" env:"RATE_LIMIT_TRUST_FORWARDED_FOR" usage:"use X-Forwarded-For as client IP (only behind a trusted proxy)"`
+}
+
+// LockoutConfig блокировка входа после серии неудачных попыток
+type LockoutConfig struct {
+	// Threshold неудачных попыток подряд до блокировки учётной записи; 0 отключает блокировку
+	Threshold int `yaml:"threshold" toml:"threshold" env:"LOCKOUT_THRESHOLD" usage:"failed logins in a row before the account is locked (0 disables)"`
+	// BaseDuration срок первой блокировки; каждая следующая неудача удваивает его
+	BaseDuration time.Duration `yaml:"base_duration" toml:"base_duration" env:"LOCKOUT_BASE_DURATION" usage:"first lockout duration, doubled on each further failure"`
+	MaxDuration  time.Duration `yaml:"max_duration" toml:"max_duration" env:"LOCKOUT_MAX_DURATION" usage:"maximum lockout duration"`
+	// IPThreshold неудачных попыток с одного IP за IPWindow до блокировки адреса; 0 отключает
+	IPThreshold int           `yaml:"ip_threshold" toml:"ip_threshold" env:"LOCKOUT_IP_THRESHOLD" usage:"failed logins from one IP within the window before the IP is locked (0 disables)"`
+	IPWindow    time.Duration `yaml:"ip_window" toml:"ip_window" env:"LOCKOUT_IP_WINDOW" usage:"window for counting failed logins per IP"`
+	// AttemptRetention сколько хранится журнал входов
+	AttemptRetention time.Duration `yaml:"attempt_retention" toml:"attempt_retention" env:"LOCKOUT_ATTEMPT_RETENTION" usage:"how long login attempts are kept"`
+}
+
+// Default возвращает конфигурацию для локального запуска
+func Default() Config {
+	return Config{
+		Database: DatabaseConfig{
+			Driver: string(database.SQLite),
+			DSN:    "forum.db?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)",
+		},
+		Auth: AuthConfig{
+			HTTPAddr: ":3001",
+			GRPCAddr: ":50052",
+		},
+		Forum: ForumConfig{
+			HTTPAddr:         ":3002",
+			AuthGRPCAddr:     "localhost:50052",
+			ChatURL:          "http://localhost:3003",
+			DeletedRetention: 30 * 24 * time.Hour,
+			PurgeInterval:    time.Hour,
+		},
+		Chat: ChatConfig{
+			HTTPAddr:        ":3003",
+			AuthGRPCAddr:    "localhost:50052",
+			Retention:       24 * time.Hour,
+			CleanupInterval: 5 * time.Minute,
+		},
+		JWT: JWTConfig{
+			KeysDir:     "keys",
+			Algorithm:   jwt.AlgRS256,
+			AccessTTL:   15 * time.Minute,
+			RefreshTTL:  30 * 24 * time.Hour,
+			KeyRotation: 7 * 24 * time.Hour,
+			JWKSURL:     "http://localhost:3001/.well-known/jwks.json",
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"http://localhost:3000"},
+		},
+		Server: ServerConfig{
+			ShutdownTimeout: 15 * time.Second,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: logging.FormatConsole,
+		},
+		RateLimit: RateLimitConfig{
+			Login:           ratelimit.Limit{Requests: 10, Per: time.Minute},
+			Register:        ratelimit.Limit{Requests: 5, Per: time.Hour},
+			Refresh:         ratelimit.Limit{Requests: 30, Per: time.Minute},
+			Post:            ratelimit.Limit{Requests: 20, Per: time.Minute},
+			ChatMessage:     ratelimit.Limit{Requests: 10, Per: 10 * time.Second},
+			ChatMaxWarnings: 3,
+		},
+		Lockout: LockoutConfig{
+			Threshold:        5,
+			BaseDuration:     time.Minute,
+			MaxDuration:      time.Hour,
+			IPThreshold:      20,
+			IPWindow:         15 * time.Minute,
+			AttemptRetention: 90 * 24 * time.Hour,
+		},
+	}
+}
+
+// Load регистрирует флаги конфигурации в fs, разбирает args и собирает
+// итоговую конфигурацию. Вызывающий может заранее добавить в fs свои флаги.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := Default()
+
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file (env CONFIG_FILE)")
+	flags := make(map[string]*fieldFlag)
+	for _, field := range fields(&cfg) {
+		f := &fieldFlag{field: field}
+		flags[field.flag] = f
+		fs.Var(f, field.flag, fmt.Sprintf("%s (env %s)", field.usage, field.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(&cfg, *configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, field := range fields(&cfg) {
+		if value, ok := os.LookupEnv(field.env); ok {
+			if err := setValue(field.value, value); err != nil {
+				return nil, fmt.Errorf("env %s: %w", field.env, err)
+			}
+		}
+	}
+
+	// Флаги применяются последними, поверх файла и окружения
+	for _, field := range fields(&cfg) {
+		if f := flags[field.flag]; f.set {
+			if err := setValue(field.value, f.raw); err != nil {
+				return nil, fmt.Errorf("flag -%s: %w", field.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	var unmarshal func([]byte, any) error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		unmarshal = yaml.Unmarshal
+	case ".toml":
+		unmarshal = toml.Unmarshal
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	if err := unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate проверяет, что конфигурация пригодна для запуска сервисов
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	if _, err := database.ParseDialect(c.Database.Driver); err != nil {
+		errs = append(errs, fmt.Errorf("database.driver: %w", err))
+	}
+	check(c.Database.DSN != "", "database.dsn is required")
+
+	for _, addr := range []struct{ name, value string }{
+		{"auth.http_addr", c.Auth.HTTPAddr},
+		{"auth.grpc_addr", c.Auth.GRPCAddr},
+		{"forum.http_addr", c.Forum.HTTPAddr},
+		{"forum.auth_grpc_addr", c.Forum.AuthGRPCAddr},
+		{"chat.http_addr", c.Chat.HTTPAddr},
+		{"chat.auth_grpc_addr", c.Chat.AuthGRPCAddr},
+	} {
+		_, port, err := net.SplitHostPort(addr.value)
+		check(err == nil && port != "", "%s: invalid address %q", addr.name, addr.value)
+	}
+
+	check(c.Forum.DeletedRetention > 0, "forum.deleted_retention must be positive")
+	check(c.Forum.PurgeInterval > 0, "forum.purge_interval must be positive")
+	check(c.Chat.Retention > 0, "chat.retention must be positive")
+	check(c.Chat.CleanupInterval > 0, "chat.cleanup_interval must be positive")
+
+	check(c.JWT.KeysDir != "", "jwt.keys_dir is required")
+	check(c.JWT.Algorithm == jwt.AlgRS256 || c.JWT.Algorithm == jwt.AlgEdDSA,
+		"jwt.algorithm must be %s or %s, got %q", jwt.AlgRS256, jwt.AlgEdDSA, c.JWT.Algorithm)
+	check(c.JWT.AccessTTL > 0, "jwt.access_ttl must be positive")
+	check(c.JWT.RefreshTTL > c.JWT.AccessTTL, "jwt.refresh_ttl must be longer than jwt.access_ttl")
+	check(c.JWT.KeyRotation > 0, "jwt.key_rotation must be positive")
+	for _, u := range []struct{ name, value string }{
+		{"jwt.jwks_url", c.JWT.JWKSURL},
+		{"forum.chat_url", c.Forum.ChatURL},
+	} {
+		parsed, err := url.Parse(u.value)
+		check(err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "", "%s: invalid URL %q", u.name, u.value)
+	}
+
+	check(len(c.CORS.AllowedOrigins) > 0, "cors.allowed_origins must not be empty")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level must be debug, info, warn or error, got %q", c.Log.Level))
+	}
+	check(c.RateLimit.ChatMaxWarnings >= 0, "rate_limit.chat_max_warnings must not be negative")
+	check(c.Lockout.Threshold >= 0, "lockout.threshold must not be negative")
+	check(c.Lockout.Threshold == 0 || c.Lockout.BaseDuration > 0, "lockout.base_duration must be positive")
+	check(c.Lockout.MaxDuration >= c.Lockout.BaseDuration, "lockout.max_duration must not be shorter than lockout.base_duration")
+	check(c.Lockout.IPThreshold >= 0, "lockout.ip_threshold must not be negative")
+	check(c.Lockout.IPThreshold == 0 || c.Lockout.IPWindow > 0, "lockout.ip_window must be positive")
+	check(c.Lockout.AttemptRetention > 0, "lockout.attempt_retention must be positive")
+	check(c.Log.Format == logging.FormatConsole || c.Log.Format == logging.FormatJSON,
+		"log.format must be %s or %s, got %q", logging.FormatConsole, logging.FormatJSON, c.Log.Format)
+
+	return errors.Join(errs...)
+}
+
+// field поле конфигурации, которое можно задать окружением или флагом
+type field struct {
+	env   string
+	flag  string
+	usage string
+	value reflect.Value
+}
+
+func fields(cfg *Config) []field {
+	var out []field
+	sections := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Field(i)
+		for j := 0; j < section.NumField(); j++ {
+			sf := section.Type().Field(j)
+			env := sf.Tag.Get("env")
+			if env == "" {
+				continue
+			}
+			out = append(out, field{
+				env:   env,
+				flag:  strings.ReplaceAll(strings.ToLower(env), "_", "-"),
+				usage: sf.Tag.Get("usage"),
+				value: section.Field(j),
+			})
+		}
+	}
+	return out
+}
+
+// fieldFlag запоминает значение флага; применяется оно после файла и окружения
+type fieldFlag struct {
+	field field
+	raw   string
+	set   bool
+}
+
+func (f *fieldFlag) String() string {
+	if f == nil || !f.field.value.IsValid() {
+		return ""
+	}
+	return formatValue(f.field.value)
+}
+
+// IsBoolFlag позволяет писать булев флаг без значения: -rate-limit-trust-forwarded-for
+func (f *fieldFlag) IsBoolFlag() bool {
+	return f.field.value.IsValid() && f.field.value.Kind() == reflect.Bool
+}
+
+func (f *fieldFlag) Set(s string) error {
+	// Проверяем значение сразу, чтобы ошибка указывала на флаг
+	if err := setValue(reflect.New(f.field.value.Type()).Elem(), s); err != nil {
+		return err
+	}
+	f.raw, f.set = s, true
+	return nil
+}
+
+func setValue(v reflect.Value, s string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch v.Interface().(type) {
+	case string:
+		v.SetString(s)
+	case time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case []string:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported config field type %s", v.Type())
+	}
+	return nil
+}
+
+func formatValue(v reflect.Value) string {
+	switch val := v.Interface().(type) {
+	case []string:
+		return strings.Join(val, ",")
+	default:
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mos1rain/forum_go/pkg/ratelimit"
+)
+
+func load(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return Load(fs, args)
+}
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := load(t)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if want := Default(); !reflect.DeepEqual(*cfg, want) {
+		t.Errorf("expected defaults %+v, got %+v", want, *cfg)
+	}
+}
+
+// Флаги важнее окружения, окружение важнее файла
+func TestLoad_Precedence(t *testing.T) {
+	path := writeFile(t, "forum.yaml", `
+database:
+  dsn: from-file.db
+auth:
+  http_addr: ":4001"
+chat:
+  retention: 48h
+cors:
+  allowed_origins: ["https://file.example"]
+rate_limit:
+  login: 3/1m
+  post: "off"
+`)
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("AUTH_HTTP_ADDR", ":5001")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://a.example, https://b.example")
+	t.Setenv("JWT_ACCESS_TTL", "5m")
+
+	cfg, err := load(t, "-jwt-access-ttl", "10m", "-rate-limit-trust-forwarded-for")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if cfg.Database.DSN != "from-file.db" {
+		t.Errorf("dsn from file: got %q", cfg.Database.DSN)
+	}
+	if cfg.Chat.Retention != 48*time.Hour {
+		t.Errorf("retention from file: got %v", cfg.Chat.Retention)
+	}
+	if cfg.Auth.HTTPAddr != ":5001" {
+		t.Errorf("env must override file: got %q", cfg.Auth.HTTPAddr)
+	}
+	if want := []string{"https://a.example", "https://b.example"}; !reflect.DeepEqual(cfg.CORS.AllowedOrigins, want) {
+		t.Errorf("origins from env: got %v", cfg.CORS.AllowedOrigins)
+	}
+	if cfg.JWT.AccessTTL != 10*time.Minute {
+		t.Errorf("flag must override env: got %v", cfg.JWT.AccessTTL)
+	}
+	if cfg.RateLimit.Login != (ratelimit.Limit{Requests: 3, Per: time.Minute}) || cfg.RateLimit.Post.Enabled() {
+		t.Errorf("rate limits from file: got %v, %v", cfg.RateLimit.Login, cfg.RateLimit.Post)
+	}
+	if !cfg.RateLimit.TrustForwardedFor {
+		t.Error("bool flag without value must be true")
+	}
+	if cfg.Forum.HTTPAddr != ":3002" {
+		t.Errorf("unset fields keep defaults: got %q", cfg.Forum.HTTPAddr)
+	}
+}
+
+func TestLoad_TOML(t *testing.T) {
+	path := writeFile(t, "forum.toml", `
+[database]
+driver = "postgres"
+dsn = "postgres://localhost/forum"
+
+[jwt]
+algorithm = "EdDSA"
+`)
+	cfg, err := load(t, "-config", path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Database.Driver != "postgres" || cfg.Database.DSN != "postgres://localhost/forum" {
+		t.Errorf("unexpected database config %+v", cfg.Database)
+	}
+	if cfg.JWT.Algorithm != "EdDSA" {
+		t.Errorf("unexpected algorithm %q", cfg.JWT.Algorithm)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := map[string]struct {
+		args []string
+		env  map[string]string
+		want string
+	}{
+		"bad duration flag":  {args: []string{"-chat-retention", "soon"}, want: "chat-retention"},
+		"bad duration env":   {env: map[string]string{"JWT_REFRESH_TTL": "forever"}, want: "JWT_REFRESH_TTL"},
+		"unknown driver":     {args: []string{"-db-driver", "mysql"}, want: "database.driver"},
+		"bad address":        {args: []string{"-auth-http-addr", "3001"}, want: "auth.http_addr"},
+		"refresh too short":  {args: []string{"-jwt-refresh-ttl", "1m"}, want: "jwt.refresh_ttl"},
+		"bad algorithm":      {env: map[string]string{"JWT_ALGORITHM": "HS256"}, want: "jwt.algorithm"},
+		"no origins":         {args: []string{"-cors-allowed-origins", ""}, want: "cors.allowed_origins"},
+		"bad jwks url":       {args: []string{"-jwt-jwks-url", "localhost:3001"}, want: "jwt.jwks_url"},
+		"bad chat url":       {env: map[string]string{"FORUM_CHAT_URL": "chat:3003"}, want: "forum.chat_url"},
+		"no retention":       {env: map[string]string{"FORUM_DELETED_RETENTION": "0s"}, want: "forum.deleted_retention"},
+		"bad rate limit":     {env: map[string]string{"RATE_LIMIT_LOGIN": "ten per minute"}, want: "RATE_LIMIT_LOGIN"},
+		"bad log level":      {env: map[string]string{"LOG_LEVEL": "verbose"}, want: "log.level"},
+		"lockout max < base": {args: []string{"-lockout-max-duration", "30s"}, want: "lockout.max_duration"},
+		"unknown file type":  {args: []string{"-config", "forum.ini"}, want: "unsupported config file format"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := load(t, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error mentioning %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestCORSConfig_Allowed(t *testing.T) {
+	cors := CORSConfig{AllowedOrigins: []string{"http://localhost:3000"}}
+	if !cors.Allowed("http://localhost:3000") {
+		t.Error("configured origin must be allowed")
+	}
+	if cors.Allowed("http://evil.example") || cors.Allowed("") {
+		t.Error("other origins must be rejected")
+	}
+	if !(CORSConfig{AllowedOrigins: []string{"*"}}).Allowed("http://any.example") {
+		t.Error("* must allow any origin")
+	}
+}
+
+func TestCORSConfig_Middleware(t *testing.T) {
+	cors := CORSConfig{AllowedOrigins: []string{"http://localhost:3000"}}
+	called := false
+	handler := cors.Middleware("GET, OPTIONS")(func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Origin", "http://localhost:3000")
+	w := httptest.NewRecorder()
+	handler(w, r)
+	if !called || w.Header().Get("Access-Control-Allow-Origin") != "http://localhost:3000" || w.Header().Get("Access-Control-Allow-Methods") != "GET, OPTIONS" {
+		t.Errorf("allowed origin: called=%v headers=%v", called, w.Header())
+	}
+
+	r.Header.Set("Origin", "http://evil.example")
+	w = httptest.NewRecorder()
+	handler(w, r)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("other origins must not be allowed, got %q", got)
+	}
+
+	// Preflight не доходит до обработчика
+	called = false
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodOptions, "/", nil))
+	if called || w.Code != http.StatusOK {
+		t.Errorf("preflight: called=%v status=%d", called, w.Code)
+	}
+}
@@ -0,0 +1,86 @@
+// Package chat HTTP-клиент chat-сервиса: forum читает и удаляет сообщения
+// чата, на которые пожаловались пользователи
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/forum/models"
+	"github.com/mos1rain/forum_go/pkg/logging"
+)
+
+type Client struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewClient создаёт клиент chat-сервиса по адресу вида http://localhost:3003
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 3 * time.Second},
+	}
+}
+
+// Message возвращает сообщение чата или nil, если его нет
+func (c *Client) Message(ctx context.Context, id int64) (*models.ChatMessage, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/messages/"+strconv.FormatInt(id, 10), "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("get chat message: unexpected status %d", resp.StatusCode)
+	}
+	var msg models.ChatMessage
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		return nil, fmt.Errorf("decode chat message: %w", err)
+	}
+	return &msg, nil
+}
+
+// DeleteMessage удаляет сообщение от имени модератора, которому
+// принадлежит token; chat записывает удаление в журнал с причиной reason
+func (c *Client) DeleteMessage(ctx context.Context, token string, id int64, reason string) error {
+	query := url.Values{"id": {strconv.FormatInt(id, 10)}}
+	if reason != "" {
+		query.Set("reason", reason)
+	}
+	resp, err := c.do(ctx, http.MethodDelete, "/delete_message?"+query.Encode(), token)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("delete chat message: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, method, path, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Запросы связываются в логах forum и chat по одному идентификатору
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.client.Do(req)
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/mos1rain/forum_go/internal/forum/service"
+)
+
+// GetDeleted обрабатывает GET /api/forum/deleted/{type} — список удалённых
+// постов, комментариев или категорий (type: posts, comments, categories)
+func (h *ForumHandler) GetDeleted(w http.ResponseWriter, r *http.Request) {
+	actor, ok := actorFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	opts, err := listOptionsFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var page any
+	switch r.PathValue("type") {
+	case "posts":
+		page, err = h.service.Posts.ListDeleted(r.Context(), actor, opts)
+	case "comments":
+		page, err = h.service.Comments.ListDeleted(r.Context(), actor, opts)
+	case "categories":
+		page, err = h.service.Categories.ListDeleted(r.Context(), actor, opts)
+	default:
+		http.Error(w, "Unknown content type", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeDeletedError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// RestoreDeleted обрабатывает POST /api/forum/deleted/{type}/{id}/restore
+func (h *ForumHandler) RestoreDeleted(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var restored any
+	switch r.PathValue("type") {
+	case "posts":
+		restored, err = h.service.Posts.Restore(r.Context(), id, actor)
+	case "comments":
+		restored, err = h.service.Comments.Restore(r.Context(), id, actor)
+	case "categories":
+		restored, err = h.service.Categories.Restore(r.Context(), int64(id), actor)
+	default:
+		http.Error(w, "Unknown content type", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeDeletedError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(restored)
+}
+
+func writeDeletedError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidCursor), errors.Is(err, service.ErrInvalidSort):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrPermissionDenied):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, service.ErrPostNotFound), errors.Is(err, service.ErrCommentNotFound), errors.Is(err, service.ErrCategoryNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrAuditUnavailable):
+		http.Error(w, "audit log unavailable", http.StatusServiceUnavailable)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
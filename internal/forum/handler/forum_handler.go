@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/mos1rain/forum_go/internal/forum/models"
 	"github.com/mos1rain/forum_go/internal/forum/service"
@@ -19,9 +20,14 @@ func NewForumHandler(service *service.ForumService) *ForumHandler {
 }
 
 func (h *ForumHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
-	categories, err := h.service.Categories.GetAll(r.Context())
+	opts, err := listOptionsFromQuery(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	categories, err := h.service.Categories.List(r.Context(), opts)
+	if err != nil {
+		writeListError(w, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -53,9 +59,14 @@ func (h *ForumHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ForumHandler) GetPosts(w http.ResponseWriter, r *http.Request) {
-	posts, err := h.service.Posts.GetAll()
+	opts, err := listOptionsFromQuery(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	posts, err := h.service.Posts.List(opts)
+	if err != nil {
+		writeListError(w, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -98,7 +109,11 @@ func (h *ForumHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	// Устанавливаем ID пользователя
 	post.AuthorID = int64(userID)
 
-	if err := h.service.Posts.Create(&post); err != nil {
+	if err := h.service.Posts.Create(r.Context(), &post); err != nil {
+		if errors.Is(err, service.ErrCategoryNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -106,8 +121,122 @@ func (h *ForumHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(post)
 }
 
-func (h *ForumHandler) GetPostByID(id int) (*models.Post, error) {
-	return h.service.Posts.GetByID(id)
+// GetCategoryPosts обрабатывает GET /api/forum/categories/{id}/posts
+func (h *ForumHandler) GetCategoryPosts(w http.ResponseWriter, r *http.Request) {
+	categoryID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+	opts, err := listOptionsFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	posts, err := h.service.Posts.ListByCategory(r.Context(), categoryID, opts)
+	if err != nil {
+		if errors.Is(err, service.ErrCategoryNotFound) {
+			http.Error(w, "Category not found", http.StatusNotFound)
+			return
+		}
+		writeListError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(posts)
+}
+
+// GetUserPosts обрабатывает GET /api/forum/users/{id}/posts
+func (h *ForumHandler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	opts, err := listOptionsFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	posts, err := h.service.Posts.ListByAuthor(r.Context(), userID, opts)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		writeListError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(posts)
+}
+
+// GetPost обрабатывает GET /api/forum/posts/{id}. Удалённый пост не отдаётся.
+func (h *ForumHandler) GetPost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid post id", http.StatusBadRequest)
+		return
+	}
+	post, err := h.service.Posts.GetByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if post == nil {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
+}
+
+// UpdatePost обрабатывает PUT/PATCH /api/forum/posts/{id}.
+// PUT заменяет все поля поста, PATCH меняет только переданные.
+func (h *ForumHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid post id", http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input models.UpdatePostInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodPut && (input.Title == nil || input.Content == nil || input.CategoryID == nil) {
+		http.Error(w, "title, content and category_id are required", http.StatusBadRequest)
+		return
+	}
+
+	post, err := h.service.Posts.Update(r.Context(), id, input, actor)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrPostNotFound):
+			http.Error(w, "Post not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrPermissionDenied):
+			http.Error(w, "You don't have permission to edit this post", http.StatusForbidden)
+		case errors.Is(err, service.ErrInvalidPost):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, service.ErrCategoryNotFound):
+			http.Error(w, "Category not found", http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
 }
 
 func (h *ForumHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
@@ -117,8 +246,25 @@ func (h *ForumHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid post ID", http.StatusBadRequest)
 		return
 	}
-	if err := h.service.Posts.Delete(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+	actor, ok := actorFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// reason — необязательная причина удаления для журнала модерации
+	if err := h.service.Posts.Delete(r.Context(), id, actor, r.URL.Query().Get("reason")); err != nil {
+		switch {
+		case errors.Is(err, service.ErrPostNotFound):
+			http.Error(w, "Post not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrPermissionDenied):
+			http.Error(w, "You don't have permission to delete this post", http.StatusForbidden)
+		case errors.Is(err, service.ErrAuditUnavailable):
+			http.Error(w, "audit log unavailable", http.StatusServiceUnavailable)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -132,9 +278,26 @@ func (h *ForumHandler) GetCommentsByPost(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Invalid post ID", http.StatusBadRequest)
 		return
 	}
-	comments, err := h.service.Comments.GetByPostID(postID)
+	opts, err := listOptionsFromQuery(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// view=flat (по умолчанию) возвращает список с уровнем вложенности,
+	// view=tree возвращает корневые комментарии с вложенными ответами
+	var comments interface{}
+	switch r.URL.Query().Get("view") {
+	case "", "flat":
+		comments, err = h.service.Comments.GetByPostID(postID, opts)
+	case "tree":
+		comments, err = h.service.Comments.GetTree(postID, opts)
+	default:
+		http.Error(w, "Invalid view, expected flat or tree", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeListError(w, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -147,8 +310,30 @@ func (h *ForumHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err := h.service.Comments.Create(&comment); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+	actor, ok := actorFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Автор всегда берётся из токена, а не из тела запроса
+	comment.ID = 0
+	comment.AuthorID = actor.UserID
+	comment.Deleted = false
+	comment.Replies = nil
+
+	if err := h.service.Comments.Create(r.Context(), &comment); err != nil {
+		switch {
+		case errors.Is(err, service.ErrPostNotFound):
+			http.Error(w, "Post not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrPostLocked):
+			http.Error(w, "Post is locked", http.StatusForbidden)
+		case errors.Is(err, service.ErrInvalidParent), errors.Is(err, service.ErrMaxDepthExceeded):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
@@ -162,8 +347,27 @@ func (h *ForumHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid comment ID", http.StatusBadRequest)
 		return
 	}
-	if err := h.service.Comments.Delete(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+	actor, ok := actorFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// reason — необязательная причина удаления для журнала модерации
+	if err := h.service.Comments.Delete(r.Context(), id, actor, r.URL.Query().Get("reason")); err != nil {
+		switch {
+		case errors.Is(err, service.ErrCommentNotFound):
+			http.Error(w, "Comment not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrPostNotFound):
+			http.Error(w, "Post not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrPermissionDenied):
+			http.Error(w, "You don't have permission to delete this comment", http.StatusForbidden)
+		case errors.Is(err, service.ErrAuditUnavailable):
+			http.Error(w, "audit log unavailable", http.StatusServiceUnavailable)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -178,19 +382,20 @@ func (h *ForumHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Получаем роль пользователя из контекста
-	userRole, ok := r.Context().Value("user_role").(string)
+	actor, ok := actorFromContext(r)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	if err := h.service.Categories.Delete(r.Context(), id, userRole); err != nil {
+	if err := h.service.Categories.Delete(r.Context(), id, actor, r.URL.Query().Get("reason")); err != nil {
 		switch {
 		case errors.Is(err, service.ErrAdminRoleRequired):
 			http.Error(w, err.Error(), http.StatusForbidden)
 		case errors.Is(err, service.ErrCategoryNotFound):
 			http.Error(w, "Category not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrAuditUnavailable):
+			http.Error(w, "audit log unavailable", http.StatusServiceUnavailable)
 		default:
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
@@ -200,3 +405,248 @@ func (h *ForumHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Category deleted successfully"})
 }
+
+// LockPost обрабатывает PUT /api/forum/posts/{id}/lock
+func (h *ForumHandler) LockPost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid post id", http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input models.LockPostInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	post, err := h.service.Posts.SetLocked(r.Context(), id, input.Locked, actor)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrPostNotFound):
+			http.Error(w, "Post not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrPermissionDenied):
+			http.Error(w, "You don't have permission to lock this post", http.StatusForbidden)
+		case errors.Is(err, service.ErrAuditUnavailable):
+			http.Error(w, "audit log unavailable", http.StatusServiceUnavailable)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
+}
+
+// GetCategoryModerators обрабатывает GET /api/forum/categories/{id}/moderators
+func (h *ForumHandler) GetCategoryModerators(w http.ResponseWriter, r *http.Request) {
+	categoryID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	moderators, err := h.service.Categories.Moderators(r.Context(), categoryID)
+	if err != nil {
+		if errors.Is(err, service.ErrCategoryNotFound) {
+			http.Error(w, "Category not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(moderators)
+}
+
+// AssignCategoryModerator обрабатывает POST /api/forum/categories/{id}/moderators.
+// Повторное назначение не ошибка: отвечает 200 вместо 201.
+func (h *ForumHandler) AssignCategoryModerator(w http.ResponseWriter, r *http.Request) {
+	categoryID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input models.AssignModeratorInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if input.UserID <= 0 {
+		http.Error(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+
+	moderator, created, err := h.service.Categories.AssignModerator(r.Context(), categoryID, input.UserID, actor)
+	if err != nil {
+		writeModeratorError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(moderator)
+}
+
+// RemoveCategoryModerator обрабатывает DELETE /api/forum/categories/{id}/moderators/{user_id}
+func (h *ForumHandler) RemoveCategoryModerator(w http.ResponseWriter, r *http.Request) {
+	categoryID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.Categories.RemoveModerator(r.Context(), categoryID, userID, actor); err != nil {
+		writeModeratorError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeModeratorError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrPermissionDenied):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, service.ErrCategoryNotFound):
+		http.Error(w, "Category not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrUserNotModerator):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrAuditUnavailable):
+		http.Error(w, "audit log unavailable", http.StatusServiceUnavailable)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// actorFromContext возвращает пользователя, установленного AuthMiddleware
+func actorFromContext(r *http.Request) (service.Actor, bool) {
+	userID, ok := r.Context().Value("user_id").(int)
+	if !ok {
+		return service.Actor{}, false
+	}
+	role, _ := r.Context().Value("user_role").(string)
+	token, _ := r.Context().Value("token").(string)
+	return service.Actor{UserID: int64(userID), Role: role, Token: token}, true
+}
+
+// Search обрабатывает GET /api/forum/search?q=...
+// Фильтры: type (post|comment), category_id, author_id, from и to
+// (RFC 3339 или YYYY-MM-DD; дата в to включается целиком).
+func (h *ForumHandler) Search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := models.SearchQuery{Query: q.Get("q"), Type: q.Get("type")}
+
+	var err error
+	if v := q.Get("category_id"); v != "" {
+		if query.CategoryID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			http.Error(w, "Invalid category ID", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("author_id"); v != "" {
+		if query.AuthorID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			http.Error(w, "Invalid author ID", http.StatusBadRequest)
+			return
+		}
+	}
+	if query.From, err = parseSearchTime(q.Get("from"), false); err != nil {
+		http.Error(w, "Invalid from date", http.StatusBadRequest)
+		return
+	}
+	if query.To, err = parseSearchTime(q.Get("to"), true); err != nil {
+		http.Error(w, "Invalid to date", http.StatusBadRequest)
+		return
+	}
+
+	opts, err := listOptionsFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := h.service.Search.Search(r.Context(), query, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrEmptyQuery),
+			errors.Is(err, service.ErrInvalidSearchType),
+			errors.Is(err, service.ErrInvalidDateRange):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			writeListError(w, err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// parseSearchTime разбирает границу периода поиска. Для верхней границы,
+// заданной датой без времени, возвращается начало следующего дня.
+func parseSearchTime(v string, end bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// listOptionsFromQuery читает параметры страницы limit, cursor и sort
+func listOptionsFromQuery(r *http.Request) (models.ListOptions, error) {
+	q := r.URL.Query()
+	opts := models.ListOptions{Cursor: q.Get("cursor"), Sort: q.Get("sort")}
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return opts, errors.New("invalid limit")
+		}
+		opts.Limit = n
+	}
+	return opts, nil
+}
+
+func writeListError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidCursor), errors.Is(err, service.ErrInvalidSort):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mos1rain/forum_go/internal/forum/models"
+	"github.com/mos1rain/forum_go/internal/forum/repository"
+	"github.com/mos1rain/forum_go/internal/forum/service"
+	"github.com/mos1rain/forum_go/pkg/database"
+	"github.com/mos1rain/forum_go/pkg/database/dbtest"
+)
+
+type allUsers struct{}
+
+func (allUsers) UserExists(ctx context.Context, id int64) (bool, error) { return true, nil }
+
+// newPostFixture создаёт сервис форума на db и пост автора 1
+func newPostFixture(t *testing.T, db *database.DB) (*service.ForumService, *models.Post) {
+	t.Helper()
+	categories := repository.NewCategoryRepository(db)
+	posts := repository.NewPostRepository(db)
+	fs := service.NewForumService(categories, posts, repository.NewCommentRepository(db), repository.NewSearchRepository(db), allUsers{})
+
+	category := &models.Category{Name: "general", CreatorID: 1}
+	if err := categories.CreateCategory(context.Background(), category); err != nil {
+		t.Fatalf("create category: %v", err)
+	}
+	post := &models.Post{Title: "title", Content: "content", CategoryID: category.ID, AuthorID: 1}
+	if err := posts.Create(post); err != nil {
+		t.Fatalf("create post: %v", err)
+	}
+	return fs, post
+}
+
+func TestGetPost(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		fs, post := newPostFixture(t, db)
+		mux := http.NewServeMux()
+		mux.HandleFunc("/api/forum/posts/{id}", NewForumHandler(fs).GetPost)
+
+		get := func(path string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			return w
+		}
+
+		w := get("/api/forum/posts/1")
+		var got models.Post
+		if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&got) != nil || got.ID != post.ID {
+			t.Fatalf("get post: %d %s", w.Code, w.Body.String())
+		}
+		if w := get("/api/forum/posts/abc"); w.Code != http.StatusBadRequest {
+			t.Errorf("invalid id: expected 400, got %d", w.Code)
+		}
+		if w := get("/api/forum/posts/42"); w.Code != http.StatusNotFound {
+			t.Errorf("missing post: expected 404, got %d", w.Code)
+		}
+
+		if err := fs.Posts.Delete(context.Background(), int(post.ID), service.Actor{UserID: 1, Role: "user"}, ""); err != nil {
+			t.Fatalf("delete post: %v", err)
+		}
+		if w := get("/api/forum/posts/1"); w.Code != http.StatusNotFound {
+			t.Errorf("deleted post: expected 404, got %d %s", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestUpdatePost(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		fs, _ := newPostFixture(t, db)
+		mux := http.NewServeMux()
+		mux.HandleFunc("/api/forum/posts/{id}", NewForumHandler(fs).UpdatePost)
+
+		patch := func(path, body string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest(http.MethodPatch, path, strings.NewReader(body))
+			r = r.WithContext(context.WithValue(r.Context(), "user_id", 1))
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			return w
+		}
+
+		w := patch("/api/forum/posts/1", `{"title":"new title"}`)
+		var got models.Post
+		if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&got) != nil || got.Title != "new title" {
+			t.Fatalf("update post: %d %s", w.Code, w.Body.String())
+		}
+		if w := patch("/api/forum/posts/abc", `{"title":"x"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("invalid id: expected 400, got %d", w.Code)
+		}
+		if w := patch("/api/forum/posts/42", `{"title":"x"}`); w.Code != http.StatusNotFound {
+			t.Errorf("missing post: expected 404, got %d", w.Code)
+		}
+	})
+}
+
+func TestCreatePost(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		fs, _ := newPostFixture(t, db)
+		handler := NewForumHandler(fs).CreatePost
+
+		create := func(body string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest(http.MethodPost, "/api/forum/create_post", strings.NewReader(body))
+			r = r.WithContext(context.WithValue(r.Context(), "user_id", 1))
+			w := httptest.NewRecorder()
+			handler(w, r)
+			return w
+		}
+
+		if w := create(`{"title":"t","content":"c","categoryId":"1"}`); w.Code != http.StatusCreated {
+			t.Fatalf("create post: %d %s", w.Code, w.Body.String())
+		}
+		if err := fs.Categories.Delete(context.Background(), 1, service.Actor{UserID: 1, Role: service.RoleAdmin}, ""); err != nil {
+			t.Fatalf("delete category: %v", err)
+		}
+		for _, id := range []string{"1", "42"} {
+			if w := create(`{"title":"t","content":"c","categoryId":"` + id + `"}`); w.Code != http.StatusNotFound {
+				t.Errorf("category %s: expected 404, got %d", id, w.Code)
+			}
+		}
+	})
+}
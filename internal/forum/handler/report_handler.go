@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/mos1rain/forum_go/internal/forum/grpc"
+	"github.com/mos1rain/forum_go/internal/forum/models"
+	"github.com/mos1rain/forum_go/internal/forum/service"
+)
+
+// CreateReport обрабатывает POST /api/forum/reports
+func (h *ForumHandler) CreateReport(w http.ResponseWriter, r *http.Request) {
+	actor, ok := actorFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input models.CreateReportInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.Reports.Create(r.Context(), input, actor)
+	if err != nil {
+		writeReportError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetReports обрабатывает GET /api/forum/reports — очередь модерации.
+// Фильтры: status (open по умолчанию, all — любые), target_type.
+func (h *ForumHandler) GetReports(w http.ResponseWriter, r *http.Request) {
+	actor, ok := actorFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := models.ReportFilter{Status: q.Get("status"), TargetType: q.Get("target_type")}
+	switch filter.Status {
+	case "":
+		filter.Status = models.ReportStatusOpen
+	case "all":
+		filter.Status = ""
+	}
+	opts, err := listOptionsFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reports, err := h.service.Reports.List(r.Context(), filter, opts, actor)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) || errors.Is(err, service.ErrInvalidSort) {
+			writeListError(w, err)
+			return
+		}
+		writeReportError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reports)
+}
+
+// GetReport обрабатывает GET /api/forum/reports/{id}
+func (h *ForumHandler) GetReport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	report, err := h.service.Reports.GetByID(r.Context(), id, actor)
+	if err != nil {
+		writeReportError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// ResolveReport обрабатывает POST /api/forum/reports/{id}/resolve
+func (h *ForumHandler) ResolveReport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input models.ResolveReportInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.Reports.Resolve(r.Context(), id, input, actor)
+	if err != nil {
+		writeReportError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func writeReportError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidReport), errors.Is(err, service.ErrInvalidAction):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrPermissionDenied):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, grpc.ErrRejected):
+		// auth отказал в санкции: нет прав на автора или он не найден
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, service.ErrReportNotFound):
+		http.Error(w, "Report not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrTargetNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrAlreadyReported), errors.Is(err, service.ErrReportClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrAuditUnavailable):
+		http.Error(w, "audit log unavailable", http.StatusServiceUnavailable)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/mos1rain/forum_go/internal/forum/service"
+)
+
+// GetPostRevisions обрабатывает GET /api/forum/posts/{id}/revisions
+func (h *ForumHandler) GetPostRevisions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid post id", http.StatusBadRequest)
+		return
+	}
+	opts, err := listOptionsFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	revisions, err := h.service.Posts.ListRevisions(id, opts)
+	if err != nil {
+		writeRevisionError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// DiffPostRevisions обрабатывает GET /api/forum/posts/{id}/revisions/diff?from=1&to=3 —
+// разница между ревизиями в формате unified diff
+func (h *ForumHandler) DiffPostRevisions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid post id", http.StatusBadRequest)
+		return
+	}
+	q := r.URL.Query()
+	from, errFrom := strconv.Atoi(q.Get("from"))
+	to, errTo := strconv.Atoi(q.Get("to"))
+	if errFrom != nil || errTo != nil {
+		http.Error(w, "from and to must be revision numbers", http.StatusBadRequest)
+		return
+	}
+
+	diff, err := h.service.Posts.DiffRevisions(id, from, to)
+	if err != nil {
+		writeRevisionError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+	io.WriteString(w, diff)
+}
+
+// RollbackPost обрабатывает POST /api/forum/posts/{id}/revisions/{revision}/rollback
+func (h *ForumHandler) RollbackPost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid post id", http.StatusBadRequest)
+		return
+	}
+	revision, err := strconv.Atoi(r.PathValue("revision"))
+	if err != nil {
+		http.Error(w, "Invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	actor, ok := actorFromContext(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	post, err := h.service.Posts.Rollback(r.Context(), id, revision, actor)
+	if err != nil {
+		writeRevisionError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
+}
+
+func writeRevisionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidCursor), errors.Is(err, service.ErrInvalidSort):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrPostNotFound):
+		http.Error(w, "Post not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrRevisionNotFound):
+		http.Error(w, "Revision not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrPermissionDenied):
+		http.Error(w, "You don't have permission to edit this post", http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
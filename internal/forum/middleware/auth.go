@@ -2,12 +2,15 @@ package middleware
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/mos1rain/forum_go/internal/forum/grpc"
 	"github.com/mos1rain/forum_go/pkg/jwt"
+	"github.com/mos1rain/forum_go/pkg/logging"
+	"github.com/mos1rain/forum_go/pkg/rbac"
+	"github.com/mos1rain/forum_go/pkg/sanction"
+	"github.com/rs/zerolog"
 )
 
 var (
@@ -21,13 +24,18 @@ func SetAuthClient(client *grpc.AuthGRPCClient) {
 
 func SetTokenManager(tm *jwt.TokenManager) {
 	tokenManager = tm
-	fmt.Printf("TokenManager set with key: %v\n", tm.GetSigningKey())
+}
+
+// AuthUserID возвращает пользователя, которого AuthMiddleware положил в контекст
+func AuthUserID(r *http.Request) (int, bool) {
+	id, ok := r.Context().Value("user_id").(int)
+	return id, ok
 }
 
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := zerolog.Ctx(r.Context())
 		token := r.Header.Get("Authorization")
-		fmt.Printf("Received token: %s\n", token)
 
 		if token == "" {
 			http.Error(w, "unauthorized: no token provided", http.StatusUnauthorized)
@@ -36,31 +44,74 @@ func AuthMiddleware(next http.Handler) http.Handler {
 
 		// Убираем "Bearer " из токена
 		token = strings.TrimPrefix(token, "Bearer ")
-		fmt.Printf("Token after trim: %s\n", token)
 
 		// Валидируем токен
 		if tokenManager == nil {
-			fmt.Println("TokenManager is nil!")
+			logger.Error().Msg("Token manager is not initialized")
 			http.Error(w, "token manager not initialized", http.StatusInternalServerError)
 			return
 		}
 
 		claims, err := tokenManager.Parse(token)
 		if err != nil {
-			fmt.Printf("Token validation error: %v\n", err)
+			logger.Info().Err(err).Msg("Rejected invalid token")
 			http.Error(w, "invalid token", http.StatusUnauthorized)
 			return
 		}
 
-		fmt.Printf("Token validated successfully. UserID: %d, Username: %s, Role: %s\n",
-			claims.UserID, claims.Username, claims.Role)
+		// Проверяем в auth-сервисе, не отозвана ли сессия токена
+		if authClient != nil {
+			resp, err := authClient.ValidateToken(r.Context(), token)
+			if err != nil {
+				logger.Error().Err(err).Msg("Failed to validate token in auth service")
+				http.Error(w, "auth service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			if !resp.Valid {
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+
+			// AuthMiddleware стоит только на изменяющих маршрутах, поэтому
+			// забаненный или отстранённый пользователь получает отказ
+			active, err := authClient.ActiveSanctions(r.Context(), claims.UserID)
+			if err != nil {
+				logger.Error().Err(err).Msg("Failed to check user sanctions in auth service")
+				http.Error(w, "auth service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			if restriction := sanction.Find(active, sanction.Forum); restriction != nil {
+				logger.Info().Int("user_id", claims.UserID).Str("sanction", string(restriction.Type)).Msg("Rejected sanctioned user")
+				sanction.WriteHTTP(w, *restriction)
+				return
+			}
+		}
+
+		logging.SetUserID(r.Context(), claims.UserID)
 
 		// Добавляем данные пользователя в контекст
 		ctx := context.WithValue(r.Context(), "user_id", claims.UserID)
 		ctx = context.WithValue(ctx, "username", claims.Username)
 		ctx = context.WithValue(ctx, "user_role", claims.Role)
+		// Токен нужен, когда forum действует от имени пользователя в auth и chat
+		ctx = context.WithValue(ctx, "token", token)
 
 		// Передаем запрос дальше с обновленным контекстом
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// RequirePermission пропускает только пользователей, чья роль даёт право
+// perm на всём форуме. Ставится после AuthMiddleware. Права модераторов
+// категорий зависят от конкретного поста и проверяются в сервисах.
+func RequirePermission(perm rbac.Permission, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, _ := r.Context().Value("user_role").(string)
+		if !rbac.Default.Can(role, perm) {
+			zerolog.Ctx(r.Context()).Warn().Str("role", role).Str("permission", string(perm)).Msg("Access denied")
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
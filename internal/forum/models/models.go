@@ -5,33 +5,218 @@ import "time"
 // Category represents a forum category
 // @Description Forum category information
 type Category struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name"`        // Название категории
-	Description string    `json:"description"` // Описание категории
-	CreatorID   int64     `json:"creator_id"`  // ID создателя категории
-	CreatedAt   time.Time `json:"created_at"`  // Дата создания
-	UpdatedAt   time.Time `json:"updated_at"`  // Дата последнего обновления
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`                 // Название категории
+	Description string     `json:"description"`          // Описание категории
+	CreatorID   int64      `json:"creator_id"`           // ID создателя категории
+	CreatedAt   time.Time  `json:"created_at"`           // Дата создания
+	UpdatedAt   time.Time  `json:"updated_at"`           // Дата последнего обновления
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"` // Дата удаления (только в списке удалённых)
+	DeletedBy   *int64     `json:"deleted_by,omitempty"` // Кто удалил (только в списке удалённых)
 }
 
 // Post represents a forum post
 // @Description Forum post information
 type Post struct {
-	ID         int64     `json:"id"`
-	Title      string    `json:"title"`       // Заголовок поста
-	Content    string    `json:"content"`     // Содержание поста
-	CategoryID int64     `json:"category_id"` // ID категории
-	AuthorID   int64     `json:"author_id"`   // ID автора
-	CreatedAt  time.Time `json:"created_at"`  // Дата создания
-	UpdatedAt  time.Time `json:"updated_at"`  // Дата последнего обновления
+	ID         int64      `json:"id"`
+	Title      string     `json:"title"`                // Заголовок поста
+	Content    string     `json:"content"`              // Содержание поста
+	CategoryID int64      `json:"category_id"`          // ID категории
+	AuthorID   int64      `json:"author_id"`            // ID автора
+	Locked     bool       `json:"locked"`               // Закрыт ли пост для новых комментариев
+	CreatedAt  time.Time  `json:"created_at"`           // Дата создания
+	UpdatedAt  time.Time  `json:"updated_at"`           // Дата последнего обновления
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"` // Дата удаления (только в списке удалённых)
+	DeletedBy  *int64     `json:"deleted_by,omitempty"` // Кто удалил (только в списке удалённых)
+}
+
+// UpdatePostInput represents a post update request
+// @Description Fields to change; omitted fields keep their current values
+type UpdatePostInput struct {
+	Title      *string `json:"title"`       // Новый заголовок
+	Content    *string `json:"content"`     // Новое содержание
+	CategoryID *int64  `json:"category_id"` // Новая категория
+}
+
+// PostRevision represents a stored version of a post
+// @Description Post title and content after an edit
+type PostRevision struct {
+	ID        int64     `json:"id"`
+	PostID    int64     `json:"post_id"`             // ID поста
+	Revision  int       `json:"revision"`            // Номер ревизии, 1 — пост при создании
+	EditorID  *int64    `json:"editor_id,omitempty"` // Кто создал ревизию
+	Title     string    `json:"title"`               // Заголовок после правки
+	Content   string    `json:"content"`             // Содержание после правки
+	CreatedAt time.Time `json:"created_at"`          // Дата правки
+}
+
+// LockPostInput represents a thread lock request
+// @Description Whether the post is closed for new comments
+type LockPostInput struct {
+	Locked bool `json:"locked"` // Закрыть (true) или открыть (false) пост
+}
+
+// CategoryModerator represents a moderator assigned to a category
+// @Description User who moderates posts and comments of a single category
+type CategoryModerator struct {
+	CategoryID int64     `json:"category_id"`           // ID категории
+	UserID     int64     `json:"user_id"`               // ID модератора
+	AssignedBy *int64    `json:"assigned_by,omitempty"` // ID администратора, назначившего модератора
+	CreatedAt  time.Time `json:"created_at"`            // Дата назначения
+}
+
+// AssignModeratorInput represents a category moderator assignment request
+// @Description User to assign as category moderator
+type AssignModeratorInput struct {
+	UserID int64 `json:"user_id"` // ID пользователя
+}
+
+// ChatMessage represents a chat message as returned by the chat service
+// @Description Chat message that can be reported
+type ChatMessage struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`    // ID автора
+	Username  string    `json:"username"`   // Имя автора
+	Content   string    `json:"content"`    // Текст сообщения
+	CreatedAt time.Time `json:"created_at"` // Дата отправки
+}
+
+// Объекты, на которые можно пожаловаться
+const (
+	ReportTargetPost        = "post"
+	ReportTargetComment     = "comment"
+	ReportTargetChatMessage = "chat_message"
+)
+
+// Статусы жалоб
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusActioned  = "actioned"
+	ReportStatusDismissed = "dismissed"
+)
+
+// Решения модератора по жалобе
+const (
+	ReportActionDelete  = "delete"  // Удалить объект жалобы
+	ReportActionWarn    = "warn"    // Предупредить автора
+	ReportActionSuspend = "suspend" // Отстранить автора на срок
+	ReportActionDismiss = "dismiss" // Отклонить жалобу
+)
+
+// Report represents a user report about a post, comment or chat message
+// @Description Report in the moderation queue
+type Report struct {
+	ID             int64      `json:"id"`
+	TargetType     string     `json:"target_type"`               // post, comment или chat_message
+	TargetID       int64      `json:"target_id"`                 // ID объекта жалобы
+	TargetAuthorID int64      `json:"target_author_id"`          // ID автора объекта
+	CategoryID     *int64     `json:"category_id,omitempty"`     // Категория поста или комментария
+	Snapshot       string     `json:"snapshot"`                  // Текст объекта на момент жалобы
+	ReporterID     int64      `json:"reporter_id"`               // ID пожаловавшегося
+	Reason         string     `json:"reason"`                    // Причина жалобы
+	Status         string     `json:"status"`                    // open, actioned или dismissed
+	Action         string     `json:"action,omitempty"`          // Решение модератора
+	ResolutionNote string     `json:"resolution_note,omitempty"` // Комментарий модератора
+	ResolvedBy     *int64     `json:"resolved_by,omitempty"`     // ID модератора, закрывшего жалобу
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`     // Дата решения
+	CreatedAt      time.Time  `json:"created_at"`                // Дата жалобы
+}
+
+// CreateReportInput represents a report request
+// @Description Content to report and the reason
+type CreateReportInput struct {
+	TargetType string `json:"target_type"` // post, comment или chat_message
+	TargetID   int64  `json:"target_id"`   // ID объекта
+	Reason     string `json:"reason"`      // Причина жалобы
+}
+
+// ResolveReportInput represents a moderator decision on a report
+// @Description Action to take; duration is required for suspend
+type ResolveReportInput struct {
+	Action   string `json:"action"`             // delete, warn, suspend или dismiss
+	Reason   string `json:"reason"`             // Комментарий модератора и причина санкции
+	Duration string `json:"duration,omitempty"` // Срок отстранения, например 72h
+}
+
+// ReportFilter narrows the moderation queue; empty fields are not applied
+type ReportFilter struct {
+	Status     string // Только жалобы в статусе
+	TargetType string // Только жалобы на объекты вида
+}
+
+// PostFilter narrows a post list; zero fields are not applied
+type PostFilter struct {
+	CategoryID int64 // Только посты категории
+	AuthorID   int64 // Только посты автора
 }
 
 // Comment represents a forum comment
 // @Description Forum comment information
 type Comment struct {
-	ID        int64     `json:"id"`
-	Content   string    `json:"content"`    // Содержание комментария
-	PostID    int64     `json:"post_id"`    // ID поста
-	AuthorID  int64     `json:"author_id"`  // ID автора
-	CreatedAt time.Time `json:"created_at"` // Дата создания
-	UpdatedAt time.Time `json:"updated_at"` // Дата последнего обновления
+	ID        int64      `json:"id"`
+	Content   string     `json:"content"`              // Содержание комментария
+	PostID    int64      `json:"post_id"`              // ID поста
+	AuthorID  int64      `json:"author_id"`            // ID автора
+	ParentID  *int64     `json:"parent_id"`            // ID комментария, на который это ответ
+	Depth     int        `json:"depth"`                // Уровень вложенности, 0 для корневых
+	Deleted   bool       `json:"deleted"`              // Комментарий удалён, но на него есть ответы
+	Replies   []*Comment `json:"replies,omitempty"`    // Ответы (только в режиме дерева)
+	CreatedAt time.Time  `json:"created_at"`           // Дата создания
+	UpdatedAt time.Time  `json:"updated_at"`           // Дата последнего обновления
+	DeletedAt *time.Time `json:"deleted_at,omitempty"` // Дата удаления (только в списке удалённых)
+	DeletedBy *int64     `json:"deleted_by,omitempty"` // Кто удалил (только в списке удалённых)
+}
+
+// Режимы сортировки списков
+const (
+	SortNewest         = "newest"
+	SortOldest         = "oldest"
+	SortMostCommented  = "most_commented"
+	SortRecentlyActive = "recently_active"
+	SortRelevance      = "relevance"
+)
+
+// ListOptions describes a page request
+type ListOptions struct {
+	Limit  int    // Размер страницы
+	Cursor string // Курсор из next_cursor предыдущей страницы
+	Sort   string // Режим сортировки
+}
+
+// Page is a single page of a list
+// @Description Page of results with a cursor for the next page
+type Page[T any] struct {
+	Items      []T    `json:"items"`                 // Элементы страницы
+	NextCursor string `json:"next_cursor,omitempty"` // Курсор следующей страницы, пустой на последней
+	Total      int    `json:"total"`                 // Общее количество элементов
+}
+
+// Типы документов в результатах поиска
+const (
+	SearchTypePost    = "post"
+	SearchTypeComment = "comment"
+)
+
+// SearchQuery describes a full-text search request
+type SearchQuery struct {
+	Query      string    // Поисковая строка
+	Type       string    // post, comment или пусто для обоих
+	CategoryID int64     // Только в категории
+	AuthorID   int64     // Только от автора
+	From       time.Time // Создано не раньше (включительно)
+	To         time.Time // Создано раньше (не включительно)
+}
+
+// SearchResult represents a single search hit
+// @Description Post or comment matching a search query
+type SearchResult struct {
+	Type       string    `json:"type"`        // post или comment
+	ID         int64     `json:"id"`          // ID поста или комментария
+	PostID     int64     `json:"post_id"`     // ID поста, к которому относится результат
+	CategoryID int64     `json:"category_id"` // ID категории поста
+	AuthorID   int64     `json:"author_id"`   // ID автора
+	Title      string    `json:"title"`       // Заголовок поста, совпадения выделены <mark>
+	Snippet    string    `json:"snippet"`     // Фрагмент текста, совпадения выделены <mark>
+	Rank       float64   `json:"rank"`        // Релевантность, чем меньше, тем лучше
+	CreatedAt  time.Time `json:"created_at"`  // Дата создания
 }
@@ -6,37 +6,37 @@ import (
 	"time"
 
 	"github.com/mos1rain/forum_go/internal/forum/models"
+	"github.com/mos1rain/forum_go/pkg/database"
 )
 
 type CategoryRepository struct {
-	db *sql.DB
+	db *database.DB
 }
 
 type CategoryRepositoryInterface interface {
 	CreateCategory(ctx context.Context, category *models.Category) error
 	GetCategories(ctx context.Context) ([]*models.Category, error)
+	ListCategories(ctx context.Context, opts models.ListOptions) (*models.Page[*models.Category], error)
 	GetCategoryByID(ctx context.Context, id int64) (*models.Category, error)
-	DeleteCategory(ctx context.Context, id int64) error
+	DeleteCategory(ctx context.Context, id, deletedBy int64) error
+	GetDeletedCategory(ctx context.Context, id int64) (*models.Category, error)
+	ListDeletedCategories(ctx context.Context, opts models.ListOptions) (*models.Page[*models.Category], error)
+	RestoreCategory(ctx context.Context, id int64) (bool, error)
+	PurgeCategories(ctx context.Context, before time.Time) (int64, error)
 }
 
-func NewCategoryRepository(db *sql.DB) *CategoryRepository {
+func NewCategoryRepository(db *database.DB) *CategoryRepository {
 	return &CategoryRepository{db: db}
 }
 
 func (r *CategoryRepository) CreateCategory(ctx context.Context, category *models.Category) error {
-	query := `INSERT INTO categories (name, description, creator_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`
+	query := `INSERT INTO categories (name, description, creator_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?) RETURNING id`
 	now := time.Now()
-	result, err := r.db.ExecContext(ctx, query, category.Name, category.Description, category.CreatorID, now, now)
+	err := r.db.QueryRowContext(ctx, query, category.Name, category.Description, category.CreatorID, now, now).Scan(&category.ID)
 	if err != nil {
 		return err
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return err
-	}
-
-	category.ID = id
 	category.CreatedAt = now
 	category.UpdatedAt = now
 
@@ -44,7 +44,7 @@ func (r *CategoryRepository) CreateCategory(ctx context.Context, category *model
 }
 
 func (r *CategoryRepository) GetCategories(ctx context.Context) ([]*models.Category, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT id, name, description, creator_id, created_at, updated_at FROM categories`)
+	rows, err := r.db.QueryContext(ctx, `SELECT id, name, description, creator_id, created_at, updated_at FROM categories WHERE deleted_at IS NULL`)
 	if err != nil {
 		return nil, err
 	}
@@ -61,9 +61,61 @@ func (r *CategoryRepository) GetCategories(ctx context.Context) ([]*models.Categ
 	return categories, nil
 }
 
+var categorySortKeys = map[string]sortKey{
+	models.SortNewest: {expr: "cat.id", desc: true},
+	models.SortOldest: {expr: "cat.id"},
+	models.SortMostCommented: {expr: `(SELECT COUNT(*) FROM comments c JOIN posts p ON p.id = c.post_id
+		WHERE p.category_id = cat.id AND p.deleted_at IS NULL AND NOT c.deleted)`, desc: true},
+	models.SortRecentlyActive: {expr: `COALESCE((SELECT MAX(p.created_at) FROM posts p
+		WHERE p.category_id = cat.id AND p.deleted_at IS NULL), cat.created_at)`, desc: true},
+}
+
+// ListCategories возвращает страницу неудалённых категорий, по умолчанию
+// в порядке создания
+func (r *CategoryRepository) ListCategories(ctx context.Context, opts models.ListOptions) (*models.Page[*models.Category], error) {
+	key, cur, limit, err := pageParams(opts, categorySortKeys, models.SortOldest)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM categories WHERE deleted_at IS NULL`).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	inner := `SELECT cat.id, cat.name, cat.description, cat.creator_id, cat.created_at, cat.updated_at, ` +
+		key.expr + ` AS sort_key FROM categories cat WHERE cat.deleted_at IS NULL`
+	query, args := keysetQuery("", inner, nil, key, cur, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []*models.Category{}
+	var keys []any
+	for rows.Next() {
+		var c models.Category
+		var k any
+		if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.CreatorID, &c.CreatedAt, &c.UpdatedAt, &k); err != nil {
+			return nil, err
+		}
+		categories = append(categories, &c)
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	categories, next := trimPage(categories, keys, limit, func(c *models.Category) int64 { return c.ID })
+	return &models.Page[*models.Category]{Items: categories, NextCursor: next, Total: total}, nil
+}
+
+// GetCategoryByID возвращает категорию или nil, если её нет или она удалена
 func (r *CategoryRepository) GetCategoryByID(ctx context.Context, id int64) (*models.Category, error) {
 	var c models.Category
-	err := r.db.QueryRowContext(ctx, `SELECT id, name, description, creator_id, created_at, updated_at FROM categories WHERE id = ?`, id).
+	err := r.db.QueryRowContext(ctx, `SELECT id, name, description, creator_id, created_at, updated_at FROM categories WHERE id = ? AND deleted_at IS NULL`, id).
 		Scan(&c.ID, &c.Name, &c.Description, &c.CreatorID, &c.CreatedAt, &c.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -74,7 +126,87 @@ func (r *CategoryRepository) GetCategoryByID(ctx context.Context, id int64) (*mo
 	return &c, nil
 }
 
-func (r *CategoryRepository) DeleteCategory(ctx context.Context, id int64) error {
-	_, err := r.db.ExecContext(ctx, `DELETE FROM categories WHERE id = ?`, id)
+// DeleteCategory помечает категорию удалённой; её посты остаются в базе,
+// но скрыты, пока категория не восстановлена
+func (r *CategoryRepository) DeleteCategory(ctx context.Context, id, deletedBy int64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE categories SET deleted_at = ?, deleted_by = ? WHERE id = ? AND deleted_at IS NULL`, time.Now(), deletedBy, id)
 	return err
 }
+
+const deletedCategoryColumns = `cat.id, cat.name, cat.description, cat.creator_id, cat.created_at, cat.updated_at, cat.deleted_at, cat.deleted_by`
+
+// GetDeletedCategory возвращает удалённую категорию или nil, если такой нет
+func (r *CategoryRepository) GetDeletedCategory(ctx context.Context, id int64) (*models.Category, error) {
+	var c models.Category
+	err := r.db.QueryRowContext(ctx, `SELECT `+deletedCategoryColumns+` FROM categories cat WHERE cat.id = ? AND cat.deleted_at IS NOT NULL`, id).
+		Scan(&c.ID, &c.Name, &c.Description, &c.CreatorID, &c.CreatedAt, &c.UpdatedAt, &c.DeletedAt, &c.DeletedBy)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ListDeletedCategories возвращает страницу удалённых категорий, по
+// умолчанию сначала удалённые последними
+func (r *CategoryRepository) ListDeletedCategories(ctx context.Context, opts models.ListOptions) (*models.Page[*models.Category], error) {
+	key, cur, limit, err := pageParams(opts, deletedSortKeys("cat"), models.SortNewest)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM categories WHERE deleted_at IS NOT NULL`).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	inner := `SELECT ` + deletedCategoryColumns + `, ` + key.expr + ` AS sort_key FROM categories cat WHERE cat.deleted_at IS NOT NULL`
+	query, args := keysetQuery("", inner, nil, key, cur, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []*models.Category{}
+	var keys []any
+	for rows.Next() {
+		var c models.Category
+		var k any
+		if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.CreatorID, &c.CreatedAt, &c.UpdatedAt, &c.DeletedAt, &c.DeletedBy, &k); err != nil {
+			return nil, err
+		}
+		categories = append(categories, &c)
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	categories, next := trimPage(categories, keys, limit, func(c *models.Category) int64 { return c.ID })
+	return &models.Page[*models.Category]{Items: categories, NextCursor: next, Total: total}, nil
+}
+
+// RestoreCategory снимает с категории отметку об удалении, и её посты
+// снова видны. Возвращает false, если удалённой категории с таким id нет.
+func (r *CategoryRepository) RestoreCategory(ctx context.Context, id int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `UPDATE categories SET deleted_at = NULL, deleted_by = NULL WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// PurgeCategories окончательно удаляет категории, удалённые раньше before,
+// вместе со всеми их постами и комментариями
+func (r *CategoryRepository) PurgeCategories(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM categories WHERE deleted_at < ?`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
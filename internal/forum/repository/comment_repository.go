@@ -2,46 +2,68 @@ package repository
 
 import (
 	"database/sql"
+	"strings"
 	"time"
 
 	"github.com/mos1rain/forum_go/internal/forum/models"
+	"github.com/mos1rain/forum_go/pkg/database"
 )
 
 type CommentRepository struct {
-	db *sql.DB
+	db *database.DB
 }
 
 type CommentRepositoryInterface interface {
 	Create(comment *models.Comment) error
 	GetByPostID(postID int) ([]models.Comment, error)
-	Delete(id int) error
+	ListThreads(postID int, opts models.ListOptions) (*models.Page[models.Comment], error)
+	GetByID(id int) (*models.Comment, error)
+	Delete(id int, deletedBy int64) error
+	ListDeleted(opts models.ListOptions) (*models.Page[models.Comment], error)
+	Restore(id int) (bool, error)
+	Purge(before time.Time) (int64, error)
 }
 
-func NewCommentRepository(db *sql.DB) *CommentRepository {
+func NewCommentRepository(db *database.DB) *CommentRepository {
 	return &CommentRepository{db: db}
 }
 
 func (r *CommentRepository) Create(comment *models.Comment) error {
-	query := `INSERT INTO comments (post_id, user_id, content) VALUES (?, ?, ?)`
-	result, err := r.db.Exec(query, comment.PostID, comment.AuthorID, comment.Content)
+	// updated_at задаётся явно: в SQLite колонка добавлена миграцией без значения по умолчанию
+	query := `INSERT INTO comments (post_id, user_id, parent_id, depth, content, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`
+	now := time.Now()
+	err := r.db.QueryRow(query, comment.PostID, comment.AuthorID, comment.ParentID, comment.Depth, comment.Content, now, now).Scan(&comment.ID)
 	if err != nil {
 		return err
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return err
-	}
+	comment.CreatedAt = now
+	comment.UpdatedAt = now
 
-	comment.ID = id
-	comment.CreatedAt = time.Now()
-	comment.UpdatedAt = time.Now()
+	return nil
+}
 
+const commentColumns = `id, post_id, user_id, parent_id, depth, deleted, content, created_at, updated_at, deleted_at, deleted_by`
+
+// scanComment читает колонки commentColumns; extra получает колонки, идущие следом
+func scanComment(row interface{ Scan(...any) error }, c *models.Comment, extra ...any) error {
+	var parentID sql.NullInt64
+	dest := append([]any{&c.ID, &c.PostID, &c.AuthorID, &parentID, &c.Depth, &c.Deleted, &c.Content, &c.CreatedAt, &c.UpdatedAt,
+		&c.DeletedAt, &c.DeletedBy}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	if parentID.Valid {
+		c.ParentID = &parentID.Int64
+	}
 	return nil
 }
 
+// GetByPostID возвращает все комментарии поста, включая удалённые
+// (tombstone), в порядке создания
 func (r *CommentRepository) GetByPostID(postID int) ([]models.Comment, error) {
-	rows, err := r.db.Query(`SELECT id, post_id, user_id, content, created_at, updated_at FROM comments WHERE post_id = ?`, postID)
+	rows, err := r.db.Query(`SELECT `+commentColumns+` FROM comments WHERE post_id = ? ORDER BY created_at, id`, postID)
 	if err != nil {
 		return nil, err
 	}
@@ -50,15 +72,183 @@ func (r *CommentRepository) GetByPostID(postID int) ([]models.Comment, error) {
 	var comments []models.Comment
 	for rows.Next() {
 		var c models.Comment
-		if err := rows.Scan(&c.ID, &c.PostID, &c.AuthorID, &c.Content, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		if err := scanComment(rows, &c); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+// commentThreads считает для каждого корневого комментария поста
+// число живых ответов во всей ветке и время последней активности в ней
+const commentThreads = `WITH RECURSIVE thread(root_id, id, created_at, deleted) AS (
+	SELECT id, id, created_at, deleted FROM comments WHERE post_id = ? AND parent_id IS NULL
+	UNION ALL
+	SELECT t.root_id, c.id, c.created_at, c.deleted FROM comments c JOIN thread t ON c.parent_id = t.id
+), threads AS (
+	SELECT root_id,
+		SUM(CASE WHEN id <> root_id AND NOT deleted THEN 1 ELSE 0 END) AS replies,
+		MAX(created_at) AS last_activity
+	FROM thread GROUP BY root_id
+)`
+
+var commentSortKeys = map[string]sortKey{
+	models.SortNewest:         {expr: "c.id", desc: true},
+	models.SortOldest:         {expr: "c.id"},
+	models.SortMostCommented:  {expr: "t.replies", desc: true},
+	models.SortRecentlyActive: {expr: "t.last_activity", desc: true},
+}
+
+// visibleRoot условие на корневой комментарий c с веткой t: удалённый
+// корень показывается, только пока в ветке есть неудалённые ответы
+const visibleRoot = `(NOT c.deleted OR t.replies > 0)`
+
+// ListThreads постранично возвращает ветки обсуждения поста: страница
+// состоит из корневых комментариев, за которыми следуют все ответы на них
+// в порядке создания, включая удалённые. Total считает видимые корневые
+// комментарии.
+func (r *CommentRepository) ListThreads(postID int, opts models.ListOptions) (*models.Page[models.Comment], error) {
+	key, cur, limit, err := pageParams(opts, commentSortKeys, models.SortOldest)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int
+	if err := r.db.QueryRow(commentThreads+` SELECT COUNT(*) FROM comments c JOIN threads t ON t.root_id = c.id WHERE `+visibleRoot,
+		postID).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	inner := `SELECT c.id, c.post_id, c.user_id, c.parent_id, c.depth, c.deleted, c.content, c.created_at, c.updated_at, c.deleted_at, c.deleted_by, ` +
+		key.expr + ` AS sort_key FROM comments c JOIN threads t ON t.root_id = c.id WHERE ` + visibleRoot
+	query, args := keysetQuery(commentThreads, inner, []any{postID}, key, cur, limit)
+
+	roots, keys, err := r.queryComments(query, args, true)
+	if err != nil {
+		return nil, err
+	}
+	roots, next := trimPage(roots, keys, limit, func(c models.Comment) int64 { return c.ID })
+
+	comments := roots
+	if len(roots) > 0 {
+		placeholders := make([]string, len(roots))
+		ids := make([]any, len(roots))
+		for i, c := range roots {
+			placeholders[i] = "?"
+			ids[i] = c.ID
+		}
+		replies, _, err := r.queryComments(`WITH RECURSIVE sub(id) AS (
+			SELECT id FROM comments WHERE parent_id IN (`+strings.Join(placeholders, ", ")+`)
+			UNION ALL
+			SELECT c.id FROM comments c JOIN sub ON c.parent_id = sub.id
+		) SELECT `+commentColumns+` FROM comments WHERE id IN (SELECT id FROM sub) ORDER BY created_at, id`, ids, false)
+		if err != nil {
 			return nil, err
 		}
+		comments = append(comments, replies...)
+	}
+
+	return &models.Page[models.Comment]{Items: comments, NextCursor: next, Total: total}, nil
+}
+
+// queryComments читает комментарии; при withKey в последней колонке ожидается sort_key
+func (r *CommentRepository) queryComments(query string, args []any, withKey bool) ([]models.Comment, []any, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	comments := []models.Comment{}
+	var keys []any
+	for rows.Next() {
+		var c models.Comment
+		var k any
+		var extra []any
+		if withKey {
+			extra = append(extra, &k)
+		}
+		if err := scanComment(rows, &c, extra...); err != nil {
+			return nil, nil, err
+		}
 		comments = append(comments, c)
+		keys = append(keys, k)
+	}
+	return comments, keys, rows.Err()
+}
+
+func (r *CommentRepository) GetByID(id int) (*models.Comment, error) {
+	var c models.Comment
+	err := scanComment(r.db.QueryRow(`SELECT `+commentColumns+` FROM comments WHERE id = ?`, id), &c)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
-	return comments, nil
+	return &c, nil
 }
 
-func (r *CommentRepository) Delete(id int) error {
-	_, err := r.db.Exec(`DELETE FROM comments WHERE id = ?`, id)
+// Delete помечает комментарий удалённым. Запись с текстом остаётся, чтобы
+// не терять ответы на неё и чтобы комментарий можно было восстановить.
+func (r *CommentRepository) Delete(id int, deletedBy int64) error {
+	_, err := r.db.Exec(`UPDATE comments SET deleted = TRUE, deleted_at = ?, deleted_by = ? WHERE id = ? AND NOT deleted`,
+		time.Now(), deletedBy, id)
 	return err
 }
+
+// ListDeleted возвращает страницу удалённых комментариев, по умолчанию
+// сначала удалённые последними
+func (r *CommentRepository) ListDeleted(opts models.ListOptions) (*models.Page[models.Comment], error) {
+	key, cur, limit, err := pageParams(opts, deletedSortKeys("c"), models.SortNewest)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM comments WHERE deleted`).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	inner := `SELECT c.id, c.post_id, c.user_id, c.parent_id, c.depth, c.deleted, c.content, c.created_at, c.updated_at, c.deleted_at, c.deleted_by, ` +
+		key.expr + ` AS sort_key FROM comments c WHERE c.deleted`
+	query, args := keysetQuery("", inner, nil, key, cur, limit)
+
+	comments, keys, err := r.queryComments(query, args, true)
+	if err != nil {
+		return nil, err
+	}
+	comments, next := trimPage(comments, keys, limit, func(c models.Comment) int64 { return c.ID })
+	return &models.Page[models.Comment]{Items: comments, NextCursor: next, Total: total}, nil
+}
+
+// Restore снимает с комментария отметку об удалении. Возвращает false,
+// если удалённого комментария с таким id нет.
+func (r *CommentRepository) Restore(id int) (bool, error) {
+	result, err := r.db.Exec(`UPDATE comments SET deleted = FALSE, deleted_at = NULL, deleted_by = NULL WHERE id = ? AND deleted`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Purge окончательно удаляет комментарии, удалённые раньше before.
+// Внешний ключ удалил бы вместе с комментарием и ответы на него, поэтому
+// удаляются только комментарии без ответов, снизу вверх по ветке.
+func (r *CommentRepository) Purge(before time.Time) (int64, error) {
+	var total int64
+	for {
+		result, err := r.db.Exec(`DELETE FROM comments WHERE deleted AND deleted_at < ?
+			AND NOT EXISTS (SELECT 1 FROM comments reply WHERE reply.parent_id = comments.id)`, before)
+		if err != nil {
+			return total, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil || n == 0 {
+			return total, err
+		}
+		total += n
+	}
+}
@@ -0,0 +1,16 @@
+package repository
+
+import "github.com/mos1rain/forum_go/internal/forum/models"
+
+// deletedSortKeys сортировка списков удалённого по времени удаления
+// объектов с псевдонимом alias; по умолчанию сначала удалённые последними
+func deletedSortKeys(alias string) map[string]sortKey {
+	return map[string]sortKey{
+		models.SortNewest: {expr: alias + ".deleted_at", desc: true},
+		models.SortOldest: {expr: alias + ".deleted_at"},
+	}
+}
+
+// visiblePost условие на пост p: он не удалён и его категория тоже,
+// ведь вместе с категорией скрываются все её посты
+const visiblePost = `p.deleted_at IS NULL AND p.category_id IN (SELECT id FROM categories WHERE deleted_at IS NULL)`
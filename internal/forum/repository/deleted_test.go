@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/forum/models"
+	"github.com/mos1rain/forum_go/pkg/database"
+	"github.com/mos1rain/forum_go/pkg/database/dbtest"
+)
+
+func TestPostRepository_SoftDelete(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewPostRepository(db)
+		categories := NewCategoryRepository(db)
+		seedCategories(t, db, 2)
+		posts := createTestPosts(t, repo, 2)
+		other := &models.Post{Title: "other", Content: "content", AuthorID: 1, CategoryID: 2}
+		if err := repo.Create(other); err != nil {
+			t.Fatalf("create post: %v", err)
+		}
+
+		if err := repo.Delete(int(posts[0].ID), 7); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		if p, _ := repo.GetByID(int(posts[0].ID)); p != nil {
+			t.Errorf("deleted post must be hidden, got %+v", p)
+		}
+		page, err := repo.List(models.PostFilter{}, models.ListOptions{})
+		if err != nil || page.Total != 2 {
+			t.Fatalf("expected 2 visible posts, got %+v, %v", page, err)
+		}
+
+		deleted, err := repo.ListDeleted(models.ListOptions{})
+		if err != nil || deleted.Total != 1 || deleted.Items[0].ID != posts[0].ID ||
+			deleted.Items[0].DeletedBy == nil || *deleted.Items[0].DeletedBy != 7 || deleted.Items[0].DeletedAt == nil {
+			t.Fatalf("unexpected deleted posts: %+v, %v", deleted, err)
+		}
+
+		// Посты удалённой категории скрыты вместе с ней и возвращаются при восстановлении
+		if err := categories.DeleteCategory(context.Background(), 2, 7); err != nil {
+			t.Fatalf("delete category: %v", err)
+		}
+		if p, _ := repo.GetByID(int(other.ID)); p != nil {
+			t.Errorf("post of deleted category must be hidden, got %+v", p)
+		}
+		if ok, err := categories.RestoreCategory(context.Background(), 2); err != nil || !ok {
+			t.Fatalf("restore category: %v, %v", ok, err)
+		}
+		if p, _ := repo.GetByID(int(other.ID)); p == nil {
+			t.Error("post must be visible again after its category is restored")
+		}
+
+		if ok, err := repo.Restore(int(posts[0].ID)); err != nil || !ok {
+			t.Fatalf("restore: %v, %v", ok, err)
+		}
+		if ok, _ := repo.Restore(int(posts[0].ID)); ok {
+			t.Error("restoring a visible post must report false")
+		}
+		if p, _ := repo.GetByID(int(posts[0].ID)); p == nil {
+			t.Error("restored post must be visible")
+		}
+
+		// Очищаются только посты, удалённые раньше срока
+		if err := repo.Delete(int(posts[1].ID), 7); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		if n, err := repo.Purge(time.Now().Add(-time.Hour)); err != nil || n != 0 {
+			t.Errorf("expected nothing purged, got %d, %v", n, err)
+		}
+		if n, err := repo.Purge(time.Now().Add(time.Minute)); err != nil || n != 1 {
+			t.Errorf("expected 1 purged, got %d, %v", n, err)
+		}
+		if p, _ := repo.GetDeleted(int(posts[1].ID)); p != nil {
+			t.Errorf("purged post must be gone, got %+v", p)
+		}
+	})
+}
+
+func TestCommentRepository_SoftDelete(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewCommentRepository(db)
+		seedCategories(t, db, 1)
+		createTestPosts(t, NewPostRepository(db), 1)
+		create := func(parent *models.Comment) *models.Comment {
+			c := &models.Comment{PostID: 1, AuthorID: 1, Content: "c"}
+			if parent != nil {
+				c.ParentID = &parent.ID
+				c.Depth = parent.Depth + 1
+			}
+			if err := repo.Create(c); err != nil {
+				t.Fatalf("create comment: %v", err)
+			}
+			return c
+		}
+
+		root := create(nil)
+		reply := create(root)
+		lonely := create(nil)
+		for _, c := range []*models.Comment{root, lonely} {
+			if err := repo.Delete(int(c.ID), 7); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+		}
+
+		// Удалённый корень с живым ответом остаётся в ветке, одинокий — нет
+		page, err := repo.ListThreads(1, models.ListOptions{})
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		var got []int64
+		for _, c := range page.Items {
+			got = append(got, c.ID)
+		}
+		if want := []int64{root.ID, reply.ID}; !equalIDs(got, want) || page.Total != 1 {
+			t.Errorf("expected %v of 1 thread, got %v of %d", want, got, page.Total)
+		}
+
+		deleted, err := repo.ListDeleted(models.ListOptions{Sort: models.SortOldest})
+		if err != nil || deleted.Total != 2 || deleted.Items[0].ID != root.ID || deleted.Items[0].Content != "c" {
+			t.Fatalf("unexpected deleted comments: %+v, %v", deleted, err)
+		}
+
+		// Корень с ответом не очищается, пока ответ не удалён и не очищен
+		if n, err := repo.Purge(time.Now().Add(time.Minute)); err != nil || n != 1 {
+			t.Errorf("expected only the lonely comment purged, got %d, %v", n, err)
+		}
+		if err := repo.Delete(int(reply.ID), 7); err != nil {
+			t.Fatalf("delete reply: %v", err)
+		}
+		if n, err := repo.Purge(time.Now().Add(time.Minute)); err != nil || n != 2 {
+			t.Errorf("expected reply and root purged, got %d, %v", n, err)
+		}
+		if c, _ := repo.GetByID(int(root.ID)); c != nil {
+			t.Errorf("purged comment must be gone, got %+v", c)
+		}
+
+		if ok, err := repo.Restore(int(root.ID)); err != nil || ok {
+			t.Errorf("restoring a purged comment must report false, got %v, %v", ok, err)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/forum/models"
+	"github.com/mos1rain/forum_go/pkg/database"
+)
+
+// ModeratorRepository назначения модераторов категорий
+type ModeratorRepository struct {
+	db *database.DB
+}
+
+type ModeratorRepositoryInterface interface {
+	Add(ctx context.Context, moderator *models.CategoryModerator) (bool, error)
+	Remove(ctx context.Context, categoryID, userID int64) (bool, error)
+	ListByCategory(ctx context.Context, categoryID int64) ([]models.CategoryModerator, error)
+	IsModerator(ctx context.Context, categoryID, userID int64) (bool, error)
+}
+
+func NewModeratorRepository(db *database.DB) *ModeratorRepository {
+	return &ModeratorRepository{db: db}
+}
+
+// Add назначает пользователя модератором категории. Возвращает false,
+// если он уже назначен; существующее назначение не меняется.
+func (r *ModeratorRepository) Add(ctx context.Context, moderator *models.CategoryModerator) (bool, error) {
+	query := `
+		INSERT INTO category_moderators (category_id, user_id, assigned_by, created_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (category_id, user_id) DO NOTHING`
+
+	if moderator.CreatedAt.IsZero() {
+		moderator.CreatedAt = time.Now()
+	}
+	result, err := r.db.ExecContext(ctx, query, moderator.CategoryID, moderator.UserID, moderator.AssignedBy, moderator.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// Remove снимает назначение. Возвращает false, если его не было.
+func (r *ModeratorRepository) Remove(ctx context.Context, categoryID, userID int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM category_moderators WHERE category_id = ? AND user_id = ?`, categoryID, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// ListByCategory возвращает модераторов категории в порядке назначения
+func (r *ModeratorRepository) ListByCategory(ctx context.Context, categoryID int64) ([]models.CategoryModerator, error) {
+	query := `
+		SELECT category_id, user_id, assigned_by, created_at
+		FROM category_moderators
+		WHERE category_id = ?
+		ORDER BY created_at, user_id`
+
+	rows, err := r.db.QueryContext(ctx, query, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	moderators := []models.CategoryModerator{}
+	for rows.Next() {
+		var m models.CategoryModerator
+		var assignedBy sql.NullInt64
+		if err := rows.Scan(&m.CategoryID, &m.UserID, &assignedBy, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		if assignedBy.Valid {
+			m.AssignedBy = &assignedBy.Int64
+		}
+		moderators = append(moderators, m)
+	}
+	return moderators, rows.Err()
+}
+
+// IsModerator сообщает, назначен ли пользователь модератором категории
+func (r *ModeratorRepository) IsModerator(ctx context.Context, categoryID, userID int64) (bool, error) {
+	var one int
+	err := r.db.QueryRowContext(ctx, `SELECT 1 FROM category_moderators WHERE category_id = ? AND user_id = ?`, categoryID, userID).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mos1rain/forum_go/internal/forum/models"
+	"github.com/mos1rain/forum_go/pkg/database"
+	"github.com/mos1rain/forum_go/pkg/database/dbtest"
+)
+
+func TestModeratorRepository(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewModeratorRepository(db)
+		ctx := context.Background()
+		seedCategories(t, db, 2)
+
+		admin := int64(1)
+		created, err := repo.Add(ctx, &models.CategoryModerator{CategoryID: 1, UserID: 5, AssignedBy: &admin})
+		if err != nil || !created {
+			t.Fatalf("add: created %v, err %v", created, err)
+		}
+		if created, err = repo.Add(ctx, &models.CategoryModerator{CategoryID: 1, UserID: 5}); err != nil || created {
+			t.Fatalf("duplicate add: created %v, err %v", created, err)
+		}
+		if _, err := repo.Add(ctx, &models.CategoryModerator{CategoryID: 1, UserID: 6}); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+
+		list, err := repo.ListByCategory(ctx, 1)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if len(list) != 2 || list[0].UserID != 5 || list[1].UserID != 6 {
+			t.Fatalf("unexpected moderators: %+v", list)
+		}
+		if list[0].AssignedBy == nil || *list[0].AssignedBy != admin || list[1].AssignedBy != nil {
+			t.Errorf("unexpected assigned_by: %v, %v", list[0].AssignedBy, list[1].AssignedBy)
+		}
+
+		if ok, err := repo.IsModerator(ctx, 1, 5); err != nil || !ok {
+			t.Errorf("expected user 5 to moderate category 1: %v, %v", ok, err)
+		}
+		if ok, err := repo.IsModerator(ctx, 2, 5); err != nil || ok {
+			t.Errorf("user 5 must not moderate category 2: %v, %v", ok, err)
+		}
+
+		if removed, err := repo.Remove(ctx, 1, 5); err != nil || !removed {
+			t.Fatalf("remove: removed %v, err %v", removed, err)
+		}
+		if removed, err := repo.Remove(ctx, 1, 5); err != nil || removed {
+			t.Fatalf("second remove: removed %v, err %v", removed, err)
+		}
+	})
+}
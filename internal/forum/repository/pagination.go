@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/forum/models"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort")
+)
+
+// sortKey SQL-выражение, по которому сортируется список.
+// Вторым ключом всегда идёт id в том же направлении.
+type sortKey struct {
+	expr string
+	desc bool
+}
+
+// cursor позиция последнего элемента страницы: значение ключа сортировки и id.
+// Ключ-дата помечается флагом Time и уходит в запрос как time.Time,
+// чтобы драйвер сам привёл её к формату хранилища.
+type cursor struct {
+	Key  any   `json:"k"`
+	ID   int64 `json:"id"`
+	Time bool  `json:"t,omitempty"`
+}
+
+func encodeCursor(key any, id int64) string {
+	c := cursor{Key: key, ID: id}
+	if t, ok := key.(time.Time); ok {
+		c.Key, c.Time = t.UTC().Format(time.RFC3339Nano), true
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	var c cursor
+	if err := dec.Decode(&c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	// Числовые ключи должны уйти в запрос числом, иначе SQLite сравнит их как текст
+	switch key := c.Key.(type) {
+	case json.Number:
+		if n, err := key.Int64(); err == nil {
+			c.Key = n
+			break
+		}
+		f, err := key.Float64()
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		c.Key = f
+	case string:
+		if c.Time {
+			t, err := time.Parse(time.RFC3339Nano, key)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			c.Key = t
+		}
+	default:
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// pageParams проверяет параметры страницы и подставляет значения по умолчанию
+func pageParams(opts models.ListOptions, keys map[string]sortKey, defaultSort string) (sortKey, *cursor, int, error) {
+	if opts.Sort == "" {
+		opts.Sort = defaultSort
+	}
+	key, ok := keys[opts.Sort]
+	if !ok {
+		return sortKey{}, nil, 0, ErrInvalidSort
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+
+	var cur *cursor
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return sortKey{}, nil, 0, err
+		}
+		cur = c
+	}
+	return key, cur, limit, nil
+}
+
+// keysetQuery оборачивает inner (SELECT ..., <ключ> AS sort_key ...) в запрос
+// страницы: отбрасывает всё до курсора, сортирует и берёт limit+1 строк,
+// чтобы понять, есть ли следующая страница
+func keysetQuery(with, inner string, args []any, key sortKey, cur *cursor, limit int) (string, []any) {
+	op, dir := ">", "ASC"
+	if key.desc {
+		op, dir = "<", "DESC"
+	}
+
+	var b strings.Builder
+	if with != "" {
+		b.WriteString(with)
+		b.WriteString(" ")
+	}
+	b.WriteString("SELECT * FROM (")
+	b.WriteString(inner)
+	b.WriteString(") AS page")
+	if cur != nil {
+		b.WriteString(" WHERE (sort_key " + op + " ? OR (sort_key = ? AND id " + op + " ?))")
+		args = append(args, cur.Key, cur.Key, cur.ID)
+	}
+	b.WriteString(" ORDER BY sort_key " + dir + ", id " + dir + " LIMIT ?")
+	args = append(args, limit+1)
+
+	return b.String(), args
+}
+
+// trimPage отрезает лишнюю строку, прочитанную keysetQuery,
+// и возвращает курсор следующей страницы, если она есть
+func trimPage[T any](items []T, keys []any, limit int, id func(T) int64) ([]T, string) {
+	if len(items) <= limit {
+		return items, ""
+	}
+	items = items[:limit]
+	return items, encodeCursor(keys[limit-1], id(items[limit-1]))
+}
@@ -0,0 +1,238 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mos1rain/forum_go/internal/forum/models"
+	"github.com/mos1rain/forum_go/pkg/database"
+	"github.com/mos1rain/forum_go/pkg/database/dbtest"
+)
+
+// seedCategories создаёт n категорий с id 1..n: в Postgres посты
+// ссылаются на категорию внешним ключом
+func seedCategories(t *testing.T, db *database.DB, n int) {
+	t.Helper()
+	repo := NewCategoryRepository(db)
+	for i := 1; i <= n; i++ {
+		c := &models.Category{Name: fmt.Sprintf("category %d", i), Description: "d", CreatorID: 1}
+		if err := repo.CreateCategory(context.Background(), c); err != nil {
+			t.Fatalf("create category: %v", err)
+		}
+	}
+}
+
+func createTestPosts(t *testing.T, repo *PostRepository, n int) []*models.Post {
+	t.Helper()
+	var posts []*models.Post
+	for i := 0; i < n; i++ {
+		p := &models.Post{Title: "title", Content: "content", AuthorID: 1, CategoryID: 1}
+		if err := repo.Create(p); err != nil {
+			t.Fatalf("create post: %v", err)
+		}
+		posts = append(posts, p)
+	}
+	return posts
+}
+
+func postIDs(posts []models.Post) []int64 {
+	var ids []int64
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPostRepository_ListPagination(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewPostRepository(db)
+		seedCategories(t, db, 1)
+		createTestPosts(t, repo, 5)
+
+		var got []int64
+		opts := models.ListOptions{Limit: 2}
+		for pages := 0; ; pages++ {
+			if pages > 3 {
+				t.Fatal("pagination did not terminate")
+			}
+			page, err := repo.List(models.PostFilter{}, opts)
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if page.Total != 5 {
+				t.Errorf("expected total 5, got %d", page.Total)
+			}
+			got = append(got, postIDs(page.Items)...)
+			if page.NextCursor == "" {
+				break
+			}
+			opts.Cursor = page.NextCursor
+		}
+
+		if want := []int64{5, 4, 3, 2, 1}; !equalIDs(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+}
+
+func TestPostRepository_ListMostCommented(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		posts := NewPostRepository(db)
+		comments := NewCommentRepository(db)
+		seedCategories(t, db, 1)
+		createTestPosts(t, posts, 3)
+
+		for postID, n := range map[int64]int{2: 3, 3: 1} {
+			for i := 0; i < n; i++ {
+				if err := comments.Create(&models.Comment{PostID: postID, AuthorID: 1, Content: "c"}); err != nil {
+					t.Fatalf("create comment: %v", err)
+				}
+			}
+		}
+
+		first, err := posts.List(models.PostFilter{}, models.ListOptions{Limit: 2, Sort: models.SortMostCommented})
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		second, err := posts.List(models.PostFilter{}, models.ListOptions{Limit: 2, Sort: models.SortMostCommented, Cursor: first.NextCursor})
+		if err != nil {
+			t.Fatalf("list next: %v", err)
+		}
+
+		got := append(postIDs(first.Items), postIDs(second.Items)...)
+		if want := []int64{2, 3, 1}; !equalIDs(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+}
+
+func TestPostRepository_ListFilter(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewPostRepository(db)
+		seedCategories(t, db, 2)
+		for _, p := range []models.Post{
+			{Title: "a", Content: "c", AuthorID: 1, CategoryID: 1},
+			{Title: "b", Content: "c", AuthorID: 2, CategoryID: 1},
+			{Title: "c", Content: "c", AuthorID: 2, CategoryID: 2},
+		} {
+			if err := repo.Create(&p); err != nil {
+				t.Fatalf("create post: %v", err)
+			}
+		}
+
+		byCategory, err := repo.List(models.PostFilter{CategoryID: 1}, models.ListOptions{})
+		if err != nil {
+			t.Fatalf("list by category: %v", err)
+		}
+		if want := []int64{2, 1}; !equalIDs(postIDs(byCategory.Items), want) || byCategory.Total != 2 {
+			t.Errorf("expected %v, got %v (total %d)", want, postIDs(byCategory.Items), byCategory.Total)
+		}
+
+		byAuthor, err := repo.List(models.PostFilter{AuthorID: 2}, models.ListOptions{Limit: 1, Sort: models.SortOldest})
+		if err != nil {
+			t.Fatalf("list by author: %v", err)
+		}
+		next, err := repo.List(models.PostFilter{AuthorID: 2}, models.ListOptions{Limit: 1, Sort: models.SortOldest, Cursor: byAuthor.NextCursor})
+		if err != nil {
+			t.Fatalf("list by author next: %v", err)
+		}
+		got := append(postIDs(byAuthor.Items), postIDs(next.Items)...)
+		if want := []int64{2, 3}; !equalIDs(got, want) || next.NextCursor != "" {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+}
+
+func TestPostRepository_ListInvalidParams(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewPostRepository(db)
+
+		if _, err := repo.List(models.PostFilter{}, models.ListOptions{Sort: "random"}); !errors.Is(err, ErrInvalidSort) {
+			t.Errorf("expected ErrInvalidSort, got %v", err)
+		}
+		if _, err := repo.List(models.PostFilter{}, models.ListOptions{Cursor: "not-a-cursor"}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("expected ErrInvalidCursor, got %v", err)
+		}
+	})
+}
+
+func TestCategoryRepository_ListCategories(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewCategoryRepository(db)
+		for _, name := range []string{"a", "b", "c"} {
+			if err := repo.CreateCategory(context.Background(), &models.Category{Name: name, Description: name, CreatorID: 1}); err != nil {
+				t.Fatalf("create category: %v", err)
+			}
+		}
+
+		page, err := repo.ListCategories(context.Background(), models.ListOptions{Limit: 2})
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if len(page.Items) != 2 || page.Items[0].Name != "a" || page.NextCursor == "" || page.Total != 3 {
+			t.Fatalf("unexpected first page: %+v", page)
+		}
+
+		page, err = repo.ListCategories(context.Background(), models.ListOptions{Limit: 2, Cursor: page.NextCursor})
+		if err != nil {
+			t.Fatalf("list next: %v", err)
+		}
+		if len(page.Items) != 1 || page.Items[0].Name != "c" || page.NextCursor != "" {
+			t.Fatalf("unexpected last page: %+v", page)
+		}
+	})
+}
+
+func TestCommentRepository_ListThreads(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewCommentRepository(db)
+		seedCategories(t, db, 1)
+		createTestPosts(t, NewPostRepository(db), 1)
+		create := func(parent *models.Comment) *models.Comment {
+			c := &models.Comment{PostID: 1, AuthorID: 1, Content: "c"}
+			if parent != nil {
+				c.ParentID = &parent.ID
+				c.Depth = parent.Depth + 1
+			}
+			if err := repo.Create(c); err != nil {
+				t.Fatalf("create comment: %v", err)
+			}
+			return c
+		}
+
+		quiet := create(nil)
+		busy := create(nil)
+		reply := create(busy)
+		nested := create(reply)
+		create(quiet)
+
+		page, err := repo.ListThreads(1, models.ListOptions{Limit: 1, Sort: models.SortMostCommented})
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		var got []int64
+		for _, c := range page.Items {
+			got = append(got, c.ID)
+		}
+		if want := []int64{busy.ID, reply.ID, nested.ID}; !equalIDs(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+		if page.Total != 2 || page.NextCursor == "" {
+			t.Errorf("unexpected page meta: total %d, cursor %q", page.Total, page.NextCursor)
+		}
+	})
+}
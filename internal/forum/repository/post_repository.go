@@ -2,48 +2,67 @@ package repository
 
 import (
 	"database/sql"
+	"strings"
 	"time"
 
 	"github.com/mos1rain/forum_go/internal/forum/models"
+	"github.com/mos1rain/forum_go/pkg/database"
 )
 
 type PostRepository struct {
-	db *sql.DB
+	db *database.DB
 }
 
 type PostRepositoryInterface interface {
 	Create(post *models.Post) error
 	GetAll() ([]models.Post, error)
+	List(filter models.PostFilter, opts models.ListOptions) (*models.Page[models.Post], error)
 	GetByID(id int) (*models.Post, error)
-	Update(post *models.Post) error
-	Delete(id int) error
+	Update(post *models.Post, editorID int64) error
+	ListRevisions(postID int, opts models.ListOptions) (*models.Page[models.PostRevision], error)
+	GetRevision(postID, revision int) (*models.PostRevision, error)
+	SetLocked(id int, locked bool) error
+	Delete(id int, deletedBy int64) error
+	GetDeleted(id int) (*models.Post, error)
+	ListDeleted(opts models.ListOptions) (*models.Page[models.Post], error)
+	Restore(id int) (bool, error)
+	Purge(before time.Time) (int64, error)
 }
 
-func NewPostRepository(db *sql.DB) *PostRepository {
+func NewPostRepository(db *database.DB) *PostRepository {
 	return &PostRepository{db: db}
 }
 
+// Create добавляет пост и его первую ревизию
 func (r *PostRepository) Create(post *models.Post) error {
-	query := `INSERT INTO posts (author_id, category_id, title, content) VALUES (?, ?, ?, ?)`
-	result, err := r.db.Exec(query, post.AuthorID, post.CategoryID, post.Title, post.Content)
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	id, err := result.LastInsertId()
-	if err != nil {
+	query := `INSERT INTO posts (author_id, category_id, title, content) VALUES (?, ?, ?, ?) RETURNING id`
+	if err := tx.QueryRow(query, post.AuthorID, post.CategoryID, post.Title, post.Content).Scan(&post.ID); err != nil {
+		return err
+	}
+	now := time.Now()
+	if _, err := tx.Exec(`INSERT INTO post_revisions (post_id, revision, editor_id, title, content, created_at) VALUES (?, 1, ?, ?, ?, ?)`,
+		post.ID, post.AuthorID, post.Title, post.Content, now); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
 		return err
 	}
 
-	post.ID = id
-	post.CreatedAt = time.Now()
-	post.UpdatedAt = time.Now()
+	post.CreatedAt = now
+	post.UpdatedAt = now
 
 	return nil
 }
 
 func (r *PostRepository) GetAll() ([]models.Post, error) {
-	rows, err := r.db.Query(`SELECT id, author_id, category_id, title, content, created_at, updated_at FROM posts`)
+	rows, err := r.db.Query(`SELECT p.id, p.author_id, p.category_id, p.title, p.content, p.locked, p.created_at, p.updated_at
+		FROM posts p WHERE ` + visiblePost)
 	if err != nil {
 		return nil, err
 	}
@@ -52,7 +71,7 @@ func (r *PostRepository) GetAll() ([]models.Post, error) {
 	var posts []models.Post
 	for rows.Next() {
 		var p models.Post
-		if err := rows.Scan(&p.ID, &p.AuthorID, &p.CategoryID, &p.Title, &p.Content, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.AuthorID, &p.CategoryID, &p.Title, &p.Content, &p.Locked, &p.CreatedAt, &p.UpdatedAt); err != nil {
 			return nil, err
 		}
 		posts = append(posts, p)
@@ -60,10 +79,73 @@ func (r *PostRepository) GetAll() ([]models.Post, error) {
 	return posts, nil
 }
 
+var postSortKeys = map[string]sortKey{
+	models.SortNewest:         {expr: "p.id", desc: true},
+	models.SortOldest:         {expr: "p.id"},
+	models.SortMostCommented:  {expr: "(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND NOT c.deleted)", desc: true},
+	models.SortRecentlyActive: {expr: "COALESCE((SELECT MAX(c.created_at) FROM comments c WHERE c.post_id = p.id), p.created_at)", desc: true},
+}
+
+// List возвращает страницу постов, подходящих под filter, по умолчанию
+// сначала новые. Удалённые посты и посты удалённых категорий не попадают.
+func (r *PostRepository) List(filter models.PostFilter, opts models.ListOptions) (*models.Page[models.Post], error) {
+	key, cur, limit, err := pageParams(opts, postSortKeys, models.SortNewest)
+	if err != nil {
+		return nil, err
+	}
+
+	conds := []string{visiblePost}
+	var filterArgs []any
+	if filter.CategoryID != 0 {
+		conds = append(conds, "p.category_id = ?")
+		filterArgs = append(filterArgs, filter.CategoryID)
+	}
+	if filter.AuthorID != 0 {
+		conds = append(conds, "p.author_id = ?")
+		filterArgs = append(filterArgs, filter.AuthorID)
+	}
+	where := " WHERE " + strings.Join(conds, " AND ")
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM posts p`+where, filterArgs...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	inner := `SELECT p.id, p.author_id, p.category_id, p.title, p.content, p.locked, p.created_at, p.updated_at, ` +
+		key.expr + ` AS sort_key FROM posts p` + where
+	query, args := keysetQuery("", inner, filterArgs, key, cur, limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []models.Post{}
+	var keys []any
+	for rows.Next() {
+		var p models.Post
+		var k any
+		if err := rows.Scan(&p.ID, &p.AuthorID, &p.CategoryID, &p.Title, &p.Content, &p.Locked, &p.CreatedAt, &p.UpdatedAt, &k); err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	posts, next := trimPage(posts, keys, limit, func(p models.Post) int64 { return p.ID })
+	return &models.Page[models.Post]{Items: posts, NextCursor: next, Total: total}, nil
+}
+
+// GetByID возвращает пост или nil, если его нет или он удалён
 func (r *PostRepository) GetByID(id int) (*models.Post, error) {
 	var p models.Post
-	err := r.db.QueryRow(`SELECT id, author_id, category_id, title, content, created_at, updated_at FROM posts WHERE id = ?`, id).Scan(
-		&p.ID, &p.AuthorID, &p.CategoryID, &p.Title, &p.Content, &p.CreatedAt, &p.UpdatedAt)
+	err := r.db.QueryRow(`SELECT p.id, p.author_id, p.category_id, p.title, p.content, p.locked, p.created_at, p.updated_at
+		FROM posts p WHERE p.id = ? AND `+visiblePost, id).Scan(
+		&p.ID, &p.AuthorID, &p.CategoryID, &p.Title, &p.Content, &p.Locked, &p.CreatedAt, &p.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -73,13 +155,188 @@ func (r *PostRepository) GetByID(id int) (*models.Post, error) {
 	return &p, nil
 }
 
-func (r *PostRepository) Update(post *models.Post) error {
-	query := `UPDATE posts SET title = ?, content = ?, category_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
-	_, err := r.db.Exec(query, post.Title, post.Content, post.CategoryID, post.ID)
+// Update сохраняет пост. Если изменились заголовок или содержание, в той
+// же транзакции добавляется ревизия от имени editorID.
+func (r *PostRepository) Update(post *models.Post, editorID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldTitle, oldContent string
+	if err := tx.QueryRow(`SELECT title, content FROM posts WHERE id = ?`, post.ID).Scan(&oldTitle, &oldContent); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	query := `UPDATE posts SET title = ?, content = ?, category_id = ?, updated_at = ? WHERE id = ?`
+	if _, err := tx.Exec(query, post.Title, post.Content, post.CategoryID, now, post.ID); err != nil {
+		return err
+	}
+	if post.Title != oldTitle || post.Content != oldContent {
+		_, err := tx.Exec(`INSERT INTO post_revisions (post_id, revision, editor_id, title, content, created_at)
+			SELECT ?, COALESCE(MAX(revision), 0) + 1, ?, ?, ?, ? FROM post_revisions WHERE post_id = ?`,
+			post.ID, editorID, post.Title, post.Content, now, post.ID)
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	post.UpdatedAt = now
+	return nil
+}
+
+var revisionSortKeys = map[string]sortKey{
+	models.SortNewest: {expr: "r.revision", desc: true},
+	models.SortOldest: {expr: "r.revision"},
+}
+
+// ListRevisions возвращает страницу ревизий поста, по умолчанию сначала новые
+func (r *PostRepository) ListRevisions(postID int, opts models.ListOptions) (*models.Page[models.PostRevision], error) {
+	key, cur, limit, err := pageParams(opts, revisionSortKeys, models.SortNewest)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM post_revisions WHERE post_id = ?`, postID).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	inner := `SELECT r.id, r.post_id, r.revision, r.editor_id, r.title, r.content, r.created_at, ` +
+		key.expr + ` AS sort_key FROM post_revisions r WHERE r.post_id = ?`
+	query, args := keysetQuery("", inner, []any{postID}, key, cur, limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.PostRevision{}
+	var keys []any
+	for rows.Next() {
+		var rev models.PostRevision
+		var k any
+		if err := rows.Scan(&rev.ID, &rev.PostID, &rev.Revision, &rev.EditorID, &rev.Title, &rev.Content, &rev.CreatedAt, &k); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	revisions, next := trimPage(revisions, keys, limit, func(rev models.PostRevision) int64 { return rev.ID })
+	return &models.Page[models.PostRevision]{Items: revisions, NextCursor: next, Total: total}, nil
+}
+
+// GetRevision возвращает ревизию поста по номеру или nil, если её нет
+func (r *PostRepository) GetRevision(postID, revision int) (*models.PostRevision, error) {
+	var rev models.PostRevision
+	err := r.db.QueryRow(`SELECT id, post_id, revision, editor_id, title, content, created_at
+		FROM post_revisions WHERE post_id = ? AND revision = ?`, postID, revision).Scan(
+		&rev.ID, &rev.PostID, &rev.Revision, &rev.EditorID, &rev.Title, &rev.Content, &rev.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// SetLocked закрывает пост для новых комментариев или открывает его снова
+func (r *PostRepository) SetLocked(id int, locked bool) error {
+	_, err := r.db.Exec(`UPDATE posts SET locked = ? WHERE id = ?`, locked, id)
 	return err
 }
 
-func (r *PostRepository) Delete(id int) error {
-	_, err := r.db.Exec(`DELETE FROM posts WHERE id = ?`, id)
+// Delete помечает пост удалённым; комментарии остаются на месте
+// и возвращаются вместе с постом при восстановлении
+func (r *PostRepository) Delete(id int, deletedBy int64) error {
+	_, err := r.db.Exec(`UPDATE posts SET deleted_at = ?, deleted_by = ? WHERE id = ? AND deleted_at IS NULL`, time.Now(), deletedBy, id)
 	return err
 }
+
+const deletedPostColumns = `p.id, p.author_id, p.category_id, p.title, p.content, p.locked, p.created_at, p.updated_at, p.deleted_at, p.deleted_by`
+
+// GetDeleted возвращает удалённый пост или nil, если такого нет
+func (r *PostRepository) GetDeleted(id int) (*models.Post, error) {
+	var p models.Post
+	err := r.db.QueryRow(`SELECT `+deletedPostColumns+` FROM posts p WHERE p.id = ? AND p.deleted_at IS NOT NULL`, id).Scan(
+		&p.ID, &p.AuthorID, &p.CategoryID, &p.Title, &p.Content, &p.Locked, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt, &p.DeletedBy)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListDeleted возвращает страницу удалённых постов, по умолчанию сначала
+// удалённые последними
+func (r *PostRepository) ListDeleted(opts models.ListOptions) (*models.Page[models.Post], error) {
+	key, cur, limit, err := pageParams(opts, deletedSortKeys("p"), models.SortNewest)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM posts p WHERE p.deleted_at IS NOT NULL`).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	inner := `SELECT ` + deletedPostColumns + `, ` + key.expr + ` AS sort_key FROM posts p WHERE p.deleted_at IS NOT NULL`
+	query, args := keysetQuery("", inner, nil, key, cur, limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []models.Post{}
+	var keys []any
+	for rows.Next() {
+		var p models.Post
+		var k any
+		if err := rows.Scan(&p.ID, &p.AuthorID, &p.CategoryID, &p.Title, &p.Content, &p.Locked, &p.CreatedAt, &p.UpdatedAt,
+			&p.DeletedAt, &p.DeletedBy, &k); err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	posts, next := trimPage(posts, keys, limit, func(p models.Post) int64 { return p.ID })
+	return &models.Page[models.Post]{Items: posts, NextCursor: next, Total: total}, nil
+}
+
+// Restore снимает с поста отметку об удалении. Возвращает false, если
+// удалённого поста с таким id нет.
+func (r *PostRepository) Restore(id int) (bool, error) {
+	result, err := r.db.Exec(`UPDATE posts SET deleted_at = NULL, deleted_by = NULL WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Purge окончательно удаляет посты, удалённые раньше before, вместе с их комментариями
+func (r *PostRepository) Purge(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM posts WHERE deleted_at < ?`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
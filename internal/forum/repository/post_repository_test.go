@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/mos1rain/forum_go/internal/forum/models"
+	"github.com/mos1rain/forum_go/pkg/database"
+	"github.com/mos1rain/forum_go/pkg/database/dbtest"
+)
+
+func TestPostRepository_Revisions(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewPostRepository(db)
+		seedCategories(t, db, 2)
+		post := createTestPosts(t, repo, 1)[0]
+
+		post.Content = "second"
+		if err := repo.Update(post, 2); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		// Перенос в другую категорию не меняет текст и ревизию не добавляет
+		post.CategoryID = 2
+		if err := repo.Update(post, 2); err != nil {
+			t.Fatalf("move: %v", err)
+		}
+		post.Title = "third"
+		if err := repo.Update(post, 3); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+
+		page, err := repo.ListRevisions(int(post.ID), models.ListOptions{Limit: 2})
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if page.Total != 3 || len(page.Items) != 2 || page.Items[0].Revision != 3 || page.Items[1].Revision != 2 || page.NextCursor == "" {
+			t.Fatalf("unexpected first page: %+v", page)
+		}
+		if got := page.Items[0]; got.Title != "third" || got.Content != "second" || got.EditorID == nil || *got.EditorID != 3 {
+			t.Errorf("unexpected latest revision: %+v", got)
+		}
+		next, err := repo.ListRevisions(int(post.ID), models.ListOptions{Limit: 2, Cursor: page.NextCursor})
+		if err != nil || len(next.Items) != 1 || next.Items[0].Revision != 1 || next.NextCursor != "" {
+			t.Fatalf("unexpected second page: %+v, %v", next, err)
+		}
+
+		first, err := repo.GetRevision(int(post.ID), 1)
+		if err != nil || first == nil || first.Title != "title" || first.Content != "content" || *first.EditorID != post.AuthorID {
+			t.Fatalf("unexpected first revision: %+v, %v", first, err)
+		}
+		if rev, err := repo.GetRevision(int(post.ID), 4); err != nil || rev != nil {
+			t.Errorf("expected no revision 4, got %+v, %v", rev, err)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/forum/models"
+	"github.com/mos1rain/forum_go/pkg/database"
+)
+
+// ReportRepository жалобы пользователей и очередь модерации
+type ReportRepository struct {
+	db *database.DB
+}
+
+type ReportRepositoryInterface interface {
+	Create(ctx context.Context, report *models.Report) (bool, error)
+	GetByID(ctx context.Context, id int64) (*models.Report, error)
+	List(ctx context.Context, filter models.ReportFilter, opts models.ListOptions) (*models.Page[models.Report], error)
+	ResolveTarget(ctx context.Context, resolution *models.Report) (int, error)
+}
+
+func NewReportRepository(db *database.DB) *ReportRepository {
+	return &ReportRepository{db: db}
+}
+
+const reportColumns = `r.id, r.target_type, r.target_id, r.target_author_id, r.category_id, r.snapshot, r.reporter_id, r.reason,
+	r.status, r.action, r.resolution_note, r.resolved_by, r.resolved_at, r.created_at`
+
+// Очередь по умолчанию разбирается с самых старых жалоб
+var reportSortKeys = map[string]sortKey{
+	models.SortOldest: {expr: "r.id"},
+	models.SortNewest: {expr: "r.id", desc: true},
+}
+
+// Create сохраняет открытую жалобу. Возвращает false, если пользователь
+// уже жаловался на этот объект; существующая жалоба не меняется.
+func (r *ReportRepository) Create(ctx context.Context, report *models.Report) (bool, error) {
+	query := `
+		INSERT INTO reports (target_type, target_id, target_author_id, category_id, snapshot, reporter_id, reason, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (target_type, target_id, reporter_id) DO NOTHING
+		RETURNING id`
+
+	if report.CreatedAt.IsZero() {
+		report.CreatedAt = time.Now()
+	}
+	report.Status = models.ReportStatusOpen
+	err := r.db.QueryRowContext(ctx, query, report.TargetType, report.TargetID, report.TargetAuthorID, report.CategoryID,
+		report.Snapshot, report.ReporterID, report.Reason, report.Status, report.CreatedAt).Scan(&report.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetByID возвращает жалобу или nil, если её нет
+func (r *ReportRepository) GetByID(ctx context.Context, id int64) (*models.Report, error) {
+	var report models.Report
+	err := scanReport(r.db.QueryRowContext(ctx, `SELECT `+reportColumns+` FROM reports r WHERE r.id = ?`, id), &report)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// List возвращает страницу жалоб, подходящих под filter, по умолчанию
+// начиная со старых
+func (r *ReportRepository) List(ctx context.Context, filter models.ReportFilter, opts models.ListOptions) (*models.Page[models.Report], error) {
+	key, cur, limit, err := pageParams(opts, reportSortKeys, models.SortOldest)
+	if err != nil {
+		return nil, err
+	}
+
+	var conds []string
+	var filterArgs []any
+	if filter.Status != "" {
+		conds = append(conds, "r.status = ?")
+		filterArgs = append(filterArgs, filter.Status)
+	}
+	if filter.TargetType != "" {
+		conds = append(conds, "r.target_type = ?")
+		filterArgs = append(filterArgs, filter.TargetType)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM reports r`+where, filterArgs...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	inner := `SELECT ` + reportColumns + `, ` + key.expr + ` AS sort_key FROM reports r` + where
+	query, args := keysetQuery("", inner, filterArgs, key, cur, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []models.Report{}
+	var keys []any
+	for rows.Next() {
+		var report models.Report
+		var k any
+		if err := scanReport(rows, &report, &k); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	reports, next := trimPage(reports, keys, limit, func(r models.Report) int64 { return r.ID })
+	return &models.Page[models.Report]{Items: reports, NextCursor: next, Total: total}, nil
+}
+
+// ResolveTarget закрывает все открытые жалобы на объект resolution
+// решением из resolution (Status, Action, ResolutionNote, ResolvedBy,
+// ResolvedAt). Возвращает количество закрытых жалоб.
+func (r *ReportRepository) ResolveTarget(ctx context.Context, resolution *models.Report) (int, error) {
+	query := `
+		UPDATE reports
+		SET status = ?, action = ?, resolution_note = ?, resolved_by = ?, resolved_at = ?
+		WHERE target_type = ? AND target_id = ? AND status = ?`
+
+	result, err := r.db.ExecContext(ctx, query, resolution.Status, resolution.Action, resolution.ResolutionNote,
+		resolution.ResolvedBy, resolution.ResolvedAt, resolution.TargetType, resolution.TargetID, models.ReportStatusOpen)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
+
+func scanReport(row interface{ Scan(...any) error }, report *models.Report, extra ...any) error {
+	var categoryID, resolvedBy sql.NullInt64
+	var action sql.NullString
+	var resolvedAt sql.NullTime
+	dest := append([]any{&report.ID, &report.TargetType, &report.TargetID, &report.TargetAuthorID, &categoryID,
+		&report.Snapshot, &report.ReporterID, &report.Reason, &report.Status, &action, &report.ResolutionNote,
+		&resolvedBy, &resolvedAt, &report.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	if categoryID.Valid {
+		report.CategoryID = &categoryID.Int64
+	}
+	report.Action = action.String
+	if resolvedBy.Valid {
+		report.ResolvedBy = &resolvedBy.Int64
+	}
+	if resolvedAt.Valid {
+		report.ResolvedAt = &resolvedAt.Time
+	}
+	return nil
+}
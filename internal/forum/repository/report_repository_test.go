@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/forum/models"
+	"github.com/mos1rain/forum_go/pkg/database"
+	"github.com/mos1rain/forum_go/pkg/database/dbtest"
+)
+
+func TestReportRepository(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		repo := NewReportRepository(db)
+		ctx := context.Background()
+		category := int64(1)
+
+		reports := []*models.Report{
+			{TargetType: models.ReportTargetPost, TargetID: 10, TargetAuthorID: 2, CategoryID: &category, Snapshot: "spam", ReporterID: 5, Reason: "spam"},
+			{TargetType: models.ReportTargetPost, TargetID: 10, TargetAuthorID: 2, CategoryID: &category, Snapshot: "spam", ReporterID: 6, Reason: "ads"},
+			{TargetType: models.ReportTargetChatMessage, TargetID: 3, TargetAuthorID: 4, Snapshot: "hi", ReporterID: 5, Reason: "rude"},
+		}
+		for _, report := range reports {
+			created, err := repo.Create(ctx, report)
+			if err != nil || !created || report.ID == 0 {
+				t.Fatalf("create: created %v, id %d, err %v", created, report.ID, err)
+			}
+		}
+		if created, err := repo.Create(ctx, &models.Report{TargetType: models.ReportTargetPost, TargetID: 10, TargetAuthorID: 2, Snapshot: "spam", ReporterID: 5, Reason: "again"}); err != nil || created {
+			t.Fatalf("duplicate create: created %v, err %v", created, err)
+		}
+
+		page, err := repo.List(ctx, models.ReportFilter{Status: models.ReportStatusOpen}, models.ListOptions{Limit: 2})
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if page.Total != 3 || len(page.Items) != 2 || page.Items[0].ID != reports[0].ID || page.NextCursor == "" {
+			t.Fatalf("unexpected first page: %+v", page)
+		}
+		page, err = repo.List(ctx, models.ReportFilter{Status: models.ReportStatusOpen}, models.ListOptions{Limit: 2, Cursor: page.NextCursor})
+		if err != nil || len(page.Items) != 1 || page.Items[0].CategoryID != nil {
+			t.Fatalf("unexpected second page: %+v, %v", page, err)
+		}
+
+		moderator := int64(9)
+		now := time.Now().UTC().Truncate(time.Second)
+		closed, err := repo.ResolveTarget(ctx, &models.Report{
+			TargetType:     models.ReportTargetPost,
+			TargetID:       10,
+			Status:         models.ReportStatusActioned,
+			Action:         models.ReportActionDelete,
+			ResolutionNote: "removed",
+			ResolvedBy:     &moderator,
+			ResolvedAt:     &now,
+		})
+		if err != nil || closed != 2 {
+			t.Fatalf("resolve: closed %d, err %v", closed, err)
+		}
+
+		report, err := repo.GetByID(ctx, reports[1].ID)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		if report.Status != models.ReportStatusActioned || report.Action != models.ReportActionDelete ||
+			report.ResolvedBy == nil || *report.ResolvedBy != moderator || report.ResolvedAt == nil || *report.CategoryID != category {
+			t.Errorf("unexpected resolved report: %+v", report)
+		}
+
+		page, err = repo.List(ctx, models.ReportFilter{Status: models.ReportStatusOpen, TargetType: models.ReportTargetChatMessage}, models.ListOptions{})
+		if err != nil || page.Total != 1 || page.Items[0].Action != "" {
+			t.Errorf("unexpected open chat reports: %+v, %v", page, err)
+		}
+		if page, _ = repo.List(ctx, models.ReportFilter{Status: models.ReportStatusOpen, TargetType: models.ReportTargetPost}, models.ListOptions{}); page.Total != 0 {
+			t.Errorf("expected no open post reports, got %+v", page.Items)
+		}
+		if missing, err := repo.GetByID(ctx, 999); err != nil || missing != nil {
+			t.Errorf("get missing: %+v, %v", missing, err)
+		}
+	})
+}
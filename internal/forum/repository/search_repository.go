@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/mos1rain/forum_go/internal/forum/models"
+	"github.com/mos1rain/forum_go/pkg/database"
+)
+
+var ErrEmptyQuery = errors.New("search query is empty")
+
+// Маркеры подсветки, которые FTS5 вставляет в текст. Текст экранируется
+// целиком, и только потом маркеры заменяются на <mark>, поэтому
+// пользовательский HTML в результаты не попадает.
+const (
+	markStart = "\x02"
+	markEnd   = "\x03"
+)
+
+type SearchRepository struct {
+	db *database.DB
+}
+
+type SearchRepositoryInterface interface {
+	Search(query models.SearchQuery, opts models.ListOptions) (*models.Page[models.SearchResult], error)
+}
+
+func NewSearchRepository(db *database.DB) *SearchRepository {
+	return &SearchRepository{db: db}
+}
+
+var searchSortKeys = map[string]sortKey{
+	models.SortRelevance: {expr: "rank"},
+	models.SortNewest:    {expr: "created_at", desc: true},
+	models.SortOldest:    {expr: "created_at"},
+}
+
+// sqliteSearchDocuments объединяет совпадения по постам (заголовок весит больше
+// текста) и по комментариям; удалённое не ищется. Посты и комментарии имеют
+// независимые id, поэтому для курсора используется doc_id: чётный у постов,
+// нечётный у комментариев.
+const sqliteSearchDocuments = `
+	SELECT 'post' AS type, p.id AS ref_id, p.id * 2 AS id, p.id AS post_id, p.category_id, p.author_id,
+		highlight(posts_fts, 0, '` + markStart + `', '` + markEnd + `') AS title,
+		snippet(posts_fts, 1, '` + markStart + `', '` + markEnd + `', '…', 24) AS snippet,
+		bm25(posts_fts, 10.0, 1.0) AS rank, p.created_at
+	FROM posts_fts JOIN posts p ON p.id = posts_fts.rowid
+	WHERE posts_fts MATCH ? AND ` + visiblePost + `
+	UNION ALL
+	SELECT 'comment', c.id, c.id * 2 + 1, c.post_id, p.category_id, c.user_id,
+		p.title,
+		snippet(comments_fts, 0, '` + markStart + `', '` + markEnd + `', '…', 24),
+		bm25(comments_fts), c.created_at
+	FROM comments_fts JOIN comments c ON c.id = comments_fts.rowid JOIN posts p ON p.id = c.post_id
+	WHERE comments_fts MATCH ? AND NOT c.deleted AND ` + visiblePost
+
+// headlineOptions параметры ts_headline с теми же маркерами подсветки
+const headlineOptions = `StartSel=` + markStart + `, StopSel=` + markEnd
+
+// postgresSearchDocuments то же, что sqliteSearchDocuments, но на tsvector.
+// ts_rank растёт с релевантностью, поэтому берётся со знаком минус, как bm25.
+const postgresSearchDocuments = `
+	SELECT 'post' AS type, p.id AS ref_id, p.id * 2 AS id, p.id AS post_id, p.category_id, p.author_id,
+		ts_headline('simple', p.title, q.query, 'HighlightAll=true, ` + headlineOptions + `') AS title,
+		ts_headline('simple', p.content, q.query, 'MaxWords=24, MinWords=8, ` + headlineOptions + `') AS snippet,
+		-ts_rank(p.search_vector, q.query)::float8 AS rank, p.created_at
+	FROM posts p CROSS JOIN to_tsquery('simple', ?) AS q(query)
+	WHERE p.search_vector @@ q.query AND ` + visiblePost + `
+	UNION ALL
+	SELECT 'comment', c.id, c.id * 2 + 1, c.post_id, p.category_id, c.user_id,
+		p.title,
+		ts_headline('simple', c.content, q.query, 'MaxWords=24, MinWords=8, ` + headlineOptions + `'),
+		-ts_rank(c.search_vector, q.query)::float8, c.created_at
+	FROM comments c JOIN posts p ON p.id = c.post_id CROSS JOIN to_tsquery('simple', ?) AS q(query)
+	WHERE c.search_vector @@ q.query AND NOT c.deleted AND ` + visiblePost
+
+// Search ищет по заголовкам и текстам постов и по комментариям.
+// По умолчанию результаты упорядочены по релевантности.
+func (r *SearchRepository) Search(query models.SearchQuery, opts models.ListOptions) (*models.Page[models.SearchResult], error) {
+	documents, match := sqliteSearchDocuments, ftsQuery(query.Query)
+	if r.db.Dialect == database.Postgres {
+		documents, match = postgresSearchDocuments, tsQuery(query.Query)
+	}
+	if match == "" {
+		return nil, ErrEmptyQuery
+	}
+
+	key, cur, limit, err := pageParams(opts, searchSortKeys, models.SortRelevance)
+	if err != nil {
+		return nil, err
+	}
+
+	var conds []string
+	args := []any{match, match}
+	if query.Type != "" {
+		conds = append(conds, "type = ?")
+		args = append(args, query.Type)
+	}
+	if query.CategoryID != 0 {
+		conds = append(conds, "category_id = ?")
+		args = append(args, query.CategoryID)
+	}
+	if query.AuthorID != 0 {
+		conds = append(conds, "author_id = ?")
+		args = append(args, query.AuthorID)
+	}
+	if !query.From.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, query.From)
+	}
+	if !query.To.IsZero() {
+		conds = append(conds, "created_at < ?")
+		args = append(args, query.To)
+	}
+	docs := `SELECT * FROM (` + documents + `) AS docs`
+	if len(conds) > 0 {
+		docs += " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM (`+docs+`) AS matches`, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	inner := `SELECT type, ref_id, id, post_id, category_id, author_id, title, snippet, rank, created_at, ` +
+		key.expr + ` AS sort_key FROM (` + docs + `) AS matches`
+	q, qargs := keysetQuery("", inner, args, key, cur, limit)
+
+	rows, err := r.db.Query(q, qargs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []models.SearchResult{}
+	var docIDs []int64
+	var keys []any
+	for rows.Next() {
+		var res models.SearchResult
+		var docID int64
+		var k any
+		if err := rows.Scan(&res.Type, &res.ID, &docID, &res.PostID, &res.CategoryID, &res.AuthorID,
+			&res.Title, &res.Snippet, &res.Rank, &res.CreatedAt, &k); err != nil {
+			return nil, err
+		}
+		res.Title = highlight(res.Title)
+		res.Snippet = highlight(res.Snippet)
+		results = append(results, res)
+		docIDs = append(docIDs, docID)
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	next := ""
+	if len(results) > limit {
+		results = results[:limit]
+		next = encodeCursor(keys[limit-1], docIDs[limit-1])
+	}
+	return &models.Page[models.SearchResult]{Items: results, NextCursor: next, Total: total}, nil
+}
+
+// ftsQuery превращает пользовательский ввод в запрос FTS5: каждое слово
+// берётся в кавычки (чтобы операторы FTS5 не ломали запрос) и ищется по префиксу
+func ftsQuery(q string) string {
+	var terms []string
+	for _, word := range strings.Fields(q) {
+		word = strings.ReplaceAll(word, `"`, `""`)
+		terms = append(terms, `"`+word+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// tsQuery строит запрос to_tsquery: в словах остаются только буквы и цифры,
+// каждое ищется по префиксу, и все они должны встретиться в документе
+func tsQuery(q string) string {
+	words := strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = word + ":*"
+	}
+	return strings.Join(terms, " & ")
+}
+
+func highlight(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, markStart, "<mark>")
+	return strings.ReplaceAll(s, markEnd, "</mark>")
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/forum/models"
+	"github.com/mos1rain/forum_go/pkg/database"
+	"github.com/mos1rain/forum_go/pkg/database/dbtest"
+)
+
+func TestSearchRepository_Search(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		posts := NewPostRepository(db)
+		comments := NewCommentRepository(db)
+		search := NewSearchRepository(db)
+		seedCategories(t, db, 2)
+
+		mountains := &models.Post{Title: "Горы Кавказа", Content: "Поход по <b>горам</b>", AuthorID: 1, CategoryID: 1}
+		rivers := &models.Post{Title: "Реки", Content: "Сплав по рекам, потом в горы", AuthorID: 2, CategoryID: 2}
+		for _, p := range []*models.Post{mountains, rivers} {
+			if err := posts.Create(p); err != nil {
+				t.Fatalf("create post: %v", err)
+			}
+		}
+		comment := &models.Comment{PostID: rivers.ID, AuthorID: 3, Content: "Какие горы посоветуете?"}
+		if err := comments.Create(comment); err != nil {
+			t.Fatalf("create comment: %v", err)
+		}
+
+		page, err := search.Search(models.SearchQuery{Query: "гор"}, models.ListOptions{})
+		if err != nil {
+			t.Fatalf("search: %v", err)
+		}
+		if page.Total != 3 || len(page.Items) != 3 {
+			t.Fatalf("expected 3 results, got %+v", page)
+		}
+		// Совпадение в заголовке весит больше, чем в тексте
+		if first := page.Items[0]; first.Type != models.SearchTypePost || first.ID != mountains.ID {
+			t.Errorf("expected title match first, got %+v", first)
+		}
+		if got := page.Items[0].Snippet; got != "Поход по &lt;b&gt;<mark>горам</mark>&lt;/b&gt;" {
+			t.Errorf("unexpected snippet %q", got)
+		}
+
+		page, err = search.Search(models.SearchQuery{Query: "гор", Type: models.SearchTypeComment}, models.ListOptions{})
+		if err != nil {
+			t.Fatalf("search comments: %v", err)
+		}
+		if len(page.Items) != 1 || page.Items[0].ID != comment.ID || page.Items[0].PostID != rivers.ID {
+			t.Errorf("expected only the comment, got %+v", page.Items)
+		}
+
+		page, err = search.Search(models.SearchQuery{Query: "гор", CategoryID: 2, AuthorID: 2}, models.ListOptions{})
+		if err != nil {
+			t.Fatalf("search filtered: %v", err)
+		}
+		if len(page.Items) != 1 || page.Items[0].ID != rivers.ID {
+			t.Errorf("expected only the rivers post, got %+v", page.Items)
+		}
+
+		page, err = search.Search(models.SearchQuery{Query: "гор", To: time.Now().Add(-24 * time.Hour)}, models.ListOptions{})
+		if err != nil {
+			t.Fatalf("search by date: %v", err)
+		}
+		if page.Total != 0 {
+			t.Errorf("expected nothing before yesterday, got %+v", page.Items)
+		}
+	})
+}
+
+func TestSearchRepository_SyncAndPaging(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *database.DB) {
+		posts := NewPostRepository(db)
+		search := NewSearchRepository(db)
+		seedCategories(t, db, 1)
+
+		post := &models.Post{Title: "Первый", Content: "черновик", AuthorID: 1, CategoryID: 1}
+		if err := posts.Create(post); err != nil {
+			t.Fatalf("create post: %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := posts.Create(&models.Post{Title: "Другой", Content: "черновик", AuthorID: 1, CategoryID: 1}); err != nil {
+				t.Fatalf("create post: %v", err)
+			}
+		}
+
+		first, err := search.Search(models.SearchQuery{Query: "черновик"}, models.ListOptions{Limit: 2})
+		if err != nil {
+			t.Fatalf("search: %v", err)
+		}
+		second, err := search.Search(models.SearchQuery{Query: "черновик"}, models.ListOptions{Limit: 2, Cursor: first.NextCursor})
+		if err != nil {
+			t.Fatalf("search next: %v", err)
+		}
+		if len(first.Items) != 2 || len(second.Items) != 1 || second.NextCursor != "" {
+			t.Fatalf("unexpected pages: %+v / %+v", first, second)
+		}
+
+		post.Content = "финальная версия"
+		if err := posts.Update(post, post.AuthorID); err != nil {
+			t.Fatalf("update post: %v", err)
+		}
+		if page, _ := search.Search(models.SearchQuery{Query: "финальная"}, models.ListOptions{}); page.Total != 1 {
+			t.Errorf("updated post must be found by new content")
+		}
+		if page, _ := search.Search(models.SearchQuery{Query: "черновик"}, models.ListOptions{}); page.Total != 2 {
+			t.Errorf("updated post must not be found by old content")
+		}
+
+		if err := posts.Delete(int(post.ID), 1); err != nil {
+			t.Fatalf("delete post: %v", err)
+		}
+		if page, _ := search.Search(models.SearchQuery{Query: "финальная"}, models.ListOptions{}); page.Total != 0 {
+			t.Errorf("deleted post must not be found")
+		}
+
+		if _, err := search.Search(models.SearchQuery{Query: `  "  `}, models.ListOptions{}); err != nil && !errors.Is(err, ErrEmptyQuery) {
+			t.Errorf("quotes must not break the query: %v", err)
+		}
+		if _, err := search.Search(models.SearchQuery{Query: "   "}, models.ListOptions{}); !errors.Is(err, ErrEmptyQuery) {
+			t.Errorf("expected ErrEmptyQuery, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+
+	"github.com/mos1rain/forum_go/internal/forum/repository"
+	"github.com/mos1rain/forum_go/pkg/rbac"
+)
+
+// Роли пользователей
+const (
+	RoleUser      = rbac.RoleUser
+	RoleModerator = rbac.RoleModerator
+	RoleAdmin     = rbac.RoleAdmin
+)
+
+// Actor пользователь, от имени которого выполняется действие
+// (берётся из контекста AuthMiddleware)
+type Actor struct {
+	UserID int64
+	Role   string
+	// Token access-токен пользователя; с ним auth и chat выполняют
+	// действия, которые forum делает от его имени
+	Token string
+}
+
+// Authorizer проверяет права пользователя: по роли — на всём форуме,
+// по назначению модератором — в пределах категории
+type Authorizer struct {
+	policy     *rbac.Policy
+	moderators repository.ModeratorRepositoryInterface
+}
+
+// Can сообщает, есть ли у actor право perm в категории categoryID.
+// Без хранилища назначений учитывается только роль.
+func (a *Authorizer) Can(ctx context.Context, actor Actor, perm rbac.Permission, categoryID int64) (bool, error) {
+	if a.policy.Can(actor.Role, perm) {
+		return true, nil
+	}
+	if a.moderators == nil || categoryID == 0 || !rbac.IsCategoryScoped(perm) {
+		return false, nil
+	}
+	return a.moderators.IsModerator(ctx, categoryID, actor.UserID)
+}
+
+// require то же, что Can, но отсутствие права возвращает ErrPermissionDenied
+func (a *Authorizer) require(ctx context.Context, actor Actor, perm rbac.Permission, categoryID int64) error {
+	ok, err := a.Can(ctx, actor, perm, categoryID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPermissionDenied
+	}
+	return nil
+}
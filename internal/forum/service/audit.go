@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mos1rain/forum_go/pkg/audit"
+)
+
+// ErrAuditUnavailable журнал действий недоступен; действие модерации не выполнено
+var ErrAuditUnavailable = errors.New("audit log unavailable")
+
+// AuditLog журнал действий модерации, который ведёт auth-сервис
+type AuditLog interface {
+	RecordAudit(ctx context.Context, token string, entry audit.Entry) error
+}
+
+// auditor записывает действия модераторов и администраторов форума.
+// Пока журнал не включён через EnableAudit, записи отбрасываются.
+type auditor struct {
+	log AuditLog
+}
+
+// record пишет в журнал действие actor над объектом; before — состояние
+// объекта до действия. Запись подписывается токеном actor. Вызывается
+// перед изменением: если записать не удалось, действие не выполняется.
+func (a *auditor) record(ctx context.Context, actor Actor, action, targetType string, targetID int64, before any, reason string) error {
+	if a.log == nil {
+		return nil
+	}
+	err := a.log.RecordAudit(ctx, actor.Token, audit.Entry{
+		Service:    audit.ServiceForum,
+		ActorID:    actor.UserID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Snapshot:   audit.Snapshot(before),
+		Reason:     reason,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrAuditUnavailable, err)
+	}
+	return nil
+}
@@ -6,20 +6,29 @@ import (
 
 	"github.com/mos1rain/forum_go/internal/forum/models"
 	"github.com/mos1rain/forum_go/internal/forum/repository"
+	"github.com/mos1rain/forum_go/pkg/audit"
+	"github.com/mos1rain/forum_go/pkg/rbac"
 )
 
 var (
 	ErrCategoryNotFound  = errors.New("category not found")
 	ErrAdminRoleRequired = errors.New("only admin can delete categories")
+	ErrUserNotModerator  = errors.New("user is not a moderator of this category")
 )
 
 type CategoryService struct {
-	repo repository.CategoryRepositoryInterface
+	repo       repository.CategoryRepositoryInterface
+	users      UserDirectory
+	authz      *Authorizer
+	audit      *auditor
+	moderators repository.ModeratorRepositoryInterface
 }
 
 func NewCategoryService(repo repository.CategoryRepositoryInterface) *CategoryService {
 	return &CategoryService{
-		repo: repo,
+		repo:  repo,
+		authz: &Authorizer{policy: rbac.Default},
+		audit: &auditor{},
 	}
 }
 
@@ -31,6 +40,10 @@ func (s *CategoryService) GetAll(ctx context.Context) ([]*models.Category, error
 	return s.repo.GetCategories(ctx)
 }
 
+func (s *CategoryService) List(ctx context.Context, opts models.ListOptions) (*models.Page[*models.Category], error) {
+	return s.repo.ListCategories(ctx, opts)
+}
+
 func (s *CategoryService) GetByID(ctx context.Context, id int64) (*models.Category, error) {
 	category, err := s.repo.GetCategoryByID(ctx, id)
 	if err != nil {
@@ -42,9 +55,13 @@ func (s *CategoryService) GetByID(ctx context.Context, id int64) (*models.Catego
 	return category, nil
 }
 
-func (s *CategoryService) Delete(ctx context.Context, id int64, role string) error {
-	// Проверяем роль пользователя
-	if role != "admin" {
+// Delete удаляет категорию вместе с её постами. Нужно право
+// delete_category, которое роль даёт на всём форуме. Удаление
+// записывается в журнал с причиной reason; до очистки категорию можно
+// восстановить.
+func (s *CategoryService) Delete(ctx context.Context, id int64, actor Actor, reason string) error {
+	// Проверяем права роли
+	if !s.authz.policy.Can(actor.Role, rbac.DeleteCategory) {
 		return ErrAdminRoleRequired
 	}
 
@@ -57,6 +74,139 @@ func (s *CategoryService) Delete(ctx context.Context, id int64, role string) err
 		return ErrCategoryNotFound
 	}
 
+	if err := s.audit.record(ctx, actor, audit.ActionDeleteCategory, audit.TargetCategory, category.ID, category, reason); err != nil {
+		return err
+	}
+
 	// Удаляем категорию
-	return s.repo.DeleteCategory(ctx, id)
+	return s.repo.DeleteCategory(ctx, id, actor.UserID)
+}
+
+// ListDeleted возвращает страницу удалённых категорий. Нужно право restore_content.
+func (s *CategoryService) ListDeleted(ctx context.Context, actor Actor, opts models.ListOptions) (*models.Page[*models.Category], error) {
+	if err := s.authz.require(ctx, actor, rbac.RestoreContent, 0); err != nil {
+		return nil, err
+	}
+	return s.repo.ListDeletedCategories(ctx, opts)
+}
+
+// Restore возвращает удалённую категорию, а с ней и её посты.
+// Нужно право restore_content; восстановление записывается в журнал.
+func (s *CategoryService) Restore(ctx context.Context, id int64, actor Actor) (*models.Category, error) {
+	if err := s.authz.require(ctx, actor, rbac.RestoreContent, 0); err != nil {
+		return nil, err
+	}
+	category, err := s.repo.GetDeletedCategory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if category == nil {
+		return nil, ErrCategoryNotFound
+	}
+
+	if err := s.audit.record(ctx, actor, audit.ActionRestoreCategory, audit.TargetCategory, category.ID, category, ""); err != nil {
+		return nil, err
+	}
+	restored, err := s.repo.RestoreCategory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !restored {
+		return nil, ErrCategoryNotFound
+	}
+	category.DeletedAt, category.DeletedBy = nil, nil
+	return category, nil
+}
+
+// Moderators возвращает модераторов категории
+func (s *CategoryService) Moderators(ctx context.Context, categoryID int64) ([]models.CategoryModerator, error) {
+	if _, err := s.GetByID(ctx, categoryID); err != nil {
+		return nil, err
+	}
+	return s.moderators.ListByCategory(ctx, categoryID)
+}
+
+// AssignModerator назначает пользователя модератором категории: в её
+// пределах он получает права rbac.CategoryScoped. Нужно право
+// manage_moderators. Возвращает false, если назначение уже было.
+func (s *CategoryService) AssignModerator(ctx context.Context, categoryID, userID int64, actor Actor) (*models.CategoryModerator, bool, error) {
+	if err := s.authz.require(ctx, actor, rbac.ManageModerators, 0); err != nil {
+		return nil, false, err
+	}
+	if _, err := s.GetByID(ctx, categoryID); err != nil {
+		return nil, false, err
+	}
+
+	// Пользователи хранятся в auth-сервисе
+	exists, err := s.users.UserExists(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if !exists {
+		return nil, false, ErrUserNotFound
+	}
+
+	moderator := &models.CategoryModerator{CategoryID: categoryID, UserID: userID, AssignedBy: &actor.UserID}
+
+	// В журнал попадает только новое назначение
+	assigned, err := s.moderators.IsModerator(ctx, categoryID, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if !assigned {
+		if err := s.audit.record(ctx, actor, audit.ActionAssignModerator, audit.TargetUser, userID, moderator, ""); err != nil {
+			return nil, false, err
+		}
+	}
+	created, err := s.moderators.Add(ctx, moderator)
+	if err != nil {
+		return nil, false, err
+	}
+	if created {
+		return moderator, true, nil
+	}
+
+	// Уже назначен: возвращаем существующую запись
+	moderators, err := s.moderators.ListByCategory(ctx, categoryID)
+	if err != nil {
+		return nil, false, err
+	}
+	for i := range moderators {
+		if moderators[i].UserID == userID {
+			return &moderators[i], false, nil
+		}
+	}
+	return moderator, false, nil
+}
+
+// RemoveModerator снимает пользователя с модерации категории.
+// Нужно право manage_moderators.
+func (s *CategoryService) RemoveModerator(ctx context.Context, categoryID, userID int64, actor Actor) error {
+	if err := s.authz.require(ctx, actor, rbac.ManageModerators, 0); err != nil {
+		return err
+	}
+	if _, err := s.GetByID(ctx, categoryID); err != nil {
+		return err
+	}
+
+	assigned, err := s.moderators.IsModerator(ctx, categoryID, userID)
+	if err != nil {
+		return err
+	}
+	if !assigned {
+		return ErrUserNotModerator
+	}
+	if err := s.audit.record(ctx, actor, audit.ActionRemoveModerator, audit.TargetUser, userID,
+		models.CategoryModerator{CategoryID: categoryID, UserID: userID}, ""); err != nil {
+		return err
+	}
+
+	removed, err := s.moderators.Remove(ctx, categoryID, userID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrUserNotModerator
+	}
+	return nil
 }
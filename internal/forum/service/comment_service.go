@@ -1,20 +1,213 @@
 package service
 
 import (
+	"context"
+	"errors"
+
 	"github.com/mos1rain/forum_go/internal/forum/models"
 	"github.com/mos1rain/forum_go/internal/forum/repository"
+	"github.com/mos1rain/forum_go/pkg/audit"
+	"github.com/mos1rain/forum_go/pkg/rbac"
+)
+
+// DefaultMaxCommentDepth максимальная вложенность ответов по умолчанию
+const DefaultMaxCommentDepth = 5
+
+var (
+	ErrCommentNotFound  = errors.New("comment not found")
+	ErrPostLocked       = errors.New("post is locked")
+	ErrInvalidParent    = errors.New("parent comment not found in this post")
+	ErrMaxDepthExceeded = errors.New("maximum reply depth exceeded")
 )
 
 type CommentService struct {
-	repo repository.CommentRepositoryInterface
+	repo  repository.CommentRepositoryInterface
+	posts repository.PostRepositoryInterface
+	authz *Authorizer
+	audit *auditor
+	// MaxDepth максимальный уровень вложенности ответа (корневые комментарии имеют уровень 0)
+	MaxDepth int
 }
 
-func (s *CommentService) Create(comment *models.Comment) error {
+// Create добавляет комментарий к существующему и не закрытому посту.
+// AuthorID должен быть заполнен вызывающей стороной из аутентифицированного пользователя.
+// Если задан ParentID, комментарий становится ответом на комментарий того же поста.
+func (s *CommentService) Create(ctx context.Context, comment *models.Comment) error {
+	post, err := s.posts.GetByID(int(comment.PostID))
+	if err != nil {
+		return err
+	}
+	if post == nil {
+		return ErrPostNotFound
+	}
+	if post.Locked {
+		return ErrPostLocked
+	}
+
+	comment.Depth = 0
+	if comment.ParentID != nil {
+		parent, err := s.repo.GetByID(int(*comment.ParentID))
+		if err != nil {
+			return err
+		}
+		if parent == nil || parent.PostID != comment.PostID || parent.Deleted {
+			return ErrInvalidParent
+		}
+		if parent.Depth+1 > s.MaxDepth {
+			return ErrMaxDepthExceeded
+		}
+		comment.Depth = parent.Depth + 1
+	}
+
 	return s.repo.Create(comment)
 }
-func (s *CommentService) GetByPostID(postID int) ([]models.Comment, error) {
-	return s.repo.GetByPostID(postID)
+
+// GetByPostID возвращает страницу веток обсуждения поста плоским списком
+// в порядке обхода дерева: за каждым комментарием следуют ответы на него,
+// уровень указан в Depth
+func (s *CommentService) GetByPostID(postID int, opts models.ListOptions) (*models.Page[models.Comment], error) {
+	tree, err := s.GetTree(postID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	comments := []models.Comment{}
+	var walk func(nodes []*models.Comment)
+	walk = func(nodes []*models.Comment) {
+		for _, c := range nodes {
+			replies := c.Replies
+			c.Replies = nil
+			comments = append(comments, *c)
+			walk(replies)
+		}
+	}
+	walk(tree.Items)
+	return &models.Page[models.Comment]{Items: comments, NextCursor: tree.NextCursor, Total: tree.Total}, nil
 }
-func (s *CommentService) Delete(id int) error {
-	return s.repo.Delete(id)
+
+// GetTree возвращает страницу корневых комментариев поста с вложенными
+// ответами. Удалённый комментарий остаётся в дереве без текста и автора,
+// только если под ним есть неудалённые ответы. У удалённого поста
+// комментариев не видно.
+func (s *CommentService) GetTree(postID int, opts models.ListOptions) (*models.Page[*models.Comment], error) {
+	post, err := s.posts.GetByID(postID)
+	if err != nil {
+		return nil, err
+	}
+	if post == nil {
+		return &models.Page[*models.Comment]{Items: []*models.Comment{}}, nil
+	}
+
+	page, err := s.repo.ListThreads(postID, opts)
+	if err != nil {
+		return nil, err
+	}
+	comments := page.Items
+
+	nodes := make(map[int64]*models.Comment, len(comments))
+	for i := range comments {
+		c := &comments[i]
+		// У удалённых комментариев не показываем ни текст, ни автора
+		if c.Deleted {
+			c.Content = ""
+			c.AuthorID = 0
+		}
+		c.DeletedAt, c.DeletedBy = nil, nil
+		nodes[c.ID] = c
+	}
+
+	roots := []*models.Comment{}
+	for i := range comments {
+		c := &comments[i]
+		if c.ParentID != nil {
+			if parent, ok := nodes[*c.ParentID]; ok {
+				parent.Replies = append(parent.Replies, c)
+				continue
+			}
+		}
+		roots = append(roots, c)
+	}
+	return &models.Page[*models.Comment]{Items: pruneDeleted(roots), NextCursor: page.NextCursor, Total: page.Total}, nil
+}
+
+// pruneDeleted убирает из дерева удалённые комментарии, под которыми
+// не осталось неудалённых ответов
+func pruneDeleted(nodes []*models.Comment) []*models.Comment {
+	visible := nodes[:0]
+	for _, c := range nodes {
+		c.Replies = pruneDeleted(c.Replies)
+		if !c.Deleted || len(c.Replies) > 0 {
+			visible = append(visible, c)
+		}
+	}
+	return visible
+}
+
+// Delete удаляет комментарий. Удалить его может автор или тот, у кого
+// есть право delete_any_comment в категории поста (модератор категории).
+// Комментарий остаётся в базе до очистки: в ветке обсуждения на его месте
+// видна отметка об удалении, пока на него есть ответы. Удаление чужого
+// комментария записывается в журнал с причиной reason.
+func (s *CommentService) Delete(ctx context.Context, id int, actor Actor, reason string) error {
+	comment, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if comment == nil || comment.Deleted {
+		return ErrCommentNotFound
+	}
+
+	if comment.AuthorID != actor.UserID {
+		post, err := s.posts.GetByID(int(comment.PostID))
+		if err != nil {
+			return err
+		}
+		if post == nil {
+			return ErrPostNotFound
+		}
+		if err := s.authz.require(ctx, actor, rbac.DeleteAnyComment, post.CategoryID); err != nil {
+			return err
+		}
+		if err := s.audit.record(ctx, actor, audit.ActionDeleteComment, audit.TargetComment, comment.ID, comment, reason); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.Delete(id, actor.UserID)
+}
+
+// ListDeleted возвращает страницу удалённых комментариев. Нужно право restore_content.
+func (s *CommentService) ListDeleted(ctx context.Context, actor Actor, opts models.ListOptions) (*models.Page[models.Comment], error) {
+	if err := s.authz.require(ctx, actor, rbac.RestoreContent, 0); err != nil {
+		return nil, err
+	}
+	return s.repo.ListDeleted(opts)
+}
+
+// Restore возвращает удалённый комментарий. Нужно право restore_content;
+// восстановление записывается в журнал.
+func (s *CommentService) Restore(ctx context.Context, id int, actor Actor) (*models.Comment, error) {
+	if err := s.authz.require(ctx, actor, rbac.RestoreContent, 0); err != nil {
+		return nil, err
+	}
+	comment, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if comment == nil || !comment.Deleted {
+		return nil, ErrCommentNotFound
+	}
+
+	if err := s.audit.record(ctx, actor, audit.ActionRestoreComment, audit.TargetComment, comment.ID, comment, ""); err != nil {
+		return nil, err
+	}
+	restored, err := s.repo.Restore(id)
+	if err != nil {
+		return nil, err
+	}
+	if !restored {
+		return nil, ErrCommentNotFound
+	}
+	comment.Deleted, comment.DeletedAt, comment.DeletedBy = false, nil, nil
+	return comment, nil
 }
@@ -2,24 +2,98 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/mos1rain/forum_go/internal/forum/repository"
+	"github.com/mos1rain/forum_go/pkg/rbac"
+)
+
+// Ошибки параметров страницы, общие для всех списков
+var (
+	ErrInvalidCursor = repository.ErrInvalidCursor
+	ErrInvalidSort   = repository.ErrInvalidSort
 )
 
 type ForumService struct {
 	Categories *CategoryService
 	Posts      *PostService
 	Comments   *CommentService
+	Search     *SearchService
+	Reports    *ReportService
+	Authz      *Authorizer
+
+	audit *auditor
 }
 
-func NewForumService(catRepo repository.CategoryRepositoryInterface, postRepo repository.PostRepositoryInterface, commRepo repository.CommentRepositoryInterface) *ForumService {
+// NewForumService собирает сервисы форума. Права проверяются по rbac.Default;
+// модераторы категорий учитываются после EnableCategoryModerators.
+func NewForumService(catRepo repository.CategoryRepositoryInterface, postRepo repository.PostRepositoryInterface, commRepo repository.CommentRepositoryInterface, searchRepo repository.SearchRepositoryInterface, users UserDirectory) *ForumService {
+	authz := &Authorizer{policy: rbac.Default}
+	audit := &auditor{}
 	return &ForumService{
-		Categories: &CategoryService{repo: catRepo},
-		Posts:      &PostService{repo: postRepo},
-		Comments:   &CommentService{repo: commRepo},
+		Categories: &CategoryService{repo: catRepo, users: users, authz: authz, audit: audit},
+		Posts:      &PostService{repo: postRepo, categories: catRepo, users: users, authz: authz, audit: audit},
+		Comments:   &CommentService{repo: commRepo, posts: postRepo, authz: authz, audit: audit, MaxDepth: DefaultMaxCommentDepth},
+		Search:     &SearchService{repo: searchRepo},
+		Authz:      authz,
+		audit:      audit,
+	}
+}
+
+// EnableCategoryModerators включает назначения модераторов категорий:
+// назначенный пользователь получает права rbac.CategoryScoped в своей категории
+func (s *ForumService) EnableCategoryModerators(moderators repository.ModeratorRepositoryInterface) {
+	s.Authz.moderators = moderators
+	s.Categories.moderators = moderators
+}
+
+// EnableAudit включает журнал действий модерации: правка, откат и удаление
+// чужих постов, удаление чужих комментариев, удаление категорий, закрытие
+// тем, назначение модераторов и решения по жалобам записываются в log до
+// выполнения
+func (s *ForumService) EnableAudit(log AuditLog) {
+	s.audit.log = log
+}
+
+// EnableReports включает жалобы и очередь модерации. Сообщения чата
+// читаются и удаляются через chat, санкции выдаются через auth.
+func (s *ForumService) EnableReports(reports repository.ReportRepositoryInterface, chat ChatMessages, moderation Moderation) {
+	s.Reports = &ReportService{
+		repo:       reports,
+		posts:      s.Posts,
+		comments:   s.Comments,
+		chat:       chat,
+		moderation: moderation,
+		authz:      s.Authz,
+		audit:      s.audit,
+		now:        time.Now,
+	}
+}
+
+// PurgeStats сколько объектов окончательно удалено при очистке; посты и
+// комментарии, удалённые вместе с категорией или постом, не считаются
+type PurgeStats struct {
+	Categories int64
+	Posts      int64
+	Comments   int64
+}
+
+// PurgeDeleted окончательно удаляет категории, посты и комментарии,
+// удалённые раньше before. Вместе с категорией удаляются все её посты,
+// а вместе с постом — его комментарии.
+func (s *ForumService) PurgeDeleted(ctx context.Context, before time.Time) (PurgeStats, error) {
+	var stats PurgeStats
+	var err error
+	if stats.Comments, err = s.Comments.repo.Purge(before); err != nil {
+		return stats, err
+	}
+	if stats.Posts, err = s.Posts.repo.Purge(before); err != nil {
+		return stats, err
 	}
+	stats.Categories, err = s.Categories.repo.PurgeCategories(ctx, before)
+	return stats, err
 }
 
-func (s *ForumService) DeleteCategory(id int, userRole string) error {
-	return s.Categories.Delete(context.Background(), int64(id), userRole)
+func (s *ForumService) DeleteCategory(id int, actor Actor) error {
+	return s.Categories.Delete(context.Background(), int64(id), actor, "")
 }
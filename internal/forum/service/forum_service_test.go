@@ -1,118 +1,502 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/mos1rain/forum_go/internal/forum/models"
 	"github.com/mos1rain/forum_go/internal/forum/repository"
+	"github.com/mos1rain/forum_go/pkg/audit"
+	"github.com/mos1rain/forum_go/pkg/rbac"
 )
 
 type mockCategoryRepo struct{ cats []models.Category }
 
 var _ repository.CategoryRepositoryInterface = (*mockCategoryRepo)(nil)
 
-func (m *mockCategoryRepo) Create(cat *models.Category) error {
+func (m *mockCategoryRepo) CreateCategory(ctx context.Context, cat *models.Category) error {
 	m.cats = append(m.cats, *cat)
 	return nil
 }
-func (m *mockCategoryRepo) GetAll() ([]models.Category, error) { return m.cats, nil }
-func (m *mockCategoryRepo) Delete(id int) error                { return nil }
-func (m *mockCategoryRepo) GetByID(id int) (*models.Category, error) {
+func (m *mockCategoryRepo) GetCategories(ctx context.Context) ([]*models.Category, error) {
+	var res []*models.Category
+	for i := range m.cats {
+		res = append(res, &m.cats[i])
+	}
+	return res, nil
+}
+func (m *mockCategoryRepo) ListCategories(ctx context.Context, opts models.ListOptions) (*models.Page[*models.Category], error) {
+	cats, _ := m.GetCategories(ctx)
+	return &models.Page[*models.Category]{Items: cats, Total: len(cats)}, nil
+}
+func (m *mockCategoryRepo) DeleteCategory(ctx context.Context, id, deletedBy int64) error {
+	for i, c := range m.cats {
+		if c.ID == id && c.DeletedAt == nil {
+			now := time.Now()
+			m.cats[i].DeletedAt, m.cats[i].DeletedBy = &now, &deletedBy
+		}
+	}
+	return nil
+}
+func (m *mockCategoryRepo) GetCategoryByID(ctx context.Context, id int64) (*models.Category, error) {
+	for _, c := range m.cats {
+		if c.ID == id && c.DeletedAt == nil {
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+func (m *mockCategoryRepo) GetDeletedCategory(ctx context.Context, id int64) (*models.Category, error) {
 	for _, c := range m.cats {
-		if c.ID == id {
+		if c.ID == id && c.DeletedAt != nil {
 			return &c, nil
 		}
 	}
-	return nil, errors.New("not found")
+	return nil, nil
+}
+func (m *mockCategoryRepo) ListDeletedCategories(ctx context.Context, opts models.ListOptions) (*models.Page[*models.Category], error) {
+	cats := []*models.Category{}
+	for i := range m.cats {
+		if m.cats[i].DeletedAt != nil {
+			cats = append(cats, &m.cats[i])
+		}
+	}
+	return &models.Page[*models.Category]{Items: cats, Total: len(cats)}, nil
+}
+func (m *mockCategoryRepo) RestoreCategory(ctx context.Context, id int64) (bool, error) {
+	for i, c := range m.cats {
+		if c.ID == id && c.DeletedAt != nil {
+			m.cats[i].DeletedAt, m.cats[i].DeletedBy = nil, nil
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (m *mockCategoryRepo) PurgeCategories(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
 }
 
-type mockPostRepo struct{ posts []models.Post }
+type mockPostRepo struct {
+	posts     []models.Post
+	revisions []models.PostRevision
+}
 
 var _ repository.PostRepositoryInterface = (*mockPostRepo)(nil)
 
 func (m *mockPostRepo) Create(post *models.Post) error {
 	m.posts = append(m.posts, *post)
+	m.addRevision(post, post.AuthorID)
 	return nil
 }
+func (m *mockPostRepo) addRevision(post *models.Post, editorID int64) {
+	n := 1
+	for _, r := range m.revisions {
+		if r.PostID == post.ID && r.Revision >= n {
+			n = r.Revision + 1
+		}
+	}
+	m.revisions = append(m.revisions, models.PostRevision{ID: int64(len(m.revisions) + 1), PostID: post.ID, Revision: n,
+		EditorID: &editorID, Title: post.Title, Content: post.Content})
+}
 func (m *mockPostRepo) GetAll() ([]models.Post, error) { return m.posts, nil }
+func (m *mockPostRepo) List(filter models.PostFilter, opts models.ListOptions) (*models.Page[models.Post], error) {
+	posts := []models.Post{}
+	for _, p := range m.posts {
+		if (filter.CategoryID == 0 || p.CategoryID == filter.CategoryID) && (filter.AuthorID == 0 || p.AuthorID == filter.AuthorID) {
+			posts = append(posts, p)
+		}
+	}
+	return &models.Page[models.Post]{Items: posts, Total: len(posts)}, nil
+}
 func (m *mockPostRepo) GetByID(id int) (*models.Post, error) {
 	for _, p := range m.posts {
-		if p.ID == id {
+		if p.ID == int64(id) && p.DeletedAt == nil {
 			return &p, nil
 		}
 	}
-	return nil, errors.New("not found")
+	return nil, nil
 }
-func (m *mockPostRepo) Delete(id int) error { return nil }
-func (m *mockPostRepo) Update(post *models.Post) error {
+func (m *mockPostRepo) Delete(id int, deletedBy int64) error {
+	for i, p := range m.posts {
+		if p.ID == int64(id) && p.DeletedAt == nil {
+			now := time.Now()
+			m.posts[i].DeletedAt, m.posts[i].DeletedBy = &now, &deletedBy
+		}
+	}
+	return nil
+}
+func (m *mockPostRepo) GetDeleted(id int) (*models.Post, error) {
+	for _, p := range m.posts {
+		if p.ID == int64(id) && p.DeletedAt != nil {
+			return &p, nil
+		}
+	}
+	return nil, nil
+}
+func (m *mockPostRepo) ListDeleted(opts models.ListOptions) (*models.Page[models.Post], error) {
+	posts := []models.Post{}
+	for _, p := range m.posts {
+		if p.DeletedAt != nil {
+			posts = append(posts, p)
+		}
+	}
+	return &models.Page[models.Post]{Items: posts, Total: len(posts)}, nil
+}
+func (m *mockPostRepo) Restore(id int) (bool, error) {
+	for i, p := range m.posts {
+		if p.ID == int64(id) && p.DeletedAt != nil {
+			m.posts[i].DeletedAt, m.posts[i].DeletedBy = nil, nil
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (m *mockPostRepo) Purge(before time.Time) (int64, error) { return 0, nil }
+func (m *mockPostRepo) SetLocked(id int, locked bool) error {
+	for i, p := range m.posts {
+		if p.ID == int64(id) {
+			m.posts[i].Locked = locked
+		}
+	}
+	return nil
+}
+func (m *mockPostRepo) Update(post *models.Post, editorID int64) error {
 	for i, p := range m.posts {
 		if p.ID == post.ID {
 			m.posts[i] = *post
+			if p.Title != post.Title || p.Content != post.Content {
+				m.addRevision(post, editorID)
+			}
 			return nil
 		}
 	}
 	return errors.New("not found")
 }
+func (m *mockPostRepo) ListRevisions(postID int, opts models.ListOptions) (*models.Page[models.PostRevision], error) {
+	revisions := []models.PostRevision{}
+	for _, r := range m.revisions {
+		if r.PostID == int64(postID) {
+			revisions = append(revisions, r)
+		}
+	}
+	return &models.Page[models.PostRevision]{Items: revisions, Total: len(revisions)}, nil
+}
+func (m *mockPostRepo) GetRevision(postID, revision int) (*models.PostRevision, error) {
+	for _, r := range m.revisions {
+		if r.PostID == int64(postID) && r.Revision == revision {
+			return &r, nil
+		}
+	}
+	return nil, nil
+}
 
 type mockCommentRepo struct{ comms []models.Comment }
 
 var _ repository.CommentRepositoryInterface = (*mockCommentRepo)(nil)
 
 func (m *mockCommentRepo) Create(c *models.Comment) error {
+	if c.ID == 0 {
+		c.ID = int64(len(m.comms) + 1)
+	}
 	m.comms = append(m.comms, *c)
 	return nil
 }
 func (m *mockCommentRepo) GetByPostID(postID int) ([]models.Comment, error) {
 	var res []models.Comment
 	for _, c := range m.comms {
-		if c.PostID == postID {
+		if c.PostID == int64(postID) {
 			res = append(res, c)
 		}
 	}
 	return res, nil
 }
-func (m *mockCommentRepo) Delete(id int) error { return nil }
+func (m *mockCommentRepo) ListThreads(postID int, opts models.ListOptions) (*models.Page[models.Comment], error) {
+	comms, _ := m.GetByPostID(postID)
+	roots := 0
+	for _, c := range comms {
+		if c.ParentID == nil {
+			roots++
+		}
+	}
+	return &models.Page[models.Comment]{Items: comms, Total: roots}, nil
+}
+func (m *mockCommentRepo) GetByID(id int) (*models.Comment, error) {
+	for _, c := range m.comms {
+		if c.ID == int64(id) {
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+func (m *mockCommentRepo) Delete(id int, deletedBy int64) error {
+	for i, c := range m.comms {
+		if c.ID == int64(id) && !c.Deleted {
+			now := time.Now()
+			m.comms[i].Deleted, m.comms[i].DeletedAt, m.comms[i].DeletedBy = true, &now, &deletedBy
+		}
+	}
+	return nil
+}
+func (m *mockCommentRepo) ListDeleted(opts models.ListOptions) (*models.Page[models.Comment], error) {
+	comms := []models.Comment{}
+	for _, c := range m.comms {
+		if c.Deleted {
+			comms = append(comms, c)
+		}
+	}
+	return &models.Page[models.Comment]{Items: comms, Total: len(comms)}, nil
+}
+func (m *mockCommentRepo) Restore(id int) (bool, error) {
+	for i, c := range m.comms {
+		if c.ID == int64(id) && c.Deleted {
+			m.comms[i].Deleted, m.comms[i].DeletedAt, m.comms[i].DeletedBy = false, nil, nil
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (m *mockCommentRepo) Purge(before time.Time) (int64, error) { return 0, nil }
+
+type mockSearchRepo struct{ last models.SearchQuery }
+
+func (m *mockSearchRepo) Search(query models.SearchQuery, opts models.ListOptions) (*models.Page[models.SearchResult], error) {
+	m.last = query
+	return &models.Page[models.SearchResult]{Items: []models.SearchResult{}}, nil
+}
+
+type mockUserDirectory struct{ ids []int64 }
+
+func (m *mockUserDirectory) UserExists(ctx context.Context, id int64) (bool, error) {
+	for _, u := range m.ids {
+		if u == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// mockModeratorRepo хранит назначения как категория -> пользователи
+type mockModeratorRepo struct{ assigned map[int64][]int64 }
+
+var _ repository.ModeratorRepositoryInterface = (*mockModeratorRepo)(nil)
+
+func (m *mockModeratorRepo) Add(ctx context.Context, moderator *models.CategoryModerator) (bool, error) {
+	if ok, _ := m.IsModerator(ctx, moderator.CategoryID, moderator.UserID); ok {
+		return false, nil
+	}
+	if m.assigned == nil {
+		m.assigned = map[int64][]int64{}
+	}
+	m.assigned[moderator.CategoryID] = append(m.assigned[moderator.CategoryID], moderator.UserID)
+	return true, nil
+}
+func (m *mockModeratorRepo) Remove(ctx context.Context, categoryID, userID int64) (bool, error) {
+	for i, id := range m.assigned[categoryID] {
+		if id == userID {
+			m.assigned[categoryID] = append(m.assigned[categoryID][:i], m.assigned[categoryID][i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (m *mockModeratorRepo) ListByCategory(ctx context.Context, categoryID int64) ([]models.CategoryModerator, error) {
+	res := []models.CategoryModerator{}
+	for _, id := range m.assigned[categoryID] {
+		res = append(res, models.CategoryModerator{CategoryID: categoryID, UserID: id})
+	}
+	return res, nil
+}
+func (m *mockModeratorRepo) IsModerator(ctx context.Context, categoryID, userID int64) (bool, error) {
+	for _, id := range m.assigned[categoryID] {
+		if id == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func strPtr(s string) *string { return &s }
+func int64Ptr(i int64) *int64 { return &i }
 
 func TestCreateAndGetCategory(t *testing.T) {
 	catRepo := &mockCategoryRepo{}
-	fs := NewForumService(catRepo, &mockPostRepo{}, &mockCommentRepo{})
+	fs := NewForumService(catRepo, &mockPostRepo{}, &mockCommentRepo{}, &mockSearchRepo{}, &mockUserDirectory{})
 	cat := &models.Category{Name: "TestCat", Description: "desc"}
-	if err := fs.Categories.Create(cat); err != nil {
+	if err := fs.Categories.Create(context.Background(), cat); err != nil {
 		t.Fatalf("create: %v", err)
 	}
-	cats, err := fs.Categories.GetAll()
+	cats, err := fs.Categories.GetAll(context.Background())
 	if err != nil || len(cats) != 1 {
 		t.Fatalf("get all: %v", err)
 	}
 }
 
 func TestCreateAndGetPost(t *testing.T) {
+	now := time.Now()
+	catRepo := &mockCategoryRepo{cats: []models.Category{{ID: 1, Name: "One"}, {ID: 2, Name: "Gone", DeletedAt: &now}}}
 	postRepo := &mockPostRepo{}
-	fs := NewForumService(&mockCategoryRepo{}, postRepo, &mockCommentRepo{})
-	post := &models.Post{ID: 1, Title: "Test", Content: "Body", CategoryID: 1, UserID: 1}
-	if err := fs.Posts.Create(post); err != nil {
+	fs := NewForumService(catRepo, postRepo, &mockCommentRepo{}, &mockSearchRepo{}, &mockUserDirectory{})
+	ctx := context.Background()
+	post := &models.Post{ID: 1, Title: "Test", Content: "Body", CategoryID: 1, AuthorID: 1}
+	if err := fs.Posts.Create(ctx, post); err != nil {
 		t.Fatalf("create: %v", err)
 	}
 	posts, err := fs.Posts.GetAll()
 	if err != nil || len(posts) != 1 {
 		t.Fatalf("get all: %v", err)
 	}
+
+	for _, categoryID := range []int64{2, 99} {
+		orphan := &models.Post{ID: 2, Title: "Orphan", Content: "Body", CategoryID: categoryID, AuthorID: 1}
+		if err := fs.Posts.Create(ctx, orphan); !errors.Is(err, ErrCategoryNotFound) {
+			t.Errorf("category %d: expected ErrCategoryNotFound, got %v", categoryID, err)
+		}
+	}
+	if posts, _ := fs.Posts.GetAll(); len(posts) != 1 {
+		t.Errorf("posts in missing categories must not be created, got %d", len(posts))
+	}
 }
 
 func TestCreateAndGetComment(t *testing.T) {
 	commRepo := &mockCommentRepo{}
-	fs := NewForumService(&mockCategoryRepo{}, &mockPostRepo{}, commRepo)
-	comm := &models.Comment{ID: 1, PostID: 1, Content: "Test comment", UserID: 1}
-	if err := fs.Comments.Create(comm); err != nil {
+	postRepo := &mockPostRepo{posts: []models.Post{{ID: 1, Title: "Title", Content: "Content", AuthorID: 1}}}
+	fs := NewForumService(&mockCategoryRepo{}, postRepo, commRepo, &mockSearchRepo{}, &mockUserDirectory{})
+	comm := &models.Comment{ID: 1, PostID: 1, Content: "Test comment", AuthorID: 1}
+	if err := fs.Comments.Create(context.Background(), comm); err != nil {
 		t.Fatalf("create: %v", err)
 	}
-	comms, err := fs.Comments.GetByPostID(1)
-	if err != nil || len(comms) != 1 {
+	comms, err := fs.Comments.GetByPostID(1, models.ListOptions{})
+	if err != nil || len(comms.Items) != 1 {
 		t.Fatalf("get by post: %v", err)
 	}
 }
 
+func TestCreateCommentPostChecks(t *testing.T) {
+	postRepo := &mockPostRepo{
+		posts: []models.Post{{ID: 1, Title: "Locked", Content: "Content", AuthorID: 1, Locked: true}},
+	}
+	fs := NewForumService(&mockCategoryRepo{}, postRepo, &mockCommentRepo{}, &mockSearchRepo{}, &mockUserDirectory{})
+
+	err := fs.Comments.Create(context.Background(), &models.Comment{PostID: 999, Content: "c", AuthorID: 1})
+	if !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("expected ErrPostNotFound, got %v", err)
+	}
+
+	err = fs.Comments.Create(context.Background(), &models.Comment{PostID: 1, Content: "c", AuthorID: 1})
+	if !errors.Is(err, ErrPostLocked) {
+		t.Errorf("expected ErrPostLocked, got %v", err)
+	}
+}
+
+func newThreadService(t *testing.T, maxDepth int) (*ForumService, *mockCommentRepo) {
+	t.Helper()
+	postRepo := &mockPostRepo{posts: []models.Post{
+		{ID: 1, Title: "One", Content: "Content", AuthorID: 1},
+		{ID: 2, Title: "Two", Content: "Content", AuthorID: 1},
+	}}
+	commRepo := &mockCommentRepo{}
+	fs := NewForumService(&mockCategoryRepo{}, postRepo, commRepo, &mockSearchRepo{}, &mockUserDirectory{})
+	fs.Comments.MaxDepth = maxDepth
+	return fs, commRepo
+}
+
+func createComment(t *testing.T, fs *ForumService, postID int64, parentID *int64) *models.Comment {
+	t.Helper()
+	c := &models.Comment{PostID: postID, ParentID: parentID, Content: "text", AuthorID: 1}
+	if err := fs.Comments.Create(context.Background(), c); err != nil {
+		t.Fatalf("create comment: %v", err)
+	}
+	return c
+}
+
+func TestCommentReplies(t *testing.T) {
+	fs, _ := newThreadService(t, 2)
+
+	root := createComment(t, fs, 1, nil)
+	reply := createComment(t, fs, 1, &root.ID)
+	nested := createComment(t, fs, 1, &reply.ID)
+	second := createComment(t, fs, 1, nil)
+
+	if reply.Depth != 1 || nested.Depth != 2 {
+		t.Errorf("unexpected depths: reply %d, nested %d", reply.Depth, nested.Depth)
+	}
+
+	err := fs.Comments.Create(context.Background(), &models.Comment{PostID: 1, ParentID: &nested.ID, Content: "deep", AuthorID: 1})
+	if !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("expected ErrMaxDepthExceeded, got %v", err)
+	}
+	err = fs.Comments.Create(context.Background(), &models.Comment{PostID: 2, ParentID: &root.ID, Content: "other post", AuthorID: 1})
+	if !errors.Is(err, ErrInvalidParent) {
+		t.Errorf("expected ErrInvalidParent, got %v", err)
+	}
+
+	tree, err := fs.Comments.GetTree(1, models.ListOptions{})
+	if err != nil {
+		t.Fatalf("tree: %v", err)
+	}
+	if len(tree.Items) != 2 || len(tree.Items[0].Replies) != 1 || len(tree.Items[0].Replies[0].Replies) != 1 {
+		t.Fatalf("unexpected tree shape: %+v", tree)
+	}
+
+	flat, err := fs.Comments.GetByPostID(1, models.ListOptions{})
+	if err != nil {
+		t.Fatalf("flat: %v", err)
+	}
+	var ids []int64
+	for _, c := range flat.Items {
+		ids = append(ids, c.ID)
+	}
+	want := []int64{root.ID, reply.ID, nested.ID, second.ID}
+	if len(ids) != len(want) {
+		t.Fatalf("expected %v, got %v", want, ids)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, ids)
+		}
+	}
+}
+
+func TestCommentDeleteKeepsThread(t *testing.T) {
+	fs, commRepo := newThreadService(t, DefaultMaxCommentDepth)
+	author := Actor{UserID: 1, Role: RoleUser}
+
+	root := createComment(t, fs, 1, nil)
+	reply := createComment(t, fs, 1, &root.ID)
+
+	// Комментарий с ответом становится tombstone
+	if err := fs.Comments.Delete(context.Background(), int(root.ID), author, ""); err != nil {
+		t.Fatalf("delete root: %v", err)
+	}
+	tree, _ := fs.Comments.GetTree(1, models.ListOptions{})
+	if len(tree.Items) != 1 || !tree.Items[0].Deleted || tree.Items[0].Content != "" || tree.Items[0].AuthorID != 0 || len(tree.Items[0].Replies) != 1 {
+		t.Fatalf("expected tombstone with reply, got %+v", tree)
+	}
+	if err := fs.Comments.Delete(context.Background(), int(root.ID), author, ""); !errors.Is(err, ErrCommentNotFound) {
+		t.Errorf("expected ErrCommentNotFound for tombstone, got %v", err)
+	}
+	err := fs.Comments.Create(context.Background(), &models.Comment{PostID: 1, ParentID: &root.ID, Content: "late", AuthorID: 1})
+	if !errors.Is(err, ErrInvalidParent) {
+		t.Errorf("expected ErrInvalidParent when replying to tombstone, got %v", err)
+	}
+
+	// После удаления последнего ответа ветка не видна, но комментарии
+	// остаются в базе до очистки
+	if err := fs.Comments.Delete(context.Background(), int(reply.ID), author, ""); err != nil {
+		t.Fatalf("delete reply: %v", err)
+	}
+	tree, _ = fs.Comments.GetTree(1, models.ListOptions{})
+	if len(tree.Items) != 0 {
+		t.Errorf("expected empty thread, got %+v", tree.Items)
+	}
+	if len(commRepo.comms) != 2 || !commRepo.comms[1].Deleted {
+		t.Errorf("expected both comments kept as deleted, got %+v", commRepo.comms)
+	}
+}
+
 func TestCategoryGetByID(t *testing.T) {
 	catRepo := &mockCategoryRepo{
 		cats: []models.Category{
@@ -120,10 +504,10 @@ func TestCategoryGetByID(t *testing.T) {
 			{ID: 2, Name: "TestCat2", Description: "desc2"},
 		},
 	}
-	fs := NewForumService(catRepo, &mockPostRepo{}, &mockCommentRepo{})
+	fs := NewForumService(catRepo, &mockPostRepo{}, &mockCommentRepo{}, &mockSearchRepo{}, &mockUserDirectory{})
 
 	// Test existing category
-	cat, err := fs.Categories.GetByID(1)
+	cat, err := fs.Categories.GetByID(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("get by id: %v", err)
 	}
@@ -132,9 +516,9 @@ func TestCategoryGetByID(t *testing.T) {
 	}
 
 	// Test non-existing category
-	cat, err = fs.Categories.GetByID(999)
-	if err == nil {
-		t.Error("expected error for non-existing category")
+	_, err = fs.Categories.GetByID(context.Background(), 999)
+	if !errors.Is(err, ErrCategoryNotFound) {
+		t.Errorf("expected ErrCategoryNotFound, got %v", err)
 	}
 }
 
@@ -144,30 +528,34 @@ func TestCategoryDelete(t *testing.T) {
 			{ID: 1, Name: "TestCat", Description: "desc"},
 		},
 	}
-	fs := NewForumService(catRepo, &mockPostRepo{}, &mockCommentRepo{})
+	fs := NewForumService(catRepo, &mockPostRepo{}, &mockCommentRepo{}, &mockSearchRepo{}, &mockUserDirectory{})
 
-	if err := fs.Categories.Delete(1); err != nil {
+	if err := fs.Categories.Delete(context.Background(), 1, Actor{UserID: 1, Role: RoleAdmin}, ""); err != nil {
 		t.Fatalf("delete: %v", err)
 	}
+	if err := fs.Categories.Delete(context.Background(), 1, Actor{UserID: 2, Role: RoleUser}, ""); !errors.Is(err, ErrAdminRoleRequired) {
+		t.Fatalf("expected ErrAdminRoleRequired, got %v", err)
+	}
 }
 
 func TestPostUpdate(t *testing.T) {
+	catRepo := &mockCategoryRepo{
+		cats: []models.Category{{ID: 1, Name: "One"}, {ID: 2, Name: "Two"}},
+	}
 	postRepo := &mockPostRepo{
 		posts: []models.Post{
-			{ID: 1, Title: "Old Title", Content: "Old Content", CategoryID: 1, UserID: 1},
+			{ID: 1, Title: "Old Title", Content: "Old Content", CategoryID: 1, AuthorID: 1},
 		},
 	}
-	fs := NewForumService(&mockCategoryRepo{}, postRepo, &mockCommentRepo{})
+	fs := NewForumService(catRepo, postRepo, &mockCommentRepo{}, &mockSearchRepo{}, &mockUserDirectory{})
 
-	updatedPost := &models.Post{
-		ID:         1,
-		Title:      "New Title",
-		Content:    "New Content",
-		CategoryID: 2,
-		UserID:     1,
+	input := models.UpdatePostInput{
+		Title:      strPtr("New Title"),
+		Content:    strPtr("New Content"),
+		CategoryID: int64Ptr(2),
 	}
 
-	if err := fs.Posts.Update(updatedPost); err != nil {
+	if _, err := fs.Posts.Update(context.Background(), 1, input, Actor{UserID: 1, Role: RoleUser}); err != nil {
 		t.Fatalf("update: %v", err)
 	}
 
@@ -189,30 +577,569 @@ func TestPostUpdate(t *testing.T) {
 
 func TestPostUpdateNonExisting(t *testing.T) {
 	postRepo := &mockPostRepo{}
-	fs := NewForumService(&mockCategoryRepo{}, postRepo, &mockCommentRepo{})
+	fs := NewForumService(&mockCategoryRepo{}, postRepo, &mockCommentRepo{}, &mockSearchRepo{}, &mockUserDirectory{})
 
-	nonExistingPost := &models.Post{
-		ID:         999,
-		Title:      "New Title",
-		Content:    "New Content",
-		CategoryID: 1,
-		UserID:     1,
+	input := models.UpdatePostInput{Title: strPtr("New Title")}
+	_, err := fs.Posts.Update(context.Background(), 999, input, Actor{UserID: 1, Role: RoleUser})
+	if !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("expected ErrPostNotFound, got %v", err)
 	}
+}
+
+// Чужой пост переносится, только если право edit_any_post есть в обеих категориях
+func TestPostUpdateMove(t *testing.T) {
+	deleted := time.Now()
+	catRepo := &mockCategoryRepo{cats: []models.Category{
+		{ID: 1, Name: "One"}, {ID: 2, Name: "Two"}, {ID: 3, Name: "Deleted", DeletedAt: &deleted},
+	}}
+
+	tests := []struct {
+		name     string
+		actor    Actor
+		category int64
+		wantErr  error
+	}{
+		{name: "author", actor: Actor{UserID: 1, Role: RoleUser}, category: 2},
+		{name: "moderator", actor: Actor{UserID: 2, Role: RoleModerator}, category: 2},
+		{name: "moderator of both categories", actor: Actor{UserID: 7, Role: RoleUser}, category: 2},
+		{name: "moderator of source category only", actor: Actor{UserID: 6, Role: RoleUser}, category: 2, wantErr: ErrPermissionDenied},
+		{name: "missing category", actor: Actor{UserID: 2, Role: RoleModerator}, category: 42, wantErr: ErrCategoryNotFound},
+		{name: "deleted category", actor: Actor{UserID: 1, Role: RoleUser}, category: 3, wantErr: ErrCategoryNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			postRepo := &mockPostRepo{
+				posts: []models.Post{{ID: 1, Title: "Title", Content: "Content", CategoryID: 1, AuthorID: 1}},
+			}
+			fs := NewForumService(catRepo, postRepo, &mockCommentRepo{}, &mockSearchRepo{}, &mockUserDirectory{})
+			fs.EnableCategoryModerators(&mockModeratorRepo{assigned: map[int64][]int64{1: {6, 7}, 2: {7}}})
 
-	if err := fs.Posts.Update(nonExistingPost); err == nil {
-		t.Error("expected error for updating non-existing post")
+			_, err := fs.Posts.Update(context.Background(), 1, models.UpdatePostInput{CategoryID: &tt.category}, tt.actor)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			want := int64(1)
+			if tt.wantErr == nil {
+				want = tt.category
+			}
+			if postRepo.posts[0].CategoryID != want {
+				t.Errorf("expected post in category %d, got %d", want, postRepo.posts[0].CategoryID)
+			}
+		})
+	}
+}
+
+func TestPostUpdatePermissions(t *testing.T) {
+	catRepo := &mockCategoryRepo{cats: []models.Category{{ID: 1, Name: "One"}}}
+
+	tests := []struct {
+		name    string
+		actor   Actor
+		wantErr error
+	}{
+		{name: "author", actor: Actor{UserID: 1, Role: RoleUser}},
+		{name: "moderator", actor: Actor{UserID: 2, Role: RoleModerator}},
+		{name: "admin", actor: Actor{UserID: 3, Role: RoleAdmin}},
+		{name: "other user", actor: Actor{UserID: 4, Role: RoleUser}, wantErr: ErrPermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			postRepo := &mockPostRepo{
+				posts: []models.Post{{ID: 1, Title: "Title", Content: "Content", CategoryID: 1, AuthorID: 1}},
+			}
+			fs := NewForumService(catRepo, postRepo, &mockCommentRepo{}, &mockSearchRepo{}, &mockUserDirectory{})
+
+			_, err := fs.Posts.Update(context.Background(), 1, models.UpdatePostInput{Title: strPtr("Edited")}, tt.actor)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPostUpdateValidation(t *testing.T) {
+	postRepo := &mockPostRepo{
+		posts: []models.Post{{ID: 1, Title: "Title", Content: "Content", CategoryID: 1, AuthorID: 1}},
+	}
+	fs := NewForumService(&mockCategoryRepo{}, postRepo, &mockCommentRepo{}, &mockSearchRepo{}, &mockUserDirectory{})
+	author := Actor{UserID: 1, Role: RoleUser}
+
+	if _, err := fs.Posts.Update(context.Background(), 1, models.UpdatePostInput{Title: strPtr("  ")}, author); !errors.Is(err, ErrInvalidPost) {
+		t.Errorf("expected ErrInvalidPost, got %v", err)
+	}
+	if _, err := fs.Posts.Update(context.Background(), 1, models.UpdatePostInput{CategoryID: int64Ptr(42)}, author); !errors.Is(err, ErrCategoryNotFound) {
+		t.Errorf("expected ErrCategoryNotFound, got %v", err)
+	}
+}
+
+func TestPostDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		postID  int
+		actor   Actor
+		wantErr error
+	}{
+		{name: "author", postID: 1, actor: Actor{UserID: 1, Role: RoleUser}},
+		{name: "moderator", postID: 1, actor: Actor{UserID: 2, Role: RoleModerator}},
+		{name: "admin", postID: 1, actor: Actor{UserID: 3, Role: RoleAdmin}},
+		{name: "other user", postID: 1, actor: Actor{UserID: 4, Role: RoleUser}, wantErr: ErrPermissionDenied},
+		{name: "category moderator", postID: 1, actor: Actor{UserID: 6, Role: RoleUser}},
+		{name: "moderator of other category", postID: 1, actor: Actor{UserID: 7, Role: RoleUser}, wantErr: ErrPermissionDenied},
+		{name: "not found", postID: 999, actor: Actor{UserID: 1, Role: RoleAdmin}, wantErr: ErrPostNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			postRepo := &mockPostRepo{
+				posts: []models.Post{{ID: 1, Title: "Title", Content: "Content", CategoryID: 1, AuthorID: 1}},
+			}
+			fs := NewForumService(&mockCategoryRepo{}, postRepo, &mockCommentRepo{}, &mockSearchRepo{}, &mockUserDirectory{})
+			fs.EnableCategoryModerators(&mockModeratorRepo{assigned: map[int64][]int64{1: {6}, 2: {7}}})
+
+			if err := fs.Posts.Delete(context.Background(), tt.postID, tt.actor, ""); !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
 	}
 }
 
 func TestCommentDelete(t *testing.T) {
-	commRepo := &mockCommentRepo{
-		comms: []models.Comment{
-			{ID: 1, PostID: 1, Content: "Test comment", UserID: 1},
-		},
+	tests := []struct {
+		name      string
+		commentID int
+		actor     Actor
+		wantErr   error
+	}{
+		{name: "author", commentID: 1, actor: Actor{UserID: 1, Role: RoleUser}},
+		{name: "moderator", commentID: 1, actor: Actor{UserID: 2, Role: RoleModerator}},
+		{name: "admin", commentID: 1, actor: Actor{UserID: 3, Role: RoleAdmin}},
+		{name: "post author", commentID: 1, actor: Actor{UserID: 5, Role: RoleUser}, wantErr: ErrPermissionDenied},
+		{name: "category moderator", commentID: 1, actor: Actor{UserID: 6, Role: RoleUser}},
+		{name: "moderator of other category", commentID: 1, actor: Actor{UserID: 7, Role: RoleUser}, wantErr: ErrPermissionDenied},
+		{name: "not found", commentID: 999, actor: Actor{UserID: 1, Role: RoleAdmin}, wantErr: ErrCommentNotFound},
+		{name: "post not found", commentID: 2, actor: Actor{UserID: 3, Role: RoleAdmin}, wantErr: ErrPostNotFound},
+		{name: "post not found for category moderator", commentID: 2, actor: Actor{UserID: 6, Role: RoleUser}, wantErr: ErrPostNotFound},
 	}
-	fs := NewForumService(&mockCategoryRepo{}, &mockPostRepo{}, commRepo)
 
-	if err := fs.Comments.Delete(1); err != nil {
-		t.Fatalf("delete: %v", err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			postRepo := &mockPostRepo{
+				posts: []models.Post{{ID: 1, Title: "Title", Content: "Content", CategoryID: 1, AuthorID: 5}},
+			}
+			commRepo := &mockCommentRepo{
+				comms: []models.Comment{
+					{ID: 1, PostID: 1, Content: "Test comment", AuthorID: 1},
+					// Комментарий к посту, которого уже нет
+					{ID: 2, PostID: 2, Content: "Orphan comment", AuthorID: 1},
+				},
+			}
+			fs := NewForumService(&mockCategoryRepo{}, postRepo, commRepo, &mockSearchRepo{}, &mockUserDirectory{})
+			fs.EnableCategoryModerators(&mockModeratorRepo{assigned: map[int64][]int64{1: {6}, 2: {7}}})
+
+			if err := fs.Comments.Delete(context.Background(), tt.commentID, tt.actor, ""); !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPostSetLocked(t *testing.T) {
+	postRepo := &mockPostRepo{
+		posts: []models.Post{{ID: 1, Title: "Title", Content: "Content", CategoryID: 1, AuthorID: 1}},
+	}
+	fs := NewForumService(&mockCategoryRepo{}, postRepo, &mockCommentRepo{}, &mockSearchRepo{}, &mockUserDirectory{})
+	fs.EnableCategoryModerators(&mockModeratorRepo{assigned: map[int64][]int64{1: {6}}})
+	ctx := context.Background()
+
+	// Автор без права lock_thread закрыть свой пост не может
+	if _, err := fs.Posts.SetLocked(ctx, 1, true, Actor{UserID: 1, Role: RoleUser}); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("expected ErrPermissionDenied, got %v", err)
+	}
+	if _, err := fs.Posts.SetLocked(ctx, 999, true, Actor{UserID: 3, Role: RoleAdmin}); !errors.Is(err, ErrPostNotFound) {
+		t.Fatalf("expected ErrPostNotFound, got %v", err)
+	}
+
+	post, err := fs.Posts.SetLocked(ctx, 1, true, Actor{UserID: 6, Role: RoleUser})
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	if !post.Locked {
+		t.Error("expected post to be locked")
+	}
+	if err := fs.Comments.Create(ctx, &models.Comment{PostID: 1, AuthorID: 2, Content: "late"}); !errors.Is(err, ErrPostLocked) {
+		t.Errorf("expected ErrPostLocked, got %v", err)
+	}
+
+	if post, err = fs.Posts.SetLocked(ctx, 1, false, Actor{UserID: 2, Role: RoleModerator}); err != nil || post.Locked {
+		t.Fatalf("unlock: post %+v, err %v", post, err)
+	}
+}
+
+func TestModerationAudit(t *testing.T) {
+	catRepo := &mockCategoryRepo{cats: []models.Category{{ID: 1, Name: "One"}}}
+	postRepo := &mockPostRepo{posts: []models.Post{
+		{ID: 1, Title: "Mine", Content: "Content", CategoryID: 1, AuthorID: 1},
+		{ID: 2, Title: "Spam", Content: "Buy now", CategoryID: 1, AuthorID: 1},
+		{ID: 3, Title: "Draft", Content: "Oops", CategoryID: 1, AuthorID: 1},
+	}}
+	commRepo := &mockCommentRepo{comms: []models.Comment{{ID: 1, PostID: 1, AuthorID: 1, Content: "rude"}}}
+	fs := NewForumService(catRepo, postRepo, commRepo, &mockSearchRepo{}, &mockUserDirectory{})
+	log := &mockModeration{}
+	fs.EnableAudit(log)
+	ctx := context.Background()
+	author := Actor{UserID: 1, Role: RoleUser}
+	moderator := Actor{UserID: 2, Role: RoleModerator}
+
+	// Автор удаляет своё — это не модерация
+	if err := fs.Posts.Delete(ctx, 3, author, ""); err != nil {
+		t.Fatalf("author delete: %v", err)
+	}
+	if len(log.entries) != 0 {
+		t.Fatalf("author delete must not be audited: %+v", log.entries)
+	}
+
+	if err := fs.Posts.Delete(ctx, 2, moderator, "spam"); err != nil {
+		t.Fatalf("moderator delete: %v", err)
+	}
+	if err := fs.Comments.Delete(ctx, 1, moderator, "rude"); err != nil {
+		t.Fatalf("moderator delete comment: %v", err)
+	}
+	if _, err := fs.Posts.SetLocked(ctx, 1, true, moderator); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	if err := fs.Categories.Delete(ctx, 1, Actor{UserID: 3, Role: RoleAdmin}, "cleanup"); err != nil {
+		t.Fatalf("delete category: %v", err)
+	}
+
+	want := []struct {
+		action, targetType string
+		targetID           int64
+		actorID            int64
+	}{
+		{audit.ActionDeletePost, audit.TargetPost, 2, 2},
+		{audit.ActionDeleteComment, audit.TargetComment, 1, 2},
+		{audit.ActionLockPost, audit.TargetPost, 1, 2},
+		{audit.ActionDeleteCategory, audit.TargetCategory, 1, 3},
+	}
+	if len(log.entries) != len(want) {
+		t.Fatalf("expected %d entries, got %+v", len(want), log.entries)
+	}
+	for i, w := range want {
+		e := log.entries[i]
+		if e.Service != audit.ServiceForum || e.Action != w.action || e.TargetType != w.targetType || e.TargetID != w.targetID || e.ActorID != w.actorID {
+			t.Errorf("entry %d: expected %+v, got %+v", i, w, e)
+		}
+	}
+	var before models.Post
+	if err := json.Unmarshal(log.entries[0].Snapshot, &before); err != nil || before.Title != "Spam" || log.entries[0].Reason != "spam" {
+		t.Errorf("unexpected snapshot %s, reason %q", log.entries[0].Snapshot, log.entries[0].Reason)
+	}
+
+	// Без журнала действие модерации не выполняется
+	log.auditErr = errors.New("connection refused")
+	if _, err := fs.Posts.SetLocked(ctx, 1, false, moderator); !errors.Is(err, ErrAuditUnavailable) {
+		t.Fatalf("expected ErrAuditUnavailable, got %v", err)
+	}
+	if post, _ := postRepo.GetByID(1); !post.Locked {
+		t.Error("post must stay locked when the audit log is unavailable")
+	}
+}
+
+func TestCategoryModerators(t *testing.T) {
+	catRepo := &mockCategoryRepo{cats: []models.Category{{ID: 1, Name: "One"}}}
+	fs := NewForumService(catRepo, &mockPostRepo{}, &mockCommentRepo{}, &mockSearchRepo{}, &mockUserDirectory{ids: []int64{1, 5}})
+	fs.EnableCategoryModerators(&mockModeratorRepo{})
+	ctx := context.Background()
+	admin := Actor{UserID: 1, Role: RoleAdmin}
+
+	if _, _, err := fs.Categories.AssignModerator(ctx, 1, 5, Actor{UserID: 2, Role: RoleModerator}); !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("expected ErrPermissionDenied, got %v", err)
+	}
+	if _, _, err := fs.Categories.AssignModerator(ctx, 42, 5, admin); !errors.Is(err, ErrCategoryNotFound) {
+		t.Fatalf("expected ErrCategoryNotFound, got %v", err)
+	}
+	if _, _, err := fs.Categories.AssignModerator(ctx, 1, 99, admin); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+
+	moderator, created, err := fs.Categories.AssignModerator(ctx, 1, 5, admin)
+	if err != nil || !created {
+		t.Fatalf("assign: created %v, err %v", created, err)
+	}
+	if moderator.AssignedBy == nil || *moderator.AssignedBy != admin.UserID {
+		t.Errorf("expected assigned_by %d, got %v", admin.UserID, moderator.AssignedBy)
+	}
+	if _, created, _ := fs.Categories.AssignModerator(ctx, 1, 5, admin); created {
+		t.Error("second assignment must not be reported as created")
+	}
+
+	ok, err := fs.Authz.Can(ctx, Actor{UserID: 5, Role: RoleUser}, rbac.LockThread, 1)
+	if err != nil || !ok {
+		t.Errorf("category moderator must be able to lock threads: %v, %v", ok, err)
+	}
+	// Назначение не даёт прав на уровне форума
+	if ok, _ := fs.Authz.Can(ctx, Actor{UserID: 5, Role: RoleUser}, rbac.DeleteCategory, 1); ok {
+		t.Error("category moderator must not get site-wide permissions")
+	}
+
+	list, err := fs.Categories.Moderators(ctx, 1)
+	if err != nil || len(list) != 1 || list[0].UserID != 5 {
+		t.Fatalf("moderators: %+v, err %v", list, err)
+	}
+
+	if err := fs.Categories.RemoveModerator(ctx, 1, 5, admin); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if err := fs.Categories.RemoveModerator(ctx, 1, 5, admin); !errors.Is(err, ErrUserNotModerator) {
+		t.Errorf("expected ErrUserNotModerator, got %v", err)
+	}
+	if ok, _ := fs.Authz.Can(ctx, Actor{UserID: 5, Role: RoleUser}, rbac.LockThread, 1); ok {
+		t.Error("removed moderator must lose category permissions")
+	}
+}
+
+func TestPostListByCategoryAndAuthor(t *testing.T) {
+	catRepo := &mockCategoryRepo{cats: []models.Category{{ID: 1, Name: "One"}}}
+	postRepo := &mockPostRepo{posts: []models.Post{
+		{ID: 1, Title: "a", Content: "c", CategoryID: 1, AuthorID: 1},
+		{ID: 2, Title: "b", Content: "c", CategoryID: 2, AuthorID: 2},
+	}}
+	fs := NewForumService(catRepo, postRepo, &mockCommentRepo{}, &mockSearchRepo{}, &mockUserDirectory{ids: []int64{1, 2}})
+
+	page, err := fs.Posts.ListByCategory(context.Background(), 1, models.ListOptions{})
+	if err != nil || len(page.Items) != 1 || page.Items[0].ID != 1 {
+		t.Fatalf("list by category: %+v, %v", page, err)
+	}
+	if _, err := fs.Posts.ListByCategory(context.Background(), 999, models.ListOptions{}); !errors.Is(err, ErrCategoryNotFound) {
+		t.Errorf("expected ErrCategoryNotFound, got %v", err)
+	}
+
+	page, err = fs.Posts.ListByAuthor(context.Background(), 2, models.ListOptions{})
+	if err != nil || len(page.Items) != 1 || page.Items[0].ID != 2 {
+		t.Fatalf("list by author: %+v, %v", page, err)
+	}
+	if _, err := fs.Posts.ListByAuthor(context.Background(), 999, models.ListOptions{}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestSearchValidation(t *testing.T) {
+	searchRepo := &mockSearchRepo{}
+	fs := NewForumService(&mockCategoryRepo{}, &mockPostRepo{}, &mockCommentRepo{}, searchRepo, &mockUserDirectory{})
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		query   models.SearchQuery
+		wantErr error
+	}{
+		{name: "valid", query: models.SearchQuery{Query: "  go  ", Type: models.SearchTypePost}},
+		{name: "empty", query: models.SearchQuery{Query: "   "}, wantErr: ErrEmptyQuery},
+		{name: "bad type", query: models.SearchQuery{Query: "go", Type: "user"}, wantErr: ErrInvalidSearchType},
+		{name: "bad range", query: models.SearchQuery{Query: "go", From: now, To: now.Add(-time.Hour)}, wantErr: ErrInvalidDateRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fs.Search.Search(context.Background(), tt.query, models.ListOptions{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	if searchRepo.last.Query != "go" {
+		t.Errorf("expected trimmed query, got %q", searchRepo.last.Query)
+	}
+}
+
+func TestRestoreDeleted(t *testing.T) {
+	catRepo := &mockCategoryRepo{cats: []models.Category{{ID: 1, Name: "One"}}}
+	postRepo := &mockPostRepo{posts: []models.Post{{ID: 1, Title: "Post", Content: "Content", CategoryID: 1, AuthorID: 1}}}
+	commRepo := &mockCommentRepo{comms: []models.Comment{{ID: 1, PostID: 1, AuthorID: 1, Content: "text"}}}
+	fs := NewForumService(catRepo, postRepo, commRepo, &mockSearchRepo{}, &mockUserDirectory{})
+	log := &mockModeration{}
+	fs.EnableAudit(log)
+	ctx := context.Background()
+	author := Actor{UserID: 1, Role: RoleUser}
+	moderator := Actor{UserID: 2, Role: RoleModerator}
+	admin := Actor{UserID: 3, Role: RoleAdmin}
+
+	if err := fs.Comments.Delete(ctx, 1, author, ""); err != nil {
+		t.Fatalf("delete comment: %v", err)
+	}
+	if err := fs.Posts.Delete(ctx, 1, author, ""); err != nil {
+		t.Fatalf("delete post: %v", err)
+	}
+	if err := fs.Categories.Delete(ctx, 1, admin, ""); err != nil {
+		t.Fatalf("delete category: %v", err)
+	}
+	log.entries = nil
+
+	// Смотреть и восстанавливать удалённое может только администратор
+	if _, err := fs.Posts.ListDeleted(ctx, moderator, models.ListOptions{}); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected ErrPermissionDenied for moderator list, got %v", err)
+	}
+	if _, err := fs.Posts.Restore(ctx, 1, author); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected ErrPermissionDenied for author restore, got %v", err)
+	}
+	page, err := fs.Comments.ListDeleted(ctx, admin, models.ListOptions{})
+	if err != nil || page.Total != 1 || page.Items[0].Content != "text" {
+		t.Fatalf("unexpected deleted comments: %+v, %v", page, err)
+	}
+
+	category, err := fs.Categories.Restore(ctx, 1, admin)
+	if err != nil || category.DeletedAt != nil {
+		t.Fatalf("restore category: %+v, %v", category, err)
+	}
+	post, err := fs.Posts.Restore(ctx, 1, admin)
+	if err != nil || post.DeletedAt != nil || post.DeletedBy != nil {
+		t.Fatalf("restore post: %+v, %v", post, err)
+	}
+	if _, err := fs.Posts.Restore(ctx, 1, admin); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("expected ErrPostNotFound for visible post, got %v", err)
+	}
+	if _, err := fs.Comments.Restore(ctx, 1, admin); err != nil {
+		t.Fatalf("restore comment: %v", err)
+	}
+	tree, _ := fs.Comments.GetTree(1, models.ListOptions{})
+	if len(tree.Items) != 1 || tree.Items[0].Content != "text" {
+		t.Errorf("expected restored comment in the thread, got %+v", tree.Items)
+	}
+
+	want := []string{audit.ActionRestoreCategory, audit.ActionRestorePost, audit.ActionRestoreComment}
+	if len(log.entries) != len(want) {
+		t.Fatalf("expected %d entries, got %+v", len(want), log.entries)
+	}
+	for i, action := range want {
+		if e := log.entries[i]; e.Action != action || e.ActorID != admin.UserID {
+			t.Errorf("entry %d: expected %s by %d, got %+v", i, action, admin.UserID, e)
+		}
+	}
+
+	// Без журнала восстановление не выполняется
+	if err := fs.Posts.Delete(ctx, 1, author, ""); err != nil {
+		t.Fatalf("delete post: %v", err)
+	}
+	log.auditErr = errors.New("connection refused")
+	if _, err := fs.Posts.Restore(ctx, 1, admin); !errors.Is(err, ErrAuditUnavailable) {
+		t.Fatalf("expected ErrAuditUnavailable, got %v", err)
+	}
+	if post, _ := postRepo.GetByID(1); post != nil {
+		t.Error("post must stay deleted when the audit log is unavailable")
+	}
+}
+
+func TestPostRevisions(t *testing.T) {
+	catRepo := &mockCategoryRepo{cats: []models.Category{{ID: 1, Name: "One"}}}
+	postRepo := &mockPostRepo{}
+	fs := NewForumService(catRepo, postRepo, &mockCommentRepo{}, &mockSearchRepo{}, &mockUserDirectory{})
+	ctx := context.Background()
+	author := Actor{UserID: 1, Role: RoleUser}
+
+	if err := postRepo.Create(&models.Post{ID: 1, Title: "Title", Content: "one\ntwo", CategoryID: 1, AuthorID: 1}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	content := "one\nthree"
+	if _, err := fs.Posts.Update(ctx, 1, models.UpdatePostInput{Content: &content}, author); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	page, err := fs.Posts.ListRevisions(1, models.ListOptions{})
+	if err != nil || page.Total != 2 {
+		t.Fatalf("expected 2 revisions, got %+v, %v", page, err)
+	}
+	if _, err := fs.Posts.ListRevisions(999, models.ListOptions{}); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("expected ErrPostNotFound, got %v", err)
+	}
+
+	diff, err := fs.Posts.DiffRevisions(1, 1, 2)
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	want := "--- post/1/revision/1\n+++ post/1/revision/2\n@@ -1,4 +1,4 @@\n Title\n \n one\n-two\n+three\n"
+	if diff != want {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+	if _, err := fs.Posts.DiffRevisions(1, 1, 5); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("expected ErrRevisionNotFound, got %v", err)
+	}
+
+	// Откатить чужой пост может только модератор; откат — новая ревизия
+	if _, err := fs.Posts.Rollback(ctx, 1, 1, Actor{UserID: 2, Role: RoleUser}); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected ErrPermissionDenied, got %v", err)
+	}
+	if _, err := fs.Posts.Rollback(ctx, 1, 7, author); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("expected ErrRevisionNotFound, got %v", err)
+	}
+	post, err := fs.Posts.Rollback(ctx, 1, 1, Actor{UserID: 2, Role: RoleModerator})
+	if err != nil || post.Content != "one\ntwo" {
+		t.Fatalf("rollback: %+v, %v", post, err)
+	}
+	latest := postRepo.revisions[len(postRepo.revisions)-1]
+	if latest.Revision != 3 || latest.Content != "one\ntwo" || *latest.EditorID != 2 {
+		t.Errorf("expected rollback stored as revision 3 by the moderator, got %+v", latest)
+	}
+}
+
+func TestPostEditAudit(t *testing.T) {
+	catRepo := &mockCategoryRepo{cats: []models.Category{{ID: 1, Name: "One"}}}
+	postRepo := &mockPostRepo{}
+	fs := NewForumService(catRepo, postRepo, &mockCommentRepo{}, &mockSearchRepo{}, &mockUserDirectory{})
+	log := &mockModeration{}
+	fs.EnableAudit(log)
+	ctx := context.Background()
+	author := Actor{UserID: 1, Role: RoleUser}
+	moderator := Actor{UserID: 2, Role: RoleModerator}
+
+	if err := postRepo.Create(&models.Post{ID: 1, Title: "Title", Content: "Original", CategoryID: 1, AuthorID: 1}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// Автор правит своё — это не модерация
+	if _, err := fs.Posts.Update(ctx, 1, models.UpdatePostInput{Content: strPtr("Edited")}, author); err != nil {
+		t.Fatalf("author update: %v", err)
+	}
+	if len(log.entries) != 0 {
+		t.Fatalf("author edit must not be audited: %+v", log.entries)
+	}
+
+	if _, err := fs.Posts.Update(ctx, 1, models.UpdatePostInput{Content: strPtr("Moderated")}, moderator); err != nil {
+		t.Fatalf("moderator update: %v", err)
+	}
+	if _, err := fs.Posts.Rollback(ctx, 1, 1, moderator); err != nil {
+		t.Fatalf("moderator rollback: %v", err)
+	}
+
+	want := []struct {
+		action, content, reason string
+	}{
+		{audit.ActionEditPost, "Edited", ""},
+		{audit.ActionRollbackPost, "Moderated", "revision 1"},
+	}
+	if len(log.entries) != len(want) {
+		t.Fatalf("expected %d entries, got %+v", len(want), log.entries)
+	}
+	for i, w := range want {
+		e := log.entries[i]
+		var before models.Post
+		if err := json.Unmarshal(e.Snapshot, &before); err != nil {
+			t.Fatalf("entry %d: snapshot %s: %v", i, e.Snapshot, err)
+		}
+		if e.Action != w.action || e.TargetType != audit.TargetPost || e.TargetID != 1 || e.ActorID != 2 ||
+			before.Content != w.content || e.Reason != w.reason {
+			t.Errorf("entry %d: expected %+v, got %+v with snapshot %+v", i, w, e, before)
+		}
+	}
+
+	// Без журнала чужой пост не меняется
+	log.auditErr = errors.New("connection refused")
+	if _, err := fs.Posts.Update(ctx, 1, models.UpdatePostInput{Content: strPtr("Lost")}, moderator); !errors.Is(err, ErrAuditUnavailable) {
+		t.Fatalf("expected ErrAuditUnavailable, got %v", err)
+	}
+	if post, _ := postRepo.GetByID(1); post.Content != "Original" {
+		t.Errorf("post must not change when the audit log is unavailable, got %q", post.Content)
 	}
 }
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mos1rain/forum_go/internal/forum/models"
+	"github.com/mos1rain/forum_go/pkg/audit"
+	"github.com/mos1rain/forum_go/pkg/rbac"
+	"github.com/mos1rain/forum_go/pkg/textdiff"
+)
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// ListRevisions возвращает страницу ревизий поста, по умолчанию сначала новые
+func (s *PostService) ListRevisions(postID int, opts models.ListOptions) (*models.Page[models.PostRevision], error) {
+	if _, err := s.visiblePost(postID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListRevisions(postID, opts)
+}
+
+// DiffRevisions возвращает разницу между ревизиями from и to поста в
+// формате unified diff. Ревизия сравнивается как заголовок, пустая строка
+// и содержание. Для одинаковых ревизий возвращается пустая строка.
+func (s *PostService) DiffRevisions(postID, from, to int) (string, error) {
+	if _, err := s.visiblePost(postID); err != nil {
+		return "", err
+	}
+	a, err := s.revision(postID, from)
+	if err != nil {
+		return "", err
+	}
+	b, err := s.revision(postID, to)
+	if err != nil {
+		return "", err
+	}
+	return textdiff.Unified(fmt.Sprintf("post/%d/revision/%d", postID, from), fmt.Sprintf("post/%d/revision/%d", postID, to),
+		revisionText(a), revisionText(b), textdiff.DefaultContext), nil
+}
+
+// Rollback возвращает посту заголовок и содержание ревизии revision.
+// Откат — обычная правка: откатить пост может автор или тот, у кого есть
+// право edit_any_post в категории поста, и откат сохраняется новой ревизией.
+// Откат чужого поста записывается в журнал.
+func (s *PostService) Rollback(ctx context.Context, postID, revision int, actor Actor) (*models.Post, error) {
+	post, err := s.visiblePost(postID)
+	if err != nil {
+		return nil, err
+	}
+	if post.AuthorID != actor.UserID {
+		if err := s.authz.require(ctx, actor, rbac.EditAnyPost, post.CategoryID); err != nil {
+			return nil, err
+		}
+	}
+	rev, err := s.revision(postID, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	if post.AuthorID != actor.UserID {
+		reason := fmt.Sprintf("revision %d", revision)
+		if err := s.audit.record(ctx, actor, audit.ActionRollbackPost, audit.TargetPost, post.ID, post, reason); err != nil {
+			return nil, err
+		}
+	}
+	post.Title, post.Content = rev.Title, rev.Content
+	if err := s.repo.Update(post, actor.UserID); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+func (s *PostService) visiblePost(id int) (*models.Post, error) {
+	post, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if post == nil {
+		return nil, ErrPostNotFound
+	}
+	return post, nil
+}
+
+func (s *PostService) revision(postID, revision int) (*models.PostRevision, error) {
+	rev, err := s.repo.GetRevision(postID, revision)
+	if err != nil {
+		return nil, err
+	}
+	if rev == nil {
+		return nil, ErrRevisionNotFound
+	}
+	return rev, nil
+}
+
+func revisionText(rev *models.PostRevision) string {
+	return rev.Title + "\n\n" + rev.Content + "\n"
+}
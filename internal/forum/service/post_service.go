@@ -1,26 +1,228 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"strings"
+
 	"github.com/mos1rain/forum_go/internal/forum/models"
 	"github.com/mos1rain/forum_go/internal/forum/repository"
+	"github.com/mos1rain/forum_go/pkg/audit"
+	"github.com/mos1rain/forum_go/pkg/rbac"
+)
+
+var (
+	ErrPostNotFound     = errors.New("post not found")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrInvalidPost      = errors.New("title and content cannot be empty")
+	ErrUserNotFound     = errors.New("user not found")
 )
 
+// UserDirectory даёт доступ к пользователям auth-сервиса
+type UserDirectory interface {
+	UserExists(ctx context.Context, id int64) (bool, error)
+}
+
 type PostService struct {
-	repo repository.PostRepositoryInterface
+	repo       repository.PostRepositoryInterface
+	categories repository.CategoryRepositoryInterface
+	users      UserDirectory
+	authz      *Authorizer
+	audit      *auditor
 }
 
-func (s *PostService) Create(post *models.Post) error {
+// Create создаёт пост в существующей категории
+func (s *PostService) Create(ctx context.Context, post *models.Post) error {
+	category, err := s.categories.GetCategoryByID(ctx, post.CategoryID)
+	if err != nil {
+		return err
+	}
+	if category == nil {
+		return ErrCategoryNotFound
+	}
 	return s.repo.Create(post)
 }
 func (s *PostService) GetAll() ([]models.Post, error) {
 	return s.repo.GetAll()
 }
+func (s *PostService) List(opts models.ListOptions) (*models.Page[models.Post], error) {
+	return s.repo.List(models.PostFilter{}, opts)
+}
+
+// ListByCategory возвращает страницу постов категории
+func (s *PostService) ListByCategory(ctx context.Context, categoryID int64, opts models.ListOptions) (*models.Page[models.Post], error) {
+	category, err := s.categories.GetCategoryByID(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	if category == nil {
+		return nil, ErrCategoryNotFound
+	}
+	return s.repo.List(models.PostFilter{CategoryID: categoryID}, opts)
+}
+
+// ListByAuthor возвращает страницу постов пользователя.
+// Пользователи хранятся в auth-сервисе, поэтому существование проверяется через него.
+func (s *PostService) ListByAuthor(ctx context.Context, authorID int64, opts models.ListOptions) (*models.Page[models.Post], error) {
+	exists, err := s.users.UserExists(ctx, authorID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+	return s.repo.List(models.PostFilter{AuthorID: authorID}, opts)
+}
 func (s *PostService) GetByID(id int) (*models.Post, error) {
 	return s.repo.GetByID(id)
 }
-func (s *PostService) Update(post *models.Post) error {
-	return s.repo.Update(post)
+
+// Update изменяет заголовок, содержание и категорию поста.
+// Редактировать пост может автор или тот, у кого есть право edit_any_post
+// в категории поста; чтобы перенести чужой пост, это право нужно и в новой
+// категории. Правка заголовка или содержания сохраняется ревизией, правка
+// чужого поста записывается в журнал.
+func (s *PostService) Update(ctx context.Context, id int, input models.UpdatePostInput, actor Actor) (*models.Post, error) {
+	post, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if post == nil {
+		return nil, ErrPostNotFound
+	}
+	before := *post
+
+	if post.AuthorID != actor.UserID {
+		if err := s.authz.require(ctx, actor, rbac.EditAnyPost, post.CategoryID); err != nil {
+			return nil, err
+		}
+	}
+
+	if input.Title != nil {
+		post.Title = strings.TrimSpace(*input.Title)
+	}
+	if input.Content != nil {
+		post.Content = strings.TrimSpace(*input.Content)
+	}
+	if post.Title == "" || post.Content == "" {
+		return nil, ErrInvalidPost
+	}
+
+	if input.CategoryID != nil && *input.CategoryID != post.CategoryID {
+		category, err := s.categories.GetCategoryByID(ctx, *input.CategoryID)
+		if err != nil {
+			return nil, err
+		}
+		if category == nil {
+			return nil, ErrCategoryNotFound
+		}
+		if post.AuthorID != actor.UserID {
+			if err := s.authz.require(ctx, actor, rbac.EditAnyPost, category.ID); err != nil {
+				return nil, err
+			}
+		}
+		post.CategoryID = category.ID
+	}
+
+	if post.AuthorID != actor.UserID {
+		if err := s.audit.record(ctx, actor, audit.ActionEditPost, audit.TargetPost, post.ID, before, ""); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.repo.Update(post, actor.UserID); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+// Delete удаляет пост. Удалить его может автор или тот, у кого есть
+// право delete_any_post в категории поста (модератор категории).
+// Удаление чужого поста записывается в журнал с причиной reason.
+// Пост остаётся в базе до очистки и может быть восстановлен.
+func (s *PostService) Delete(ctx context.Context, id int, actor Actor, reason string) error {
+	post, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if post == nil {
+		return ErrPostNotFound
+	}
+
+	if post.AuthorID != actor.UserID {
+		if err := s.authz.require(ctx, actor, rbac.DeleteAnyPost, post.CategoryID); err != nil {
+			return err
+		}
+		if err := s.audit.record(ctx, actor, audit.ActionDeletePost, audit.TargetPost, post.ID, post, reason); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.Delete(id, actor.UserID)
+}
+
+// ListDeleted возвращает страницу удалённых постов. Нужно право restore_content.
+func (s *PostService) ListDeleted(ctx context.Context, actor Actor, opts models.ListOptions) (*models.Page[models.Post], error) {
+	if err := s.authz.require(ctx, actor, rbac.RestoreContent, 0); err != nil {
+		return nil, err
+	}
+	return s.repo.ListDeleted(opts)
 }
-func (s *PostService) Delete(id int) error {
-	return s.repo.Delete(id)
+
+// Restore возвращает удалённый пост вместе с его комментариями.
+// Нужно право restore_content; восстановление записывается в журнал.
+func (s *PostService) Restore(ctx context.Context, id int, actor Actor) (*models.Post, error) {
+	if err := s.authz.require(ctx, actor, rbac.RestoreContent, 0); err != nil {
+		return nil, err
+	}
+	post, err := s.repo.GetDeleted(id)
+	if err != nil {
+		return nil, err
+	}
+	if post == nil {
+		return nil, ErrPostNotFound
+	}
+
+	if err := s.audit.record(ctx, actor, audit.ActionRestorePost, audit.TargetPost, post.ID, post, ""); err != nil {
+		return nil, err
+	}
+	restored, err := s.repo.Restore(id)
+	if err != nil {
+		return nil, err
+	}
+	if !restored {
+		return nil, ErrPostNotFound
+	}
+	post.DeletedAt, post.DeletedBy = nil, nil
+	return post, nil
+}
+
+// SetLocked закрывает пост для новых комментариев или открывает его.
+// Нужно право lock_thread в категории поста.
+func (s *PostService) SetLocked(ctx context.Context, id int, locked bool, actor Actor) (*models.Post, error) {
+	post, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if post == nil {
+		return nil, ErrPostNotFound
+	}
+
+	if err := s.authz.require(ctx, actor, rbac.LockThread, post.CategoryID); err != nil {
+		return nil, err
+	}
+
+	if post.Locked != locked {
+		action := audit.ActionLockPost
+		if !locked {
+			action = audit.ActionUnlockPost
+		}
+		if err := s.audit.record(ctx, actor, action, audit.TargetPost, post.ID, post, ""); err != nil {
+			return nil, err
+		}
+		if err := s.repo.SetLocked(id, locked); err != nil {
+			return nil, err
+		}
+		post.Locked = locked
+	}
+	return post, nil
 }
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mos1rain/forum_go/internal/forum/models"
+	"github.com/mos1rain/forum_go/internal/forum/repository"
+	"github.com/mos1rain/forum_go/pkg/audit"
+	"github.com/mos1rain/forum_go/pkg/rbac"
+	"github.com/mos1rain/forum_go/pkg/sanction"
+)
+
+var (
+	ErrReportNotFound  = errors.New("report not found")
+	ErrInvalidReport   = errors.New("invalid report")
+	ErrAlreadyReported = errors.New("you have already reported this content")
+	ErrReportClosed    = errors.New("report is already closed")
+	ErrTargetNotFound  = errors.New("reported content not found")
+	ErrInvalidAction   = errors.New("invalid report action")
+)
+
+// MaxReportReasonSize наибольшая длина причины жалобы в символах
+const MaxReportReasonSize = 1000
+
+// ChatMessages сообщения chat-сервиса, на которые можно пожаловаться
+type ChatMessages interface {
+	// Message возвращает сообщение или nil, если его нет
+	Message(ctx context.Context, id int64) (*models.ChatMessage, error)
+	DeleteMessage(ctx context.Context, token string, id int64, reason string) error
+}
+
+// Moderation санкции auth-сервиса, которые модератор выдаёт из очереди
+// жалоб. Санкция выдаётся от имени владельца token; auth сам проверяет его
+// права и старшинство ролей и записывает санкцию в журнал.
+type Moderation interface {
+	IssueSanction(ctx context.Context, token string, userID int64, kind sanction.Type, reason, duration string) error
+}
+
+// ReportService жалобы на посты, комментарии и сообщения чата и очередь
+// модерации. Решение по жалобе закрывает все открытые жалобы на тот же
+// объект и записывается в журнал действий тем сервисом, который его
+// выполняет.
+type ReportService struct {
+	repo       repository.ReportRepositoryInterface
+	posts      *PostService
+	comments   *CommentService
+	chat       ChatMessages
+	moderation Moderation
+	authz      *Authorizer
+	audit      *auditor
+	now        func() time.Time
+}
+
+// Create принимает жалобу actor на объект. Текст объекта сохраняется в
+// жалобе, чтобы модератор видел его даже после правки или удаления.
+func (s *ReportService) Create(ctx context.Context, input models.CreateReportInput, actor Actor) (*models.Report, error) {
+	reason := strings.TrimSpace(input.Reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidReport)
+	}
+	if utf8.RuneCountInString(reason) > MaxReportReasonSize {
+		return nil, fmt.Errorf("%w: reason is longer than %d characters", ErrInvalidReport, MaxReportReasonSize)
+	}
+
+	report := &models.Report{TargetType: input.TargetType, TargetID: input.TargetID, ReporterID: actor.UserID, Reason: reason, CreatedAt: s.now()}
+	if err := s.loadTarget(ctx, report); err != nil {
+		return nil, err
+	}
+	if report.TargetAuthorID == actor.UserID {
+		return nil, fmt.Errorf("%w: you cannot report your own content", ErrInvalidReport)
+	}
+
+	created, err := s.repo.Create(ctx, report)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, ErrAlreadyReported
+	}
+	return report, nil
+}
+
+// loadTarget находит объект жалобы и заполняет автора, категорию и текст
+func (s *ReportService) loadTarget(ctx context.Context, report *models.Report) error {
+	if report.TargetID <= 0 {
+		return fmt.Errorf("%w: target_id is required", ErrInvalidReport)
+	}
+	switch report.TargetType {
+	case models.ReportTargetPost:
+		post, err := s.posts.repo.GetByID(int(report.TargetID))
+		if err != nil {
+			return err
+		}
+		if post == nil {
+			return ErrTargetNotFound
+		}
+		report.TargetAuthorID = post.AuthorID
+		report.CategoryID = &post.CategoryID
+		report.Snapshot = post.Title + "\n\n" + post.Content
+	case models.ReportTargetComment:
+		comment, err := s.comments.repo.GetByID(int(report.TargetID))
+		if err != nil {
+			return err
+		}
+		if comment == nil || comment.Deleted {
+			return ErrTargetNotFound
+		}
+		post, err := s.posts.repo.GetByID(int(comment.PostID))
+		if err != nil {
+			return err
+		}
+		if post != nil {
+			report.CategoryID = &post.CategoryID
+		}
+		report.TargetAuthorID = comment.AuthorID
+		report.Snapshot = comment.Content
+	case models.ReportTargetChatMessage:
+		msg, err := s.chat.Message(ctx, report.TargetID)
+		if err != nil {
+			return err
+		}
+		if msg == nil {
+			return ErrTargetNotFound
+		}
+		report.TargetAuthorID = msg.UserID
+		report.Snapshot = msg.Content
+	default:
+		return fmt.Errorf("%w: unknown target_type %q", ErrInvalidReport, report.TargetType)
+	}
+	return nil
+}
+
+// List возвращает страницу очереди модерации. Нужно право review_reports.
+func (s *ReportService) List(ctx context.Context, filter models.ReportFilter, opts models.ListOptions, actor Actor) (*models.Page[models.Report], error) {
+	if err := s.authz.require(ctx, actor, rbac.ReviewReports, 0); err != nil {
+		return nil, err
+	}
+	switch filter.Status {
+	case "", models.ReportStatusOpen, models.ReportStatusActioned, models.ReportStatusDismissed:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidReport, filter.Status)
+	}
+	switch filter.TargetType {
+	case "", models.ReportTargetPost, models.ReportTargetComment, models.ReportTargetChatMessage:
+	default:
+		return nil, fmt.Errorf("%w: unknown target_type %q", ErrInvalidReport, filter.TargetType)
+	}
+	return s.repo.List(ctx, filter, opts)
+}
+
+// GetByID возвращает жалобу модератору с правом review_reports
+func (s *ReportService) GetByID(ctx context.Context, id int64, actor Actor) (*models.Report, error) {
+	if err := s.authz.require(ctx, actor, rbac.ReviewReports, 0); err != nil {
+		return nil, err
+	}
+	report, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if report == nil {
+		return nil, ErrReportNotFound
+	}
+	return report, nil
+}
+
+// Resolve выполняет решение модератора по открытой жалобе: удаляет объект,
+// предупреждает или отстраняет автора либо отклоняет жалобу. Все открытые
+// жалобы на тот же объект закрываются, действие попадает в журнал.
+func (s *ReportService) Resolve(ctx context.Context, id int64, input models.ResolveReportInput, actor Actor) (*models.Report, error) {
+	report, err := s.GetByID(ctx, id, actor)
+	if err != nil {
+		return nil, err
+	}
+	if report.Status != models.ReportStatusOpen {
+		return nil, ErrReportClosed
+	}
+
+	note := strings.TrimSpace(input.Reason)
+	reason := note
+	if reason == "" {
+		reason = report.Reason
+	}
+
+	status := models.ReportStatusActioned
+	switch input.Action {
+	case models.ReportActionDelete:
+		if err := s.deleteTarget(ctx, report, actor, reason); err != nil {
+			return nil, err
+		}
+	case models.ReportActionWarn, models.ReportActionSuspend:
+		kind := sanction.Warning
+		if input.Action == models.ReportActionSuspend {
+			if input.Duration == "" {
+				return nil, fmt.Errorf("%w: suspend requires duration", ErrInvalidAction)
+			}
+			kind = sanction.Suspension
+		} else if input.Duration != "" {
+			return nil, fmt.Errorf("%w: warn has no duration", ErrInvalidAction)
+		}
+		if err := s.authz.require(ctx, actor, rbac.BanUser, 0); err != nil {
+			return nil, err
+		}
+		if err := s.moderation.IssueSanction(ctx, actor.Token, report.TargetAuthorID, kind, reason, input.Duration); err != nil {
+			return nil, err
+		}
+	case models.ReportActionDismiss:
+		status = models.ReportStatusDismissed
+		if err := s.audit.record(ctx, actor, audit.ActionDismissReport, audit.TargetReport, report.ID, report, reason); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAction, input.Action)
+	}
+
+	now := s.now()
+	report.Status = status
+	report.Action = input.Action
+	report.ResolutionNote = note
+	report.ResolvedBy = &actor.UserID
+	report.ResolvedAt = &now
+	if _, err := s.repo.ResolveTarget(ctx, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// deleteTarget удаляет объект жалобы с причиной reason. Уже удалённый
+// объект не ошибка: жалобы на него всё равно нужно закрыть.
+func (s *ReportService) deleteTarget(ctx context.Context, report *models.Report, actor Actor, reason string) error {
+	var err error
+	switch report.TargetType {
+	case models.ReportTargetPost:
+		err = s.posts.Delete(ctx, int(report.TargetID), actor, reason)
+		if errors.Is(err, ErrPostNotFound) {
+			return nil
+		}
+	case models.ReportTargetComment:
+		err = s.comments.Delete(ctx, int(report.TargetID), actor, reason)
+		if errors.Is(err, ErrCommentNotFound) {
+			return nil
+		}
+	case models.ReportTargetChatMessage:
+		if err := s.authz.require(ctx, actor, rbac.DeleteChatMessage, 0); err != nil {
+			return err
+		}
+		err = s.chat.DeleteMessage(ctx, actor.Token, report.TargetID, reason)
+	}
+	return err
+}
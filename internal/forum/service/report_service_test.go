@@ -0,0 +1,330 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mos1rain/forum_go/internal/forum/models"
+	"github.com/mos1rain/forum_go/internal/forum/repository"
+	"github.com/mos1rain/forum_go/pkg/audit"
+	"github.com/mos1rain/forum_go/pkg/sanction"
+)
+
+type mockReportRepo struct{ reports []models.Report }
+
+var _ repository.ReportRepositoryInterface = (*mockReportRepo)(nil)
+
+func (m *mockReportRepo) Create(ctx context.Context, report *models.Report) (bool, error) {
+	for _, r := range m.reports {
+		if r.TargetType == report.TargetType && r.TargetID == report.TargetID && r.ReporterID == report.ReporterID {
+			return false, nil
+		}
+	}
+	report.ID = int64(len(m.reports) + 1)
+	report.Status = models.ReportStatusOpen
+	m.reports = append(m.reports, *report)
+	return true, nil
+}
+func (m *mockReportRepo) GetByID(ctx context.Context, id int64) (*models.Report, error) {
+	for _, r := range m.reports {
+		if r.ID == id {
+			return &r, nil
+		}
+	}
+	return nil, nil
+}
+func (m *mockReportRepo) List(ctx context.Context, filter models.ReportFilter, opts models.ListOptions) (*models.Page[models.Report], error) {
+	res := []models.Report{}
+	for _, r := range m.reports {
+		if (filter.Status == "" || r.Status == filter.Status) && (filter.TargetType == "" || r.TargetType == filter.TargetType) {
+			res = append(res, r)
+		}
+	}
+	return &models.Page[models.Report]{Items: res, Total: len(res)}, nil
+}
+func (m *mockReportRepo) ResolveTarget(ctx context.Context, resolution *models.Report) (int, error) {
+	closed := 0
+	for i, r := range m.reports {
+		if r.TargetType == resolution.TargetType && r.TargetID == resolution.TargetID && r.Status == models.ReportStatusOpen {
+			m.reports[i].Status = resolution.Status
+			m.reports[i].Action = resolution.Action
+			closed++
+		}
+	}
+	return closed, nil
+}
+
+type mockChat struct {
+	messages map[int64]models.ChatMessage
+	deleted  []int64
+	reasons  []string
+}
+
+func (m *mockChat) Message(ctx context.Context, id int64) (*models.ChatMessage, error) {
+	msg, ok := m.messages[id]
+	if !ok {
+		return nil, nil
+	}
+	return &msg, nil
+}
+func (m *mockChat) DeleteMessage(ctx context.Context, token string, id int64, reason string) error {
+	m.deleted = append(m.deleted, id)
+	m.reasons = append(m.reasons, reason)
+	return nil
+}
+
+type issuedSanction struct {
+	token    string
+	userID   int64
+	kind     sanction.Type
+	duration string
+}
+
+// mockModeration выдаёт санкции и ведёт журнал, как auth-клиент;
+// с auditErr журнал недоступен
+type mockModeration struct {
+	sanctions []issuedSanction
+	entries   []audit.Entry
+	auditErr  error
+}
+
+func (m *mockModeration) IssueSanction(ctx context.Context, token string, userID int64, kind sanction.Type, reason, duration string) error {
+	m.sanctions = append(m.sanctions, issuedSanction{token: token, userID: userID, kind: kind, duration: duration})
+	return nil
+}
+func (m *mockModeration) RecordAudit(ctx context.Context, token string, entry audit.Entry) error {
+	if m.auditErr != nil {
+		return m.auditErr
+	}
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+// newReportService: пост 1 автора 1, комментарий 1 автора 2 к нему,
+// сообщение чата 7 автора 3
+func newReportService() (*ForumService, *mockReportRepo, *mockChat, *mockModeration) {
+	postRepo := &mockPostRepo{posts: []models.Post{{ID: 1, Title: "Title", Content: "Content", CategoryID: 1, AuthorID: 1}}}
+	commRepo := &mockCommentRepo{comms: []models.Comment{{ID: 1, PostID: 1, AuthorID: 2, Content: "rude"}}}
+	fs := NewForumService(&mockCategoryRepo{}, postRepo, commRepo, &mockSearchRepo{}, &mockUserDirectory{})
+
+	reports := &mockReportRepo{}
+	chat := &mockChat{messages: map[int64]models.ChatMessage{7: {ID: 7, UserID: 3, Username: "carol", Content: "spam"}}}
+	moderation := &mockModeration{}
+	fs.EnableAudit(moderation)
+	fs.EnableReports(reports, chat, moderation)
+	fs.Reports.now = func() time.Time { return time.Date(2024, 3, 20, 10, 0, 0, 0, time.UTC) }
+	return fs, reports, chat, moderation
+}
+
+func TestReportCreate(t *testing.T) {
+	reporter := Actor{UserID: 5, Role: RoleUser}
+	tests := []struct {
+		name    string
+		input   models.CreateReportInput
+		actor   Actor
+		wantErr error
+	}{
+		{name: "post", input: models.CreateReportInput{TargetType: models.ReportTargetPost, TargetID: 1, Reason: "spam"}, actor: reporter},
+		{name: "comment", input: models.CreateReportInput{TargetType: models.ReportTargetComment, TargetID: 1, Reason: "rude"}, actor: reporter},
+		{name: "chat message", input: models.CreateReportInput{TargetType: models.ReportTargetChatMessage, TargetID: 7, Reason: "spam"}, actor: reporter},
+		{name: "empty reason", input: models.CreateReportInput{TargetType: models.ReportTargetPost, TargetID: 1, Reason: "  "}, actor: reporter, wantErr: ErrInvalidReport},
+		{name: "unknown type", input: models.CreateReportInput{TargetType: "user", TargetID: 1, Reason: "spam"}, actor: reporter, wantErr: ErrInvalidReport},
+		{name: "missing post", input: models.CreateReportInput{TargetType: models.ReportTargetPost, TargetID: 99, Reason: "spam"}, actor: reporter, wantErr: ErrTargetNotFound},
+		{name: "missing chat message", input: models.CreateReportInput{TargetType: models.ReportTargetChatMessage, TargetID: 99, Reason: "spam"}, actor: reporter, wantErr: ErrTargetNotFound},
+		{name: "own content", input: models.CreateReportInput{TargetType: models.ReportTargetPost, TargetID: 1, Reason: "spam"}, actor: Actor{UserID: 1, Role: RoleUser}, wantErr: ErrInvalidReport},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs, _, _, _ := newReportService()
+			report, err := fs.Reports.Create(context.Background(), tt.input, tt.actor)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && (report.ID == 0 || report.Snapshot == "" || report.TargetAuthorID == 0) {
+				t.Errorf("unexpected report: %+v", report)
+			}
+		})
+	}
+
+	fs, _, _, _ := newReportService()
+	input := models.CreateReportInput{TargetType: models.ReportTargetPost, TargetID: 1, Reason: "spam"}
+	if _, err := fs.Reports.Create(context.Background(), input, reporter); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := fs.Reports.Create(context.Background(), input, reporter); !errors.Is(err, ErrAlreadyReported) {
+		t.Errorf("expected ErrAlreadyReported, got %v", err)
+	}
+}
+
+func TestReportQueuePermissions(t *testing.T) {
+	fs, _, _, _ := newReportService()
+	ctx := context.Background()
+	if _, err := fs.Reports.Create(ctx, models.CreateReportInput{TargetType: models.ReportTargetPost, TargetID: 1, Reason: "spam"}, Actor{UserID: 5, Role: RoleUser}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if _, err := fs.Reports.List(ctx, models.ReportFilter{}, models.ListOptions{}, Actor{UserID: 5, Role: RoleUser}); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected ErrPermissionDenied for user, got %v", err)
+	}
+	if _, err := fs.Reports.List(ctx, models.ReportFilter{Status: "closed"}, models.ListOptions{}, Actor{UserID: 9, Role: RoleModerator}); !errors.Is(err, ErrInvalidReport) {
+		t.Errorf("expected ErrInvalidReport for unknown status, got %v", err)
+	}
+	page, err := fs.Reports.List(ctx, models.ReportFilter{Status: models.ReportStatusOpen}, models.ListOptions{}, Actor{UserID: 9, Role: RoleModerator})
+	if err != nil || page.Total != 1 {
+		t.Errorf("unexpected queue: %+v, %v", page, err)
+	}
+	if _, err := fs.Reports.GetByID(ctx, 42, Actor{UserID: 9, Role: RoleModerator}); !errors.Is(err, ErrReportNotFound) {
+		t.Errorf("expected ErrReportNotFound, got %v", err)
+	}
+}
+
+func TestReportResolve(t *testing.T) {
+	moderator := Actor{UserID: 9, Role: RoleModerator, Token: "mod-token"}
+	tests := []struct {
+		name       string
+		target     models.CreateReportInput
+		input      models.ResolveReportInput
+		wantErr    error
+		wantStatus string
+		// wantAudit запись журнала forum; санкции и сообщения чата
+		// записывают auth и chat
+		wantAudit string
+	}{
+		{
+			name:       "delete post",
+			target:     models.CreateReportInput{TargetType: models.ReportTargetPost, TargetID: 1, Reason: "spam"},
+			input:      models.ResolveReportInput{Action: models.ReportActionDelete},
+			wantStatus: models.ReportStatusActioned,
+			wantAudit:  audit.ActionDeletePost,
+		},
+		{
+			name:       "delete comment",
+			target:     models.CreateReportInput{TargetType: models.ReportTargetComment, TargetID: 1, Reason: "rude"},
+			input:      models.ResolveReportInput{Action: models.ReportActionDelete},
+			wantStatus: models.ReportStatusActioned,
+			wantAudit:  audit.ActionDeleteComment,
+		},
+		{
+			name:       "delete chat message",
+			target:     models.CreateReportInput{TargetType: models.ReportTargetChatMessage, TargetID: 7, Reason: "spam"},
+			input:      models.ResolveReportInput{Action: models.ReportActionDelete},
+			wantStatus: models.ReportStatusActioned,
+		},
+		{
+			name:       "warn",
+			target:     models.CreateReportInput{TargetType: models.ReportTargetComment, TargetID: 1, Reason: "rude"},
+			input:      models.ResolveReportInput{Action: models.ReportActionWarn, Reason: "be nice"},
+			wantStatus: models.ReportStatusActioned,
+		},
+		{
+			name:       "suspend",
+			target:     models.CreateReportInput{TargetType: models.ReportTargetChatMessage, TargetID: 7, Reason: "spam"},
+			input:      models.ResolveReportInput{Action: models.ReportActionSuspend, Duration: "24h"},
+			wantStatus: models.ReportStatusActioned,
+		},
+		{
+			name:       "dismiss",
+			target:     models.CreateReportInput{TargetType: models.ReportTargetPost, TargetID: 1, Reason: "spam"},
+			input:      models.ResolveReportInput{Action: models.ReportActionDismiss},
+			wantStatus: models.ReportStatusDismissed,
+			wantAudit:  audit.ActionDismissReport,
+		},
+		{
+			name:    "suspend without duration",
+			target:  models.CreateReportInput{TargetType: models.ReportTargetPost, TargetID: 1, Reason: "spam"},
+			input:   models.ResolveReportInput{Action: models.ReportActionSuspend},
+			wantErr: ErrInvalidAction,
+		},
+		{
+			name:    "warn with duration",
+			target:  models.CreateReportInput{TargetType: models.ReportTargetPost, TargetID: 1, Reason: "spam"},
+			input:   models.ResolveReportInput{Action: models.ReportActionWarn, Duration: "1h"},
+			wantErr: ErrInvalidAction,
+		},
+		{
+			name:    "unknown action",
+			target:  models.CreateReportInput{TargetType: models.ReportTargetPost, TargetID: 1, Reason: "spam"},
+			input:   models.ResolveReportInput{Action: "ignore"},
+			wantErr: ErrInvalidAction,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs, reports, chat, moderation := newReportService()
+			ctx := context.Background()
+			first, err := fs.Reports.Create(ctx, tt.target, Actor{UserID: 5, Role: RoleUser})
+			if err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			if _, err := fs.Reports.Create(ctx, tt.target, Actor{UserID: 6, Role: RoleUser}); err != nil {
+				t.Fatalf("create second: %v", err)
+			}
+
+			report, err := fs.Reports.Resolve(ctx, first.ID, tt.input, moderator)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				if len(moderation.entries) != 0 || len(moderation.sanctions) != 0 {
+					t.Errorf("failed resolve must not act: %+v", moderation)
+				}
+				return
+			}
+
+			if report.Status != tt.wantStatus || report.ResolvedBy == nil || *report.ResolvedBy != moderator.UserID {
+				t.Errorf("unexpected resolved report: %+v", report)
+			}
+			for _, r := range reports.reports {
+				if r.Status != tt.wantStatus {
+					t.Errorf("report %d on the same target left %q", r.ID, r.Status)
+				}
+			}
+			switch {
+			case tt.wantAudit == "" && len(moderation.entries) != 0:
+				t.Errorf("unexpected forum audit entries: %+v", moderation.entries)
+			case tt.wantAudit != "" && (len(moderation.entries) != 1 || moderation.entries[0].Action != tt.wantAudit ||
+				moderation.entries[0].ActorID != moderator.UserID || moderation.entries[0].Reason != tt.target.Reason):
+				t.Errorf("unexpected audit entries: %+v", moderation.entries)
+			}
+
+			switch tt.input.Action {
+			case models.ReportActionWarn, models.ReportActionSuspend:
+				issued := moderation.sanctions
+				if len(issued) != 1 || issued[0].userID != first.TargetAuthorID || issued[0].token != moderator.Token {
+					t.Errorf("unexpected sanctions: %+v", issued)
+				}
+				if tt.input.Action == models.ReportActionWarn && issued[0].kind != sanction.Warning {
+					t.Errorf("expected warning, got %q", issued[0].kind)
+				}
+			case models.ReportActionDelete:
+				if tt.target.TargetType == models.ReportTargetChatMessage && (len(chat.deleted) != 1 || chat.deleted[0] != 7 || chat.reasons[0] != "spam") {
+					t.Errorf("chat message not deleted: %v %v", chat.deleted, chat.reasons)
+				}
+			}
+
+			if _, err := fs.Reports.Resolve(ctx, first.ID, tt.input, moderator); !errors.Is(err, ErrReportClosed) {
+				t.Errorf("expected ErrReportClosed on second resolve, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReportResolvePermissions(t *testing.T) {
+	fs, _, _, moderation := newReportService()
+	ctx := context.Background()
+	report, err := fs.Reports.Create(ctx, models.CreateReportInput{TargetType: models.ReportTargetPost, TargetID: 1, Reason: "spam"}, Actor{UserID: 5, Role: RoleUser})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if _, err := fs.Reports.Resolve(ctx, report.ID, models.ResolveReportInput{Action: models.ReportActionDismiss}, Actor{UserID: 6, Role: RoleUser}); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected ErrPermissionDenied, got %v", err)
+	}
+	if len(moderation.entries) != 0 {
+		t.Errorf("denied resolve must not be audited: %+v", moderation.entries)
+	}
+}
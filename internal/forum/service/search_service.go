@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/mos1rain/forum_go/internal/forum/models"
+	"github.com/mos1rain/forum_go/internal/forum/repository"
+)
+
+var (
+	ErrEmptyQuery        = repository.ErrEmptyQuery
+	ErrInvalidSearchType = errors.New("type must be post or comment")
+	ErrInvalidDateRange  = errors.New("from must be before to")
+)
+
+type SearchService struct {
+	repo repository.SearchRepositoryInterface
+}
+
+// Search выполняет полнотекстовый поиск по постам и комментариям
+func (s *SearchService) Search(ctx context.Context, query models.SearchQuery, opts models.ListOptions) (*models.Page[models.SearchResult], error) {
+	query.Query = strings.TrimSpace(query.Query)
+	if query.Query == "" {
+		return nil, ErrEmptyQuery
+	}
+
+	switch query.Type {
+	case "", models.SearchTypePost, models.SearchTypeComment:
+	default:
+		return nil, ErrInvalidSearchType
+	}
+
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return nil, ErrInvalidDateRange
+	}
+
+	return s.repo.Search(query, opts)
+}
@@ -0,0 +1,42 @@
+// Package migrations содержит SQL-миграции схемы для каждого диалекта:
+// sqlite/ и postgres/ с файлами NNNNNN_name.up.sql и NNNNNN_name.down.sql.
+// Номера и состав миграций в обоих каталогах совпадают.
+//
+// Пользователи принадлежат auth-сервису, а форум и чат могут жить
+// в отдельных базах, поэтому таблицы ссылаются на пользователей без
+// внешних ключей на users.
+package migrations
+
+import (
+	"embed"
+
+	"github.com/mos1rain/forum_go/pkg/database"
+)
+
+//go:embed sqlite/*.sql postgres/*.sql
+var FS embed.FS
+
+// Legacy объекты, по которым видно, что версия уже есть в базе, созданной
+// до появления миграций: раньше сервисы сами создавали и дополняли свои
+// таблицы при старте. Такие версии migrate.Migrator отмечает применёнными,
+// не выполняя их (см. EnableBaseline); остальные накатываются как обычно.
+var Legacy = map[database.Dialect]map[int64]string{
+	database.SQLite: {
+		1: "users",
+		2: "categories",
+		3: "chat_messages",
+		4: "refresh_tokens",
+		5: "posts.locked",
+		6: "comments.parent_id",
+		7: "posts_fts",
+	},
+	database.Postgres: {
+		1: "users",
+		2: "categories",
+		3: "chat_messages",
+		4: "refresh_tokens",
+		5: "posts.locked",
+		6: "comments.parent_id",
+		7: "posts.search_vector",
+	},
+}
@@ -1,3 +1,3 @@
 DROP TABLE IF EXISTS comments;
 DROP TABLE IF EXISTS posts;
-DROP TABLE IF EXISTS categories; 
\ No newline at end of file
+DROP TABLE IF EXISTS categories; 
@@ -0,0 +1,4 @@
+ALTER TABLE posts ADD COLUMN locked BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE comments ADD COLUMN updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP;
+UPDATE comments SET updated_at = created_at;
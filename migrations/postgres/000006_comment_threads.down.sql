@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_comments_parent_id;
+
+ALTER TABLE comments DROP COLUMN deleted;
+ALTER TABLE comments DROP COLUMN depth;
+ALTER TABLE comments DROP COLUMN parent_id;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_comments_search;
+DROP INDEX IF EXISTS idx_posts_search;
+
+ALTER TABLE comments DROP COLUMN search_vector;
+ALTER TABLE posts DROP COLUMN search_vector;
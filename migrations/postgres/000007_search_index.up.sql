@@ -0,0 +1,12 @@
+-- Поисковые векторы — генерируемые колонки, поэтому обновляются сами.
+-- Заголовок поста весит больше текста (A против B).
+ALTER TABLE posts ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', content), 'B')
+) STORED;
+
+ALTER TABLE comments ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    to_tsvector('simple', content)
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_posts_search ON posts USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_comments_search ON comments USING GIN (search_vector);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS role_changes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    old_role TEXT NOT NULL,
+    new_role TEXT NOT NULL,
+    changed_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_changes_user_id ON role_changes(user_id, created_at);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS category_moderators (
+    category_id BIGINT NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL,
+    assigned_by BIGINT,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (category_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_category_moderators_user_id ON category_moderators(user_id);
@@ -0,0 +1,22 @@
+-- Жалобы на посты, комментарии и сообщения чата. В snapshot сохраняется
+-- текст на момент жалобы: автор может изменить или удалить его.
+-- Пользователь жалуется на один объект не больше одного раза.
+CREATE TABLE IF NOT EXISTS reports (
+    id BIGSERIAL PRIMARY KEY,
+    target_type TEXT NOT NULL,
+    target_id BIGINT NOT NULL,
+    target_author_id BIGINT NOT NULL,
+    category_id BIGINT,
+    snapshot TEXT NOT NULL,
+    reporter_id BIGINT NOT NULL,
+    reason TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open',
+    action TEXT,
+    resolution_note TEXT NOT NULL DEFAULT '',
+    resolved_by BIGINT,
+    resolved_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (target_type, target_id, reporter_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reports_status ON reports(status, created_at);
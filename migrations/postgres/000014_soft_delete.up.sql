@@ -0,0 +1,15 @@
+-- Удалённые категории, посты и комментарии остаются в базе до очистки:
+-- deleted_at — когда удалены, deleted_by — кем. Пост удалённой категории
+-- скрыт вместе с ней. Комментарии и раньше помечались флагом deleted;
+-- их текст теперь не стирается, чтобы комментарий можно было восстановить.
+ALTER TABLE categories ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE categories ADD COLUMN deleted_by BIGINT;
+ALTER TABLE posts ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE posts ADD COLUMN deleted_by BIGINT;
+ALTER TABLE comments ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE comments ADD COLUMN deleted_by BIGINT;
+UPDATE comments SET deleted_at = updated_at WHERE deleted;
+
+CREATE INDEX IF NOT EXISTS idx_categories_deleted_at ON categories(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments(deleted_at);
@@ -0,0 +1,16 @@
+-- Каждая правка поста сохраняется ревизией: номер по порядку внутри поста,
+-- кто правил, заголовок и текст после правки. Первая ревизия — пост
+-- в момент создания; для существующих постов ею становится текущая версия.
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    post_id BIGINT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    editor_id BIGINT,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (post_id, revision)
+);
+
+INSERT INTO post_revisions (post_id, revision, editor_id, title, content, created_at)
+SELECT id, 1, author_id, title, content, COALESCE(updated_at, created_at, CURRENT_TIMESTAMP) FROM posts;
@@ -7,4 +7,4 @@ CREATE TABLE IF NOT EXISTS chat_messages (
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 );
 
-CREATE INDEX IF NOT EXISTS idx_chat_messages_created_at ON chat_messages(created_at); 
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_chat_messages_created_at ON chat_messages(created_at); 
@@ -0,0 +1,4 @@
+ALTER TABLE posts ADD COLUMN locked BOOLEAN NOT NULL DEFAULT 0;
+
+ALTER TABLE comments ADD COLUMN updated_at TIMESTAMP;
+UPDATE comments SET updated_at = created_at WHERE updated_at IS NULL;
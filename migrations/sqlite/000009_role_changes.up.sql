@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS role_changes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    old_role TEXT NOT NULL,
+    new_role TEXT NOT NULL,
+    changed_by INTEGER,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (changed_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_changes_user_id ON role_changes(user_id, created_at);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS category_moderators (
+    category_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    assigned_by INTEGER,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (category_id, user_id),
+    FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (assigned_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_category_moderators_user_id ON category_moderators(user_id);
@@ -0,0 +1,22 @@
+-- Жалобы на посты, комментарии и сообщения чата. В snapshot сохраняется
+-- текст на момент жалобы: автор может изменить или удалить его.
+-- Пользователь жалуется на один объект не больше одного раза.
+CREATE TABLE IF NOT EXISTS reports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_type TEXT NOT NULL,
+    target_id INTEGER NOT NULL,
+    target_author_id INTEGER NOT NULL,
+    category_id INTEGER,
+    snapshot TEXT NOT NULL,
+    reporter_id INTEGER NOT NULL,
+    reason TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open',
+    action TEXT,
+    resolution_note TEXT NOT NULL DEFAULT '',
+    resolved_by INTEGER,
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (target_type, target_id, reporter_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reports_status ON reports(status, created_at);
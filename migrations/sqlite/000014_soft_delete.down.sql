@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS idx_comments_deleted_at;
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+DROP INDEX IF EXISTS idx_categories_deleted_at;
+
+-- Мягко удалённые посты и категории удаляются окончательно
+DELETE FROM posts WHERE deleted_at IS NOT NULL;
+DELETE FROM categories WHERE deleted_at IS NOT NULL;
+-- До этой миграции у удалённых комментариев не было текста
+UPDATE comments SET content = '' WHERE deleted;
+
+ALTER TABLE comments DROP COLUMN deleted_by;
+ALTER TABLE comments DROP COLUMN deleted_at;
+ALTER TABLE posts DROP COLUMN deleted_by;
+ALTER TABLE posts DROP COLUMN deleted_at;
+ALTER TABLE categories DROP COLUMN deleted_by;
+ALTER TABLE categories DROP COLUMN deleted_at;
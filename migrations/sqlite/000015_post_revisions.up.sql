@@ -0,0 +1,18 @@
+-- Каждая правка поста сохраняется ревизией: номер по порядку внутри поста,
+-- кто правил, заголовок и текст после правки. Первая ревизия — пост
+-- в момент создания; для существующих постов ею становится текущая версия.
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    editor_id INTEGER,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (post_id, revision),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (editor_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+INSERT INTO post_revisions (post_id, revision, editor_id, title, content, created_at)
+SELECT id, 1, author_id, title, content, COALESCE(updated_at, created_at, CURRENT_TIMESTAMP) FROM posts;
@@ -0,0 +1,84 @@
+// Package audit описывает записи журнала действий модераторов и
+// администраторов. Журнал хранит auth-сервис, forum и chat передают ему
+// записи через gRPC RecordAudit. Действия модерации записываются до
+// самого действия: если журнал недоступен, действие не выполняется.
+// Смена роли и снятие блокировки auth-сервис записывает после
+// изменения, чтобы в журнале не было несостоявшихся действий.
+package audit
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Действия модерации
+const (
+	ActionDeleteCategory    = "delete_category"
+	ActionDeletePost        = "delete_post"
+	ActionDeleteComment     = "delete_comment"
+	ActionDeleteChatMessage = "delete_chat_message"
+	ActionEditPost          = "edit_post"
+	ActionRollbackPost      = "rollback_post"
+	ActionLockPost          = "lock_post"
+	ActionUnlockPost        = "unlock_post"
+	ActionAssignModerator   = "assign_moderator"
+	ActionRemoveModerator   = "remove_moderator"
+	ActionBanUser           = "ban_user"
+	ActionSuspendUser       = "suspend_user"
+	ActionMuteUser          = "mute_user"
+	ActionWarnUser          = "warn_user"
+	ActionRevokeSanction    = "revoke_sanction"
+	ActionDismissReport     = "dismiss_report"
+)
+
+// Действия администраторов
+const (
+	ActionSetRole         = "set_role"
+	ActionUnlockUser      = "unlock_user"
+	ActionRestoreCategory = "restore_category"
+	ActionRestorePost     = "restore_post"
+	ActionRestoreComment  = "restore_comment"
+)
+
+// Виды объектов, над которыми выполняется действие
+const (
+	TargetCategory    = "category"
+	TargetPost        = "post"
+	TargetComment     = "comment"
+	TargetChatMessage = "chat_message"
+	TargetUser        = "user"
+	TargetSanction    = "sanction"
+	TargetReport      = "report"
+)
+
+// Сервисы, которые пишут в журнал
+const (
+	ServiceAuth  = "auth"
+	ServiceForum = "forum"
+	ServiceChat  = "chat"
+)
+
+// Entry запись журнала: кто (ActorID), что сделал (Action) и с чем
+// (TargetType, TargetID). Snapshot — состояние объекта до действия в JSON;
+// для нового ограничения пользователя — само ограничение.
+type Entry struct {
+	ID         int64           `json:"id"`
+	Service    string          `json:"service"`
+	ActorID    int64           `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   int64           `json:"target_id"`
+	Snapshot   json.RawMessage `json:"snapshot,omitempty"`
+	Reason     string          `json:"reason,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// Snapshot сериализует состояние объекта для Entry.Snapshot.
+// Значение, которое не удалось сериализовать, не сохраняется.
+func Snapshot(v any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,249 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// Dialect SQL-диалект хранилища
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// TimeFormat формат, в котором время хранится в SQLite. Совпадает с
+// CURRENT_TIMESTAMP, поэтому значения из Go и из БД сравниваются как строки.
+const TimeFormat = "2006-01-02 15:04:05.999999999"
+
+var ErrUnknownDriver = errors.New("unknown database driver")
+
+// Config параметры подключения к хранилищу
+type Config struct {
+	// Driver sqlite или postgres
+	Driver string
+	// DSN путь к файлу SQLite или строка подключения Postgres
+	DSN string
+}
+
+// DB обёртка над *sql.DB, которая приводит запросы к диалекту: плейсхолдеры ?
+// в Postgres становятся $1, $2..., а время в SQLite передаётся строкой в UTC.
+// Благодаря этому репозитории пишут один SQL для обоих хранилищ.
+type DB struct {
+	*sql.DB
+	Dialect Dialect
+}
+
+// Open открывает хранилище и проверяет соединение
+func Open(cfg Config) (*DB, error) {
+	dialect, err := ParseDialect(cfg.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	dsn := cfg.DSN
+	if dialect == SQLite && !strings.Contains(dsn, "busy_timeout") {
+		// Сервисы делят один файл базы: без таймаута параллельная запись
+		// сразу падает с SQLITE_BUSY вместо того, чтобы подождать
+		dsn = withQueryParam(dsn, "_pragma=busy_timeout(5000)")
+	}
+
+	db, err := sql.Open(string(dialect), dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %v", err)
+	}
+	if dialect == SQLite && strings.Contains(cfg.DSN, ":memory:") {
+		// База в памяти существует только в рамках одного соединения
+		db.SetMaxOpenConns(1)
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error connecting to the database: %v", err)
+	}
+
+	return New(db, dialect), nil
+}
+
+func withQueryParam(dsn, param string) string {
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + param
+	}
+	return dsn + "?" + param
+}
+
+// New оборачивает уже открытое соединение
+func New(db *sql.DB, dialect Dialect) *DB {
+	return &DB{DB: db, Dialect: dialect}
+}
+
+func ParseDialect(driver string) (Dialect, error) {
+	switch strings.ToLower(driver) {
+	case "", "sqlite", "sqlite3":
+		return SQLite, nil
+	case "postgres", "postgresql", "pgx":
+		return Postgres, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownDriver, driver)
+}
+
+func (db *DB) Exec(query string, args ...any) (sql.Result, error) {
+	return db.ExecContext(context.Background(), query, args...)
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	query, args = db.Dialect.prepare(query, args)
+	return db.DB.ExecContext(ctx, query, args...)
+}
+
+func (db *DB) Query(query string, args ...any) (*sql.Rows, error) {
+	return db.QueryContext(context.Background(), query, args...)
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	query, args = db.Dialect.prepare(query, args)
+	return db.DB.QueryContext(ctx, query, args...)
+}
+
+func (db *DB) QueryRow(query string, args ...any) *sql.Row {
+	return db.QueryRowContext(context.Background(), query, args...)
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	query, args = db.Dialect.prepare(query, args)
+	return db.DB.QueryRowContext(ctx, query, args...)
+}
+
+func (db *DB) Begin() (*Tx, error) {
+	return db.BeginTx(context.Background(), nil)
+}
+
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, Dialect: db.Dialect}, nil
+}
+
+// Tx транзакция с той же подстановкой диалекта, что и DB
+type Tx struct {
+	*sql.Tx
+	Dialect Dialect
+}
+
+func (tx *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return tx.ExecContext(context.Background(), query, args...)
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	query, args = tx.Dialect.prepare(query, args)
+	return tx.Tx.ExecContext(ctx, query, args...)
+}
+
+func (tx *Tx) Query(query string, args ...any) (*sql.Rows, error) {
+	return tx.QueryContext(context.Background(), query, args...)
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	query, args = tx.Dialect.prepare(query, args)
+	return tx.Tx.QueryContext(ctx, query, args...)
+}
+
+func (tx *Tx) QueryRow(query string, args ...any) *sql.Row {
+	return tx.QueryRowContext(context.Background(), query, args...)
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	query, args = tx.Dialect.prepare(query, args)
+	return tx.Tx.QueryRowContext(ctx, query, args...)
+}
+
+func (d Dialect) prepare(query string, args []any) (string, []any) {
+	switch d {
+	case Postgres:
+		return Rebind(query), args
+	case SQLite:
+		return query, sqliteArgs(args)
+	}
+	return query, args
+}
+
+// Rebind заменяет плейсхолдеры ? на $1, $2... Знаки вопроса внутри строковых
+// литералов и идентификаторов в кавычках не трогаются.
+func Rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// sqliteArgs переводит время в строки формата TimeFormat. Иначе драйвер
+// сохраняет time.Time в формате String(), который не сравнивается с
+// CURRENT_TIMESTAMP.
+func sqliteArgs(args []any) []any {
+	var out []any
+	for i, arg := range args {
+		v, ok := sqliteTime(arg)
+		if !ok {
+			continue
+		}
+		if out == nil {
+			out = make([]any, len(args))
+			copy(out, args)
+		}
+		out[i] = v
+	}
+	if out == nil {
+		return args
+	}
+	return out
+}
+
+func sqliteTime(arg any) (any, bool) {
+	switch v := arg.(type) {
+	case time.Time:
+		return v.UTC().Format(TimeFormat), true
+	case *time.Time:
+		if v == nil {
+			return nil, true
+		}
+		return v.UTC().Format(TimeFormat), true
+	case sql.NullTime:
+		if !v.Valid {
+			return nil, true
+		}
+		return v.Time.UTC().Format(TimeFormat), true
+	}
+	return nil, false
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT * FROM t WHERE a = ? AND b = ?", "SELECT * FROM t WHERE a = $1 AND b = $2"},
+		{"SELECT '?' FROM t WHERE a = ?", "SELECT '?' FROM t WHERE a = $1"},
+		{`SELECT "a?" FROM t WHERE b = 'it''s?' AND c = ?`, `SELECT "a?" FROM t WHERE b = 'it''s?' AND c = $1`},
+	}
+	for _, tt := range tests {
+		if got := Rebind(tt.query); got != tt.want {
+			t.Errorf("Rebind(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSQLiteTimeArgs(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	ts := time.Date(2024, 3, 20, 13, 0, 0, 500, moscow)
+
+	args := sqliteArgs([]any{1, ts, (*time.Time)(nil), "x"})
+	if args[1] != "2024-03-20 10:00:00.0000005" {
+		t.Errorf("time must be stored in UTC, got %v", args[1])
+	}
+	if args[2] != nil {
+		t.Errorf("nil time must stay NULL, got %v", args[2])
+	}
+	if args[0] != 1 || args[3] != "x" {
+		t.Errorf("other args must be passed as is, got %v", args)
+	}
+}
+
+func TestParseDialect(t *testing.T) {
+	if d, err := ParseDialect("postgresql"); err != nil || d != Postgres {
+		t.Errorf("ParseDialect(postgresql) = %v, %v", d, err)
+	}
+	if _, err := ParseDialect("mysql"); err == nil {
+		t.Error("expected error for unknown driver")
+	}
+}
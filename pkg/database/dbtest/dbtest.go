@@ -0,0 +1,125 @@
+// Package dbtest запускает тесты репозиториев на всех поддерживаемых хранилищах
+package dbtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mos1rain/forum_go/migrations"
+	"github.com/mos1rain/forum_go/pkg/database"
+	"github.com/mos1rain/forum_go/pkg/migrate"
+)
+
+// PostgresDSNEnv переменная окружения со строкой подключения к тестовому
+// Postgres. Если она не задана, тесты на Postgres пропускаются с
+// предупреждением, а в CI падают.
+const PostgresDSNEnv = "TEST_POSTGRES_DSN"
+
+// RequirePostgresEnv если задана (или задана CI), тесты без
+// TEST_POSTGRES_DSN не пропускаются, а падают
+const RequirePostgresEnv = "TEST_REQUIRE_POSTGRES"
+
+// skipWarning предупреждает о пропуске тестов на Postgres один раз на пакет
+var skipWarning sync.Once
+
+// Dialects диалекты, на которых выполняются тесты
+var Dialects = []database.Dialect{database.SQLite, database.Postgres}
+
+// Run выполняет fn на SQLite в памяти и, если задан TEST_POSTGRES_DSN, на
+// Postgres. Каждый запуск получает пустую базу со всеми миграциями.
+func Run(t *testing.T, fn func(t *testing.T, db *database.DB)) {
+	t.Helper()
+	for _, dialect := range Dialects {
+		t.Run(string(dialect), func(t *testing.T) {
+			db := Open(t, dialect)
+			migrator, err := migrate.New(db, migrations.FS)
+			if err != nil {
+				t.Fatalf("Failed to load migrations: %v", err)
+			}
+			if _, err := migrator.Up(); err != nil {
+				t.Fatalf("Failed to apply migrations: %v", err)
+			}
+			fn(t, db)
+		})
+	}
+}
+
+// Open открывает пустую тестовую базу указанного диалекта. База закрывается
+// (а схема Postgres удаляется) по завершении теста.
+func Open(t *testing.T, dialect database.Dialect) *database.DB {
+	t.Helper()
+	switch dialect {
+	case database.SQLite:
+		db, err := database.Open(database.Config{Driver: string(database.SQLite), DSN: ":memory:"})
+		if err != nil {
+			t.Fatalf("Failed to open test database: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	case database.Postgres:
+		return openPostgres(t)
+	}
+	t.Fatalf("unsupported dialect %q", dialect)
+	return nil
+}
+
+// openPostgres создаёт для теста отдельную схему, чтобы тесты не видели
+// данных друг друга и могли выполняться параллельно
+func openPostgres(t *testing.T) *database.DB {
+	t.Helper()
+	dsn := os.Getenv(PostgresDSNEnv)
+	if dsn == "" {
+		if os.Getenv(RequirePostgresEnv) != "" || os.Getenv("CI") != "" {
+			t.Fatalf("%s is not set, but Postgres tests are required (%s or CI is set)", PostgresDSNEnv, RequirePostgresEnv)
+		}
+		skipWarning.Do(func() {
+			fmt.Fprintf(os.Stderr, "WARNING: %s is not set, Postgres tests are skipped; run them with `make test-postgres`\n", PostgresDSNEnv)
+		})
+		t.Skipf("%s is not set", PostgresDSNEnv)
+	}
+
+	admin, err := database.Open(database.Config{Driver: string(database.Postgres), DSN: dsn})
+	if err != nil {
+		t.Fatalf("Failed to connect to Postgres: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := randomSchema()
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("Failed to create test schema: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+
+	db, err := database.Open(database.Config{Driver: string(database.Postgres), DSN: withSearchPath(dsn, schema)})
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func randomSchema() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "test_" + hex.EncodeToString(b)
+}
+
+// withSearchPath добавляет search_path к DSN в форме URL или key=value
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err == nil {
+			q := u.Query()
+			q.Set("search_path", schema)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	return dsn + " search_path=" + schema
+}
@@ -1,24 +1,12 @@
 package database
 
 import (
-	"database/sql"
 	"fmt"
-
-	_ "github.com/lib/pq"
 )
 
-func NewPostgresDB(host, port, user, password, dbname string) (*sql.DB, error) {
+func NewPostgresDB(host, port, user, password, dbname string) (*DB, error) {
 	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		host, port, user, password, dbname)
 
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		return nil, fmt.Errorf("error opening database: %v", err)
-	}
-
-	if err = db.Ping(); err != nil {
-		return nil, fmt.Errorf("error connecting to the database: %v", err)
-	}
-
-	return db, nil
+	return Open(Config{Driver: string(Postgres), DSN: connStr})
 }
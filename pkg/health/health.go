@@ -0,0 +1,128 @@
+// Package health отдаёт эндпоинты /healthz и /readyz.
+// /healthz отвечает 200, пока процесс жив. /readyz выполняет проверки
+// зависимостей (база, соседние сервисы) и отвечает 503, если хотя бы одна
+// не прошла или сервис уже останавливается.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CheckFunc проверяет одну зависимость сервиса
+type CheckFunc func(ctx context.Context) error
+
+// Response тело ответа /healthz и /readyz
+type Response struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+	StatusStopping    = "shutting down"
+)
+
+type Checker struct {
+	timeout  time.Duration
+	mu       sync.RWMutex
+	checks   map[string]CheckFunc
+	stopping atomic.Bool
+}
+
+// NewChecker создаёт набор проверок; каждая проверка ограничена timeout
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout, checks: make(map[string]CheckFunc)}
+}
+
+// Add регистрирует проверку под именем name
+func (c *Checker) Add(name string, check CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// Shutdown переводит /readyz в 503, чтобы балансировщик перестал слать
+// новые запросы, пока сервер дорабатывает текущие
+func (c *Checker) Shutdown() {
+	c.stopping.Store(true)
+}
+
+// Check выполняет все проверки параллельно и возвращает результат по каждой
+func (c *Checker) Check(ctx context.Context) (map[string]string, bool) {
+	c.mu.RLock()
+	names := make([]string, 0, len(c.checks))
+	for name := range c.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]CheckFunc, len(names))
+	for i, name := range names {
+		checks[i] = c.checks[name]
+	}
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = check(ctx)
+		}()
+	}
+	wg.Wait()
+
+	results := make(map[string]string, len(names))
+	healthy := true
+	for i, name := range names {
+		if errs[i] != nil {
+			results[name] = errs[i].Error()
+			healthy = false
+		} else {
+			results[name] = StatusOK
+		}
+	}
+	return results, healthy
+}
+
+// Liveness обработчик /healthz
+func (c *Checker) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Response{Status: StatusOK})
+}
+
+// Readiness обработчик /readyz
+func (c *Checker) Readiness(w http.ResponseWriter, r *http.Request) {
+	if c.stopping.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, Response{Status: StatusStopping})
+		return
+	}
+	results, healthy := c.Check(r.Context())
+	if !healthy {
+		writeJSON(w, http.StatusServiceUnavailable, Response{Status: StatusUnavailable, Checks: results})
+		return
+	}
+	writeJSON(w, http.StatusOK, Response{Status: StatusOK, Checks: results})
+}
+
+// Register добавляет /healthz и /readyz в mux
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", c.Liveness)
+	mux.HandleFunc("/readyz", c.Readiness)
+}
+
+func writeJSON(w http.ResponseWriter, status int, resp Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
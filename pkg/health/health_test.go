@@ -0,0 +1,70 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, h http.HandlerFunc) (int, Response) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	var resp Response
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return rec.Code, resp
+}
+
+func TestChecker_Readiness(t *testing.T) {
+	c := NewChecker(time.Second)
+	c.Add("database", func(ctx context.Context) error { return nil })
+
+	code, resp := get(t, c.Readiness)
+	if code != http.StatusOK || resp.Checks["database"] != StatusOK {
+		t.Fatalf("expected ready, got %d %+v", code, resp)
+	}
+
+	c.Add("auth", func(ctx context.Context) error { return errors.New("connection refused") })
+	code, resp = get(t, c.Readiness)
+	if code != http.StatusServiceUnavailable || resp.Status != StatusUnavailable {
+		t.Fatalf("expected 503, got %d %+v", code, resp)
+	}
+	if resp.Checks["auth"] != "connection refused" || resp.Checks["database"] != StatusOK {
+		t.Errorf("unexpected checks %+v", resp.Checks)
+	}
+}
+
+func TestChecker_Timeout(t *testing.T) {
+	c := NewChecker(20 * time.Millisecond)
+	c.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	if _, healthy := c.Check(context.Background()); healthy {
+		t.Error("hanging check must fail")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("check must respect timeout, took %v", elapsed)
+	}
+}
+
+func TestChecker_Shutdown(t *testing.T) {
+	c := NewChecker(time.Second)
+	c.Shutdown()
+
+	if code, resp := get(t, c.Readiness); code != http.StatusServiceUnavailable || resp.Status != StatusStopping {
+		t.Errorf("expected 503 while stopping, got %d %+v", code, resp)
+	}
+	// Процесс ещё жив, пока дорабатывает запросы
+	if code, _ := get(t, c.Liveness); code != http.StatusOK {
+		t.Errorf("liveness must stay 200, got %d", code)
+	}
+}
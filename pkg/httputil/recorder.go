@@ -0,0 +1,59 @@
+// Package httputil содержит общие для middleware помощники
+package httputil
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// Recorder запоминает код и размер ответа для логов и метрик. Flush и
+// Hijack пробрасываются, чтобы через middleware работали WebSocket-соединения.
+type Recorder struct {
+	http.ResponseWriter
+	Status      int
+	Bytes       int
+	wroteHeader bool
+}
+
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (r *Recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.Status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.Bytes += n
+	return n, err
+}
+
+func (r *Recorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *Recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	if !r.wroteHeader {
+		r.Status = http.StatusSwitchingProtocols
+		r.wroteHeader = true
+	}
+	return h.Hijack()
+}
+
+func (r *Recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
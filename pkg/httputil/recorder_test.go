@@ -0,0 +1,28 @@
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := NewRecorder(httptest.NewRecorder())
+	rec.WriteHeader(http.StatusTeapot)
+	rec.WriteHeader(http.StatusOK)
+	rec.Write([]byte("hello"))
+	if rec.Status != http.StatusTeapot || rec.Bytes != 5 {
+		t.Errorf("expected first status and 5 bytes, got %d, %d", rec.Status, rec.Bytes)
+	}
+
+	// Запись без WriteHeader означает 200
+	rec = NewRecorder(httptest.NewRecorder())
+	rec.Write([]byte("ok"))
+	if rec.Status != http.StatusOK {
+		t.Errorf("expected 200, got %d", rec.Status)
+	}
+
+	if _, _, err := rec.Hijack(); err == nil {
+		t.Error("expected error when the underlying writer cannot hijack")
+	}
+}
@@ -0,0 +1,187 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// JWK публичный ключ в формате RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// OKP (Ed25519)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS возвращает публичные части всех ключей набора
+func (r *KeyRing) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range r.Keys() {
+		jwk, err := NewJWK(key)
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func NewJWK(key *Key) (JWK, error) {
+	jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Algorithm}
+	switch pub := key.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return JWK{}, ErrUnsupportedAlg
+	}
+	return jwk, nil
+}
+
+// Key восстанавливает публичный ключ из JWK
+func (j JWK) Key() (*Key, error) {
+	key := &Key{ID: j.Kid, Algorithm: j.Alg}
+	switch j.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, err
+		}
+		key.Public = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.Algorithm == "" {
+			key.Algorithm = AlgRS256
+		}
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, ErrUnsupportedAlg
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		key.Public = ed25519.PublicKey(x)
+		if key.Algorithm == "" {
+			key.Algorithm = AlgEdDSA
+		}
+	default:
+		return nil, ErrUnsupportedAlg
+	}
+	return key, nil
+}
+
+// RemoteKeySet загружает публичные ключи с JWKS-эндпоинта auth-сервиса.
+// Ключи кэшируются; при встрече неизвестного kid набор перечитывается,
+// но не чаще, чем раз в minRefresh. Одновременные запросы с неизвестным
+// kid ждут одну загрузку, а проверка известных ключей её не ждёт.
+type RemoteKeySet struct {
+	url        string
+	client     *http.Client
+	minRefresh time.Duration
+	fetches    singleflight.Group
+
+	mu          sync.Mutex
+	keys        map[string]*Key
+	lastRefresh time.Time
+}
+
+func NewRemoteKeySet(url string) *RemoteKeySet {
+	return &RemoteKeySet{
+		url:        url,
+		client:     &http.Client{Timeout: 5 * time.Second},
+		minRefresh: 30 * time.Second,
+		keys:       make(map[string]*Key),
+	}
+}
+
+func (s *RemoteKeySet) PublicKey(kid string) (*Key, error) {
+	if key, ok := s.cached(kid); ok {
+		return key, nil
+	}
+	if _, err, _ := s.fetches.Do(s.url, func() (any, error) { return nil, s.refresh() }); err != nil {
+		return nil, err
+	}
+	if key, ok := s.cached(kid); ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+func (s *RemoteKeySet) cached(kid string) (*Key, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// refresh перечитывает набор, если с прошлой загрузки прошло не меньше
+// minRefresh. Запрос к auth-сервису идёт без блокировки, под ней только
+// подменяется набор ключей.
+func (s *RemoteKeySet) refresh() error {
+	s.mu.Lock()
+	if time.Since(s.lastRefresh) < s.minRefresh {
+		s.mu.Unlock()
+		return nil
+	}
+	s.lastRefresh = time.Now()
+	s.mu.Unlock()
+
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	var set JWKSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decode jwks: %w", err)
+	}
+
+	keys := make(map[string]*Key, len(set.Keys))
+	for _, jwk := range set.Keys {
+		key, err := jwk.Key()
+		if err != nil {
+			continue
+		}
+		keys[key.ID] = key
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+	return nil
+}
@@ -1,6 +1,8 @@
 package jwt
 
 import (
+	"crypto/ed25519"
+	"crypto/rsa"
 	"errors"
 	"time"
 
@@ -9,58 +11,86 @@ import (
 
 var (
 	ErrInvalidToken = errors.New("invalid token")
-	// Секретный ключ для JWT
-	SecretKey = "forum-secret-key-2024" // В продакшене использовать безопасный ключ
 )
 
 type Claims struct {
 	UserID   int    `json:"user_id"`
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	// SessionID идентификатор семейства refresh-токенов, к которому привязан access-токен
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// TokenManager подписывает токены активным ключом KeyRing и проверяет их
+// по kid из заголовка. Сервисам, которые только проверяют токены,
+// достаточно публичных ключей (см. NewTokenVerifier).
 type TokenManager struct {
-	signingKey string
+	signer *KeyRing
+	keys   KeySource
 }
 
-func NewTokenManager(signingKey string) *TokenManager {
-	return &TokenManager{signingKey: signingKey}
+func NewTokenManager(keys *KeyRing) *TokenManager {
+	return &TokenManager{signer: keys, keys: keys}
 }
 
-func (m *TokenManager) NewJWT(userID int, username string, ttl time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
-		UserID:   userID,
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	})
+// NewTokenVerifier создаёт TokenManager, который умеет только проверять токены
+func NewTokenVerifier(keys KeySource) *TokenManager {
+	return &TokenManager{keys: keys}
+}
 
-	return token.SignedString([]byte(m.signingKey))
+func (m *TokenManager) NewJWT(userID int, username string, ttl time.Duration) (string, error) {
+	return m.NewAccessToken(userID, username, "", "", ttl)
 }
 
 func (m *TokenManager) NewJWTWithRole(userID int, username, role string, ttl time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
-		UserID:   userID,
-		Username: username,
-		Role:     role,
+	return m.NewAccessToken(userID, username, role, "", ttl)
+}
+
+// NewAccessToken выпускает короткоживущий access-токен, привязанный к сессии sessionID
+func (m *TokenManager) NewAccessToken(userID int, username, role, sessionID string, ttl time.Duration) (string, error) {
+	if m.signer == nil {
+		return "", ErrNoSigningKey
+	}
+	key, err := m.signer.Active()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.signingMethod(), Claims{
+		UserID:    userID,
+		Username:  username,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	})
-	return token.SignedString([]byte(m.signingKey))
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
 }
 
 func (m *TokenManager) Parse(accessToken string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(accessToken, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, ok := token.Header["kid"].(string)
+		if !ok || kid == "" {
 			return nil, ErrInvalidToken
 		}
-		return []byte(m.signingKey), nil
-	})
+		key, err := m.keys.PublicKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		// Алгоритм токена обязан совпадать с алгоритмом ключа
+		if token.Method.Alg() != key.Algorithm {
+			return nil, ErrInvalidToken
+		}
+		switch key.Public.(type) {
+		case *rsa.PublicKey, ed25519.PublicKey:
+			return key.Public, nil
+		}
+		return nil, ErrUnsupportedAlg
+	}, jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}))
 
 	if err != nil {
 		return nil, err
@@ -73,7 +103,3 @@ func (m *TokenManager) Parse(accessToken string) (*Claims, error) {
 
 	return claims, nil
 }
-
-func (m *TokenManager) GetSigningKey() string {
-	return m.signingKey
-}
@@ -0,0 +1,249 @@
+package jwt
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestRing(t *testing.T, alg string) *KeyRing {
+	t.Helper()
+	ring := NewKeyRing()
+	if _, err := ring.Rotate(alg); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	return ring
+}
+
+func TestTokenManager_SignAndParse(t *testing.T) {
+	for _, alg := range []string{AlgRS256, AlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			tm := NewTokenManager(newTestRing(t, alg))
+
+			token, err := tm.NewAccessToken(1, "user", "admin", "session", time.Minute)
+			if err != nil {
+				t.Fatalf("sign: %v", err)
+			}
+			claims, err := tm.Parse(token)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if claims.UserID != 1 || claims.Role != "admin" || claims.SessionID != "session" {
+				t.Errorf("unexpected claims: %+v", claims)
+			}
+		})
+	}
+}
+
+func TestTokenManager_RotationKeepsOldTokensValid(t *testing.T) {
+	ring := newTestRing(t, AlgRS256)
+	tm := NewTokenManager(ring)
+
+	old, err := tm.NewJWTWithRole(1, "user", "user", time.Minute)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	oldKey, _ := ring.Active()
+
+	if _, err := ring.Rotate(AlgEdDSA); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if _, err := tm.Parse(old); err != nil {
+		t.Fatalf("token signed with rotated-out key must stay valid: %v", err)
+	}
+
+	if err := ring.Remove(oldKey.ID); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err := tm.Parse(old); err == nil {
+		t.Fatal("token signed with removed key must be rejected")
+	}
+}
+
+func TestTokenManager_RejectsHMACToken(t *testing.T) {
+	ring := newTestRing(t, AlgRS256)
+	key, _ := ring.Active()
+	tm := NewTokenManager(ring)
+
+	// Подделка: HS256 с kid существующего ключа
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{UserID: 1, Role: "admin"})
+	forged.Header["kid"] = key.ID
+	signed, err := forged.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if _, err := tm.Parse(signed); err == nil {
+		t.Fatal("HS256 token must be rejected")
+	}
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	ring := newTestRing(t, AlgRS256)
+	tm := NewTokenManager(ring)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ring.JWKS())
+	}))
+	defer srv.Close()
+
+	verifier := NewTokenVerifier(NewRemoteKeySet(srv.URL))
+
+	token, _ := tm.NewAccessToken(7, "user", "user", "", time.Minute)
+	claims, err := verifier.Parse(token)
+	if err != nil {
+		t.Fatalf("parse with remote keys: %v", err)
+	}
+	if claims.UserID != 7 {
+		t.Errorf("expected user 7, got %d", claims.UserID)
+	}
+
+	if _, err := verifier.NewAccessToken(1, "user", "user", "", time.Minute); err != ErrNoSigningKey {
+		t.Errorf("verifier must not sign tokens, got %v", err)
+	}
+}
+
+func TestRemoteKeySet_ConcurrentRefresh(t *testing.T) {
+	ring := newTestRing(t, AlgRS256)
+	known, _ := ring.Active()
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		json.NewEncoder(w).Encode(ring.JWKS())
+	}))
+	defer srv.Close()
+
+	keys := NewRemoteKeySet(srv.URL)
+	if _, err := keys.PublicKey(known.ID); err != nil {
+		t.Fatalf("initial fetch: %v", err)
+	}
+	keys.lastRefresh = time.Time{}
+
+	// Новый ключ появляется после ротации; загрузка висит до release
+	next, err := ring.Rotate(AlgRS256)
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := keys.PublicKey(next.ID); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	// Пока идёт загрузка, известный ключ отдаётся из кэша
+	for fetches.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	done := make(chan struct{})
+	go func() {
+		keys.PublicKey(known.ID)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("cached key lookup must not wait for the JWKS fetch")
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("lookup after refresh: %v", err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("concurrent lookups must share one fetch, got %d fetches", n)
+	}
+}
+
+func TestFileKeyStore_LoadAndRotate(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileKeyStore(dir, AlgEdDSA)
+
+	ring, err := store.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	first, err := ring.Active()
+	if err != nil {
+		t.Fatalf("active: %v", err)
+	}
+
+	reloaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if key, _ := reloaded.Active(); key.ID != first.ID {
+		t.Errorf("expected active key %s after reload, got %s", first.ID, key.ID)
+	}
+
+	if err := store.Rotate(ring, 0, time.Hour); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	second, _ := ring.Active()
+	if second.ID == first.ID {
+		t.Fatal("expected a new active key")
+	}
+	if _, err := ring.PublicKey(first.ID); err != nil {
+		t.Errorf("old key must be kept while tokens may still use it: %v", err)
+	}
+
+	// Момент вывода ключа хранится в файле, а не в времени его изменения
+	old := filepath.Join(dir, first.ID+".pem")
+	longAgo := time.Now().Add(-365 * 24 * time.Hour)
+	if err := os.Chtimes(old, longAgo, longAgo); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	ring, err = store.Load()
+	if err != nil {
+		t.Fatalf("reload after rotation: %v", err)
+	}
+	retired, err := ring.PublicKey(first.ID)
+	if err != nil || retired.RetiredAt.IsZero() {
+		t.Fatalf("expected retired key %s with retirement time, got %+v, %v", first.ID, retired, err)
+	}
+	if key, _ := ring.Active(); key.ID != second.ID || !key.RetiredAt.IsZero() {
+		t.Errorf("expected active key %s without retirement time, got %+v", second.ID, key)
+	}
+	if err := store.Rotate(ring, 24*time.Hour, time.Hour); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if _, err := ring.PublicKey(first.ID); err != nil {
+		t.Errorf("key retired just now must be kept despite an old file: %v", err)
+	}
+
+	// Через tokenTTL после вывода ключ удаляется вместе с файлом
+	retired.RetiredAt = time.Now().Add(-2 * time.Hour)
+	if err := store.Save(retired); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := store.Rotate(ring, 24*time.Hour, time.Hour); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if _, err := ring.PublicKey(first.ID); err != ErrUnknownKey {
+		t.Errorf("expected key %s to be pruned, got %v", first.ID, err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("expected key file to be removed, got %v", err)
+	}
+	if _, err := ring.Active(); err != nil {
+		t.Errorf("active key must be kept: %v", err)
+	}
+}
@@ -0,0 +1,336 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Поддерживаемые алгоритмы подписи
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrNoSigningKey     = errors.New("no active signing key")
+	ErrUnsupportedAlg   = errors.New("unsupported signing algorithm")
+	ErrActiveKeyRemoval = errors.New("cannot remove active signing key")
+)
+
+// Key ключ подписи токенов. Private отсутствует у ключей, полученных из JWKS.
+type Key struct {
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+	Public    crypto.PublicKey
+	CreatedAt time.Time
+	// RetiredAt момент, когда ключ перестал подписывать токены; пусто у активного
+	RetiredAt time.Time
+}
+
+// GenerateKey создаёт новый ключ для алгоритма alg (RS256 или EdDSA)
+func GenerateKey(alg string) (*Key, error) {
+	var private crypto.Signer
+	switch alg {
+	case AlgRS256:
+		k, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		private = k
+	case AlgEdDSA:
+		_, k, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		private = k
+	default:
+		return nil, ErrUnsupportedAlg
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	return &Key{
+		// kid начинается с времени создания, поэтому ключи упорядочиваются по возрасту
+		ID:        now.Format("20060102T150405") + "-" + hex.EncodeToString(suffix),
+		Algorithm: alg,
+		Private:   private,
+		Public:    private.Public(),
+		CreatedAt: now,
+	}, nil
+}
+
+func (k *Key) signingMethod() jwt.SigningMethod {
+	switch k.Algorithm {
+	case AlgRS256:
+		return jwt.SigningMethodRS256
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA
+	}
+	return nil
+}
+
+// KeySource отдаёт публичные ключи для проверки подписи по kid
+type KeySource interface {
+	PublicKey(kid string) (*Key, error)
+}
+
+// KeyRing набор ключей: активный подписывает новые токены, остальные
+// продолжают проверять ранее выпущенные, пока их не удалят.
+type KeyRing struct {
+	mu     sync.RWMutex
+	keys   map[string]*Key
+	active string
+}
+
+func NewKeyRing() *KeyRing {
+	return &KeyRing{keys: make(map[string]*Key)}
+}
+
+// Add добавляет ключ; activate делает его ключом подписи
+func (r *KeyRing) Add(key *Key, activate bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[key.ID] = key
+	if activate {
+		r.active = key.ID
+	}
+}
+
+// Rotate создаёт новый ключ и делает его активным. Старые ключи остаются в наборе.
+func (r *KeyRing) Rotate(alg string) (*Key, error) {
+	key, err := GenerateKey(alg)
+	if err != nil {
+		return nil, err
+	}
+	r.Add(key, true)
+	return key, nil
+}
+
+// Remove удаляет неактивный ключ из набора
+func (r *KeyRing) Remove(kid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if kid == r.active {
+		return ErrActiveKeyRemoval
+	}
+	delete(r.keys, kid)
+	return nil
+}
+
+// Active возвращает текущий ключ подписи
+func (r *KeyRing) Active() (*Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key, ok := r.keys[r.active]
+	if !ok || key.Private == nil {
+		return nil, ErrNoSigningKey
+	}
+	return key, nil
+}
+
+func (r *KeyRing) PublicKey(kid string) (*Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key, ok := r.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// Keys возвращает ключи набора, от старых к новым
+func (r *KeyRing) Keys() []*Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	keys := make([]*Key, 0, len(r.keys))
+	for _, k := range r.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// FileKeyStore хранит приватные ключи в каталоге, по одному PEM-файлу (PKCS#8) на ключ.
+// Имя файла — kid; время создания и вывода ключа из обращения хранятся
+// в заголовках PEM-блока.
+type FileKeyStore struct {
+	dir string
+	alg string
+}
+
+func NewFileKeyStore(dir, alg string) *FileKeyStore {
+	return &FileKeyStore{dir: dir, alg: alg}
+}
+
+// Load читает все ключи каталога; активным становится самый новый из
+// не выведенных из обращения. Если каталог пуст, создаётся первый ключ.
+func (s *FileKeyStore) Load() (*KeyRing, error) {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	ring := NewKeyRing()
+	var active *Key
+	for _, path := range paths {
+		key, err := readKeyFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("load key %s: %w", path, err)
+		}
+		ring.Add(key, false)
+		if active == nil || key.RetiredAt.IsZero() || !active.RetiredAt.IsZero() {
+			active = key
+		}
+	}
+	if active != nil {
+		ring.Add(active, true)
+	}
+
+	if len(paths) == 0 {
+		key, err := ring.Rotate(s.alg)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.Save(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return ring, nil
+}
+
+func (s *FileKeyStore) Save(key *Key) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{pemCreated: key.CreatedAt.UTC().Format(time.RFC3339)}
+	if !key.RetiredAt.IsZero() {
+		headers[pemRetired] = key.RetiredAt.UTC().Format(time.RFC3339)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Headers: headers, Bytes: der})
+	return os.WriteFile(filepath.Join(s.dir, key.ID+".pem"), data, 0o600)
+}
+
+// Rotate выпускает новый ключ, если активный старше maxAge, и удаляет ключи,
+// которыми уже не может быть подписан ни один живой токен: выведенные из
+// обращения больше tokenTTL назад. Момент вывода записывается в файл ключа,
+// поэтому не зависит от времени изменения файла.
+func (s *FileKeyStore) Rotate(ring *KeyRing, maxAge, tokenTTL time.Duration) error {
+	active, err := ring.Active()
+	if err != nil || time.Since(active.CreatedAt) > maxAge {
+		key, err := ring.Rotate(s.alg)
+		if err != nil {
+			return err
+		}
+		if err := s.Save(key); err != nil {
+			return err
+		}
+		active = key
+	}
+
+	now := time.Now().UTC()
+	for _, key := range ring.Keys() {
+		if key.ID == active.ID {
+			continue
+		}
+		// Ключ, только что сменённый новым (или записанный без отметки),
+		// считается выведенным сейчас
+		if key.RetiredAt.IsZero() {
+			key.RetiredAt = now
+			if err := s.Save(key); err != nil {
+				return err
+			}
+			continue
+		}
+		if now.Sub(key.RetiredAt) <= tokenTTL {
+			continue
+		}
+		if err := ring.Remove(key.ID); err != nil {
+			return err
+		}
+		if err := os.Remove(filepath.Join(s.dir, key.ID+".pem")); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Заголовки PEM-блока с метаданными ключа
+const (
+	pemCreated = "Created"
+	pemRetired = "Retired"
+)
+
+func readKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// У файлов, записанных до появления заголовков, время создания берётся
+	// из времени изменения файла, а момент вывода отмечается при ротации
+	key := &Key{
+		ID:        strings.TrimSuffix(filepath.Base(path), ".pem"),
+		CreatedAt: info.ModTime(),
+	}
+	if created, ok := block.Headers[pemCreated]; ok {
+		if key.CreatedAt, err = time.Parse(time.RFC3339, created); err != nil {
+			return nil, fmt.Errorf("parse %s header: %w", pemCreated, err)
+		}
+	}
+	if retired, ok := block.Headers[pemRetired]; ok {
+		if key.RetiredAt, err = time.Parse(time.RFC3339, retired); err != nil {
+			return nil, fmt.Errorf("parse %s header: %w", pemRetired, err)
+		}
+	}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Algorithm, key.Private = AlgRS256, k
+	case ed25519.PrivateKey:
+		key.Algorithm, key.Private = AlgEdDSA, k
+	default:
+		return nil, ErrUnsupportedAlg
+	}
+	key.Public = key.Private.Public()
+	return key, nil
+}
@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryClientInterceptor передаёт идентификатор запроса из контекста
+// в метаданные исходящего вызова
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if id := RequestID(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, RequestIDMetadata, id)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// UnaryServerInterceptor принимает идентификатор запроса из метаданных
+// (или создаёт новый), кладёт в контекст логгер вызова и пишет строку лога
+// по его завершении. Сами сообщения не логируются: в них бывают токены.
+func UnaryServerInterceptor(base zerolog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var id string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(RequestIDMetadata); len(values) > 0 && validRequestID(values[0]) {
+				id = values[0]
+			}
+		}
+		if id == "" {
+			id = newRequestID()
+		}
+
+		logger := base.With().Str("request_id", id).Str("grpc_method", info.FullMethod).Logger()
+		ctx = WithRequestID(logger.WithContext(ctx), id)
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		code := status.Code(err)
+		event := zerolog.Ctx(ctx).Info()
+		if err != nil {
+			event = zerolog.Ctx(ctx).Error().Err(err)
+		}
+		event.Str("code", code.String()).Dur("duration", time.Since(start)).Msg("gRPC request")
+		return resp, err
+	}
+}
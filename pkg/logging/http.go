@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mos1rain/forum_go/pkg/httputil"
+	"github.com/rs/zerolog"
+)
+
+// Redacted подставляется в логах вместо секретов
+const Redacted = "[REDACTED]"
+
+// sensitiveParams параметры запроса, значения которых не попадают в логи.
+// Например, chat принимает токен WebSocket-подключения в ?token=.
+var sensitiveParams = map[string]bool{
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+	"password":      true,
+}
+
+// quietRoutes опрашиваются балансировщиком и Prometheus; их строки
+// access-лога пишутся на уровне debug
+var quietRoutes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// RedactQuery возвращает строку запроса со скрытыми значениями секретов
+func RedactQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	redacted := make(url.Values, len(query))
+	for key, values := range query {
+		if sensitiveParams[strings.ToLower(key)] {
+			values = []string{Redacted}
+		}
+		redacted[key] = values
+	}
+	// Encode экранирует квадратные скобки, для читаемости возвращаем их
+	return strings.ReplaceAll(redacted.Encode(), url.QueryEscape(Redacted), Redacted)
+}
+
+// Middleware присваивает запросу идентификатор (или принимает корректный
+// X-Request-ID клиента), возвращает его в ответе, кладёт в контекст логгер
+// запроса и по завершении пишет строку access-лога. Должен быть внешним
+// по отношению к ServeMux и остальным middleware.
+func Middleware(base zerolog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			logger := base.With().
+				Str("request_id", id).
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Logger()
+			ctx := WithRequestID(logger.WithContext(r.Context()), id)
+			// ServeMux записывает шаблон маршрута в тот запрос, который
+			// получил, поэтому читаем Pattern именно из этой копии
+			r = r.WithContext(ctx)
+
+			start := time.Now()
+			rec := httputil.NewRecorder(w)
+			next.ServeHTTP(rec, r)
+
+			reqLogger := zerolog.Ctx(ctx)
+			event := reqLogger.Info()
+			switch {
+			case rec.Status >= http.StatusInternalServerError:
+				event = reqLogger.Error()
+			case quietRoutes[r.Pattern]:
+				event = reqLogger.Debug()
+			}
+			if query := RedactQuery(r.URL.Query()); query != "" {
+				event = event.Str("query", query)
+			}
+			event.
+				Str("route", r.Pattern).
+				Int("status", rec.Status).
+				Int("bytes", rec.Bytes).
+				Dur("duration", time.Since(start)).
+				Str("remote_addr", r.RemoteAddr).
+				Msg("HTTP request")
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Package logging настраивает zerolog для сервисов и сквозные идентификаторы
+// запросов: HTTP-middleware присваивает или принимает X-Request-ID, кладёт
+// в контекст логгер запроса и пишет по нему строку access-лога, а
+// gRPC-интерцепторы передают идентификатор между сервисами в метаданных.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"os"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// RequestIDHeader HTTP-заголовок с идентификатором запроса
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDMetadata ключ gRPC-метаданных с идентификатором запроса
+	RequestIDMetadata = "x-request-id"
+
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+// New создаёт базовый логгер сервиса. format: console или json,
+// level: уровень zerolog (debug, info, warn, error).
+func New(service, format, level string) zerolog.Logger {
+	var out io.Writer = os.Stderr
+	if format != FormatJSON {
+		out = zerolog.ConsoleWriter{Out: os.Stderr}
+	}
+	lvl, err := zerolog.ParseLevel(level)
+	if err != nil || lvl == zerolog.NoLevel {
+		lvl = zerolog.InfoLevel
+	}
+	return zerolog.New(out).Level(lvl).With().Timestamp().Str("service", service).Logger()
+}
+
+type requestIDKey struct{}
+
+// WithRequestID сохраняет идентификатор запроса в контексте
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID возвращает идентификатор запроса из контекста или пустую строку
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// SetUserID добавляет user_id к логгеру запроса. Вызывается после
+// аутентификации; поле попадает и в итоговую строку access-лога.
+func SetUserID(ctx context.Context, userID int) {
+	logger := zerolog.Ctx(ctx)
+	if logger.GetLevel() == zerolog.Disabled {
+		return
+	}
+	logger.UpdateContext(func(c zerolog.Context) zerolog.Context {
+		return c.Int("user_id", userID)
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID принимает идентификатор от клиента, только если он не
+// слишком длинный и не содержит символов, ломающих логи
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func lastEntry(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &entry); err != nil {
+		t.Fatalf("decode log entry %q: %v", lines[len(lines)-1], err)
+	}
+	return entry
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	base := zerolog.New(&buf)
+
+	var seenID string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /posts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		seenID = RequestID(r.Context())
+		SetUserID(r.Context(), 42)
+		zerolog.Ctx(r.Context()).Info().Msg("inside handler")
+		w.WriteHeader(http.StatusTeapot)
+	})
+	h := Middleware(base)(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/posts/7?token=secret&page=2", nil)
+	req.Header.Set(RequestIDHeader, "client-id-1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if seenID != "client-id-1" || rec.Header().Get(RequestIDHeader) != "client-id-1" {
+		t.Errorf("valid client request id must be kept, got %q / %q", seenID, rec.Header().Get(RequestIDHeader))
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("token leaked into logs: %s", buf.String())
+	}
+
+	entry := lastEntry(t, &buf)
+	want := map[string]any{
+		"message":    "HTTP request",
+		"request_id": "client-id-1",
+		"route":      "GET /posts/{id}",
+		"status":     float64(http.StatusTeapot),
+		"user_id":    float64(42),
+		"query":      "page=2&token=" + Redacted,
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("%s: expected %v, got %v", key, value, entry[key])
+		}
+	}
+	if !strings.Contains(buf.String(), `"message":"inside handler"`) || !strings.Contains(buf.String(), `"request_id":"client-id-1"`) {
+		t.Errorf("handler log must carry request id: %s", buf.String())
+	}
+}
+
+func TestMiddleware_GeneratesRequestID(t *testing.T) {
+	h := Middleware(zerolog.Nop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, header := range []string{"", "bad id\nwith newline", strings.Repeat("a", 200)} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, header)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		id := rec.Header().Get(RequestIDHeader)
+		if id == "" || id == header || !validRequestID(id) {
+			t.Errorf("expected generated id for header %q, got %q", header, id)
+		}
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	query := url.Values{"Password": {"hunter2"}, "refresh_token": {"abc"}, "q": {"go"}}
+	got := RedactQuery(query)
+	if strings.Contains(got, "hunter2") || strings.Contains(got, "abc") || !strings.Contains(got, "q=go") {
+		t.Errorf("unexpected redacted query %q", got)
+	}
+	if RedactQuery(nil) != "" {
+		t.Error("empty query must stay empty")
+	}
+}
+
+// Идентификатор запроса из HTTP доходит до gRPC-сервера через метаданные
+func TestGRPC_RequestIDPropagation(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "req-123")
+
+	var outgoing metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		outgoing, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	if err := UnaryClientInterceptor()(ctx, "/auth.AuthService/ValidateToken", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("client interceptor: %v", err)
+	}
+	if got := outgoing.Get(RequestIDMetadata); len(got) != 1 || got[0] != "req-123" {
+		t.Fatalf("expected request id in metadata, got %v", got)
+	}
+
+	var buf bytes.Buffer
+	var serverID string
+	info := &grpc.UnaryServerInfo{FullMethod: "/auth.AuthService/ValidateToken"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		serverID = RequestID(ctx)
+		return nil, nil
+	}
+	incoming := metadata.NewIncomingContext(context.Background(), outgoing)
+	if _, err := UnaryServerInterceptor(zerolog.New(&buf))(incoming, nil, info, handler); err != nil {
+		t.Fatalf("server interceptor: %v", err)
+	}
+	if serverID != "req-123" {
+		t.Errorf("expected request id in server context, got %q", serverID)
+	}
+	entry := lastEntry(t, &buf)
+	if entry["request_id"] != "req-123" || entry["grpc_method"] != info.FullMethod || entry["code"] != "OK" {
+		t.Errorf("unexpected gRPC log entry %v", entry)
+	}
+}
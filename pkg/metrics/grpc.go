@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// GRPC метрики унарных gRPC-вызовов. Один тип обслуживает и сервер,
+// и клиент: side задаёт префикс метрик grpc_server_ или grpc_client_.
+type GRPC struct {
+	handled  *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func newGRPC(reg prometheus.Registerer, side string) *GRPC {
+	m := &GRPC{
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_" + side + "_handled_total",
+			Help: "Number of completed gRPC calls by method and status code.",
+		}, []string{"method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_" + side + "_handling_seconds",
+			Help:    "gRPC call latency by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+	}
+	reg.MustRegister(m.handled, m.duration)
+	return m
+}
+
+// NewGRPCServer метрики входящих вызовов
+func NewGRPCServer(reg prometheus.Registerer) *GRPC {
+	return newGRPC(reg, "server")
+}
+
+// NewGRPCClient метрики исходящих вызовов
+func NewGRPCClient(reg prometheus.Registerer) *GRPC {
+	return newGRPC(reg, "client")
+}
+
+func (m *GRPC) observe(method string, start time.Time, err error) {
+	m.handled.WithLabelValues(method, status.Code(err).String()).Inc()
+	m.duration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// UnaryServerInterceptor для grpc.NewServer(grpc.UnaryInterceptor(...))
+func (m *GRPC) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.observe(info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// UnaryClientInterceptor для grpc.WithUnaryInterceptor(...)
+func (m *GRPC) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		m.observe(method, start, err)
+		return err
+	}
+}
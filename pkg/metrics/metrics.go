@@ -0,0 +1,33 @@
+// Package metrics собирает метрики Prometheus для сервисов: HTTP-middleware,
+// gRPC-интерцепторы и статистику пула соединений с базой. Каждый сервис
+// заводит свой реестр и отдаёт его на /metrics.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// NewRegistry создаёт реестр с метриками рантайма Go и процесса
+func NewRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return reg
+}
+
+// Handler обработчик /metrics для реестра reg
+func Handler(reg *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg})
+}
+
+// RegisterDB добавляет статистику пула соединений (go_sql_*) с меткой db_name
+func RegisterDB(reg prometheus.Registerer, db *sql.DB, name string) {
+	reg.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestHTTP_Middleware(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewHTTP(reg)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /posts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "0" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	h := m.Middleware(mux)
+
+	for _, path := range []string{"/posts/1", "/posts/2", "/posts/0", "/missing"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Идентификаторы из пути не попадают в метки
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("GET /posts/{id}", "GET", "200")); got != 2 {
+		t.Errorf("expected 2 successful requests, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("GET /posts/{id}", "GET", "404")); got != 1 {
+		t.Errorf("expected 1 not found request, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("unmatched", "GET", "404")); got != 1 {
+		t.Errorf("expected 1 unmatched request, got %v", got)
+	}
+	if got := testutil.CollectAndCount(m.duration); got != 3 {
+		t.Errorf("expected 3 latency series, got %d", got)
+	}
+	if got := testutil.ToFloat64(m.inFlight); got != 0 {
+		t.Errorf("in-flight gauge must return to 0, got %v", got)
+	}
+}
+
+func TestGRPC_Interceptors(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	server := NewGRPCServer(reg)
+	client := NewGRPCClient(reg)
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/auth.AuthService/ValidateToken"}
+	ok := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+	fail := func(ctx context.Context, req any) (any, error) { return nil, status.Error(codes.Unavailable, "down") }
+	intercept := server.UnaryServerInterceptor()
+	intercept(context.Background(), nil, info, ok)
+	intercept(context.Background(), nil, info, fail)
+
+	if got := testutil.ToFloat64(server.handled.WithLabelValues(info.FullMethod, "OK")); got != 1 {
+		t.Errorf("expected 1 OK call, got %v", got)
+	}
+	if got := testutil.ToFloat64(server.handled.WithLabelValues(info.FullMethod, "Unavailable")); got != 1 {
+		t.Errorf("expected 1 Unavailable call, got %v", got)
+	}
+
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return errors.New("plain error")
+	}
+	client.UnaryClientInterceptor()(context.Background(), "/auth.AuthService/GetUserByID", nil, nil, nil, invoker)
+	if got := testutil.ToFloat64(client.handled.WithLabelValues("/auth.AuthService/GetUserByID", "Unknown")); got != 1 {
+		t.Errorf("expected 1 Unknown client call, got %v", got)
+	}
+
+	// Серверные и клиентские метрики регистрируются под разными именами
+	names, err := testutil.GatherAndCount(reg, "grpc_server_handled_total", "grpc_client_handled_total")
+	if err != nil || names != 3 {
+		t.Errorf("expected 3 series, got %d, %v", names, err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	reg := NewRegistry()
+	NewHTTP(reg)
+
+	rec := httptest.NewRecorder()
+	Handler(reg).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, name := range []string{"go_goroutines", "http_requests_in_flight"} {
+		if !strings.Contains(body, name) {
+			t.Errorf("expected %s in output", name)
+		}
+	}
+}
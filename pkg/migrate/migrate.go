@@ -0,0 +1,402 @@
+// Package migrate применяет к базе пронумерованные SQL-миграции.
+// Каждая миграция выполняется в отдельной транзакции вместе с записью
+// в таблицу schema_migrations, поэтому база не остаётся в промежуточном
+// состоянии, а параллельно стартующие сервисы не применяют миграцию дважды.
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mos1rain/forum_go/pkg/database"
+)
+
+var (
+	ErrInvalidFileName = errors.New("invalid migration file name")
+	ErrMissingDown     = errors.New("migration has no down file")
+	ErrUnknownVersion  = errors.New("unknown migration version")
+)
+
+// Migration одна версия схемы
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status состояние миграции в базе
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var versionTable = map[database.Dialect]string{
+	database.SQLite: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`,
+	database.Postgres: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL
+	)`,
+}
+
+// lockStatements выполняются первыми в транзакции миграции и держат
+// блокировку до её конца: второй процесс ждёт, а затем видит, что версия
+// уже применена
+var lockStatements = map[database.Dialect][]string{
+	// Пустое обновление сразу берёт блокировку на запись
+	database.SQLite: {
+		`UPDATE schema_migrations SET version = version WHERE 0 = 1`,
+	},
+	database.Postgres: {
+		`SELECT pg_advisory_xact_lock(7311520284)`,
+	},
+}
+
+// objectQueries проверяют, есть ли в базе таблица (table) или колонка
+// таблицы (column)
+var objectQueries = map[database.Dialect]struct{ table, column string }{
+	database.SQLite: {
+		table:  `SELECT COUNT(*) FROM sqlite_master WHERE name = ?`,
+		column: `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`,
+	},
+	database.Postgres: {
+		table:  `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?`,
+		column: `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?`,
+	},
+}
+
+// Load читает миграции диалекта из каталога <dialect>/ в fsys
+func Load(fsys fs.FS, dialect database.Dialect) ([]Migration, error) {
+	dir := string(dialect)
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		m := fileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFileName, entry.Name())
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFileName, entry.Name())
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		} else if migration.Name != m[2] {
+			return nil, fmt.Errorf("%w: version %d has two names: %s and %s", ErrInvalidFileName, version, migration.Name, m[2])
+		}
+		if m[3] == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("%w: version %d has no up file", ErrInvalidFileName, migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+type Migrator struct {
+	db         *database.DB
+	migrations []Migration
+	baseline   map[int64]string
+}
+
+// New загружает миграции диалекта db из fsys
+func New(db *database.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys, db.Dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// EnableBaseline включает взятие под управление баз, созданных без
+// миграций. objects для каждого диалекта сопоставляет версии объект,
+// который она создаёт: таблицу ("users") или колонку ("posts.locked").
+// Если в schema_migrations нет ни одной записи, Up сначала отмечает
+// применёнными, не выполняя их SQL, версии, чьи объекты уже есть в базе.
+func (m *Migrator) EnableBaseline(objects map[database.Dialect]map[int64]string) {
+	m.baseline = objects[m.db.Dialect]
+}
+
+// Up применяет все ещё не применённые миграции по возрастанию версий
+// и возвращает применённые
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 && len(m.baseline) > 0 {
+		if err := m.adoptExisting(); err != nil {
+			return nil, fmt.Errorf("baseline: %w", err)
+		}
+		if applied, err = m.applied(); err != nil {
+			return nil, err
+		}
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		ran, err := m.run(migration, true)
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		if ran {
+			done = append(done, migration)
+		}
+	}
+	return done, nil
+}
+
+// Down откатывает n последних применённых миграций и возвращает откаченные
+func (m *Migrator) Down(n int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < n; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == "" {
+			return done, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, ErrMissingDown)
+		}
+		if _, err := m.run(migration, false); err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Status возвращает состояние всех известных миграций
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		at, ok := applied[migration.Version]
+		statuses = append(statuses, Status{Version: migration.Version, Name: migration.Name, Applied: ok, AppliedAt: at})
+	}
+	return statuses, nil
+}
+
+// Force отмечает версии до version включительно применёнными, а более
+// поздние — неприменёнными, не выполняя их SQL. Нужен, чтобы взять под
+// управление базу, созданную без миграций, или поправить запись после
+// ручного вмешательства. version 0 означает пустую схему.
+func (m *Migrator) Force(version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	if err := m.ensureTable(); err != nil {
+		return err
+	}
+
+	tx, err := m.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM schema_migrations WHERE version > ?`, version); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, migration := range m.migrations {
+		if migration.Version > version {
+			break
+		}
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, migration.Version).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+			migration.Version, migration.Name, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// adoptExisting отмечает применёнными версии из baseline, чьи объекты уже
+// есть в базе. Ничего не делает, если другой процесс успел записать хотя бы
+// одну версию.
+func (m *Migrator) adoptExisting() error {
+	tx, err := m.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	queries := objectQueries[m.db.Dialect]
+	now := time.Now()
+	for _, migration := range m.migrations {
+		object, ok := m.baseline[migration.Version]
+		if !ok {
+			continue
+		}
+		var found int
+		if table, column, isColumn := strings.Cut(object, "."); isColumn {
+			err = tx.QueryRow(queries.column, table, column).Scan(&found)
+		} else {
+			err = tx.QueryRow(queries.table, table).Scan(&found)
+		}
+		if err != nil {
+			return err
+		}
+		if found == 0 {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+			migration.Version, migration.Name, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// run применяет (up) или откатывает миграцию. Возвращает false, если
+// другой процесс успел сделать это раньше.
+func (m *Migrator) run(migration Migration, up bool) (bool, error) {
+	tx, err := m.begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, migration.Version).Scan(&count); err != nil {
+		return false, err
+	}
+	if (count > 0) == up {
+		return false, nil
+	}
+
+	if up {
+		if _, err := tx.Exec(migration.Up); err != nil {
+			return false, err
+		}
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+			migration.Version, migration.Name, time.Now())
+	} else {
+		if _, err := tx.Exec(migration.Down); err != nil {
+			return false, err
+		}
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, migration.Version)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// begin открывает транзакцию и берёт блокировку миграций
+func (m *Migrator) begin() (*database.Tx, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range lockStatements[m.db.Dialect] {
+		if _, err := tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+func (m *Migrator) ensureTable() error {
+	_, err := m.db.Exec(versionTable[m.db.Dialect])
+	return err
+}
+
+// applied возвращает применённые версии и время их применения
+func (m *Migrator) applied() (map[int64]time.Time, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
@@ -0,0 +1,378 @@
+package migrate_test
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mos1rain/forum_go/migrations"
+	"github.com/mos1rain/forum_go/pkg/database"
+	"github.com/mos1rain/forum_go/pkg/database/dbtest"
+	"github.com/mos1rain/forum_go/pkg/migrate"
+)
+
+func appliedVersions(t *testing.T, m *migrate.Migrator) []int64 {
+	t.Helper()
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	var versions []int64
+	for _, s := range statuses {
+		if s.Applied {
+			versions = append(versions, s.Version)
+		}
+	}
+	return versions
+}
+
+// Все миграции проекта должны накатываться и откатываться без ошибок
+func TestMigrations_UpDown(t *testing.T) {
+	for _, dialect := range dbtest.Dialects {
+		t.Run(string(dialect), func(t *testing.T) {
+			db := dbtest.Open(t, dialect)
+			m, err := migrate.New(db, migrations.FS)
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+
+			applied, err := m.Up()
+			if err != nil {
+				t.Fatalf("up: %v", err)
+			}
+			total := len(applied)
+			if total == 0 {
+				t.Fatal("expected migrations to be applied")
+			}
+			if again, err := m.Up(); err != nil || len(again) != 0 {
+				t.Fatalf("second up must be a no-op, got %d, %v", len(again), err)
+			}
+
+			reverted, err := m.Down(2)
+			if err != nil || len(reverted) != 2 {
+				t.Fatalf("down 2: %d, %v", len(reverted), err)
+			}
+			if got := appliedVersions(t, m); len(got) != total-2 {
+				t.Errorf("expected %d applied versions, got %v", total-2, got)
+			}
+
+			if _, err := m.Down(total); err != nil {
+				t.Fatalf("down all: %v", err)
+			}
+			if got := appliedVersions(t, m); len(got) != 0 {
+				t.Errorf("expected empty schema, got %v", got)
+			}
+
+			if applied, err := m.Up(); err != nil || len(applied) != total {
+				t.Fatalf("up after down: %d, %v", len(applied), err)
+			}
+		})
+	}
+}
+
+var testMigrations = fstest.MapFS{
+	"sqlite/000001_create_a.up.sql":     {Data: []byte(`CREATE TABLE a (id INTEGER);`)},
+	"sqlite/000001_create_a.down.sql":   {Data: []byte(`DROP TABLE a;`)},
+	"sqlite/000002_create_b.up.sql":     {Data: []byte(`CREATE TABLE b (id INTEGER);`)},
+	"sqlite/000002_create_b.down.sql":   {Data: []byte(`DROP TABLE b;`)},
+	"sqlite/000003_broken.up.sql":       {Data: []byte(`CREATE TABLE c (id INTEGER); SELECT * FROM missing;`)},
+	"postgres/000001_create_a.up.sql":   {Data: []byte(`CREATE TABLE a (id INTEGER);`)},
+	"postgres/000001_create_a.down.sql": {Data: []byte(`DROP TABLE a;`)},
+	"postgres/000002_create_b.up.sql":   {Data: []byte(`CREATE TABLE b (id INTEGER);`)},
+	"postgres/000002_create_b.down.sql": {Data: []byte(`DROP TABLE b;`)},
+	"postgres/000003_broken.up.sql":     {Data: []byte(`CREATE TABLE c (id INTEGER); SELECT * FROM missing;`)},
+}
+
+func TestMigrator_FailedMigrationIsRolledBack(t *testing.T) {
+	for _, dialect := range dbtest.Dialects {
+		t.Run(string(dialect), func(t *testing.T) {
+			db := dbtest.Open(t, dialect)
+			m, err := migrate.New(db, testMigrations)
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+
+			applied, err := m.Up()
+			if err == nil {
+				t.Fatal("expected broken migration to fail")
+			}
+			if len(applied) != 2 {
+				t.Errorf("expected 2 migrations applied before the failure, got %d", len(applied))
+			}
+			if got := appliedVersions(t, m); len(got) != 2 {
+				t.Errorf("broken migration must not be recorded, got %v", got)
+			}
+			if _, err := db.Exec(`SELECT * FROM c`); err == nil {
+				t.Error("changes of the broken migration must be rolled back")
+			}
+
+			// Версия 3 без down-файла не откатывается
+			if err := m.Force(3); err != nil {
+				t.Fatalf("force 3: %v", err)
+			}
+			if _, err := m.Down(1); !errors.Is(err, migrate.ErrMissingDown) {
+				t.Errorf("expected ErrMissingDown, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMigrator_Force(t *testing.T) {
+	db := dbtest.Open(t, database.SQLite)
+	m, err := migrate.New(db, testMigrations)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	// База, созданная без миграций: таблица a уже есть
+	if _, err := db.Exec(`CREATE TABLE a (id INTEGER)`); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := m.Force(1); err != nil {
+		t.Fatalf("force: %v", err)
+	}
+	if got := appliedVersions(t, m); len(got) != 1 || got[0] != 1 {
+		t.Fatalf("expected only version 1 applied, got %v", got)
+	}
+	if _, err := m.Up(); err == nil {
+		t.Fatal("expected broken migration to fail")
+	}
+	if got := appliedVersions(t, m); len(got) != 2 {
+		t.Errorf("expected versions 1 and 2 applied, got %v", got)
+	}
+
+	if err := m.Force(0); err != nil {
+		t.Fatalf("force 0: %v", err)
+	}
+	if got := appliedVersions(t, m); len(got) != 0 {
+		t.Errorf("expected no applied versions, got %v", got)
+	}
+	if err := m.Force(42); !errors.Is(err, migrate.ErrUnknownVersion) {
+		t.Errorf("expected ErrUnknownVersion, got %v", err)
+	}
+}
+
+func TestMigrator_Baseline(t *testing.T) {
+	for _, dialect := range dbtest.Dialects {
+		t.Run(string(dialect), func(t *testing.T) {
+			db := dbtest.Open(t, dialect)
+			m, err := migrate.New(db, testMigrations)
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			m.EnableBaseline(map[database.Dialect]map[int64]string{dialect: {1: "a.id", 2: "b"}})
+
+			// Есть только объекты версии 1: она отмечается, версия 2 выполняется
+			if _, err := db.Exec(`CREATE TABLE a (id INTEGER)`); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			applied, err := m.Up()
+			if err == nil {
+				t.Fatal("expected broken migration to fail")
+			}
+			if len(applied) != 1 || applied[0].Version != 2 {
+				t.Errorf("expected only version 2 to run, got %+v", applied)
+			}
+			if got := appliedVersions(t, m); len(got) != 2 {
+				t.Errorf("expected versions 1 and 2 applied, got %v", got)
+			}
+
+		})
+	}
+}
+
+// legacySchema схема, которую сервисы создавали при старте до появления
+// миграций
+var legacySchema = map[database.Dialect]string{
+	database.SQLite: `
+		CREATE TABLE users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			username TEXT UNIQUE NOT NULL,
+			email TEXT UNIQUE NOT NULL,
+			password_hash TEXT NOT NULL,
+			role TEXT NOT NULL DEFAULT 'user',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE refresh_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			family_id TEXT NOT NULL,
+			token_hash TEXT UNIQUE NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			used_at TIMESTAMP,
+			revoked_at TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+		CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+		CREATE TABLE categories (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT UNIQUE NOT NULL,
+			description TEXT NOT NULL,
+			creator_id INTEGER NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (creator_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+		CREATE TABLE posts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			author_id INTEGER NOT NULL,
+			category_id INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			content TEXT NOT NULL,
+			locked BOOLEAN NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (author_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+		);
+		CREATE TABLE comments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			post_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			parent_id INTEGER,
+			depth INTEGER NOT NULL DEFAULT 0,
+			deleted BOOLEAN NOT NULL DEFAULT 0,
+			content TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE
+		);
+		CREATE INDEX idx_comments_parent_id ON comments(parent_id);
+		CREATE VIRTUAL TABLE posts_fts USING fts5(title, content, content='posts', content_rowid='id');
+		CREATE VIRTUAL TABLE comments_fts USING fts5(content, content='comments', content_rowid='id');
+		CREATE TABLE chat_messages (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			username TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+	`,
+	database.Postgres: `
+		CREATE TABLE users (
+			id BIGSERIAL PRIMARY KEY,
+			username TEXT UNIQUE NOT NULL,
+			email TEXT UNIQUE NOT NULL,
+			password_hash TEXT NOT NULL,
+			role TEXT NOT NULL DEFAULT 'user',
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE refresh_tokens (
+			id BIGSERIAL PRIMARY KEY,
+			user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			family_id TEXT NOT NULL,
+			token_hash TEXT UNIQUE NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			used_at TIMESTAMPTZ,
+			revoked_at TIMESTAMPTZ
+		);
+		CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+		CREATE TABLE categories (
+			id BIGSERIAL PRIMARY KEY,
+			name TEXT UNIQUE NOT NULL,
+			description TEXT NOT NULL,
+			creator_id BIGINT NOT NULL,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE posts (
+			id BIGSERIAL PRIMARY KEY,
+			author_id BIGINT NOT NULL,
+			category_id BIGINT NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+			title TEXT NOT NULL,
+			content TEXT NOT NULL,
+			locked BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			search_vector TSVECTOR GENERATED ALWAYS AS (
+				setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', content), 'B')
+			) STORED
+		);
+		CREATE TABLE comments (
+			id BIGSERIAL PRIMARY KEY,
+			post_id BIGINT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+			user_id BIGINT NOT NULL,
+			parent_id BIGINT REFERENCES comments(id) ON DELETE CASCADE,
+			depth INTEGER NOT NULL DEFAULT 0,
+			deleted BOOLEAN NOT NULL DEFAULT FALSE,
+			content TEXT NOT NULL,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+			search_vector TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED
+		);
+		CREATE INDEX idx_comments_parent_id ON comments(parent_id);
+		CREATE TABLE chat_messages (
+			id BIGSERIAL PRIMARY KEY,
+			user_id BIGINT NOT NULL,
+			username TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+		);
+	`,
+}
+
+// База, созданная сервисами до миграций, берётся под управление без
+// ручного force, и данные в ней сохраняются
+func TestMigrations_LegacySchema(t *testing.T) {
+	for _, dialect := range dbtest.Dialects {
+		t.Run(string(dialect), func(t *testing.T) {
+			db := dbtest.Open(t, dialect)
+			if _, err := db.Exec(legacySchema[dialect]); err != nil {
+				t.Fatalf("legacy schema: %v", err)
+			}
+			if _, err := db.Exec(`INSERT INTO users (username, email, password_hash) VALUES ('old', 'old@example.com', 'hash')`); err != nil {
+				t.Fatalf("insert user: %v", err)
+			}
+
+			m, err := migrate.New(db, migrations.FS)
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			m.EnableBaseline(migrations.Legacy)
+			applied, err := m.Up()
+			if err != nil {
+				t.Fatalf("up: %v", err)
+			}
+			for _, migration := range applied {
+				if _, legacy := migrations.Legacy[dialect][migration.Version]; legacy {
+					t.Errorf("legacy migration %d must not run", migration.Version)
+				}
+			}
+			statuses, err := m.Status()
+			if err != nil {
+				t.Fatalf("status: %v", err)
+			}
+			if got := appliedVersions(t, m); len(got) != len(statuses) {
+				t.Errorf("expected all %d versions applied, got %v", len(statuses), got)
+			}
+
+			var count int
+			if err := db.QueryRow(`SELECT COUNT(*) FROM users WHERE username = 'old'`).Scan(&count); err != nil || count != 1 {
+				t.Errorf("legacy data lost: %d, %v", count, err)
+			}
+		})
+	}
+}
+
+func TestLoad_InvalidFiles(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"bad name":     {"sqlite/init.sql": {Data: []byte(`SELECT 1`)}},
+		"no up file":   {"sqlite/000001_a.down.sql": {Data: []byte(`SELECT 1`)}},
+		"two names":    {"sqlite/000001_a.up.sql": {Data: []byte(`SELECT 1`)}, "sqlite/000001_b.down.sql": {Data: []byte(`SELECT 1`)}},
+		"zero version": {"sqlite/000000_a.up.sql": {Data: []byte(`SELECT 1`)}},
+	}
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := migrate.Load(fsys, database.SQLite); !errors.Is(err, migrate.ErrInvalidFileName) {
+				t.Errorf("expected ErrInvalidFileName, got %v", err)
+			}
+		})
+	}
+}
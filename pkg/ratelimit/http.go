@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// KeyFunc выбирает ключ ведра для запроса. Пустая строка отключает
+// ограничение для запроса.
+type KeyFunc func(r *http.Request) string
+
+// ByIP ключ по адресу клиента (см. ClientIP)
+func ByIP(trustForwarded bool) KeyFunc {
+	return func(r *http.Request) string {
+		return "ip:" + ClientIP(r, trustForwarded)
+	}
+}
+
+// ClientIP адрес клиента. Заголовок X-Forwarded-For учитывается, только
+// если trustForwarded: иначе клиент мог бы подставить любой адрес.
+func ClientIP(r *http.Request, trustForwarded bool) string {
+	if trustForwarded {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ByUser ключ по пользователю из контекста; для анонимных запросов
+// используется fallback
+func ByUser(userID func(r *http.Request) (int, bool), fallback KeyFunc) KeyFunc {
+	return func(r *http.Request) string {
+		if id, ok := userID(r); ok {
+			return "user:" + strconv.Itoa(id)
+		}
+		return fallback(r)
+	}
+}
+
+// Wrap пропускает запрос к next, только если в ведре key(r) есть токен
+func (l *Limiter) Wrap(key KeyFunc, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if k := key(r); k != "" {
+			if ok, retry := l.Allow(k); !ok {
+				zerolog.Ctx(r.Context()).Warn().Str("key", k).Dur("retry_after", retry).Msg("Rate limit exceeded")
+				TooManyRequests(w, retry)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// Middleware то же, что Wrap, для http.Handler
+func (l *Limiter) Middleware(key KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return l.Wrap(key, next.ServeHTTP)
+	}
+}
+
+// TooManyRequests отвечает 429 с заголовком Retry-After в целых секундах
+func TooManyRequests(w http.ResponseWriter, retry time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(RetryAfterSeconds(retry)))
+	http.Error(w, "too many requests", http.StatusTooManyRequests)
+}
+
+// RetryAfterSeconds округляет ожидание вверх до целых секунд, не меньше 1
+func RetryAfterSeconds(retry time.Duration) int {
+	seconds := int(math.Ceil(retry.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
@@ -0,0 +1,163 @@
+// Package ratelimit ограничивает частоту запросов алгоритмом token bucket.
+// Ведро вмещает Limit.Requests токенов и полностью наполняется за
+// Limit.Per; каждый запрос забирает токен. Limiter хранит отдельное ведро
+// на каждый ключ (IP, пользователь) и возвращает, через сколько можно
+// повторить отклонённый запрос.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidLimit = errors.New("invalid rate limit")
+
+// Limit не более Requests запросов за Per. Нулевое значение отключает
+// ограничение.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseLimit разбирает лимит вида "10/1m" или "off"
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "" {
+		return Limit{}, nil
+	}
+	count, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("%w %q: expected N/duration, e.g. 10/1m", ErrInvalidLimit, s)
+	}
+	requests, err := strconv.Atoi(count)
+	if err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("%w %q: request count must be a positive integer", ErrInvalidLimit, s)
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("%w %q: period must be a positive duration", ErrInvalidLimit, s)
+	}
+	return Limit{Requests: requests, Per: d}, nil
+}
+
+// Enabled сообщает, ограничивает ли лимит что-либо
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Per > 0
+}
+
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+// UnmarshalText позволяет задавать лимит строкой в файле конфигурации
+func (l *Limit) UnmarshalText(text []byte) error {
+	parsed, err := ParseLimit(string(text))
+	if err != nil {
+		return err
+	}
+	*l = parsed
+	return nil
+}
+
+func (l Limit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// Bucket ведро одного клиента. Не потокобезопасен: для общего доступа
+// используйте Limiter.
+type Bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+func NewBucket(limit Limit) *Bucket {
+	return &Bucket{limit: limit, tokens: float64(limit.Requests)}
+}
+
+// Allow забирает токен. Если токенов нет, возвращает false и время,
+// через которое появится следующий.
+func (b *Bucket) Allow() (bool, time.Duration) {
+	return b.allowAt(time.Now())
+}
+
+func (b *Bucket) allowAt(now time.Time) (bool, time.Duration) {
+	if !b.limit.Enabled() {
+		return true, 0
+	}
+	rate := float64(b.limit.Requests) / b.limit.Per.Seconds()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * rate
+		if max := float64(b.limit.Requests); b.tokens > max {
+			b.tokens = max
+		}
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+// full сообщает, что ведро успело полностью наполниться и его можно
+// забыть: новое ведро для того же ключа будет в том же состоянии
+func (b *Bucket) full(now time.Time) bool {
+	return now.Sub(b.last) >= b.limit.Per
+}
+
+// Limiter набор вёдер по ключам с общим лимитом
+type Limiter struct {
+	limit Limit
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*Bucket
+	lastSweep time.Time
+}
+
+func New(limit Limit) *Limiter {
+	return &Limiter{limit: limit, now: time.Now, buckets: make(map[string]*Bucket)}
+}
+
+// Allow забирает токен из ведра key
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if !l.limit.Enabled() {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = NewBucket(l.limit)
+		l.buckets[key] = b
+	}
+	return b.allowAt(now)
+}
+
+// sweep не чаще раза в период удаляет наполнившиеся вёдра, чтобы карта
+// не росла от разовых клиентов
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.limit.Per {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.full(now) {
+			delete(l.buckets, key)
+		}
+	}
+}
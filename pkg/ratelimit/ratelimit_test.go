@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := map[string]Limit{
+		"10/1m":  {Requests: 10, Per: time.Minute},
+		" 5/1h ": {Requests: 5, Per: time.Hour},
+		"off":    {},
+		"":       {},
+	}
+	for in, want := range tests {
+		got, err := ParseLimit(in)
+		if err != nil || got != want {
+			t.Errorf("ParseLimit(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"10", "ten/1m", "0/1m", "10/minute", "10/-1s"} {
+		if _, err := ParseLimit(in); !errors.Is(err, ErrInvalidLimit) {
+			t.Errorf("ParseLimit(%q): expected ErrInvalidLimit, got %v", in, err)
+		}
+	}
+
+	// String и ParseLimit взаимно обратны
+	limit := Limit{Requests: 3, Per: 90 * time.Second}
+	if parsed, err := ParseLimit(limit.String()); err != nil || parsed != limit {
+		t.Errorf("round trip of %v: %v, %v", limit, parsed, err)
+	}
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(Limit{Requests: 2, Per: 10 * time.Second})
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("request %d within burst must pass", i+1)
+		}
+	}
+	ok, retry := l.Allow("a")
+	if ok {
+		t.Fatal("third request must be limited")
+	}
+	if retry != 5*time.Second {
+		t.Errorf("expected retry after 5s, got %v", retry)
+	}
+	if ok, _ := l.Allow("b"); !ok {
+		t.Error("other keys have their own bucket")
+	}
+
+	now = now.Add(5 * time.Second)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Error("token must be refilled after retry delay")
+	}
+
+	// Наполнившиеся вёдра удаляются
+	now = now.Add(time.Minute)
+	l.Allow("c")
+	if _, ok := l.buckets["b"]; ok {
+		t.Error("idle bucket must be swept")
+	}
+}
+
+func TestLimiter_Disabled(t *testing.T) {
+	l := New(Limit{})
+	for i := 0; i < 100; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatal("disabled limiter must allow everything")
+		}
+	}
+}
+
+func TestLimiter_Wrap(t *testing.T) {
+	l := New(Limit{Requests: 1, Per: time.Minute})
+	h := l.Wrap(ByIP(false), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	do := func(remote, forwarded string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+		req.RemoteAddr = remote
+		if forwarded != "" {
+			req.Header.Set("X-Forwarded-For", forwarded)
+		}
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	if rec := do("10.0.0.1:5000", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("first request: expected 204, got %d", rec.Code)
+	}
+	// Другой порт и подставленный X-Forwarded-For не обходят лимит
+	rec := do("10.0.0.1:6000", "1.2.3.4")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: expected 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("expected Retry-After 60, got %q", got)
+	}
+	if rec := do("10.0.0.2:5000", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("another IP: expected 204, got %d", rec.Code)
+	}
+}
+
+func TestByUser(t *testing.T) {
+	userID := func(r *http.Request) (int, bool) {
+		if r.Header.Get("X-User") == "" {
+			return 0, false
+		}
+		return 7, true
+	}
+	key := ByUser(userID, ByIP(true))
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.5, 10.0.0.1")
+	if got := key(req); got != "ip:203.0.113.5" {
+		t.Errorf("anonymous request: expected forwarded IP key, got %q", got)
+	}
+	req.Header.Set("X-User", "7")
+	if got := key(req); got != "user:7" {
+		t.Errorf("expected user key, got %q", got)
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	for retry, want := range map[time.Duration]int{0: 1, 200 * time.Millisecond: 1, 1500 * time.Millisecond: 2, time.Minute: 60} {
+		if got := RetryAfterSeconds(retry); got != want {
+			t.Errorf("RetryAfterSeconds(%v) = %d, want %d", retry, got, want)
+		}
+	}
+}
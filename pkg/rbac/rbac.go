@@ -0,0 +1,78 @@
+// Package rbac описывает права пользователей форума и чата. Каждое
+// действие модерации и администрирования — отдельное именованное право;
+// роль даёт набор прав, а модератор категории получает права из
+// CategoryScoped только в пределах назначенных ему категорий.
+package rbac
+
+import "slices"
+
+// Роли пользователей, как их выдаёт auth-сервис
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Permission право на действие
+type Permission string
+
+const (
+	CreateCategory    Permission = "create_category"
+	DeleteCategory    Permission = "delete_category"
+	ManageModerators  Permission = "manage_moderators"
+	EditAnyPost       Permission = "edit_any_post"
+	DeleteAnyPost     Permission = "delete_any_post"
+	DeleteAnyComment  Permission = "delete_any_comment"
+	LockThread        Permission = "lock_thread"
+	BanUser           Permission = "ban_user"
+	DeleteChatMessage Permission = "delete_chat_message"
+	ManageUsers       Permission = "manage_users"
+	// ReviewReports просмотр очереди жалоб и решения по ним
+	ReviewReports Permission = "review_reports"
+	// ViewAuditLog просмотр и выгрузка журнала действий модерации
+	ViewAuditLog Permission = "view_audit_log"
+	// RestoreContent просмотр и восстановление удалённых категорий, постов и комментариев
+	RestoreContent Permission = "restore_content"
+)
+
+// CategoryScoped права, которые модератор категории получает в ней
+var CategoryScoped = []Permission{EditAnyPost, DeleteAnyPost, DeleteAnyComment, LockThread}
+
+var moderatorPermissions = append(slices.Clone(CategoryScoped), BanUser, DeleteChatMessage, ReviewReports)
+
+// Default соответствие ролей и прав, которым пользуются все сервисы
+var Default = NewPolicy(map[string][]Permission{
+	RoleUser:      nil,
+	RoleModerator: moderatorPermissions,
+	RoleAdmin: append(slices.Clone(moderatorPermissions),
+		CreateCategory, DeleteCategory, ManageModerators, ManageUsers, ViewAuditLog, RestoreContent),
+})
+
+// Policy соответствие ролей и прав. После создания не меняется, поэтому
+// безопасна для одновременного использования.
+type Policy struct {
+	grants map[string][]Permission
+}
+
+func NewPolicy(grants map[string][]Permission) *Policy {
+	p := &Policy{grants: make(map[string][]Permission, len(grants))}
+	for role, perms := range grants {
+		p.grants[role] = slices.Clone(perms)
+	}
+	return p
+}
+
+// Can сообщает, даёт ли роль право perm. Неизвестная роль не даёт ничего.
+func (p *Policy) Can(role string, perm Permission) bool {
+	return slices.Contains(p.grants[role], perm)
+}
+
+// Permissions возвращает права роли
+func (p *Policy) Permissions(role string) []Permission {
+	return slices.Clone(p.grants[role])
+}
+
+// IsCategoryScoped сообщает, может ли право быть выдано в пределах категории
+func IsCategoryScoped(perm Permission) bool {
+	return slices.Contains(CategoryScoped, perm)
+}
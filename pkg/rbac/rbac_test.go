@@ -0,0 +1,59 @@
+package rbac
+
+import "testing"
+
+func TestDefaultPolicy(t *testing.T) {
+	cases := []struct {
+		role string
+		perm Permission
+		want bool
+	}{
+		{RoleUser, DeleteAnyPost, false},
+		{RoleUser, CreateCategory, false},
+		{RoleModerator, DeleteAnyPost, true},
+		{RoleModerator, LockThread, true},
+		{RoleModerator, DeleteChatMessage, true},
+		{RoleModerator, ReviewReports, true},
+		{RoleUser, ReviewReports, false},
+		{RoleModerator, CreateCategory, false},
+		{RoleModerator, ManageUsers, false},
+		{RoleModerator, ViewAuditLog, false},
+		{RoleAdmin, ViewAuditLog, true},
+		{RoleModerator, RestoreContent, false},
+		{RoleAdmin, RestoreContent, true},
+		{RoleAdmin, DeleteAnyComment, true},
+		{RoleAdmin, DeleteCategory, true},
+		{RoleAdmin, ManageModerators, true},
+		{"guest", DeleteAnyPost, false},
+		{"", CreateCategory, false},
+	}
+	for _, c := range cases {
+		if got := Default.Can(c.role, c.perm); got != c.want {
+			t.Errorf("Can(%q, %s) = %v, want %v", c.role, c.perm, got, c.want)
+		}
+	}
+}
+
+func TestPolicyIsolated(t *testing.T) {
+	grants := map[string][]Permission{"editor": {EditAnyPost}}
+	p := NewPolicy(grants)
+	grants["editor"][0] = DeleteCategory
+
+	if !p.Can("editor", EditAnyPost) || p.Can("editor", DeleteCategory) {
+		t.Error("policy must not share grants with the caller")
+	}
+	perms := p.Permissions("editor")
+	perms[0] = DeleteCategory
+	if !p.Can("editor", EditAnyPost) {
+		t.Error("Permissions must return a copy")
+	}
+}
+
+func TestIsCategoryScoped(t *testing.T) {
+	if !IsCategoryScoped(LockThread) || !IsCategoryScoped(DeleteAnyPost) {
+		t.Error("thread moderation must be category scoped")
+	}
+	if IsCategoryScoped(DeleteCategory) || IsCategoryScoped(BanUser) {
+		t.Error("site-wide permissions must not be category scoped")
+	}
+}
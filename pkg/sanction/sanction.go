@@ -0,0 +1,126 @@
+// Package sanction описывает ограничения пользователей, которые выдаёт
+// auth-сервис, а соблюдают forum и chat: бессрочный бан, временное
+// отстранение, запрет писать в чат и предупреждение. Здесь же — ответ,
+// который получает пользователь с действующим ограничением.
+package sanction
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Type вид ограничения
+type Type string
+
+const (
+	// Ban бессрочно запрещает вход и любые изменения
+	Ban Type = "ban"
+	// Suspension то же, что Ban, но на срок
+	Suspension Type = "suspension"
+	// Mute запрещает только писать в чат
+	Mute Type = "mute"
+	// Warning ничего не запрещает и остаётся в истории пользователя
+	Warning Type = "warning"
+)
+
+// Valid сообщает, известен ли вид ограничения
+func (t Type) Valid() bool {
+	return t == Ban || t == Suspension || t == Mute || t == Warning
+}
+
+// Scope часть сервиса, на которую может действовать ограничение
+type Scope int
+
+const (
+	// Forum вход и изменения через API форума и auth
+	Forum Scope = iota
+	// Chat сообщения в чат
+	Chat
+)
+
+// Restricts сообщает, действует ли ограничение вида t в scope
+func (t Type) Restricts(scope Scope) bool {
+	switch t {
+	case Ban, Suspension:
+		return true
+	case Mute:
+		return scope == Chat
+	}
+	return false
+}
+
+// Active действующее ограничение. ExpiresAt nil — бессрочно.
+type Active struct {
+	Type      Type
+	Reason    string
+	ExpiresAt *time.Time
+}
+
+// Find возвращает самое строгое из ограничений, действующих в scope:
+// бан, затем отстранение с самым поздним сроком, затем запрет писать в чат.
+// Если ограничений нет, возвращает nil.
+func Find(active []Active, scope Scope) *Active {
+	var found *Active
+	for i := range active {
+		a := &active[i]
+		if !a.Type.Restricts(scope) {
+			continue
+		}
+		if found == nil || stricter(a, found) {
+			found = a
+		}
+	}
+	return found
+}
+
+func stricter(a, b *Active) bool {
+	if rank(a.Type) != rank(b.Type) {
+		return rank(a.Type) > rank(b.Type)
+	}
+	if a.ExpiresAt == nil || b.ExpiresAt == nil {
+		return a.ExpiresAt == nil && b.ExpiresAt != nil
+	}
+	return a.ExpiresAt.After(*b.ExpiresAt)
+}
+
+func rank(t Type) int {
+	switch t {
+	case Ban:
+		return 3
+	case Suspension:
+		return 2
+	case Mute:
+		return 1
+	}
+	return 0
+}
+
+// Notice тело ответа пользователю с действующим ограничением
+type Notice struct {
+	Error     string     `json:"error"`
+	Sanction  Type       `json:"sanction"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Notice возвращает сообщение об ограничении для пользователя
+func (a Active) Notice() Notice {
+	n := Notice{Sanction: a.Type, Reason: a.Reason, ExpiresAt: a.ExpiresAt}
+	switch a.Type {
+	case Ban:
+		n.Error = "account is banned"
+	case Suspension:
+		n.Error = "account is suspended"
+	case Mute:
+		n.Error = "you are muted in chat"
+	}
+	return n
+}
+
+// WriteHTTP отвечает 403 Forbidden с Notice в теле
+func WriteHTTP(w http.ResponseWriter, a Active) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(a.Notice())
+}
@@ -0,0 +1,66 @@
+package sanction
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRestricts(t *testing.T) {
+	if !Ban.Restricts(Forum) || !Ban.Restricts(Chat) || !Suspension.Restricts(Forum) {
+		t.Error("ban and suspension must restrict everything")
+	}
+	if Mute.Restricts(Forum) || !Mute.Restricts(Chat) {
+		t.Error("mute must restrict only chat")
+	}
+	if !Warning.Valid() || Warning.Restricts(Forum) || Warning.Restricts(Chat) {
+		t.Error("warning must be valid and restrict nothing")
+	}
+	if Type("kick").Valid() || Type("kick").Restricts(Chat) {
+		t.Error("unknown type must not be valid")
+	}
+}
+
+func TestFind(t *testing.T) {
+	soon := time.Now().Add(time.Hour)
+	later := soon.Add(time.Hour)
+	mute := Active{Type: Mute, Reason: "flood"}
+	short := Active{Type: Suspension, ExpiresAt: &soon}
+	long := Active{Type: Suspension, ExpiresAt: &later}
+	ban := Active{Type: Ban}
+
+	if got := Find(nil, Chat); got != nil {
+		t.Errorf("expected nil, got %+v", got)
+	}
+	if got := Find([]Active{mute}, Forum); got != nil {
+		t.Errorf("mute must not restrict forum, got %+v", got)
+	}
+	if got := Find([]Active{mute}, Chat); got == nil || got.Reason != "flood" {
+		t.Errorf("expected mute, got %+v", got)
+	}
+	if got := Find([]Active{short, mute, long}, Chat); got == nil || got.ExpiresAt != &later {
+		t.Errorf("expected the longest suspension, got %+v", got)
+	}
+	if got := Find([]Active{long, ban, mute}, Forum); got == nil || got.Type != Ban {
+		t.Errorf("expected ban, got %+v", got)
+	}
+}
+
+func TestWriteHTTP(t *testing.T) {
+	until := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	rec := httptest.NewRecorder()
+	WriteHTTP(rec, Active{Type: Suspension, Reason: "spam", ExpiresAt: &until})
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rec.Code)
+	}
+	var n Notice
+	if err := json.NewDecoder(rec.Body).Decode(&n); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if n.Error != "account is suspended" || n.Sanction != Suspension || n.Reason != "spam" || n.ExpiresAt == nil || !n.ExpiresAt.Equal(until) {
+		t.Errorf("unexpected notice: %+v", n)
+	}
+}
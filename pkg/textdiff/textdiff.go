@@ -0,0 +1,210 @@
+// Package textdiff строит построчную разницу двух текстов в формате
+// unified diff, как у diff -u.
+package textdiff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultContext сколько неизменённых строк показывается вокруг правок
+const DefaultContext = 3
+
+// maxEdits сколько правок ищется точно. Если тексты различаются сильнее,
+// разница показывается как замена всего отличающегося куска: поиск
+// кратчайшей разницы требует памяти, квадратичной по числу правок.
+const maxEdits = 2000
+
+// edit строка разницы: kind ' ' — общая, '-' — только в старом тексте,
+// '+' — только в новом
+type edit struct {
+	kind byte
+	text string
+}
+
+// Unified возвращает разницу между from и to в формате unified diff с
+// заголовками fromName и toName и contextLines строками контекста.
+// Для одинаковых текстов возвращает пустую строку.
+func Unified(fromName, toName, from, to string, contextLines int) string {
+	edits := diff(splitLines(from), splitLines(to))
+
+	// Сколько строк старого и нового текста идёт до каждой строки разницы
+	aLine := make([]int, len(edits)+1)
+	bLine := make([]int, len(edits)+1)
+	for i, e := range edits {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if e.kind != '+' {
+			aLine[i+1]++
+		}
+		if e.kind != '-' {
+			bLine[i+1]++
+		}
+	}
+
+	var out strings.Builder
+	for i := 0; ; {
+		for i < len(edits) && edits[i].kind == ' ' {
+			i++
+		}
+		if i == len(edits) {
+			break
+		}
+
+		// Правки, между которыми не больше двух контекстов общих строк,
+		// попадают в один блок
+		end := i
+		for {
+			for end < len(edits) && edits[end].kind != ' ' {
+				end++
+			}
+			next := end
+			for next < len(edits) && edits[next].kind == ' ' {
+				next++
+			}
+			if next == len(edits) || next-end > 2*contextLines {
+				break
+			}
+			end = next
+		}
+		start, stop := max(i-contextLines, 0), min(end+contextLines, len(edits))
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aLine[start], aLine[stop]), hunkRange(bLine[start], bLine[stop]))
+		for _, e := range edits[start:stop] {
+			out.WriteByte(e.kind)
+			out.WriteString(e.text)
+			out.WriteByte('\n')
+		}
+		i = stop
+	}
+	return out.String()
+}
+
+// hunkRange диапазон строк блока в заголовке @@: строки с from+1 по to
+func hunkRange(from, to int) string {
+	switch to - from {
+	case 0:
+		// Пустой диапазон указывает на строку перед ним
+		return strconv.Itoa(from) + ",0"
+	case 1:
+		return strconv.Itoa(from + 1)
+	default:
+		return strconv.Itoa(from+1) + "," + strconv.Itoa(to-from)
+	}
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diff возвращает кратчайшую построчную разницу между a и b
+func diff(a, b []string) []edit {
+	// Общие начало и конец в поиске не участвуют
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	edits := make([]edit, 0, len(a)+len(b))
+	for _, line := range a[:pre] {
+		edits = append(edits, edit{' ', line})
+	}
+	edits = append(edits, myers(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, line := range a[len(a)-suf:] {
+		edits = append(edits, edit{' ', line})
+	}
+	return edits
+}
+
+// myers ищет разницу алгоритмом Майерса: v[k] — самая дальняя точка x на
+// диагонали k = x - y, достижимая за d правок; trace хранит v перед каждым
+// шагом, чтобы восстановить путь
+func myers(a, b []string) []edit {
+	n, m := len(a), len(b)
+	total := n + m
+	off := total + 1
+	v := make([]int, 2*total+3)
+	var trace [][]int
+	for d := 0; d <= total; d++ {
+		if d > maxEdits {
+			return replaceAll(a, b)
+		}
+		// Для шага d нужны диагонали от -d-1 до d+1
+		trace = append(trace, append([]int(nil), v[off-d-1:off+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+	return replaceAll(a, b)
+}
+
+func backtrack(a, b []string, trace [][]int) []edit {
+	x, y := len(a), len(b)
+	var edits []edit
+	for d := len(trace) - 1; d >= 0; d-- {
+		v, o := trace[d], d+1
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[o+k-1] < v[o+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[o+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, edit{' ', a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, edit{'+', b[y-1]})
+			} else {
+				edits = append(edits, edit{'-', a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+func replaceAll(a, b []string) []edit {
+	edits := make([]edit, 0, len(a)+len(b))
+	for _, line := range a {
+		edits = append(edits, edit{'-', line})
+	}
+	for _, line := range b {
+		edits = append(edits, edit{'+', line})
+	}
+	return edits
+}
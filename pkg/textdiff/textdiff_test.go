@@ -0,0 +1,106 @@
+package textdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	tests := map[string]struct {
+		from, to string
+		want     string
+	}{
+		"equal": {from: "a\nb\n", to: "a\nb\n", want: ""},
+		"changed line": {
+			from: "a\nb\nc\n",
+			to:   "a\nB\nc\n",
+			want: "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		"insert into empty": {
+			from: "",
+			to:   "a\n",
+			want: "--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n",
+		},
+		"delete everything": {
+			from: "a\nb",
+			to:   "",
+			want: "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		"appended line": {
+			from: "1\n2\n3\n4\n5\n",
+			to:   "1\n2\n3\n4\n5\n6\n",
+			want: "--- old\n+++ new\n@@ -3,3 +3,4 @@\n 3\n 4\n 5\n+6\n",
+		},
+		"separate hunks": {
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			to:   "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			want: "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+		"merged hunk": {
+			from: "1\n2\n3\n4\n5\n6\n",
+			to:   "one\n2\n3\n4\n5\nsix\n",
+			want: "--- old\n+++ new\n@@ -1,6 +1,6 @@\n-1\n+one\n 2\n 3\n 4\n 5\n-6\n+six\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Unified("old", "new", tt.from, tt.to, DefaultContext); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// apply восстанавливает новый текст по старому и разнице
+func apply(from []string, edits []edit) []string {
+	var out []string
+	i := 0
+	for _, e := range edits {
+		switch e.kind {
+		case ' ':
+			if from[i] != e.text {
+				return nil
+			}
+			out = append(out, e.text)
+			i++
+		case '-':
+			if from[i] != e.text {
+				return nil
+			}
+			i++
+		case '+':
+			out = append(out, e.text)
+		}
+	}
+	return out
+}
+
+func TestDiffIsMinimalAndApplies(t *testing.T) {
+	a := strings.Split("a b c a b b a", " ")
+	b := strings.Split("c b a b a c", " ")
+	edits := diff(a, b)
+	if got := strings.Join(apply(a, edits), " "); got != strings.Join(b, " ") {
+		t.Fatalf("diff does not transform a into b: got %q", got)
+	}
+	changes := 0
+	for _, e := range edits {
+		if e.kind != ' ' {
+			changes++
+		}
+	}
+	if changes != 5 {
+		t.Errorf("expected 5 changes, got %d", changes)
+	}
+}
+
+func TestDiffFallsBackToReplacement(t *testing.T) {
+	var a, b []string
+	for i := 0; i < maxEdits; i++ {
+		a = append(a, "a")
+		b = append(b, "b")
+	}
+	edits := diff(a, b)
+	if len(edits) != 2*maxEdits || edits[0].kind != '-' || edits[len(edits)-1].kind != '+' {
+		t.Fatalf("expected full replacement, got %d edits", len(edits))
+	}
+}
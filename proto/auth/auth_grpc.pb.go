@@ -19,8 +19,12 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	AuthService_ValidateToken_FullMethodName = "/auth.AuthService/ValidateToken"
-	AuthService_GetUserByID_FullMethodName   = "/auth.AuthService/GetUserByID"
+	AuthService_ValidateToken_FullMethodName      = "/auth.AuthService/ValidateToken"
+	AuthService_GetUserByID_FullMethodName        = "/auth.AuthService/GetUserByID"
+	AuthService_SetUserRole_FullMethodName        = "/auth.AuthService/SetUserRole"
+	AuthService_GetActiveSanctions_FullMethodName = "/auth.AuthService/GetActiveSanctions"
+	AuthService_IssueSanction_FullMethodName      = "/auth.AuthService/IssueSanction"
+	AuthService_RecordAudit_FullMethodName        = "/auth.AuthService/RecordAudit"
 )
 
 // AuthServiceClient is the client API for AuthService service.
@@ -29,6 +33,15 @@ const (
 type AuthServiceClient interface {
 	ValidateToken(ctx context.Context, in *ValidateTokenRequest, opts ...grpc.CallOption) (*ValidateTokenResponse, error)
 	GetUserByID(ctx context.Context, in *GetUserByIDRequest, opts ...grpc.CallOption) (*GetUserByIDResponse, error)
+	// SetUserRole меняет роль пользователя; token — access-токен администратора
+	SetUserRole(ctx context.Context, in *SetUserRoleRequest, opts ...grpc.CallOption) (*SetUserRoleResponse, error)
+	// GetActiveSanctions действующие ограничения пользователя: бан,
+	// отстранение, запрет писать в чат
+	GetActiveSanctions(ctx context.Context, in *GetActiveSanctionsRequest, opts ...grpc.CallOption) (*GetActiveSanctionsResponse, error)
+	// IssueSanction ограничивает пользователя; token — access-токен модератора
+	IssueSanction(ctx context.Context, in *IssueSanctionRequest, opts ...grpc.CallOption) (*IssueSanctionResponse, error)
+	// RecordAudit добавляет запись в журнал действий модераторов
+	RecordAudit(ctx context.Context, in *RecordAuditRequest, opts ...grpc.CallOption) (*RecordAuditResponse, error)
 }
 
 type authServiceClient struct {
@@ -59,12 +72,61 @@ func (c *authServiceClient) GetUserByID(ctx context.Context, in *GetUserByIDRequ
 	return out, nil
 }
 
+func (c *authServiceClient) SetUserRole(ctx context.Context, in *SetUserRoleRequest, opts ...grpc.CallOption) (*SetUserRoleResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetUserRoleResponse)
+	err := c.cc.Invoke(ctx, AuthService_SetUserRole_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authServiceClient) GetActiveSanctions(ctx context.Context, in *GetActiveSanctionsRequest, opts ...grpc.CallOption) (*GetActiveSanctionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetActiveSanctionsResponse)
+	err := c.cc.Invoke(ctx, AuthService_GetActiveSanctions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authServiceClient) IssueSanction(ctx context.Context, in *IssueSanctionRequest, opts ...grpc.CallOption) (*IssueSanctionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(IssueSanctionResponse)
+	err := c.cc.Invoke(ctx, AuthService_IssueSanction_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authServiceClient) RecordAudit(ctx context.Context, in *RecordAuditRequest, opts ...grpc.CallOption) (*RecordAuditResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RecordAuditResponse)
+	err := c.cc.Invoke(ctx, AuthService_RecordAudit_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AuthServiceServer is the server API for AuthService service.
 // All implementations must embed UnimplementedAuthServiceServer
 // for forward compatibility.
 type AuthServiceServer interface {
 	ValidateToken(context.Context, *ValidateTokenRequest) (*ValidateTokenResponse, error)
 	GetUserByID(context.Context, *GetUserByIDRequest) (*GetUserByIDResponse, error)
+	// SetUserRole меняет роль пользователя; token — access-токен администратора
+	SetUserRole(context.Context, *SetUserRoleRequest) (*SetUserRoleResponse, error)
+	// GetActiveSanctions действующие ограничения пользователя: бан,
+	// отстранение, запрет писать в чат
+	GetActiveSanctions(context.Context, *GetActiveSanctionsRequest) (*GetActiveSanctionsResponse, error)
+	// IssueSanction ограничивает пользователя; token — access-токен модератора
+	IssueSanction(context.Context, *IssueSanctionRequest) (*IssueSanctionResponse, error)
+	// RecordAudit добавляет запись в журнал действий модераторов
+	RecordAudit(context.Context, *RecordAuditRequest) (*RecordAuditResponse, error)
 	mustEmbedUnimplementedAuthServiceServer()
 }
 
@@ -81,6 +143,18 @@ func (UnimplementedAuthServiceServer) ValidateToken(context.Context, *ValidateTo
 func (UnimplementedAuthServiceServer) GetUserByID(context.Context, *GetUserByIDRequest) (*GetUserByIDResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUserByID not implemented")
 }
+func (UnimplementedAuthServiceServer) SetUserRole(context.Context, *SetUserRoleRequest) (*SetUserRoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetUserRole not implemented")
+}
+func (UnimplementedAuthServiceServer) GetActiveSanctions(context.Context, *GetActiveSanctionsRequest) (*GetActiveSanctionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetActiveSanctions not implemented")
+}
+func (UnimplementedAuthServiceServer) IssueSanction(context.Context, *IssueSanctionRequest) (*IssueSanctionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IssueSanction not implemented")
+}
+func (UnimplementedAuthServiceServer) RecordAudit(context.Context, *RecordAuditRequest) (*RecordAuditResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RecordAudit not implemented")
+}
 func (UnimplementedAuthServiceServer) mustEmbedUnimplementedAuthServiceServer() {}
 func (UnimplementedAuthServiceServer) testEmbeddedByValue()                     {}
 
@@ -138,6 +212,78 @@ func _AuthService_GetUserByID_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _AuthService_SetUserRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetUserRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServiceServer).SetUserRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthService_SetUserRole_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServiceServer).SetUserRole(ctx, req.(*SetUserRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AuthService_GetActiveSanctions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetActiveSanctionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServiceServer).GetActiveSanctions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthService_GetActiveSanctions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServiceServer).GetActiveSanctions(ctx, req.(*GetActiveSanctionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AuthService_IssueSanction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IssueSanctionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServiceServer).IssueSanction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthService_IssueSanction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServiceServer).IssueSanction(ctx, req.(*IssueSanctionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AuthService_RecordAudit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecordAuditRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServiceServer).RecordAudit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthService_RecordAudit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServiceServer).RecordAudit(ctx, req.(*RecordAuditRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // AuthService_ServiceDesc is the grpc.ServiceDesc for AuthService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -153,6 +299,22 @@ var AuthService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetUserByID",
 			Handler:    _AuthService_GetUserByID_Handler,
 		},
+		{
+			MethodName: "SetUserRole",
+			Handler:    _AuthService_SetUserRole_Handler,
+		},
+		{
+			MethodName: "GetActiveSanctions",
+			Handler:    _AuthService_GetActiveSanctions_Handler,
+		},
+		{
+			MethodName: "IssueSanction",
+			Handler:    _AuthService_IssueSanction_Handler,
+		},
+		{
+			MethodName: "RecordAudit",
+			Handler:    _AuthService_RecordAudit_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/auth.proto",